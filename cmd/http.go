@@ -9,6 +9,7 @@ import (
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/winartodev/apollo/core/configs"
 	"github.com/winartodev/apollo/core/routes"
+	"github.com/winartodev/apollo/modules/oauth/providers"
 	"log"
 	"os"
 	"os/signal"
@@ -67,6 +68,23 @@ func main() {
 
 	twilioClient := configs.NewTwilioClient(cfg.Twilio)
 
+	geoIP, err := configs.NewGeoIP(cfg.Auth.LoginAlert)
+	if err != nil {
+		panic(err)
+	}
+
+	oauthProviders, err := providers.NewProviders(&cfg.Auth.OAuth, nil)
+	if err != nil {
+		panic(err)
+	}
+
+	schedulerCtx, stopScheduler := context.WithCancel(context.Background())
+	defer stopScheduler()
+
+	if cfg.Auth.JWT.KeyRing.Enabled() {
+		cfg.Auth.JWT.KeyRing.StartScheduler(schedulerCtx, redisClient)
+	}
+
 	app := fiber.New(fiber.Config{
 		AppName: cfg.App.Name,
 	})
@@ -75,11 +93,20 @@ func main() {
 
 	repository := routes.NewRepository(routes.RepositoryDependency{DB: db, Redis: redisClient})
 	controller := routes.NewController(routes.ControllerDependency{
+		OTP:            &cfg.OTP,
+		Auth:           &cfg.Auth,
+		Repository:     repository,
+		SMTPClient:     smtpClient,
+		Twilio:         twilioClient,
+		GeoIP:          geoIP,
+		OAuthProviders: oauthProviders})
+	handler := routes.NewHandler(routes.HandlerDependency{
+		Controller: controller,
+		Redis:      redisClient,
+		RateLimit:  &cfg.RateLimit,
+		Protected:  &cfg.Auth.Protected,
 		OTP:        &cfg.OTP,
-		Repository: repository,
-		SMTPClient: smtpClient,
-		Twilio:     twilioClient})
-	handler := routes.NewHandler(routes.HandlerDependency{Controller: controller})
+	})
 
 	if err = routes.RegisterHandler(app, handler); err != nil {
 		panic(err)
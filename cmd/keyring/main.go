@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/winartodev/apollo/core/configs"
+	"github.com/winartodev/apollo/core/helpers"
+	"log"
+	"os"
+	"time"
+)
+
+const usage = `Usage: keyring <command> [flags]
+
+Commands:
+  list                     Show the keys of the ring and their state
+  rotate [-algorithm alg]  Generate a new active key, the previous one stays valid for the overlap window
+  retire                   Retire verify-only keys that are past the overlap window
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Print(usage)
+		os.Exit(2)
+	}
+
+	cfg, err := configs.NewConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	keyRing := cfg.Auth.JWT.KeyRing
+	if !keyRing.Enabled() {
+		log.Fatal("auth.jwt.keyRing.path is not configured")
+	}
+
+	// the ring is locked in Redis while it changes, so the CLI never races a running instance
+	redisClient, err := cfg.Redis.NewRedis()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer configs.CloseRedis(redisClient)
+
+	switch os.Args[1] {
+	case "list":
+		err = list(keyRing)
+	case "rotate":
+		flags := flag.NewFlagSet("rotate", flag.ExitOnError)
+		algorithm := flags.String("algorithm", "", "RS256 or EdDSA, defaults to the configured algorithm")
+		_ = flags.Parse(os.Args[2:])
+
+		var entry *helpers.KeyRingEntry
+		entry, err = keyRing.Rotate(context.Background(), redisClient, *algorithm)
+		if err == nil {
+			log.Printf("Signing key rotated. New active key: %s (%s)", entry.ID, entry.Algorithm)
+		}
+	case "retire":
+		var retired []string
+		retired, err = keyRing.Retire(context.Background(), redisClient)
+		if err == nil {
+			log.Printf("Retired %d signing key(s) %v", len(retired), retired)
+		}
+	default:
+		fmt.Print(usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func list(keyRing configs.KeyRing) error {
+	ring, err := helpers.LoadKeyRing(keyRing.Path)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range ring.Entries {
+		fmt.Printf("%-45s %-6s %-12s created %s\n", entry.ID, entry.Algorithm, entry.State,
+			time.Unix(entry.CreatedAt, 0).Format(time.RFC3339))
+	}
+
+	return nil
+}
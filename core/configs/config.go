@@ -17,13 +17,84 @@ const (
 )
 
 type Auth struct {
-	APIKey string `yaml:"apiKey"`
-	JWT    JWT    `yaml:"jwt"`
+	APIKey        string        `yaml:"apiKey"`
+	JWT           JWT           `yaml:"jwt"`
+	PasswordReset PasswordReset `yaml:"passwordReset"`
+	MagicLink     MagicLink     `yaml:"magicLink"`
+	MFA           MFA           `yaml:"mfa"`
+	Lockout       Lockout       `yaml:"lockout"`
+	OAuth         OAuth         `yaml:"oauth"`
+	OIDC          OIDC          `yaml:"oidc"`
+	ForwardAuth   ForwardAuth   `yaml:"forwardAuth"`
+	Protected     Protected     `yaml:"protected"`
+	WebAuthn      WebAuthn      `yaml:"webAuthn"`
+	LoginAlert    LoginAlert    `yaml:"loginAlert"`
+}
+
+// Lockout limits failed sign in attempts. Zero values fall back to the defaults of the lockout controller.
+type Lockout struct {
+	MaxAttempts   int `yaml:"maxAttempts"`   // failures per account before it is locked
+	IPMaxAttempts int `yaml:"ipMaxAttempts"` // failures per ip address before it is locked
+	Window        int `yaml:"window"`        // in minutes
+	Duration      int `yaml:"duration"`      // in minutes
+	DelayAfter    int `yaml:"delayAfter"`    // failures before each attempt has to wait
+	BaseDelay     int `yaml:"baseDelay"`     // in seconds, doubled on every further failure
+	MaxDelay      int `yaml:"maxDelay"`      // in seconds
+}
+
+type MFA struct {
+	Issuer        string `yaml:"issuer"`
+	EncryptionKey string `yaml:"encryptionKey"`
+}
+
+type PasswordReset struct {
+	URL string `yaml:"url"`
+}
+
+type MagicLink struct {
+	URL string `yaml:"url"`
+}
+
+// OIDC lets other services sign their users in through Apollo, Issuer is the public url Apollo is served under.
+type OIDC struct {
+	Issuer string `yaml:"issuer"`
+}
+
+// Protected guards the protected routes, they need a session that signed in or stepped up within MaxAge.
+type Protected struct {
+	MaxAge int `yaml:"maxAge"` // in minutes
+}
+
+// WebAuthn is the relying party passkeys are bound to. RPID is the domain of the site, Origins are the exact
+// origins the browser reports during a ceremony.
+type WebAuthn struct {
+	RPID    string   `yaml:"rpID"`
+	RPName  string   `yaml:"rpName"`
+	Origins []string `yaml:"origins"`
+	Timeout int      `yaml:"timeout"` // in seconds
+}
+
+// LoginAlert emails users signing in from a device or network they never signed in from. URL is the page that
+// reports the sign in as not theirs, GeoIPDatabase a MaxMind DB file used to tell the approximate location.
+type LoginAlert struct {
+	Enabled       bool   `yaml:"enabled"`
+	URL           string `yaml:"url"`
+	GeoIPDatabase string `yaml:"geoIPDatabase"`
 }
 
 type JWT struct {
 	AccessToken  AccessToken  `yaml:"accessToken"`
 	RefreshToken RefreshToken `yaml:"refreshToken"`
+	ActiveKey    string       `yaml:"activeKey"`
+	SigningKeys  []SigningKey `yaml:"signingKeys"`
+	KeyRing      KeyRing      `yaml:"keyRing"`
+}
+
+// SigningKey points to a PEM encoded private key used to sign access tokens with RS256 or EdDSA.
+type SigningKey struct {
+	ID         string `yaml:"kid"`
+	Algorithm  string `yaml:"algorithm"`
+	PrivateKey string `yaml:"privateKey"`
 }
 
 type AccessToken struct {
@@ -61,6 +132,8 @@ type Config struct {
 	Database Database `yaml:"database"`
 	Redis    Redis    `yaml:"redis"`
 
+	RateLimit RateLimit `yaml:"rateLimit"`
+
 	OTP    OTP    `yaml:"otp"`
 	Auth   Auth   `yaml:"auth"`
 	SMTP   SMTP   `yaml:"smtp"`
@@ -93,9 +166,33 @@ func NewConfig() (*Config, error) {
 		return nil, err
 	}
 
+	if err = LoadSigningKeys(config); err != nil {
+		return nil, err
+	}
+
 	return config, nil
 }
 
+func LoadSigningKeys(config *Config) (err error) {
+	jwtConfig := config.Auth.JWT
+
+	if jwtConfig.KeyRing.Enabled() {
+		return jwtConfig.KeyRing.Load(jwtConfig.SigningKeys, jwtConfig.ActiveKey)
+	}
+
+	keys := make([]*helpers.SigningKey, 0, len(jwtConfig.SigningKeys))
+	for _, signingKey := range jwtConfig.SigningKeys {
+		key, err := helpers.LoadSigningKeyFile(signingKey.ID, signingKey.Algorithm, signingKey.PrivateKey)
+		if err != nil {
+			return err
+		}
+
+		keys = append(keys, key)
+	}
+
+	return helpers.RegisterSigningKeys(keys, jwtConfig.ActiveKey)
+}
+
 func SaveToEnv(config *Config) (err error) {
 	err = os.Setenv(core.JwtAccessTokenSecretKey, config.Auth.JWT.AccessToken.SecretKey)
 	if err != nil {
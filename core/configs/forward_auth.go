@@ -0,0 +1,77 @@
+package configs
+
+import (
+	"strings"
+)
+
+const (
+	DefaultForwardAuthCookie = "apollo_access_token"
+)
+
+// ForwardAuth lets a reverse proxy such as nginx auth_request or Traefik ask Apollo whether a request may reach
+// the app behind it. The token is read from the Authorization header, or from the cookie CookieName for browsers.
+type ForwardAuth struct {
+	CookieName string            `yaml:"cookieName"`
+	CacheTTL   int               `yaml:"cacheTTL"` // in seconds, zero turns the cache off
+	Rules      []ForwardAuthRule `yaml:"rules"`
+}
+
+// ForwardAuthRule applies to requests for Host, any host when empty, whose path is PathPrefix or lies below it. Public
+// requests are let through without a token, otherwise the user needs Role, or only to be signed in when empty.
+type ForwardAuthRule struct {
+	Host       string `yaml:"host"`
+	PathPrefix string `yaml:"pathPrefix"`
+	Role       string `yaml:"role"`
+	Public     bool   `yaml:"public"`
+}
+
+func (f *ForwardAuth) GetCookieName() string {
+	if f == nil || f.CookieName == "" {
+		return DefaultForwardAuthCookie
+	}
+
+	return f.CookieName
+}
+
+// Match returns the rule with the longest path prefix for the request, a rule for the host wins over a rule for
+// any host. Requests without a matching rule only need a signed in user.
+func (f *ForwardAuth) Match(host string, path string) (rule ForwardAuthRule, ok bool) {
+	if f == nil {
+		return ForwardAuthRule{}, false
+	}
+
+	for _, candidate := range f.Rules {
+		if candidate.Host != "" && !strings.EqualFold(candidate.Host, host) {
+			continue
+		}
+
+		if !matchPathPrefix(path, candidate.PathPrefix) {
+			continue
+		}
+
+		if ok && !isMoreSpecificRule(candidate, rule) {
+			continue
+		}
+
+		rule, ok = candidate, true
+	}
+
+	return rule, ok
+}
+
+// matchPathPrefix matches whole path segments, /public covers /public and /public/logo.png but not /publications.
+func matchPathPrefix(path string, prefix string) bool {
+	if path == prefix {
+		return true
+	}
+
+	return strings.HasPrefix(path, strings.TrimSuffix(prefix, "/")+"/")
+}
+
+func isMoreSpecificRule(candidate ForwardAuthRule, current ForwardAuthRule) bool {
+	if len(candidate.PathPrefix) != len(current.PathPrefix) {
+		return len(candidate.PathPrefix) > len(current.PathPrefix)
+	}
+
+	return candidate.Host != "" && current.Host == ""
+}
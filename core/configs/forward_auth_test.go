@@ -0,0 +1,85 @@
+package configs
+
+import (
+	"testing"
+)
+
+func TestForwardAuth_Match(t *testing.T) {
+	forwardAuth := &ForwardAuth{
+		Rules: []ForwardAuthRule{
+			{PathPrefix: "/", Role: ""},
+			{PathPrefix: "/admin", Role: "admin"},
+			{PathPrefix: "/public", Public: true},
+			{Host: "wiki.example.com", PathPrefix: "/admin", Role: "editor"},
+		},
+	}
+
+	type args struct {
+		host string
+		path string
+	}
+	tests := []struct {
+		name   string
+		args   args
+		want   ForwardAuthRule
+		wantOk bool
+	}{
+		{
+			name:   "success_longest_prefix",
+			args:   args{host: "app.example.com", path: "/admin/users"},
+			want:   ForwardAuthRule{PathPrefix: "/admin", Role: "admin"},
+			wantOk: true,
+		},
+		{
+			name:   "success_public_prefix",
+			args:   args{host: "app.example.com", path: "/public/logo.png"},
+			want:   ForwardAuthRule{PathPrefix: "/public", Public: true},
+			wantOk: true,
+		},
+		{
+			name:   "success_host_rule_wins",
+			args:   args{host: "Wiki.Example.com", path: "/admin"},
+			want:   ForwardAuthRule{Host: "wiki.example.com", PathPrefix: "/admin", Role: "editor"},
+			wantOk: true,
+		},
+		{
+			name:   "success_public_prefix_itself",
+			args:   args{host: "app.example.com", path: "/public"},
+			want:   ForwardAuthRule{PathPrefix: "/public", Public: true},
+			wantOk: true,
+		},
+		{
+			name:   "success_prefix_matches_whole_segments",
+			args:   args{host: "app.example.com", path: "/publications/x"},
+			want:   ForwardAuthRule{PathPrefix: "/"},
+			wantOk: true,
+		},
+		{
+			name:   "success_prefix_does_not_match_longer_segment",
+			args:   args{host: "app.example.com", path: "/public-admin"},
+			want:   ForwardAuthRule{PathPrefix: "/"},
+			wantOk: true,
+		},
+		{
+			name:   "success_fallback_rule",
+			args:   args{host: "app.example.com", path: "/dashboard"},
+			want:   ForwardAuthRule{PathPrefix: "/"},
+			wantOk: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := forwardAuth.Match(tt.args.host, tt.args.path)
+			if ok != tt.wantOk || got != tt.want {
+				t.Errorf("Match() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+
+	t.Run("failed_no_rules", func(t *testing.T) {
+		_, ok := (&ForwardAuth{}).Match("app.example.com", "/")
+		if ok {
+			t.Errorf("Match() ok = true, want false")
+		}
+	})
+}
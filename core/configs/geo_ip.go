@@ -0,0 +1,14 @@
+package configs
+
+import (
+	"github.com/winartodev/apollo/core/helpers"
+)
+
+// NewGeoIP opens the GeoIP database login alerts tell locations with, nil while none is configured.
+func NewGeoIP(config LoginAlert) (*helpers.MMDBReader, error) {
+	if config.GeoIPDatabase == "" {
+		return nil, nil
+	}
+
+	return helpers.OpenMMDB(config.GeoIPDatabase)
+}
@@ -0,0 +1,257 @@
+package configs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/winartodev/apollo/core/helpers"
+)
+
+const (
+	defaultKeyRingAlgorithm      = helpers.AlgorithmEdDSA
+	defaultKeyRingOverlap        = 24 * time.Hour
+	defaultKeyRingReloadInterval = time.Minute
+
+	keyRingLockKey    = "keyring_lock:%s"
+	keyRingLockTTL    = time.Minute
+	keyRingLockLength = 32
+)
+
+var (
+	ErrorKeyRingLocked = errors.New("key ring is being changed by another instance, try again later")
+
+	// unlockKeyRingScript releases the lock only while it is still held by the same owner, so an instance whose
+	// lock expired does not release the lock another instance took since.
+	unlockKeyRingScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+    return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+)
+
+// KeyRing configures a directory of signing keys that can be rotated without signing everyone out.
+// When Path is set it replaces the static SigningKeys list.
+type KeyRing struct {
+	Path             string `yaml:"path"`
+	Algorithm        string `yaml:"algorithm"`
+	RotationInterval int    `yaml:"rotationInterval"` // in hours, 0 disables scheduled rotation
+	Overlap          int    `yaml:"overlap"`          // in minutes
+	ReloadInterval   int    `yaml:"reloadInterval"`   // in seconds
+}
+
+func (k *KeyRing) Enabled() bool {
+	return k.Path != ""
+}
+
+// Load reads the ring from disk and registers its keys. The first time a ring is used it is seeded from the
+// static signing keys, so switching to a ring keeps the keys that are already in use.
+func (k *KeyRing) Load(signingKeys []SigningKey, activeKey string) (err error) {
+	ring, err := helpers.LoadKeyRing(k.Path)
+	if err != nil {
+		return err
+	}
+
+	if len(ring.Entries) == 0 && len(signingKeys) > 0 {
+		now := time.Now()
+		for _, signingKey := range signingKeys {
+			state := helpers.KeyStateVerifyOnly
+			if signingKey.ID == activeKey {
+				state = helpers.KeyStateActive
+			}
+
+			ring.Add(helpers.KeyRingEntry{
+				ID:        signingKey.ID,
+				Algorithm: signingKey.Algorithm,
+				State:     state,
+				File:      signingKey.PrivateKey,
+				RotatedAt: now.Unix(),
+			}, now)
+		}
+
+		err = ring.Save()
+		if err != nil {
+			return err
+		}
+	}
+
+	helpers.SetSigningKeysReloader(k.Reload)
+
+	return ring.Register()
+}
+
+// Reload registers the keys currently stored on disk, picking up rotations made by the CLI or another instance.
+func (k *KeyRing) Reload() (err error) {
+	ring, err := helpers.LoadKeyRing(k.Path)
+	if err != nil {
+		return err
+	}
+
+	return ring.Register()
+}
+
+// Rotate generates a new active key, keeping the previous one as verify-only for the overlap window. The ring is
+// locked in Redis while it changes, ErrorKeyRingLocked is returned when another instance holds the lock.
+func (k *KeyRing) Rotate(ctx context.Context, client redis.Cmdable, algorithm string) (entry *helpers.KeyRingEntry, err error) {
+	if algorithm == "" {
+		algorithm = k.algorithm()
+	}
+
+	var ring *helpers.KeyRing
+	err = k.withLock(ctx, client, func() (err error) {
+		ring, err = helpers.LoadKeyRing(k.Path)
+		if err != nil {
+			return err
+		}
+
+		entry, err = ring.Rotate(algorithm, k.overlap(), time.Now())
+		if err != nil {
+			return err
+		}
+
+		return ring.Save()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return entry, ring.Register()
+}
+
+// Retire retires verify-only keys that are past the overlap window, holding the same lock as Rotate.
+func (k *KeyRing) Retire(ctx context.Context, client redis.Cmdable) (retired []string, err error) {
+	var ring *helpers.KeyRing
+	err = k.withLock(ctx, client, func() (err error) {
+		ring, err = helpers.LoadKeyRing(k.Path)
+		if err != nil {
+			return err
+		}
+
+		retired = ring.Retire(k.overlap(), time.Now())
+		if len(retired) == 0 {
+			return nil
+		}
+
+		return ring.Save()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(retired) == 0 {
+		return retired, nil
+	}
+
+	return retired, ring.Register()
+}
+
+// StartScheduler reloads the ring periodically, retires keys past the overlap window and, when a rotation
+// interval is configured, rotates the active key once it is due. Only the instance holding the lock in Redis
+// changes the ring, the others pick up its changes on their next reload. It stops when ctx is done.
+func (k *KeyRing) StartScheduler(ctx context.Context, client redis.Cmdable) {
+	ticker := time.NewTicker(k.reloadInterval())
+
+	go func() {
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				err := k.maintain(ctx, client, time.Now())
+				if err != nil {
+					log.Printf("key ring maintenance error: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+func (k *KeyRing) maintain(ctx context.Context, client redis.Cmdable, now time.Time) (err error) {
+	err = k.withLock(ctx, client, func() (err error) {
+		ring, err := helpers.LoadKeyRing(k.Path)
+		if err != nil {
+			return err
+		}
+
+		changed := len(ring.Retire(k.overlap(), now)) > 0
+
+		if k.RotationInterval > 0 && ring.IsRotationDue(time.Duration(k.RotationInterval)*time.Hour, now) {
+			entry, err := ring.Rotate(k.algorithm(), k.overlap(), now)
+			if err != nil {
+				return err
+			}
+
+			log.Printf("Signing key rotated. New active key: %s", entry.ID)
+			changed = true
+		}
+
+		if !changed {
+			return nil
+		}
+
+		return ring.Save()
+	})
+	if err != nil && !errors.Is(err, ErrorKeyRingLocked) {
+		return err
+	}
+
+	return k.Reload()
+}
+
+// withLock runs fn while holding the lock of the ring in Redis, so instances sharing the ring never load, change
+// and save it at the same time. The lock expires on its own should the instance holding it stop.
+func (k *KeyRing) withLock(ctx context.Context, client redis.Cmdable, fn func() error) (err error) {
+	owner, err := helpers.GenerateRandomToken(keyRingLockLength)
+	if err != nil {
+		return err
+	}
+
+	key := fmt.Sprintf(keyRingLockKey, k.Path)
+	locked, err := client.SetNX(ctx, key, owner, keyRingLockTTL).Result()
+	if err != nil {
+		return err
+	}
+
+	if !locked {
+		return ErrorKeyRingLocked
+	}
+
+	defer func() {
+		unlockErr := unlockKeyRingScript.Run(ctx, client, []string{key}, owner).Err()
+		if unlockErr != nil {
+			log.Printf("failed to unlock key ring: %v", unlockErr)
+		}
+	}()
+
+	return fn()
+}
+
+func (k *KeyRing) algorithm() string {
+	if k.Algorithm == "" {
+		return defaultKeyRingAlgorithm
+	}
+
+	return k.Algorithm
+}
+
+func (k *KeyRing) overlap() time.Duration {
+	if k.Overlap <= 0 {
+		return defaultKeyRingOverlap
+	}
+
+	return time.Duration(k.Overlap) * time.Minute
+}
+
+func (k *KeyRing) reloadInterval() time.Duration {
+	if k.ReloadInterval <= 0 {
+		return defaultKeyRingReloadInterval
+	}
+
+	return time.Duration(k.ReloadInterval) * time.Second
+}
@@ -0,0 +1,98 @@
+package configs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/winartodev/apollo/core/helpers"
+)
+
+// fakeLockRedis holds the lock of the key ring like Redis would, a lock held by another instance is never released.
+type fakeLockRedis struct {
+	redis.Cmdable
+	owners map[string]string
+}
+
+func (f *fakeLockRedis) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.BoolCmd {
+	if _, ok := f.owners[key]; ok {
+		return redis.NewBoolResult(false, nil)
+	}
+
+	f.owners[key] = value.(string)
+	return redis.NewBoolResult(true, nil)
+}
+
+func (f *fakeLockRedis) EvalSha(ctx context.Context, sha1 string, keys []string, args ...interface{}) *redis.Cmd {
+	if f.owners[keys[0]] != args[0] {
+		return redis.NewCmdResult(int64(0), nil)
+	}
+
+	delete(f.owners, keys[0])
+	return redis.NewCmdResult(int64(1), nil)
+}
+
+func TestKeyRing_maintain(t *testing.T) {
+	t.Cleanup(func() { _ = helpers.RegisterSigningKeys(nil, "") })
+
+	now := time.Now()
+
+	tests := []struct {
+		name        string
+		lockedBy    string
+		wantRotated bool
+	}{
+		{
+			name:        "success_rotates_while_holding_the_lock",
+			wantRotated: true,
+		},
+		{
+			name:     "success_skips_rotation_locked_by_another_instance",
+			lockedBy: "another-instance",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keyRing := &KeyRing{Path: t.TempDir(), RotationInterval: 1}
+
+			ring, err := helpers.LoadKeyRing(keyRing.Path)
+			if err != nil {
+				t.Fatalf("LoadKeyRing() error = %v", err)
+			}
+
+			first, err := ring.Rotate(helpers.AlgorithmEdDSA, keyRing.overlap(), now.Add(-2*time.Hour))
+			if err != nil {
+				t.Fatalf("Rotate() error = %v", err)
+			}
+			firstID := first.ID
+
+			if err = ring.Save(); err != nil {
+				t.Fatalf("Save() error = %v", err)
+			}
+
+			client := &fakeLockRedis{owners: map[string]string{}}
+			if tt.lockedBy != "" {
+				client.owners["keyring_lock:"+keyRing.Path] = tt.lockedBy
+			}
+
+			err = keyRing.maintain(context.Background(), client, now)
+			if err != nil {
+				t.Fatalf("maintain() error = %v", err)
+			}
+
+			ring, err = helpers.LoadKeyRing(keyRing.Path)
+			if err != nil {
+				t.Fatalf("LoadKeyRing() error = %v", err)
+			}
+
+			if rotated := ring.Active().ID != firstID; rotated != tt.wantRotated {
+				t.Errorf("maintain() rotated = %v, want %v", rotated, tt.wantRotated)
+			}
+
+			if owner := client.owners["keyring_lock:"+keyRing.Path]; owner != tt.lockedBy {
+				t.Errorf("maintain() lock owner = %q, want %q", owner, tt.lockedBy)
+			}
+		})
+	}
+}
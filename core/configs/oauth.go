@@ -0,0 +1,28 @@
+package configs
+
+const (
+	OAuthProviderOIDC   = "oidc"
+	OAuthProviderGitHub = "github"
+)
+
+// OAuth configures social sign in. The callback of each provider is RedirectURL followed by /<provider>/callback,
+// it has to be registered as redirect uri at the provider.
+type OAuth struct {
+	RedirectURL string                   `yaml:"redirectURL"`
+	Providers   map[string]OAuthProvider `yaml:"providers"`
+}
+
+// OAuthProvider is either an OpenID Connect provider discovered from its Issuer, e.g. Google or Apple, or GitHub.
+// AuthURL, TokenURL and APIURL override the well known GitHub endpoints. ResponseMode is sent to OpenID Connect
+// providers, Apple requires form_post when the email scope is requested.
+type OAuthProvider struct {
+	Type         string   `yaml:"type"`
+	Issuer       string   `yaml:"issuer"`
+	ClientID     string   `yaml:"clientID"`
+	ClientSecret string   `yaml:"clientSecret"`
+	Scopes       []string `yaml:"scopes"`
+	AuthURL      string   `yaml:"authURL"`
+	TokenURL     string   `yaml:"tokenURL"`
+	APIURL       string   `yaml:"apiURL"`
+	ResponseMode string   `yaml:"responseMode"`
+}
@@ -0,0 +1,41 @@
+package configs
+
+const (
+	RateLimitKeyIP     = "ip"
+	RateLimitKeyUser   = "user"
+	RateLimitKeyQuery  = "query"
+	RateLimitKeyBody   = "body"
+	RateLimitKeyHeader = "header"
+	RateLimitKeyPhone  = "phone"
+)
+
+// RateLimit holds the named policies that route groups opt into. A policy that is not configured does not limit.
+type RateLimit struct {
+	Enable   bool                       `yaml:"enable"`
+	Policies map[string]RateLimitPolicy `yaml:"policies"`
+}
+
+// RateLimitPolicy is a set of rules that are all enforced, a request is rejected as soon as one rule is exceeded.
+type RateLimitPolicy struct {
+	Rules []RateLimitRule `yaml:"rules"`
+}
+
+// RateLimitRule allows Limit requests per sliding Window for each value of KeyBy. KeyBy is "ip", "user" or
+// "query:<name>", "body:<name>" and "header:<name>" to key on a request field, e.g. "query:phone". Prefixing a
+// field with "phone:" keys on the phone number it holds, so every way of writing a number shares one limit, e.g.
+// "phone:query:phone".
+type RateLimitRule struct {
+	Limit  int    `yaml:"limit"`
+	Window int    `yaml:"window"` // in seconds
+	KeyBy  string `yaml:"keyBy"`
+}
+
+func (r *RateLimit) GetPolicy(name string) (policy RateLimitPolicy, ok bool) {
+	if r == nil || !r.Enable {
+		return RateLimitPolicy{}, false
+	}
+
+	policy, ok = r.Policies[name]
+
+	return policy, ok && len(policy.Rules) > 0
+}
@@ -1,8 +1,7 @@
 package configs
 
 import (
-	"encoding/json"
-	"fmt"
+	"github.com/gofiber/fiber/v2/log"
 	"github.com/twilio/twilio-go"
 	twilioApi "github.com/twilio/twilio-go/rest/api/v2010"
 )
@@ -32,11 +31,13 @@ func (client *TwilioClient) SendSMS(to, message string) error {
 
 	resp, err := client.Api.CreateMessage(params)
 	if err != nil {
-		fmt.Println("Error sending SMS message: " + err.Error())
-	} else {
-		response, _ := json.Marshal(*resp)
-		fmt.Println("Response: " + string(response))
+		return err
 	}
 
-	return err
+	// the response echoes the message body, which holds the code, so only its id is logged
+	if resp.Sid != nil {
+		log.Debugf("SMS message %s sent", *resp.Sid)
+	}
+
+	return nil
 }
@@ -1,7 +1,5 @@
 package core
 
-import "errors"
-
 const (
 	API = "/api"
 	V1  = "/v1"
@@ -26,8 +24,18 @@ const (
 	DefaultOrder        = "id"
 
 	OSWindows = "windows"
-)
 
-var (
-	ErrRefreshTokenExists = errors.New("refresh token already exists")
+	RateLimitOTPEmail    = "otpEmail"
+	RateLimitOTPPhone    = "otpPhone"
+	RateLimitMagicLink   = "magicLink"
+	RateLimitPhoneSignIn = "phoneSignIn"
+
+	// permissions users are granted through their roles, service clients of the client_credentials grant are
+	// granted them as scopes. Internal routes need <resource>:read for GET and <resource>:write otherwise.
+	PermissionUsersRead      = "users:read"
+	PermissionUsersWrite     = "users:write"
+	PermissionSessionsRevoke = "sessions:revoke"
+
+	// ScopeAdmin lets an api key call the administration endpoints in place of the global api key
+	ScopeAdmin = "admin"
 )
@@ -0,0 +1,20 @@
+-- Create Table
+CREATE TABLE IF NOT EXISTS user_mfa (
+    user_id INT PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    secret TEXT NOT NULL,
+    is_enabled BOOL DEFAULT FALSE,
+    last_used_step BIGINT DEFAULT 0,
+    enabled_at BIGINT DEFAULT 0,
+    created_at BIGINT DEFAULT 0,
+    updated_at BIGINT DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS user_recovery_codes (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    code_hash VARCHAR(255) NOT NULL UNIQUE,
+    used_at BIGINT DEFAULT 0,
+    created_at BIGINT DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_recovery_codes_user_id ON user_recovery_codes (user_id);
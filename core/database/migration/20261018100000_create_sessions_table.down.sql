@@ -0,0 +1,3 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS refresh_token VARCHAR(255) DEFAULT NULL;
+
+DROP TABLE IF EXISTS sessions;
@@ -0,0 +1,19 @@
+-- Create Table
+CREATE TABLE IF NOT EXISTS sessions (
+    id VARCHAR(36) PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    refresh_token_hash VARCHAR(64) NOT NULL UNIQUE,
+    user_agent VARCHAR(255) DEFAULT NULL,
+    ip_address VARCHAR(45) DEFAULT NULL,
+    is_revoked BOOL DEFAULT FALSE,
+    revoked_at BIGINT DEFAULT 0,
+    last_used_at BIGINT DEFAULT 0,
+    expires_at BIGINT DEFAULT 0,
+    created_at BIGINT DEFAULT 0,
+    updated_at BIGINT DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions (user_id);
+
+-- Refresh tokens are now stored per session
+ALTER TABLE users DROP COLUMN IF EXISTS refresh_token;
@@ -0,0 +1,9 @@
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS refresh_token_hash VARCHAR(64) DEFAULT NULL;
+
+UPDATE sessions s
+SET refresh_token_hash = rt.token_hash
+FROM refresh_tokens rt
+WHERE rt.session_id = s.id AND rt.used_at = 0;
+
+DROP TABLE IF EXISTS security_events;
+DROP TABLE IF EXISTS refresh_tokens;
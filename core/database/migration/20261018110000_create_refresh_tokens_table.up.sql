@@ -0,0 +1,30 @@
+-- Create Table
+-- Every refresh token issued to a session belongs to the session's token family
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id SERIAL PRIMARY KEY,
+    session_id VARCHAR(36) NOT NULL REFERENCES sessions (id) ON DELETE CASCADE,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    used_at BIGINT DEFAULT 0,
+    expires_at BIGINT DEFAULT 0,
+    created_at BIGINT DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_session_id ON refresh_tokens (session_id);
+
+CREATE TABLE IF NOT EXISTS security_events (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    session_id VARCHAR(36) DEFAULT NULL,
+    event_type VARCHAR(50) NOT NULL,
+    ip_address VARCHAR(45) DEFAULT NULL,
+    user_agent VARCHAR(255) DEFAULT NULL,
+    created_at BIGINT DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_security_events_user_id ON security_events (user_id);
+
+-- Move the current token of each session into its family
+INSERT INTO refresh_tokens (session_id, token_hash, expires_at, created_at)
+SELECT id, refresh_token_hash, expires_at, updated_at FROM sessions;
+
+ALTER TABLE sessions DROP COLUMN IF EXISTS refresh_token_hash;
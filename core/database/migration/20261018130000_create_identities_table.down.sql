@@ -0,0 +1,4 @@
+-- Fails while accounts without a phone number exist
+ALTER TABLE users ALTER COLUMN phone_number SET NOT NULL;
+
+DROP TABLE IF EXISTS identities;
@@ -0,0 +1,19 @@
+-- Create Table
+-- Links the subject of an external identity provider to a user
+CREATE TABLE IF NOT EXISTS identities (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    provider VARCHAR(50) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    email VARCHAR(255) DEFAULT NULL,
+    email_verified BOOL DEFAULT FALSE,
+    created_at BIGINT DEFAULT 0,
+    updated_at BIGINT DEFAULT 0,
+    UNIQUE (provider, subject),
+    UNIQUE (user_id, provider)
+);
+
+CREATE INDEX IF NOT EXISTS idx_identities_user_id ON identities (user_id);
+
+-- Accounts created through a provider have no phone number
+ALTER TABLE users ALTER COLUMN phone_number DROP NOT NULL;
@@ -0,0 +1,13 @@
+-- Create Table
+-- Applications that sign their users in through Apollo, public clients have no secret and rely on PKCE
+CREATE TABLE IF NOT EXISTS oauth_clients (
+    id SERIAL PRIMARY KEY,
+    client_id VARCHAR(64) NOT NULL UNIQUE,
+    client_secret_hash VARCHAR(64) DEFAULT NULL,
+    name VARCHAR(100) NOT NULL,
+    redirect_uris TEXT[] NOT NULL DEFAULT '{}',
+    scopes VARCHAR(255) NOT NULL DEFAULT 'openid',
+    skip_consent BOOL DEFAULT FALSE,
+    created_at BIGINT DEFAULT 0,
+    updated_at BIGINT DEFAULT 0
+);
@@ -0,0 +1,2 @@
+-- Service clients authenticate as themselves with the client_credentials grant
+ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS grant_types VARCHAR(255) NOT NULL DEFAULT 'authorization_code';
@@ -0,0 +1,57 @@
+-- Create Table
+-- Roles group permissions, users get permissions only through their roles
+CREATE TABLE IF NOT EXISTS roles (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(50) NOT NULL UNIQUE,
+    description VARCHAR(255) DEFAULT NULL,
+    created_at BIGINT DEFAULT 0,
+    updated_at BIGINT DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS permissions (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL UNIQUE,
+    description VARCHAR(255) DEFAULT NULL,
+    created_at BIGINT DEFAULT 0,
+    updated_at BIGINT DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role_id INT NOT NULL REFERENCES roles (id) ON DELETE CASCADE,
+    permission_id INT NOT NULL REFERENCES permissions (id) ON DELETE CASCADE,
+    PRIMARY KEY (role_id, permission_id)
+);
+
+CREATE TABLE IF NOT EXISTS user_roles (
+    user_id INT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    role_id INT NOT NULL REFERENCES roles (id) ON DELETE CASCADE,
+    created_at BIGINT DEFAULT 0,
+    PRIMARY KEY (user_id, role_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_roles_role_id ON user_roles (role_id);
+
+-- Seeding Data
+DO $$
+    DECLARE current_epoch_time BIGINT;
+BEGIN
+    current_epoch_time = EXTRACT(EPOCH FROM CURRENT_TIMESTAMP);
+
+    INSERT INTO roles (name, description, created_at, updated_at)
+    VALUES ('internal', 'Staff that can look up other users', current_epoch_time, current_epoch_time)
+    ON CONFLICT (name) DO NOTHING;
+
+    INSERT INTO permissions (name, description, created_at, updated_at)
+    VALUES ('users:read', 'Read any user', current_epoch_time, current_epoch_time)
+    ON CONFLICT (name) DO NOTHING;
+
+    INSERT INTO role_permissions (role_id, permission_id)
+    SELECT r.id, p.id FROM roles r, permissions p
+    WHERE r.name = 'internal' AND p.name = 'users:read'
+    ON CONFLICT DO NOTHING;
+
+    INSERT INTO user_roles (user_id, role_id, created_at)
+    SELECT u.id, r.id, current_epoch_time FROM users u, roles r
+    WHERE r.name = 'internal' AND u.email IN ('internal-user-1@gmail.com', 'internal-user-2@gmail.com')
+    ON CONFLICT DO NOTHING;
+END$$
@@ -0,0 +1,4 @@
+-- Last time the user proved who they are in the session, by signing in or stepping up
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS authenticated_at BIGINT DEFAULT 0;
+
+UPDATE sessions SET authenticated_at = created_at WHERE authenticated_at = 0;
@@ -0,0 +1,15 @@
+-- Create Table
+-- Keys of backend consumers, only the hash of a key is stored and its prefix is used to look it up
+CREATE TABLE IF NOT EXISTS api_keys (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    prefix VARCHAR(16) NOT NULL UNIQUE,
+    key_hash VARCHAR(64) NOT NULL,
+    scopes VARCHAR(255) NOT NULL DEFAULT '',
+    is_revoked BOOL DEFAULT FALSE,
+    revoked_at BIGINT DEFAULT 0,
+    last_used_at BIGINT DEFAULT 0,
+    expires_at BIGINT DEFAULT 0,
+    created_at BIGINT DEFAULT 0,
+    updated_at BIGINT DEFAULT 0
+);
@@ -0,0 +1,18 @@
+-- Create Table
+-- Passkeys of users, the public key is stored as COSE key the way the authenticator returned it
+CREATE TABLE IF NOT EXISTS webauthn_credentials (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    credential_id TEXT NOT NULL UNIQUE,
+    public_key BYTEA NOT NULL,
+    algorithm INT NOT NULL,
+    sign_count BIGINT DEFAULT 0,
+    aaguid VARCHAR(36) DEFAULT NULL,
+    attestation_format VARCHAR(32) NOT NULL,
+    name VARCHAR(100) DEFAULT NULL,
+    last_used_at BIGINT DEFAULT 0,
+    created_at BIGINT DEFAULT 0,
+    updated_at BIGINT DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_webauthn_credentials_user_id ON webauthn_credentials (user_id);
@@ -0,0 +1,20 @@
+-- Create Table
+-- Security relevant events, user_id has no foreign key so the trail outlives the user it is about
+CREATE TABLE IF NOT EXISTS audit_events (
+    id BIGSERIAL PRIMARY KEY,
+    actor_type VARCHAR(20) NOT NULL,
+    actor_id VARCHAR(64) DEFAULT NULL,
+    action VARCHAR(50) NOT NULL,
+    target_type VARCHAR(20) DEFAULT NULL,
+    target_id VARCHAR(255) DEFAULT NULL,
+    user_id INT DEFAULT NULL,
+    ip_address VARCHAR(45) DEFAULT NULL,
+    user_agent VARCHAR(255) DEFAULT NULL,
+    result VARCHAR(10) NOT NULL,
+    metadata JSONB NOT NULL DEFAULT '{}',
+    created_at BIGINT DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_user_id ON audit_events (user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_events_action ON audit_events (action, created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_events_created_at ON audit_events (created_at);
@@ -0,0 +1,13 @@
+-- Create Table
+-- Sign in attempts of users, users.last_login is updated together with every successful one
+CREATE TABLE IF NOT EXISTS login_history (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    method VARCHAR(32) NOT NULL,
+    outcome VARCHAR(20) NOT NULL,
+    ip_address VARCHAR(45) DEFAULT NULL,
+    user_agent VARCHAR(255) DEFAULT NULL,
+    created_at BIGINT DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_history_user_id ON login_history (user_id, created_at);
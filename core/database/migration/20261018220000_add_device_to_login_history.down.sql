@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_login_history_user_id_ip_range;
+DROP INDEX IF EXISTS idx_login_history_user_id_device_id;
+
+ALTER TABLE login_history DROP COLUMN IF EXISTS location;
+ALTER TABLE login_history DROP COLUMN IF EXISTS ip_range;
+ALTER TABLE login_history DROP COLUMN IF EXISTS device_id;
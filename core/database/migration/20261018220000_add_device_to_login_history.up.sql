@@ -0,0 +1,8 @@
+-- Device and network of sign in attempts, a successful one from a device or network the user never signed in from
+-- is alerted to the user
+ALTER TABLE login_history ADD COLUMN IF NOT EXISTS device_id VARCHAR(64) DEFAULT NULL;
+ALTER TABLE login_history ADD COLUMN IF NOT EXISTS ip_range VARCHAR(50) DEFAULT NULL;
+ALTER TABLE login_history ADD COLUMN IF NOT EXISTS location VARCHAR(255) DEFAULT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_login_history_user_id_device_id ON login_history (user_id, device_id);
+CREATE INDEX IF NOT EXISTS idx_login_history_user_id_ip_range ON login_history (user_id, ip_range);
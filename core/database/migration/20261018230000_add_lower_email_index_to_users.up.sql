@@ -0,0 +1,2 @@
+-- Emails are looked up case insensitively, identity providers report them in lower case
+CREATE INDEX IF NOT EXISTS idx_users_lower_email ON users (LOWER(email));
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS security_events (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    session_id VARCHAR(36) DEFAULT NULL,
+    event_type VARCHAR(50) NOT NULL,
+    ip_address VARCHAR(45) DEFAULT NULL,
+    user_agent VARCHAR(255) DEFAULT NULL,
+    created_at BIGINT DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_security_events_user_id ON security_events (user_id);
+
+INSERT INTO security_events (user_id, session_id, event_type, ip_address, user_agent, created_at)
+SELECT user_id, metadata ->> 'session_id', SUBSTRING(action FROM 10), ip_address, user_agent, created_at
+FROM audit_events
+WHERE action LIKE 'security.%' AND user_id IN (SELECT id FROM users);
+
+DELETE FROM audit_events WHERE action LIKE 'security.%';
@@ -0,0 +1,16 @@
+-- Security events are recorded in the audit trail as security.<event type>
+INSERT INTO audit_events (actor_type, action, target_type, target_id, user_id, ip_address, user_agent, result, metadata, created_at)
+SELECT
+    'anonymous',
+    'security.' || event_type,
+    'user',
+    user_id::TEXT,
+    user_id,
+    ip_address,
+    user_agent,
+    'success',
+    CASE WHEN COALESCE(session_id, '') = '' THEN '{}'::JSONB ELSE jsonb_build_object('session_id', session_id) END,
+    created_at
+FROM security_events;
+
+DROP TABLE IF EXISTS security_events;
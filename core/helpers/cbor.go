@@ -0,0 +1,175 @@
+package helpers
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+const (
+	cborMaxDepth = 16
+
+	cborUnsignedInt = 0
+	cborNegativeInt = 1
+	cborByteString  = 2
+	cborTextString  = 3
+	cborArray       = 4
+	cborMap         = 5
+	cborTag         = 6
+	cborSimple      = 7
+)
+
+var (
+	errorCBORUnexpectedEnd = errors.New("cbor: unexpected end of data")
+	errorCBORUnsupported   = errors.New("cbor: unsupported data item")
+	errorCBORTooDeep       = errors.New("cbor: data is nested too deep")
+)
+
+// DecodeCBOR decodes the first data item of data and returns it together with the bytes that follow it. Integers
+// are returned as int64, byte strings as []byte, text strings as string, arrays as []interface{} and maps as
+// map[interface{}]interface{}. Only the definite length encoding used by WebAuthn is supported.
+func DecodeCBOR(data []byte) (value interface{}, rest []byte, err error) {
+	return decodeCBOR(data, 0)
+}
+
+func decodeCBOR(data []byte, depth int) (value interface{}, rest []byte, err error) {
+	if depth > cborMaxDepth {
+		return nil, nil, errorCBORTooDeep
+	}
+
+	if len(data) == 0 {
+		return nil, nil, errorCBORUnexpectedEnd
+	}
+
+	major := data[0] >> 5
+	info := data[0] & 0x1f
+
+	if major == cborSimple {
+		switch info {
+		case 20:
+			return false, data[1:], nil
+		case 21:
+			return true, data[1:], nil
+		case 22:
+			return nil, data[1:], nil
+		default:
+			return nil, nil, errorCBORUnsupported
+		}
+	}
+
+	argument, rest, err := decodeCBORArgument(info, data[1:])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch major {
+	case cborUnsignedInt:
+		if argument > math.MaxInt64 {
+			return nil, nil, errorCBORUnsupported
+		}
+
+		return int64(argument), rest, nil
+	case cborNegativeInt:
+		if argument > math.MaxInt64 {
+			return nil, nil, errorCBORUnsupported
+		}
+
+		return -1 - int64(argument), rest, nil
+	case cborByteString, cborTextString:
+		if argument > uint64(len(rest)) {
+			return nil, nil, errorCBORUnexpectedEnd
+		}
+
+		content := rest[:argument]
+		if major == cborTextString {
+			return string(content), rest[argument:], nil
+		}
+
+		return append([]byte{}, content...), rest[argument:], nil
+	case cborArray:
+		// every item takes at least one byte, so a longer array can not be complete
+		if argument > uint64(len(rest)) {
+			return nil, nil, errorCBORUnexpectedEnd
+		}
+
+		items := make([]interface{}, 0, argument)
+		for i := uint64(0); i < argument; i++ {
+			var item interface{}
+			item, rest, err = decodeCBOR(rest, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			items = append(items, item)
+		}
+
+		return items, rest, nil
+	case cborMap:
+		if argument > uint64(len(rest))/2 {
+			return nil, nil, errorCBORUnexpectedEnd
+		}
+
+		entries := make(map[interface{}]interface{}, argument)
+		for i := uint64(0); i < argument; i++ {
+			var key, item interface{}
+			key, rest, err = decodeCBOR(rest, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			switch key.(type) {
+			case int64, string:
+			default:
+				return nil, nil, errorCBORUnsupported
+			}
+
+			item, rest, err = decodeCBOR(rest, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			entries[key] = item
+		}
+
+		return entries, rest, nil
+	case cborTag:
+		return decodeCBOR(rest, depth+1)
+	}
+
+	return nil, nil, errorCBORUnsupported
+}
+
+func decodeCBORArgument(info byte, data []byte) (argument uint64, rest []byte, err error) {
+	var size int
+	switch {
+	case info < 24:
+		return uint64(info), data, nil
+	case info == 24:
+		size = 1
+	case info == 25:
+		size = 2
+	case info == 26:
+		size = 4
+	case info == 27:
+		size = 8
+	default:
+		return 0, nil, errorCBORUnsupported
+	}
+
+	if len(data) < size {
+		return 0, nil, errorCBORUnexpectedEnd
+	}
+
+	switch size {
+	case 1:
+		argument = uint64(data[0])
+	case 2:
+		argument = uint64(binary.BigEndian.Uint16(data))
+	case 4:
+		argument = uint64(binary.BigEndian.Uint32(data))
+	default:
+		argument = binary.BigEndian.Uint64(data)
+	}
+
+	return argument, data[size:], nil
+}
@@ -0,0 +1,100 @@
+package helpers
+
+import (
+	"encoding/hex"
+	"reflect"
+	"testing"
+)
+
+func TestDecodeCBOR(t *testing.T) {
+	// RFC 8949 appendix A vectors
+	tests := []struct {
+		name     string
+		data     string
+		want     interface{}
+		wantRest string
+		wantErr  bool
+	}{
+		{
+			name: "success_unsigned_int",
+			data: "1903e8",
+			want: int64(1000),
+		},
+		{
+			name: "success_negative_int",
+			data: "3903e7",
+			want: int64(-1000),
+		},
+		{
+			name: "success_byte_string",
+			data: "4401020304",
+			want: []byte{1, 2, 3, 4},
+		},
+		{
+			name: "success_text_string",
+			data: "6449455446",
+			want: "IETF",
+		},
+		{
+			name: "success_nested_array",
+			data: "8301820203820405",
+			want: []interface{}{int64(1), []interface{}{int64(2), int64(3)}, []interface{}{int64(4), int64(5)}},
+		},
+		{
+			name: "success_map",
+			data: "a201020304",
+			want: map[interface{}]interface{}{int64(1): int64(2), int64(3): int64(4)},
+		},
+		{
+			name: "success_simple_values",
+			data: "f5",
+			want: true,
+		},
+		{
+			name:     "success_returns_rest",
+			data:     "0102",
+			want:     int64(1),
+			wantRest: "02",
+		},
+		{
+			name:    "failed_truncated_byte_string",
+			data:    "440102",
+			wantErr: true,
+		},
+		{
+			name:    "failed_indefinite_length",
+			data:    "5f42010243030405ff",
+			wantErr: true,
+		},
+		{
+			name:    "failed_array_longer_than_data",
+			data:    "9bffffffffffffffff",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := hex.DecodeString(tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, rest, err := DecodeCBOR(data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DecodeCBOR() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DecodeCBOR() = %#v, want %#v", got, tt.want)
+			}
+
+			if hex.EncodeToString(rest) != tt.wantRest {
+				t.Errorf("DecodeCBOR() rest = %x, want %s", rest, tt.wantRest)
+			}
+		})
+	}
+}
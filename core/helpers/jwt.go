@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/dgrijalva/jwt-go"
+	"github.com/google/uuid"
 	"github.com/winartodev/apollo/core"
 	userEntity "github.com/winartodev/apollo/modules/user/entities"
 	"os"
@@ -12,19 +13,84 @@ import (
 )
 
 const (
+	AccessTokenExpiration  = 15 * time.Minute
+	RefreshTokenExpiration = 24 * time.Hour
+	ServiceTokenExpiration = 5 * time.Minute
+
+	// AccessTokenType is the typ header of access tokens (RFC 9068), it tells them apart from id tokens signed
+	// with the same keys
+	AccessTokenType = "at+jwt"
+
 	errorUnexpectedSigningMethod = "unexpected signing method: %v"
 )
 
+// TokenKind is the kind of token a caller expects, every kind is verified with its own keys only.
+type TokenKind int
+
+const (
+	TokenKindAccess TokenKind = iota + 1
+	TokenKindRefresh
+	TokenKindID
+)
+
 var (
-	errorMissingSecretKey = errors.New("missing secret key")
-	errorInvalidToken     = errors.New("invalid token")
-	errorTokenExpired     = errors.New("token is expired")
+	errorMissingSecretKey  = errors.New("missing secret key")
+	errorInvalidToken      = errors.New("invalid token")
+	errorTokenExpired      = errors.New("token is expired")
+	errorMissingKeyID      = errors.New("token is missing the kid header")
+	errorMissingSigningKey = errors.New("id tokens require an asymmetric signing key")
+	errorUnknownTokenKind  = errors.New("unknown token kind")
+	errorWrongTokenType    = errors.New("token is not of the expected type")
 )
 
 type JWTClaims struct {
-	ID       int64  `json:"id,omitempty"`
-	Username string `json:"username,omitempty"`
-	Email    string `json:"email,omitempty"`
+	ID        int64    `json:"id,omitempty"`
+	Username  string   `json:"username,omitempty"`
+	Email     string   `json:"email,omitempty"`
+	SessionID string   `json:"sid,omitempty"`
+	ClientID  string   `json:"client_id,omitempty"`
+	Scope     string   `json:"scope,omitempty"`
+	Roles     []string `json:"roles,omitempty"`
+	jwt.StandardClaims
+}
+
+// IsServiceToken tells whether the token was issued to a service client acting on its own behalf through the
+// client credentials grant.
+func (c *JWTClaims) IsServiceToken() bool {
+	return c.ID == 0 && c.ClientID != "" && c.Subject == c.ClientID
+}
+
+// IssuedAtMilli returns when the token was issued in unix milliseconds. The jti of tokens is a version 7 uuid
+// that holds its time of creation to the millisecond, older tokens fall back to the iat claim.
+func (c *JWTClaims) IssuedAtMilli() int64 {
+	id, err := uuid.Parse(c.Id)
+	if err != nil || id.Version() != 7 {
+		return c.IssuedAt * 1000
+	}
+
+	sec, nsec := id.Time().UnixTime()
+	return sec*1000 + nsec/int64(time.Millisecond)
+}
+
+// UserInfoClaims are the standard OpenID Connect claims about a user, only the claims of the granted scopes are set.
+type UserInfoClaims struct {
+	Name                string `json:"name,omitempty"`
+	GivenName           string `json:"given_name,omitempty"`
+	FamilyName          string `json:"family_name,omitempty"`
+	PreferredUsername   string `json:"preferred_username,omitempty"`
+	Picture             string `json:"picture,omitempty"`
+	Email               string `json:"email,omitempty"`
+	EmailVerified       *bool  `json:"email_verified,omitempty"`
+	PhoneNumber         string `json:"phone_number,omitempty"`
+	PhoneNumberVerified *bool  `json:"phone_number_verified,omitempty"`
+	UpdatedAt           int64  `json:"updated_at,omitempty"`
+}
+
+type IDTokenClaims struct {
+	UserInfoClaims
+	Nonce     string `json:"nonce,omitempty"`
+	AuthTime  int64  `json:"auth_time,omitempty"`
+	SessionID string `json:"sid,omitempty"`
 	jwt.StandardClaims
 }
 
@@ -39,6 +105,10 @@ type refreshToken struct {
 type JWT struct {
 	AccessToken  accessToken
 	RefreshToken refreshToken
+
+	// signingKey signs access tokens when an asymmetric key is configured, verificationKeys are looked up by kid
+	signingKey       *SigningKey
+	verificationKeys map[string]*SigningKey
 }
 
 type JWTResponse struct {
@@ -57,6 +127,8 @@ func NewJWT() (*JWT, error) {
 		return nil, errors.New("refresh token secret key is empty")
 	}
 
+	verificationKeys, activeKey := getSigningKeys()
+
 	return &JWT{
 		AccessToken: accessToken{
 			SecretKey: []byte(atSecret),
@@ -64,10 +136,13 @@ func NewJWT() (*JWT, error) {
 		RefreshToken: refreshToken{
 			SecretKey: []byte(rtSecret),
 		},
+		signingKey:       activeKey,
+		verificationKeys: verificationKeys,
 	}, nil
 }
 
-func (j *JWT) GenerateToken(user *userEntity.User) (result *JWTResponse, err error) {
+// GenerateToken issues a token pair for the session identified by sessionID, which is carried in the sid claim.
+func (j *JWT) GenerateToken(user *userEntity.User, sessionID string) (result *JWTResponse, err error) {
 	if user == nil {
 		return nil, errors.New("user not found")
 	}
@@ -76,23 +151,33 @@ func (j *JWT) GenerateToken(user *userEntity.User) (result *JWTResponse, err err
 		return nil, errorMissingSecretKey
 	}
 
+	now := time.Now()
+
+	// every token carries its own jti so a single token can be revoked before it expires
 	newAccessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, JWTClaims{
-		ID:       user.ID,
-		Username: user.Username,
-		Email:    user.Email,
+		ID:        user.ID,
+		Username:  user.Username,
+		Email:     user.Email,
+		SessionID: sessionID,
+		Roles:     user.Roles,
 		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(15 * time.Minute).Unix(),
+			Id:        newTokenID(),
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(AccessTokenExpiration).Unix(),
 		},
 	})
 
 	newRefreshToken := jwt.NewWithClaims(jwt.SigningMethodHS256, JWTClaims{
-		ID: user.ID,
+		ID:        user.ID,
+		SessionID: sessionID,
 		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(24 * time.Hour).Unix(),
+			Id:        newTokenID(),
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(RefreshTokenExpiration).Unix(),
 		},
 	})
 
-	newAccessTokenString, err := newAccessToken.SignedString(j.AccessToken.SecretKey)
+	newAccessTokenString, err := j.signAccessToken(newAccessToken)
 	if err != nil {
 		return nil, err
 	}
@@ -108,12 +193,78 @@ func (j *JWT) GenerateToken(user *userEntity.User) (result *JWTResponse, err err
 	}, nil
 }
 
-func (j *JWT) VerifyToken(secretKey []byte, tokenString string) (result map[string]interface{}, isValid bool, err error) {
-	if !isSecretKeyExists(secretKey) {
-		return nil, false, errorMissingSecretKey
+// GenerateClientAccessToken issues an access token of the session to the OAuth client clientID, limited to scope.
+func (j *JWT) GenerateClientAccessToken(user *userEntity.User, sessionID string, clientID string, scope string) (result string, err error) {
+	if user == nil {
+		return "", errors.New("user not found")
 	}
 
-	token, err := j.ParseToken(secretKey, tokenString)
+	if !isSecretKeyExists(j.AccessToken.SecretKey) {
+		return "", errorMissingSecretKey
+	}
+
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, JWTClaims{
+		ID:        user.ID,
+		Username:  user.Username,
+		Email:     user.Email,
+		SessionID: sessionID,
+		ClientID:  clientID,
+		Scope:     scope,
+		StandardClaims: jwt.StandardClaims{
+			Id:        newTokenID(),
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(AccessTokenExpiration).Unix(),
+		},
+	})
+
+	return j.signAccessToken(token)
+}
+
+// GenerateServiceToken issues an access token to a service client acting on its own behalf, its subject is the
+// client id and it carries no user.
+func (j *JWT) GenerateServiceToken(clientID string, scope string) (result string, err error) {
+	if !isSecretKeyExists(j.AccessToken.SecretKey) {
+		return "", errorMissingSecretKey
+	}
+
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, JWTClaims{
+		ClientID: clientID,
+		Scope:    scope,
+		StandardClaims: jwt.StandardClaims{
+			Id:        newTokenID(),
+			Subject:   clientID,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(ServiceTokenExpiration).Unix(),
+		},
+	})
+
+	return j.signAccessToken(token)
+}
+
+// GenerateIDToken signs an OpenID Connect id token. Relying parties verify it through the JWKS, so unlike access
+// tokens it can not fall back to the HMAC secret.
+func (j *JWT) GenerateIDToken(claims IDTokenClaims) (result string, err error) {
+	if j.signingKey == nil {
+		return "", errorMissingSigningKey
+	}
+
+	return j.sign(jwt.NewWithClaims(jwt.SigningMethodRS256, claims))
+}
+
+// SigningAlgorithm returns the algorithm of the active signing key, or an empty string when none is configured.
+func (j *JWT) SigningAlgorithm() string {
+	if j.signingKey == nil {
+		return ""
+	}
+
+	return j.signingKey.Algorithm
+}
+
+// VerifyToken parses a token of the given kind and checks that it has not expired.
+func (j *JWT) VerifyToken(kind TokenKind, tokenString string) (result map[string]interface{}, isValid bool, err error) {
+	token, err := j.ParseToken(kind, tokenString)
 	if err != nil {
 		return nil, false, err
 	}
@@ -132,9 +283,19 @@ func (j *JWT) VerifyToken(secretKey []byte, tokenString string) (result map[stri
 	return result, true, nil
 }
 
-func (j *JWT) ParseToken(secretKey []byte, tokenString string) (result *jwt.Token, err error) {
-	if !isSecretKeyExists(secretKey) {
-		return nil, errorMissingSecretKey
+// ParseToken verifies a token of the given kind. Access tokens are signed with the access secret or a key of
+// the ring, refresh tokens only ever with the refresh secret and id tokens only with a key of the ring. Access and
+// id tokens share the keys of the ring, so access tokens also have to carry the AccessTokenType typ header.
+func (j *JWT) ParseToken(kind TokenKind, tokenString string) (result *jwt.Token, err error) {
+	var secretKey []byte
+	switch kind {
+	case TokenKindAccess:
+		secretKey = j.AccessToken.SecretKey
+	case TokenKindRefresh:
+		secretKey = j.RefreshToken.SecretKey
+	case TokenKindID:
+	default:
+		return nil, errorUnknownTokenKind
 	}
 
 	const prefix = "Bearer "
@@ -143,11 +304,22 @@ func (j *JWT) ParseToken(secretKey []byte, tokenString string) (result *jwt.Toke
 	}
 
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		switch token.Method.(type) {
+		case *jwt.SigningMethodHMAC:
+			if !isSecretKeyExists(secretKey) {
+				return nil, errorMissingSecretKey
+			}
+
+			return secretKey, nil
+		case *jwt.SigningMethodRSA, *signingMethodEdDSA:
+			if kind == TokenKindRefresh {
+				return nil, fmt.Errorf(errorUnexpectedSigningMethod, token.Header["alg"])
+			}
+
+			return j.getVerificationKey(token)
+		default:
 			return false, fmt.Errorf(errorUnexpectedSigningMethod, token.Header["alg"])
 		}
-
-		return secretKey, nil
 	})
 	if err != nil {
 		return token, err
@@ -157,9 +329,75 @@ func (j *JWT) ParseToken(secretKey []byte, tokenString string) (result *jwt.Toke
 		return token, errorInvalidToken
 	}
 
+	typ, _ := token.Header["typ"].(string)
+	if (kind == TokenKindAccess) != (typ == AccessTokenType) {
+		return token, errorWrongTokenType
+	}
+
 	return token, err
 }
 
+// signAccessToken stamps the AccessTokenType typ header and signs the token as an access token.
+func (j *JWT) signAccessToken(token *jwt.Token) (string, error) {
+	token.Header["typ"] = AccessTokenType
+	return j.sign(token)
+}
+
+// sign signs with the active asymmetric key and stamps its kid, falling back to the HMAC secret when no signing
+// key is configured.
+func (j *JWT) sign(token *jwt.Token) (string, error) {
+	if j.signingKey == nil {
+		return token.SignedString(j.AccessToken.SecretKey)
+	}
+
+	switch j.signingKey.Algorithm {
+	case AlgorithmRS256:
+		token.Method = jwt.SigningMethodRS256
+	case AlgorithmEdDSA:
+		token.Method = SigningMethodEdDSA
+	default:
+		return "", fmt.Errorf(errorUnsupportedAlgorithm, j.signingKey.Algorithm)
+	}
+
+	token.Header["alg"] = token.Method.Alg()
+	token.Header["kid"] = j.signingKey.ID
+
+	return token.SignedString(j.signingKey.PrivateKey)
+}
+
+func (j *JWT) getVerificationKey(token *jwt.Token) (interface{}, error) {
+	kid, ok := token.Header["kid"].(string)
+	if !ok || kid == "" {
+		return nil, errorMissingKeyID
+	}
+
+	key, ok := j.verificationKeys[kid]
+	if !ok && reloadSigningKeys() {
+		j.verificationKeys, _ = getSigningKeys()
+		key, ok = j.verificationKeys[kid]
+	}
+
+	if !ok {
+		return nil, fmt.Errorf(errorUnknownSigningKey, kid)
+	}
+
+	if key.Algorithm != token.Method.Alg() {
+		return nil, fmt.Errorf(errorUnexpectedSigningMethod, token.Header["alg"])
+	}
+
+	return key.PublicKey, nil
+}
+
+// newTokenID returns a jti that also records the time the token was issued, see JWTClaims.IssuedAtMilli.
+func newTokenID() string {
+	id, err := uuid.NewV7()
+	if err != nil {
+		return uuid.NewString()
+	}
+
+	return id.String()
+}
+
 func isSecretKeyExists(secretKey []byte) bool {
 	return secretKey != nil && len(secretKey) > 0
 }
@@ -0,0 +1,56 @@
+package helpers
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"github.com/dgrijalva/jwt-go"
+)
+
+var (
+	errorInvalidEdDSAKey   = errors.New("key is not a valid ed25519 key")
+	errorEdDSAVerification = errors.New("ed25519 signature is invalid")
+)
+
+var SigningMethodEdDSA jwt.SigningMethod = &signingMethodEdDSA{}
+
+// signingMethodEdDSA adds Ed25519 support to jwt-go, which only ships HMAC, RSA and ECDSA methods.
+type signingMethodEdDSA struct{}
+
+func init() {
+	jwt.RegisterSigningMethod(AlgorithmEdDSA, func() jwt.SigningMethod {
+		return SigningMethodEdDSA
+	})
+}
+
+func (m *signingMethodEdDSA) Alg() string {
+	return AlgorithmEdDSA
+}
+
+func (m *signingMethodEdDSA) Sign(signingString string, key interface{}) (string, error) {
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok || len(privateKey) != ed25519.PrivateKeySize {
+		return "", errorInvalidEdDSAKey
+	}
+
+	signature := ed25519.Sign(privateKey, []byte(signingString))
+
+	return jwt.EncodeSegment(signature), nil
+}
+
+func (m *signingMethodEdDSA) Verify(signingString string, signature string, key interface{}) error {
+	publicKey, ok := key.(ed25519.PublicKey)
+	if !ok || len(publicKey) != ed25519.PublicKeySize {
+		return errorInvalidEdDSAKey
+	}
+
+	signatureBytes, err := jwt.DecodeSegment(signature)
+	if err != nil {
+		return err
+	}
+
+	if !ed25519.Verify(publicKey, []byte(signingString), signatureBytes) {
+		return errorEdDSAVerification
+	}
+
+	return nil
+}
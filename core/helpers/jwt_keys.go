@@ -0,0 +1,274 @@
+package helpers
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+
+	minRSAKeyBits = 2048
+
+	signingKeysReloadInterval = 10 * time.Second
+
+	errorUnsupportedAlgorithm = "unsupported signing algorithm: %s"
+	errorKeyAlgorithmMismatch = "signing key %s does not match algorithm %s"
+	errorReadSigningKey       = "failed to read signing key %s: %v"
+	errorUnknownSigningKey    = "unknown signing key: %s"
+)
+
+var (
+	errorInvalidPEM    = errors.New("signing key is not a valid PEM block")
+	errorRSAKeyTooWeak = fmt.Errorf("rsa signing key must be at least %d bits", minRSAKeyBits)
+)
+
+var signingKeys = struct {
+	sync.RWMutex
+	keys   map[string]*SigningKey
+	active *SigningKey
+}{}
+
+var signingKeysReloader = struct {
+	sync.Mutex
+	reload     func() error
+	lastReload time.Time
+}{}
+
+// SigningKey is an asymmetric key used to sign access tokens, identified by its kid header.
+type SigningKey struct {
+	ID         string
+	Algorithm  string
+	PrivateKey crypto.Signer
+	PublicKey  crypto.PublicKey
+}
+
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// LoadSigningKeyFile reads a PKCS#8 or PKCS#1 PEM private key for the given algorithm.
+func LoadSigningKeyFile(id string, algorithm string, path string) (*SigningKey, error) {
+	completePath, err := GetCompletePath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	pemBytes, err := os.ReadFile(completePath)
+	if err != nil {
+		return nil, fmt.Errorf(errorReadSigningKey, path, err)
+	}
+
+	return ParseSigningKeyPEM(id, algorithm, pemBytes)
+}
+
+func ParseSigningKeyPEM(id string, algorithm string, pemBytes []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, errorInvalidPEM
+	}
+
+	var privateKey interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		privateKey, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		privateKey, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return NewSigningKey(id, algorithm, privateKey)
+}
+
+// NewSigningKey validates that privateKey can be used with algorithm. An empty id is replaced by the
+// RFC 7638 thumbprint of the public key.
+func NewSigningKey(id string, algorithm string, privateKey interface{}) (*SigningKey, error) {
+	key := &SigningKey{
+		ID:        id,
+		Algorithm: algorithm,
+	}
+
+	switch algorithm {
+	case AlgorithmRS256:
+		rsaKey, ok := privateKey.(*rsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf(errorKeyAlgorithmMismatch, id, algorithm)
+		}
+
+		if rsaKey.N.BitLen() < minRSAKeyBits {
+			return nil, errorRSAKeyTooWeak
+		}
+
+		key.PrivateKey = rsaKey
+		key.PublicKey = &rsaKey.PublicKey
+	case AlgorithmEdDSA:
+		edKey, ok := privateKey.(ed25519.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf(errorKeyAlgorithmMismatch, id, algorithm)
+		}
+
+		key.PrivateKey = edKey
+		key.PublicKey = edKey.Public()
+	default:
+		return nil, fmt.Errorf(errorUnsupportedAlgorithm, algorithm)
+	}
+
+	if key.ID == "" {
+		thumbprint, err := key.Thumbprint()
+		if err != nil {
+			return nil, err
+		}
+
+		key.ID = thumbprint
+	}
+
+	return key, nil
+}
+
+// RegisterSigningKeys replaces the keys used to sign and verify access tokens. The key identified by
+// activeKeyID signs new tokens, every registered key is accepted when verifying.
+func RegisterSigningKeys(keys []*SigningKey, activeKeyID string) error {
+	registered := make(map[string]*SigningKey, len(keys))
+	for _, key := range keys {
+		registered[key.ID] = key
+	}
+
+	var active *SigningKey
+	if activeKeyID != "" {
+		key, ok := registered[activeKeyID]
+		if !ok {
+			return fmt.Errorf(errorUnknownSigningKey, activeKeyID)
+		}
+		active = key
+	}
+
+	signingKeys.Lock()
+	defer signingKeys.Unlock()
+
+	signingKeys.keys = registered
+	signingKeys.active = active
+
+	return nil
+}
+
+// SetSigningKeysReloader registers a function that refreshes the registered keys. It is called when a token
+// carries an unknown kid, so a key rotated by another instance is picked up without waiting for a reload.
+func SetSigningKeysReloader(reload func() error) {
+	signingKeysReloader.Lock()
+	defer signingKeysReloader.Unlock()
+
+	signingKeysReloader.reload = reload
+}
+
+func reloadSigningKeys() bool {
+	signingKeysReloader.Lock()
+	defer signingKeysReloader.Unlock()
+
+	if signingKeysReloader.reload == nil || time.Since(signingKeysReloader.lastReload) < signingKeysReloadInterval {
+		return false
+	}
+
+	signingKeysReloader.lastReload = time.Now()
+
+	return signingKeysReloader.reload() == nil
+}
+
+// GetJWKS returns the public part of every registered signing key.
+func GetJWKS() JWKS {
+	signingKeys.RLock()
+	defer signingKeys.RUnlock()
+
+	result := JWKS{Keys: []JWK{}}
+	for _, key := range signingKeys.keys {
+		result.Keys = append(result.Keys, key.JWK())
+	}
+
+	return result
+}
+
+func getSigningKeys() (keys map[string]*SigningKey, active *SigningKey) {
+	signingKeys.RLock()
+	defer signingKeys.RUnlock()
+
+	return signingKeys.keys, signingKeys.active
+}
+
+func (k *SigningKey) JWK() JWK {
+	jwk := JWK{
+		Use: "sig",
+		Alg: k.Algorithm,
+		Kid: k.ID,
+	}
+
+	switch publicKey := k.PublicKey.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(publicKey)
+	}
+
+	return jwk
+}
+
+// Thumbprint computes the RFC 7638 JWK thumbprint of the public key.
+func (k *SigningKey) Thumbprint() (string, error) {
+	jwk := k.JWK()
+
+	var members interface{}
+	switch jwk.Kty {
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{E: jwk.E, Kty: jwk.Kty, N: jwk.N}
+	case "OKP":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{Crv: jwk.Crv, Kty: jwk.Kty, X: jwk.X}
+	default:
+		return "", fmt.Errorf(errorUnsupportedAlgorithm, k.Algorithm)
+	}
+
+	data, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,277 @@
+package helpers
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/winartodev/apollo/core"
+	userEntity "github.com/winartodev/apollo/modules/user/entities"
+)
+
+func TestJWT_GenerateToken_AsymmetricKeys(t *testing.T) {
+	t.Setenv(core.JwtAccessTokenSecretKey, "access-secret")
+	t.Setenv(core.JwtRefreshTokenSecretKey, "refresh-secret")
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		algorithm  string
+		privateKey interface{}
+	}{
+		{
+			name:       "success_rs256",
+			algorithm:  AlgorithmRS256,
+			privateKey: rsaKey,
+		},
+		{
+			name:       "success_eddsa",
+			algorithm:  AlgorithmEdDSA,
+			privateKey: edKey,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := NewSigningKey("", tt.algorithm, tt.privateKey)
+			if err != nil {
+				t.Fatalf("NewSigningKey() error = %v", err)
+			}
+
+			err = RegisterSigningKeys([]*SigningKey{key}, key.ID)
+			if err != nil {
+				t.Fatalf("RegisterSigningKeys() error = %v", err)
+			}
+			t.Cleanup(func() { _ = RegisterSigningKeys(nil, "") })
+
+			j, err := NewJWT()
+			if err != nil {
+				t.Fatalf("NewJWT() error = %v", err)
+			}
+
+			res, err := j.GenerateToken(&userEntity.User{ID: 1, Username: "apollo", Email: "apollo@gmail.com"}, "")
+			if err != nil {
+				t.Fatalf("GenerateToken() error = %v", err)
+			}
+
+			token, err := j.ParseToken(TokenKindAccess, res.AccessToken)
+			if err != nil {
+				t.Fatalf("ParseToken() error = %v", err)
+			}
+
+			if token.Header["kid"] != key.ID || token.Method.Alg() != tt.algorithm {
+				t.Errorf("ParseToken() header = %v, want kid %v and alg %v", token.Header, key.ID, tt.algorithm)
+			}
+
+			err = RegisterSigningKeys(nil, "")
+			if err != nil {
+				t.Fatalf("RegisterSigningKeys() error = %v", err)
+			}
+
+			j, _ = NewJWT()
+			if _, err = j.ParseToken(TokenKindAccess, res.AccessToken); err == nil {
+				t.Errorf("ParseToken() accepted a token signed by an unknown key")
+			}
+		})
+	}
+}
+
+func TestJWT_ParseToken_RejectsAlgorithmMismatch(t *testing.T) {
+	t.Setenv(core.JwtAccessTokenSecretKey, "access-secret")
+	t.Setenv(core.JwtRefreshTokenSecretKey, "refresh-secret")
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := NewSigningKey("ed-key", AlgorithmEdDSA, edKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = RegisterSigningKeys([]*SigningKey{key}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = RegisterSigningKeys(nil, "") })
+
+	// an HS256 token claiming the kid of an EdDSA key must not be verified with that key
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"id": 1})
+	forged.Header["kid"] = key.ID
+	tokenString, err := forged.SignedString([]byte("another-secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	j, err := NewJWT()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = j.ParseToken(TokenKindAccess, tokenString); err == nil {
+		t.Errorf("ParseToken() accepted a token with a mismatched algorithm")
+	}
+}
+
+func TestJWT_ParseToken_RejectsOtherKinds(t *testing.T) {
+	t.Setenv(core.JwtAccessTokenSecretKey, "access-secret")
+	t.Setenv(core.JwtRefreshTokenSecretKey, "refresh-secret")
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := NewSigningKey("", AlgorithmEdDSA, edKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = RegisterSigningKeys([]*SigningKey{key}, key.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = RegisterSigningKeys(nil, "") })
+
+	j, err := NewJWT()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := j.GenerateToken(&userEntity.User{ID: 1, Username: "apollo", Email: "apollo@gmail.com"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		kind    TokenKind
+		token   string
+		wantErr bool
+	}{
+		{
+			name:  "success_access_token",
+			kind:  TokenKindAccess,
+			token: res.AccessToken,
+		},
+		{
+			name:  "success_refresh_token",
+			kind:  TokenKindRefresh,
+			token: res.RefreshToken,
+		},
+		{
+			name:    "failed_access_token_as_refresh_token",
+			kind:    TokenKindRefresh,
+			token:   res.AccessToken,
+			wantErr: true,
+		},
+		{
+			name:    "failed_refresh_token_as_access_token",
+			kind:    TokenKindAccess,
+			token:   res.RefreshToken,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := j.ParseToken(tt.kind, tt.token)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseToken() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestJWT_GenerateIDToken(t *testing.T) {
+	t.Setenv(core.JwtAccessTokenSecretKey, "access-secret")
+	t.Setenv(core.JwtRefreshTokenSecretKey, "refresh-secret")
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		privateKey interface{}
+		wantErr    bool
+	}{
+		{
+			name:       "success_signed_with_active_key",
+			privateKey: rsaKey,
+		},
+		{
+			name:    "failed_without_signing_key",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var keys []*SigningKey
+			var activeKeyID string
+			if tt.privateKey != nil {
+				key, err := NewSigningKey("", AlgorithmRS256, tt.privateKey)
+				if err != nil {
+					t.Fatalf("NewSigningKey() error = %v", err)
+				}
+
+				keys, activeKeyID = []*SigningKey{key}, key.ID
+			}
+
+			err := RegisterSigningKeys(keys, activeKeyID)
+			if err != nil {
+				t.Fatalf("RegisterSigningKeys() error = %v", err)
+			}
+			t.Cleanup(func() { _ = RegisterSigningKeys(nil, "") })
+
+			j, err := NewJWT()
+			if err != nil {
+				t.Fatalf("NewJWT() error = %v", err)
+			}
+
+			idToken, err := j.GenerateIDToken(IDTokenClaims{
+				Nonce: "nonce",
+				StandardClaims: jwt.StandardClaims{
+					Issuer:    "http://localhost:8989",
+					Subject:   "subject",
+					Audience:  "client",
+					ExpiresAt: time.Now().Add(time.Minute).Unix(),
+				},
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GenerateIDToken() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			token, err := j.ParseToken(TokenKindID, idToken)
+			if err != nil {
+				t.Fatalf("ParseToken() error = %v", err)
+			}
+
+			// id tokens share the keys of access tokens but must never pass as one
+			if _, err = j.ParseToken(TokenKindAccess, idToken); err == nil {
+				t.Errorf("ParseToken() accepted an id token as an access token")
+			}
+
+			claims := token.Claims.(jwt.MapClaims)
+			if claims["aud"] != "client" || claims["nonce"] != "nonce" || token.Header["kid"] != activeKeyID {
+				t.Errorf("GenerateIDToken() claims = %v, header = %v", claims, token.Header)
+			}
+		})
+	}
+}
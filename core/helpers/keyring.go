@@ -0,0 +1,253 @@
+package helpers
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const (
+	KeyStateActive     = "active"
+	KeyStateVerifyOnly = "verify-only"
+	KeyStateRetired    = "retired"
+
+	keyRingManifest = "keyring.json"
+	keyRingDirMode  = 0700
+	keyRingFileMode = 0600
+	rsaKeyBits      = 2048
+
+	errorReadKeyRing  = "failed to read key ring: %v"
+	errorWriteKeyRing = "failed to write key ring: %v"
+)
+
+var (
+	errorKeyRingPathEmpty = errors.New("key ring path is required")
+)
+
+// KeyRingEntry describes one signing key of the ring. Only the active key signs new tokens, verify-only keys
+// are still accepted until the tokens they signed have expired, retired keys are no longer trusted.
+type KeyRingEntry struct {
+	ID        string `json:"kid"`
+	Algorithm string `json:"algorithm"`
+	State     string `json:"state"`
+	File      string `json:"file"`
+	CreatedAt int64  `json:"created_at"`
+	RotatedAt int64  `json:"rotated_at,omitempty"`
+	RetiredAt int64  `json:"retired_at,omitempty"`
+}
+
+// KeyRing is persisted as a keyring.json manifest next to the PEM files of its keys.
+type KeyRing struct {
+	Path    string         `json:"-"`
+	Entries []KeyRingEntry `json:"keys"`
+}
+
+// LoadKeyRing reads the manifest stored in path. A missing manifest results in an empty ring.
+func LoadKeyRing(path string) (*KeyRing, error) {
+	if path == "" {
+		return nil, errorKeyRingPathEmpty
+	}
+
+	ring := &KeyRing{Path: path}
+
+	data, err := os.ReadFile(filepath.Join(path, keyRingManifest))
+	if errors.Is(err, os.ErrNotExist) {
+		return ring, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf(errorReadKeyRing, err)
+	}
+
+	err = json.Unmarshal(data, ring)
+	if err != nil {
+		return nil, fmt.Errorf(errorReadKeyRing, err)
+	}
+
+	return ring, nil
+}
+
+// Save writes the manifest atomically so a concurrent reader never sees a partially written file.
+func (r *KeyRing) Save() error {
+	err := os.MkdirAll(r.Path, keyRingDirMode)
+	if err != nil {
+		return fmt.Errorf(errorWriteKeyRing, err)
+	}
+
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf(errorWriteKeyRing, err)
+	}
+
+	tmpFile := filepath.Join(r.Path, keyRingManifest+".tmp")
+	err = os.WriteFile(tmpFile, data, keyRingFileMode)
+	if err != nil {
+		return fmt.Errorf(errorWriteKeyRing, err)
+	}
+
+	err = os.Rename(tmpFile, filepath.Join(r.Path, keyRingManifest))
+	if err != nil {
+		return fmt.Errorf(errorWriteKeyRing, err)
+	}
+
+	return nil
+}
+
+func (r *KeyRing) Active() *KeyRingEntry {
+	for i := range r.Entries {
+		if r.Entries[i].State == KeyStateActive {
+			return &r.Entries[i]
+		}
+	}
+
+	return nil
+}
+
+// Add registers an existing key file. Adding an active key demotes the current active key to verify-only.
+func (r *KeyRing) Add(entry KeyRingEntry, now time.Time) {
+	if entry.State == KeyStateActive {
+		r.demoteActive(now)
+	}
+
+	if entry.CreatedAt == 0 {
+		entry.CreatedAt = now.Unix()
+	}
+
+	r.Entries = append(r.Entries, entry)
+}
+
+// Rotate generates a new active key. The previous active key becomes verify-only, so tokens it signed stay
+// valid during the overlap window, and verify-only keys older than the overlap window are retired.
+func (r *KeyRing) Rotate(algorithm string, overlap time.Duration, now time.Time) (*KeyRingEntry, error) {
+	privateKey, err := GenerateSigningPrivateKey(algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := NewSigningKey("", algorithm, privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	err = os.MkdirAll(r.Path, keyRingDirMode)
+	if err != nil {
+		return nil, fmt.Errorf(errorWriteKeyRing, err)
+	}
+
+	file := filepath.Join(r.Path, fmt.Sprintf("%s.pem", key.ID))
+	err = os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), keyRingFileMode)
+	if err != nil {
+		return nil, fmt.Errorf(errorWriteKeyRing, err)
+	}
+
+	r.Retire(overlap, now)
+	r.Add(KeyRingEntry{
+		ID:        key.ID,
+		Algorithm: algorithm,
+		State:     KeyStateActive,
+		File:      file,
+		CreatedAt: now.Unix(),
+	}, now)
+
+	return r.Active(), nil
+}
+
+// Retire moves verify-only keys that were rotated out longer than overlap ago to the retired state.
+func (r *KeyRing) Retire(overlap time.Duration, now time.Time) (retired []string) {
+	for i := range r.Entries {
+		entry := &r.Entries[i]
+		if entry.State != KeyStateVerifyOnly {
+			continue
+		}
+
+		if now.Sub(time.Unix(entry.RotatedAt, 0)) >= overlap {
+			entry.State = KeyStateRetired
+			entry.RetiredAt = now.Unix()
+			retired = append(retired, entry.ID)
+		}
+	}
+
+	return retired
+}
+
+// IsRotationDue reports whether the active key is older than interval, or whether there is no active key at all.
+func (r *KeyRing) IsRotationDue(interval time.Duration, now time.Time) bool {
+	active := r.Active()
+	if active == nil {
+		return true
+	}
+
+	return now.Sub(time.Unix(active.CreatedAt, 0)) >= interval
+}
+
+// SigningKeys loads the private keys of every key that is not retired, newest first.
+func (r *KeyRing) SigningKeys() (keys []*SigningKey, activeKeyID string, err error) {
+	entries := make([]KeyRingEntry, len(r.Entries))
+	copy(entries, r.Entries)
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].CreatedAt > entries[j].CreatedAt
+	})
+
+	for _, entry := range entries {
+		if entry.State == KeyStateRetired {
+			continue
+		}
+
+		key, err := LoadSigningKeyFile(entry.ID, entry.Algorithm, entry.File)
+		if err != nil {
+			return nil, "", err
+		}
+
+		keys = append(keys, key)
+		if entry.State == KeyStateActive {
+			activeKeyID = entry.ID
+		}
+	}
+
+	return keys, activeKeyID, nil
+}
+
+// Register makes the ring the source of the keys used by NewJWT.
+func (r *KeyRing) Register() error {
+	keys, activeKeyID, err := r.SigningKeys()
+	if err != nil {
+		return err
+	}
+
+	return RegisterSigningKeys(keys, activeKeyID)
+}
+
+func (r *KeyRing) demoteActive(now time.Time) {
+	for i := range r.Entries {
+		if r.Entries[i].State == KeyStateActive {
+			r.Entries[i].State = KeyStateVerifyOnly
+			r.Entries[i].RotatedAt = now.Unix()
+		}
+	}
+}
+
+func GenerateSigningPrivateKey(algorithm string) (interface{}, error) {
+	switch algorithm {
+	case AlgorithmRS256:
+		return rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	case AlgorithmEdDSA:
+		_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		return privateKey, err
+	default:
+		return nil, fmt.Errorf(errorUnsupportedAlgorithm, algorithm)
+	}
+}
@@ -0,0 +1,98 @@
+package helpers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/winartodev/apollo/core"
+	userEntity "github.com/winartodev/apollo/modules/user/entities"
+)
+
+func TestKeyRing_Rotate(t *testing.T) {
+	t.Setenv(core.JwtAccessTokenSecretKey, "access-secret")
+	t.Setenv(core.JwtRefreshTokenSecretKey, "refresh-secret")
+	t.Cleanup(func() { _ = RegisterSigningKeys(nil, "") })
+
+	overlap := time.Hour
+	now := time.Now()
+
+	ring, err := LoadKeyRing(t.TempDir())
+	if err != nil {
+		t.Fatalf("LoadKeyRing() error = %v", err)
+	}
+
+	first, err := ring.Rotate(AlgorithmEdDSA, overlap, now)
+	if err != nil {
+		t.Fatalf("Rotate() error = %v", err)
+	}
+	firstID := first.ID
+
+	if err = ring.Register(); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+
+	j, _ := NewJWT()
+	res, err := j.GenerateToken(&userEntity.User{ID: 1, Username: "apollo", Email: "apollo@gmail.com"}, "")
+	if err != nil {
+		t.Fatalf("GenerateToken() error = %v", err)
+	}
+
+	second, err := ring.Rotate(AlgorithmRS256, overlap, now)
+	if err != nil {
+		t.Fatalf("Rotate() error = %v", err)
+	}
+
+	if err = ring.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	ring, err = LoadKeyRing(ring.Path)
+	if err != nil {
+		t.Fatalf("LoadKeyRing() error = %v", err)
+	}
+
+	if active := ring.Active(); active == nil || active.ID != second.ID {
+		t.Fatalf("Active() = %v, want %v", active, second.ID)
+	}
+
+	tests := []struct {
+		name      string
+		now       time.Time
+		wantState string
+		wantValid bool
+	}{
+		{
+			name:      "success_verify_only_within_overlap",
+			now:       now.Add(overlap / 2),
+			wantState: KeyStateVerifyOnly,
+			wantValid: true,
+		},
+		{
+			name:      "success_retired_after_overlap",
+			now:       now.Add(overlap),
+			wantState: KeyStateRetired,
+			wantValid: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ring.Retire(overlap, tt.now)
+
+			for _, entry := range ring.Entries {
+				if entry.ID == firstID && entry.State != tt.wantState {
+					t.Errorf("Retire() state = %v, want %v", entry.State, tt.wantState)
+				}
+			}
+
+			if err = ring.Register(); err != nil {
+				t.Fatalf("Register() error = %v", err)
+			}
+
+			j, _ := NewJWT()
+			_, err = j.ParseToken(TokenKindAccess, res.AccessToken)
+			if (err == nil) != tt.wantValid {
+				t.Errorf("ParseToken() error = %v, wantValid %v", err, tt.wantValid)
+			}
+		})
+	}
+}
@@ -0,0 +1,410 @@
+package helpers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"net"
+	"os"
+)
+
+const (
+	mmdbMaxDepth = 32
+
+	mmdbDataSectionSeparator = 16
+
+	mmdbExtended  = 0
+	mmdbPointer   = 1
+	mmdbString    = 2
+	mmdbDouble    = 3
+	mmdbBytes     = 4
+	mmdbUint16    = 5
+	mmdbUint32    = 6
+	mmdbMap       = 7
+	mmdbInt32     = 8
+	mmdbUint64    = 9
+	mmdbUint128   = 10
+	mmdbArray     = 11
+	mmdbContainer = 12
+	mmdbEndMarker = 13
+	mmdbBoolean   = 14
+	mmdbFloat     = 15
+)
+
+var (
+	mmdbMetadataStart = []byte("\xab\xcd\xefMaxMind.com")
+
+	errorMMDBMetadataNotFound = errors.New("mmdb: metadata not found")
+	errorMMDBInvalidMetadata  = errors.New("mmdb: invalid metadata")
+	errorMMDBUnexpectedEnd    = errors.New("mmdb: unexpected end of data")
+	errorMMDBUnsupported      = errors.New("mmdb: unsupported data type")
+	errorMMDBTooDeep          = errors.New("mmdb: data is nested too deep")
+	errorMMDBInvalidTree      = errors.New("mmdb: invalid search tree")
+)
+
+// MMDBReader looks up IP addresses in a MaxMind DB file such as GeoLite2 City, read entirely into memory so lookups
+// never touch the disk or the network.
+type MMDBReader struct {
+	buffer      []byte
+	dataSection []byte
+	nodeCount   uint
+	recordSize  uint
+	ipVersion   uint
+	ipv4Start   uint
+	ipv4Depth   int
+}
+
+// OpenMMDB reads the MaxMind DB file at path.
+func OpenMMDB(path string) (*MMDBReader, error) {
+	buffer, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewMMDBReader(buffer)
+}
+
+// NewMMDBReader parses a MaxMind DB held in buffer.
+func NewMMDBReader(buffer []byte) (*MMDBReader, error) {
+	start := bytes.LastIndex(buffer, mmdbMetadataStart)
+	if start < 0 {
+		return nil, errorMMDBMetadataNotFound
+	}
+
+	metadataSection := buffer[start+len(mmdbMetadataStart):]
+	value, _, err := decodeMMDB(metadataSection, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, errorMMDBInvalidMetadata
+	}
+
+	nodeCount, ok := metadata["node_count"].(uint64)
+	if !ok {
+		return nil, errorMMDBInvalidMetadata
+	}
+
+	recordSize, ok := metadata["record_size"].(uint64)
+	if !ok || (recordSize != 24 && recordSize != 28 && recordSize != 32) {
+		return nil, errorMMDBInvalidMetadata
+	}
+
+	ipVersion, ok := metadata["ip_version"].(uint64)
+	if !ok || (ipVersion != 4 && ipVersion != 6) {
+		return nil, errorMMDBInvalidMetadata
+	}
+
+	treeSize := nodeCount * recordSize / 4
+	if treeSize+mmdbDataSectionSeparator > uint64(start) {
+		return nil, errorMMDBInvalidTree
+	}
+
+	reader := &MMDBReader{
+		buffer:      buffer,
+		dataSection: buffer[treeSize+mmdbDataSectionSeparator : start],
+		nodeCount:   uint(nodeCount),
+		recordSize:  uint(recordSize),
+		ipVersion:   uint(ipVersion),
+	}
+
+	// IPv4 addresses of an IPv6 database live under ::/96, the node they start from is found once
+	if reader.ipVersion == 6 {
+		node := uint(0)
+		for i := 0; i < 96 && node < reader.nodeCount; i++ {
+			node, err = reader.readNode(node, 0)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		reader.ipv4Start = node
+		reader.ipv4Depth = 96
+	}
+
+	return reader, nil
+}
+
+// Lookup returns the record of the network ip belongs to, nil when the database has none. Maps are returned as
+// map[string]interface{}, arrays as []interface{}, unsigned integers as uint64, int32 as int64, uint128 as *big.Int,
+// doubles and floats as float64 and bytes as []byte.
+func (r *MMDBReader) Lookup(ip net.IP) (record interface{}, err error) {
+	address := ip.To4()
+	node := uint(0)
+	if address != nil {
+		node = r.ipv4Start
+	} else {
+		if r.ipVersion == 4 {
+			return nil, fmt.Errorf("mmdb: cannot look up IPv6 address %s in an IPv4 database", ip)
+		}
+
+		address = ip.To16()
+		if address == nil {
+			return nil, fmt.Errorf("mmdb: invalid IP address %s", ip)
+		}
+	}
+
+	for i := 0; i < len(address)*8 && node < r.nodeCount; i++ {
+		bit := uint(address[i/8]>>(7-uint(i%8))) & 1
+
+		node, err = r.readNode(node, bit)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if node == r.nodeCount {
+		return nil, nil
+	}
+
+	if node < r.nodeCount {
+		return nil, errorMMDBInvalidTree
+	}
+
+	offset := node - r.nodeCount - mmdbDataSectionSeparator
+	if offset >= uint(len(r.dataSection)) {
+		return nil, errorMMDBInvalidTree
+	}
+
+	record, _, err = decodeMMDB(r.dataSection, offset, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return record, nil
+}
+
+// readNode returns the left record of node for bit 0 and the right record for bit 1.
+func (r *MMDBReader) readNode(node uint, bit uint) (uint, error) {
+	size := r.recordSize / 4
+	offset := node * size
+	if offset+size > uint(len(r.buffer)) {
+		return 0, errorMMDBUnexpectedEnd
+	}
+
+	b := r.buffer[offset : offset+size]
+
+	switch r.recordSize {
+	case 24:
+		b = b[bit*3:]
+		return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2]), nil
+	case 28:
+		if bit == 0 {
+			return uint(b[3]&0xf0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2]), nil
+		}
+
+		return uint(b[3]&0x0f)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6]), nil
+	default:
+		return uint(binary.BigEndian.Uint32(b[bit*4:])), nil
+	}
+}
+
+// decodeMMDB decodes the data field at offset of section and returns it together with the offset of the field
+// that follows it.
+func decodeMMDB(section []byte, offset uint, depth int) (value interface{}, next uint, err error) {
+	if depth > mmdbMaxDepth {
+		return nil, 0, errorMMDBTooDeep
+	}
+
+	if offset >= uint(len(section)) {
+		return nil, 0, errorMMDBUnexpectedEnd
+	}
+
+	control := section[offset]
+	offset++
+
+	dataType := uint(control >> 5)
+	if dataType == mmdbPointer {
+		pointer, next, err := decodeMMDBPointer(section, control, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		// pointers never point at pointers, so following one does not count towards the depth
+		value, _, err = decodeMMDB(section, pointer, depth)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		return value, next, nil
+	}
+
+	if dataType == mmdbExtended {
+		if offset >= uint(len(section)) {
+			return nil, 0, errorMMDBUnexpectedEnd
+		}
+
+		dataType = 7 + uint(section[offset])
+		offset++
+	}
+
+	size, offset, err := decodeMMDBSize(section, control, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	switch dataType {
+	case mmdbMap:
+		res := make(map[string]interface{}, size)
+		for i := uint(0); i < size; i++ {
+			var key, item interface{}
+
+			key, offset, err = decodeMMDB(section, offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+
+			name, ok := key.(string)
+			if !ok {
+				return nil, 0, errorMMDBUnsupported
+			}
+
+			item, offset, err = decodeMMDB(section, offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+
+			res[name] = item
+		}
+
+		return res, offset, nil
+	case mmdbArray:
+		res := make([]interface{}, 0, size)
+		for i := uint(0); i < size; i++ {
+			var item interface{}
+
+			item, offset, err = decodeMMDB(section, offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+
+			res = append(res, item)
+		}
+
+		return res, offset, nil
+	case mmdbBoolean:
+		return size != 0, offset, nil
+	}
+
+	if offset+size > uint(len(section)) {
+		return nil, 0, errorMMDBUnexpectedEnd
+	}
+
+	payload := section[offset : offset+size]
+	next = offset + size
+
+	switch dataType {
+	case mmdbString:
+		return string(payload), next, nil
+	case mmdbBytes:
+		return append([]byte(nil), payload...), next, nil
+	case mmdbDouble:
+		if size != 8 {
+			return nil, 0, errorMMDBUnsupported
+		}
+
+		return math.Float64frombits(binary.BigEndian.Uint64(payload)), next, nil
+	case mmdbFloat:
+		if size != 4 {
+			return nil, 0, errorMMDBUnsupported
+		}
+
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(payload))), next, nil
+	case mmdbUint16, mmdbUint32, mmdbUint64:
+		if size > 8 {
+			return nil, 0, errorMMDBUnsupported
+		}
+
+		var res uint64
+		for _, b := range payload {
+			res = res<<8 | uint64(b)
+		}
+
+		return res, next, nil
+	case mmdbInt32:
+		if size > 4 {
+			return nil, 0, errorMMDBUnsupported
+		}
+
+		var res uint32
+		for _, b := range payload {
+			res = res<<8 | uint32(b)
+		}
+
+		if size == 4 {
+			return int64(int32(res)), next, nil
+		}
+
+		return int64(res), next, nil
+	case mmdbUint128:
+		if size > 16 {
+			return nil, 0, errorMMDBUnsupported
+		}
+
+		return new(big.Int).SetBytes(payload), next, nil
+	default:
+		return nil, 0, errorMMDBUnsupported
+	}
+}
+
+// decodeMMDBSize reads the payload size encoded in the low five bits of control and, for large payloads, the bytes
+// that follow it.
+func decodeMMDBSize(section []byte, control byte, offset uint) (size uint, next uint, err error) {
+	size = uint(control & 0x1f)
+	if size < 29 {
+		return size, offset, nil
+	}
+
+	length := size - 28
+	if offset+length > uint(len(section)) {
+		return 0, 0, errorMMDBUnexpectedEnd
+	}
+
+	var extra uint
+	for _, b := range section[offset : offset+length] {
+		extra = extra<<8 | uint(b)
+	}
+
+	switch size {
+	case 29:
+		size = 29 + extra
+	case 30:
+		size = 285 + extra
+	default:
+		size = 65821 + extra
+	}
+
+	return size, offset + length, nil
+}
+
+// decodeMMDBPointer reads the data section offset a pointer field refers to.
+func decodeMMDBPointer(section []byte, control byte, offset uint) (pointer uint, next uint, err error) {
+	length := uint(control>>3)&0x3 + 1
+	if offset+length > uint(len(section)) {
+		return 0, 0, errorMMDBUnexpectedEnd
+	}
+
+	prefix := uint(control & 0x7)
+	if length == 4 {
+		prefix = 0
+	}
+
+	pointer = prefix
+	for _, b := range section[offset : offset+length] {
+		pointer = pointer<<8 | uint(b)
+	}
+
+	switch length {
+	case 2:
+		pointer += 2048
+	case 3:
+		pointer += 526336
+	}
+
+	return pointer, offset + length, nil
+}
@@ -0,0 +1,265 @@
+package helpers
+
+import (
+	"encoding/binary"
+	"math"
+	"net"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// mmdbTestPointer is encoded as a pointer to the data section offset it holds.
+type mmdbTestPointer uint
+
+func encodeMMDBTest(value interface{}) []byte {
+	header := func(dataType int, size int) []byte {
+		var control byte
+		var res []byte
+		if dataType > 7 {
+			res = []byte{0, byte(dataType - 7)}
+		} else {
+			control = byte(dataType << 5)
+			res = []byte{0}
+		}
+
+		var extra []byte
+		switch {
+		case size < 29:
+			control |= byte(size)
+		case size < 285:
+			control |= 29
+			extra = []byte{byte(size - 29)}
+		default:
+			control |= 30
+			extra = []byte{byte((size - 285) >> 8), byte(size - 285)}
+		}
+
+		res[0] |= control
+		return append(res, extra...)
+	}
+
+	switch v := value.(type) {
+	case mmdbTestPointer:
+		return []byte{byte(mmdbPointer<<5) | byte(v>>8&0x7), byte(v)}
+	case string:
+		return append(header(mmdbString, len(v)), v...)
+	case uint64:
+		payload := make([]byte, 8)
+		binary.BigEndian.PutUint64(payload, v)
+		for len(payload) > 0 && payload[0] == 0 {
+			payload = payload[1:]
+		}
+
+		return append(header(mmdbUint32, len(payload)), payload...)
+	case float64:
+		payload := make([]byte, 8)
+		binary.BigEndian.PutUint64(payload, math.Float64bits(v))
+		return append(header(mmdbDouble, 8), payload...)
+	case bool:
+		size := 0
+		if v {
+			size = 1
+		}
+
+		return header(mmdbBoolean, size)
+	case []interface{}:
+		res := header(mmdbArray, len(v))
+		for _, item := range v {
+			res = append(res, encodeMMDBTest(item)...)
+		}
+
+		return res
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		res := header(mmdbMap, len(v))
+		for _, key := range keys {
+			res = append(res, encodeMMDBTest(key)...)
+			res = append(res, encodeMMDBTest(v[key])...)
+		}
+
+		return res
+	default:
+		panic("unsupported test value")
+	}
+}
+
+// buildMMDBTest writes a database in which only the network of prefix holds record, the leading entries of data
+// are written before it so record can point at them.
+func buildMMDBTest(recordSize int, ipVersion int, prefix []byte, prefixLength int, data []interface{}, record interface{}) []byte {
+	var dataSection []byte
+	for _, item := range data {
+		dataSection = append(dataSection, encodeMMDBTest(item)...)
+	}
+	recordOffset := len(dataSection)
+	dataSection = append(dataSection, encodeMMDBTest(record)...)
+
+	nodeCount := prefixLength
+	var tree []byte
+	for i := 0; i < nodeCount; i++ {
+		next := i + 1
+		if i == nodeCount-1 {
+			next = nodeCount + mmdbDataSectionSeparator + recordOffset
+		}
+
+		records := [2]int{nodeCount, nodeCount}
+		records[prefix[i/8]>>(7-uint(i%8))&1] = next
+
+		switch recordSize {
+		case 24:
+			tree = append(tree,
+				byte(records[0]>>16), byte(records[0]>>8), byte(records[0]),
+				byte(records[1]>>16), byte(records[1]>>8), byte(records[1]))
+		case 28:
+			tree = append(tree,
+				byte(records[0]>>16), byte(records[0]>>8), byte(records[0]),
+				byte(records[0]>>20&0xf0)|byte(records[1]>>24&0x0f),
+				byte(records[1]>>16), byte(records[1]>>8), byte(records[1]))
+		default:
+			tree = binary.BigEndian.AppendUint32(tree, uint32(records[0]))
+			tree = binary.BigEndian.AppendUint32(tree, uint32(records[1]))
+		}
+	}
+
+	res := append(tree, make([]byte, mmdbDataSectionSeparator)...)
+	res = append(res, dataSection...)
+	res = append(res, mmdbMetadataStart...)
+	res = append(res, encodeMMDBTest(map[string]interface{}{
+		"binary_format_major_version": uint64(2),
+		"database_type":               "Apollo-Test-City",
+		"ip_version":                  uint64(ipVersion),
+		"languages":                   []interface{}{"en"},
+		"node_count":                  uint64(nodeCount),
+		"record_size":                 uint64(recordSize),
+	})...)
+
+	return res
+}
+
+func TestMMDBReader_Lookup(t *testing.T) {
+	record := map[string]interface{}{
+		"city":       map[string]interface{}{"names": map[string]interface{}{"en": mmdbTestPointer(0)}},
+		"country":    map[string]interface{}{"iso_code": "ID", "names": map[string]interface{}{"en": "Indonesia"}},
+		"location":   map[string]interface{}{"latitude": -6.2, "longitude": 106.8, "accuracy_radius": uint64(1000)},
+		"is_anycast": false,
+	}
+	want := map[string]interface{}{
+		"city":       map[string]interface{}{"names": map[string]interface{}{"en": "Jakarta"}},
+		"country":    map[string]interface{}{"iso_code": "ID", "names": map[string]interface{}{"en": "Indonesia"}},
+		"location":   map[string]interface{}{"latitude": -6.2, "longitude": 106.8, "accuracy_radius": uint64(1000)},
+		"is_anycast": false,
+	}
+	ipv4Prefix := []byte{1, 2, 3, 0}
+	ipv4InIPv6Prefix := append(make([]byte, 12), ipv4Prefix...)
+
+	tests := []struct {
+		name     string
+		database []byte
+		ip       string
+		want     interface{}
+		wantErr  bool
+	}{
+		{
+			name:     "success_ipv4_database_24_bit_records",
+			database: buildMMDBTest(24, 4, ipv4Prefix, 24, []interface{}{"Jakarta"}, record),
+			ip:       "1.2.3.4",
+			want:     want,
+		},
+		{
+			name:     "success_ipv4_database_28_bit_records",
+			database: buildMMDBTest(28, 4, ipv4Prefix, 24, []interface{}{"Jakarta"}, record),
+			ip:       "1.2.3.200",
+			want:     want,
+		},
+		{
+			name:     "success_ipv4_database_32_bit_records",
+			database: buildMMDBTest(32, 4, ipv4Prefix, 24, []interface{}{"Jakarta"}, record),
+			ip:       "1.2.3.4",
+			want:     want,
+		},
+		{
+			name:     "success_ipv4_address_in_ipv6_database",
+			database: buildMMDBTest(28, 6, ipv4InIPv6Prefix, 120, []interface{}{"Jakarta"}, record),
+			ip:       "1.2.3.4",
+			want:     want,
+		},
+		{
+			name:     "success_address_not_in_database",
+			database: buildMMDBTest(24, 4, ipv4Prefix, 24, []interface{}{"Jakarta"}, record),
+			ip:       "1.2.4.4",
+			want:     nil,
+		},
+		{
+			name:     "failed_ipv6_address_in_ipv4_database",
+			database: buildMMDBTest(24, 4, ipv4Prefix, 24, []interface{}{"Jakarta"}, record),
+			ip:       "2001:db8::1",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader, err := NewMMDBReader(tt.database)
+			if err != nil {
+				t.Fatalf("NewMMDBReader() error = %v", err)
+			}
+
+			got, err := reader.Lookup(net.ParseIP(tt.ip))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Lookup() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Lookup() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewMMDBReader(t *testing.T) {
+	tests := []struct {
+		name     string
+		database []byte
+		wantErr  bool
+	}{
+		{
+			name:     "success",
+			database: buildMMDBTest(24, 4, []byte{10, 0, 0, 0}, 8, nil, "private"),
+		},
+		{
+			name:     "failed_without_metadata",
+			database: make([]byte, 64),
+			wantErr:  true,
+		},
+		{
+			name: "failed_unsupported_record_size",
+			database: append(append(make([]byte, 16), mmdbMetadataStart...), encodeMMDBTest(map[string]interface{}{
+				"ip_version":  uint64(4),
+				"node_count":  uint64(0),
+				"record_size": uint64(20),
+			})...),
+			wantErr: true,
+		},
+		{
+			name: "failed_tree_larger_than_file",
+			database: append(append(make([]byte, 16), mmdbMetadataStart...), encodeMMDBTest(map[string]interface{}{
+				"ip_version":  uint64(4),
+				"node_count":  uint64(100),
+				"record_size": uint64(24),
+			})...),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewMMDBReader(tt.database)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewMMDBReader() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
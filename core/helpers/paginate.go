@@ -1,7 +1,9 @@
 package helpers
 
 import (
+	"github.com/gofiber/fiber/v2"
 	"github.com/winartodev/apollo/core"
+	"strconv"
 	"strings"
 )
 
@@ -12,6 +14,26 @@ type Paginate struct {
 	SortBy  *string
 }
 
+// NewPaginateFromQuery reads the page, limit and sort query parameters of the request, the page is kept in Offset
+// the way BuildPaginate expects it. Missing or malformed parameters are left for Validate to default.
+func NewPaginateFromQuery(ctx *fiber.Ctx) *Paginate {
+	paginate := &Paginate{}
+
+	if page, err := strconv.ParseInt(ctx.Query("page"), 10, 64); err == nil {
+		paginate.Offset = &page
+	}
+
+	if limit, err := strconv.ParseInt(ctx.Query("limit"), 10, 64); err == nil {
+		paginate.Limit = &limit
+	}
+
+	if sort := ctx.Query("sort"); sort != "" {
+		paginate.SortBy = &sort
+	}
+
+	return paginate
+}
+
 func (f *Paginate) Validate() {
 	if f.OrderBy == nil || len(*f.OrderBy) == 0 {
 		ob := core.DefaultOrder
@@ -0,0 +1,23 @@
+package helpers
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+)
+
+const (
+	PKCEMethodS256 = "S256"
+
+	codeVerifierLength = 32
+)
+
+// GenerateCodeVerifier returns a RFC 7636 code verifier, 43 characters of the unreserved URL alphabet.
+func GenerateCodeVerifier() (string, error) {
+	return GenerateRandomToken(codeVerifierLength)
+}
+
+// CodeChallengeS256 derives the S256 code challenge sent in the authorization request from verifier.
+func CodeChallengeS256(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,563 @@
+package helpers
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+)
+
+// The encoder below only covers what Apollo needs to render otpauth URIs: byte mode, error correction level M
+// and versions 1 to 10, which is enough for payloads up to 213 bytes.
+
+const (
+	qrMinVersion   = 1
+	qrMaxVersion   = 10
+	qrQuietZone    = 4
+	qrFormatBitsM  = 0
+	qrModeByte     = 0x4
+	qrPenaltyN1    = 3
+	qrPenaltyN2    = 3
+	qrPenaltyN3    = 40
+	qrPenaltyN4    = 10
+	qrDefaultScale = 6
+)
+
+var (
+	errorQRDataTooLong = errors.New("qr code data is too long")
+)
+
+type qrBlockSpec struct {
+	ecPerBlock int
+	groups     [][2]int // number of blocks, data codewords per block
+}
+
+// qrBlockSpecsM holds the block structure of error correction level M indexed by version.
+var qrBlockSpecsM = map[int]qrBlockSpec{
+	1:  {ecPerBlock: 10, groups: [][2]int{{1, 16}}},
+	2:  {ecPerBlock: 16, groups: [][2]int{{1, 28}}},
+	3:  {ecPerBlock: 26, groups: [][2]int{{1, 44}}},
+	4:  {ecPerBlock: 18, groups: [][2]int{{2, 32}}},
+	5:  {ecPerBlock: 24, groups: [][2]int{{2, 43}}},
+	6:  {ecPerBlock: 16, groups: [][2]int{{4, 27}}},
+	7:  {ecPerBlock: 18, groups: [][2]int{{4, 31}}},
+	8:  {ecPerBlock: 22, groups: [][2]int{{2, 38}, {2, 39}}},
+	9:  {ecPerBlock: 22, groups: [][2]int{{3, 36}, {2, 37}}},
+	10: {ecPerBlock: 26, groups: [][2]int{{4, 43}, {1, 44}}},
+}
+
+var qrAlignmentPositions = map[int][]int{
+	1:  {},
+	2:  {6, 18},
+	3:  {6, 22},
+	4:  {6, 26},
+	5:  {6, 30},
+	6:  {6, 34},
+	7:  {6, 22, 38},
+	8:  {6, 24, 42},
+	9:  {6, 26, 46},
+	10: {6, 28, 50},
+}
+
+type qrCode struct {
+	version    int
+	size       int
+	modules    [][]bool
+	isFunction [][]bool
+}
+
+// GenerateQRCodePNG encodes data into a QR code and renders it as a PNG image.
+func GenerateQRCodePNG(data string, scale int) ([]byte, error) {
+	if scale < 1 {
+		scale = qrDefaultScale
+	}
+
+	qr, err := encodeQRCode([]byte(data))
+	if err != nil {
+		return nil, err
+	}
+
+	dimension := (qr.size + qrQuietZone*2) * scale
+	img := image.NewGray(image.Rect(0, 0, dimension, dimension))
+	for y := 0; y < dimension; y++ {
+		for x := 0; x < dimension; x++ {
+			img.SetGray(x, y, color.Gray{Y: 0xFF})
+		}
+	}
+
+	for y := 0; y < qr.size; y++ {
+		for x := 0; x < qr.size; x++ {
+			if !qr.modules[y][x] {
+				continue
+			}
+
+			for dy := 0; dy < scale; dy++ {
+				for dx := 0; dx < scale; dx++ {
+					img.SetGray((x+qrQuietZone)*scale+dx, (y+qrQuietZone)*scale+dy, color.Gray{Y: 0x00})
+				}
+			}
+		}
+	}
+
+	var buffer bytes.Buffer
+	err = png.Encode(&buffer, img)
+	if err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
+
+func encodeQRCode(data []byte) (*qrCode, error) {
+	version := 0
+	for v := qrMinVersion; v <= qrMaxVersion; v++ {
+		if qrCharCountBits(v)+len(data)*8+4 <= qrDataCodewords(v)*8 {
+			version = v
+			break
+		}
+	}
+
+	if version == 0 {
+		return nil, errorQRDataTooLong
+	}
+
+	codewords := qrAddErrorCorrection(version, qrBuildDataCodewords(version, data))
+
+	size := version*4 + 17
+	qr := &qrCode{
+		version:    version,
+		size:       size,
+		modules:    make([][]bool, size),
+		isFunction: make([][]bool, size),
+	}
+	for i := 0; i < size; i++ {
+		qr.modules[i] = make([]bool, size)
+		qr.isFunction[i] = make([]bool, size)
+	}
+
+	qr.drawFunctionPatterns()
+	qr.drawCodewords(codewords)
+
+	bestMask, minPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		qr.applyMask(mask)
+		qr.drawFormatBits(mask)
+		penalty := qr.penaltyScore()
+		if minPenalty < 0 || penalty < minPenalty {
+			bestMask, minPenalty = mask, penalty
+		}
+		qr.applyMask(mask) // masking is an XOR, applying it again undoes it
+	}
+
+	qr.applyMask(bestMask)
+	qr.drawFormatBits(bestMask)
+
+	return qr, nil
+}
+
+func qrCharCountBits(version int) int {
+	if version < 10 {
+		return 8
+	}
+
+	return 16
+}
+
+func qrDataCodewords(version int) int {
+	total := 0
+	for _, group := range qrBlockSpecsM[version].groups {
+		total += group[0] * group[1]
+	}
+
+	return total
+}
+
+func qrBuildDataCodewords(version int, data []byte) []byte {
+	capacity := qrDataCodewords(version) * 8
+
+	var bits []bool
+	appendBits := func(value int, length int) {
+		for i := length - 1; i >= 0; i-- {
+			bits = append(bits, (value>>uint(i))&1 == 1)
+		}
+	}
+
+	appendBits(qrModeByte, 4)
+	appendBits(len(data), qrCharCountBits(version))
+	for _, b := range data {
+		appendBits(int(b), 8)
+	}
+
+	terminator := capacity - len(bits)
+	if terminator > 4 {
+		terminator = 4
+	}
+	appendBits(0, terminator)
+
+	if len(bits)%8 != 0 {
+		appendBits(0, 8-len(bits)%8)
+	}
+
+	for pad := 0xEC; len(bits) < capacity; pad ^= 0xEC ^ 0x11 {
+		appendBits(pad, 8)
+	}
+
+	result := make([]byte, len(bits)/8)
+	for i, bit := range bits {
+		if bit {
+			result[i/8] |= 1 << uint(7-i%8)
+		}
+	}
+
+	return result
+}
+
+func qrAddErrorCorrection(version int, data []byte) []byte {
+	spec := qrBlockSpecsM[version]
+	divisor := qrReedSolomonDivisor(spec.ecPerBlock)
+
+	var dataBlocks, ecBlocks [][]byte
+	offset, maxDataLength := 0, 0
+	for _, group := range spec.groups {
+		for i := 0; i < group[0]; i++ {
+			block := data[offset : offset+group[1]]
+			offset += group[1]
+
+			dataBlocks = append(dataBlocks, block)
+			ecBlocks = append(ecBlocks, qrReedSolomonRemainder(block, divisor))
+			if len(block) > maxDataLength {
+				maxDataLength = len(block)
+			}
+		}
+	}
+
+	var result []byte
+	for i := 0; i < maxDataLength; i++ {
+		for _, block := range dataBlocks {
+			if i < len(block) {
+				result = append(result, block[i])
+			}
+		}
+	}
+
+	for i := 0; i < spec.ecPerBlock; i++ {
+		for _, block := range ecBlocks {
+			result = append(result, block[i])
+		}
+	}
+
+	return result
+}
+
+func qrReedSolomonDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range result {
+			result[j] = qrGFMultiply(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = qrGFMultiply(root, 0x02)
+	}
+
+	return result
+}
+
+func qrReedSolomonRemainder(data []byte, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i, coefficient := range divisor {
+			result[i] ^= qrGFMultiply(coefficient, factor)
+		}
+	}
+
+	return result
+}
+
+// qrGFMultiply multiplies two elements of GF(2^8) modulo the QR code polynomial x^8 + x^4 + x^3 + x^2 + 1.
+func qrGFMultiply(x byte, y byte) byte {
+	z := 0
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11D)
+		z ^= int((y>>uint(i))&1) * int(x)
+	}
+
+	return byte(z)
+}
+
+func (qr *qrCode) setFunctionModule(x int, y int, dark bool) {
+	qr.modules[y][x] = dark
+	qr.isFunction[y][x] = true
+}
+
+func (qr *qrCode) drawFunctionPatterns() {
+	for i := 0; i < qr.size; i++ {
+		qr.setFunctionModule(6, i, i%2 == 0)
+		qr.setFunctionModule(i, 6, i%2 == 0)
+	}
+
+	qr.drawFinderPattern(3, 3)
+	qr.drawFinderPattern(qr.size-4, 3)
+	qr.drawFinderPattern(3, qr.size-4)
+
+	positions := qrAlignmentPositions[qr.version]
+	count := len(positions)
+	for i := 0; i < count; i++ {
+		for j := 0; j < count; j++ {
+			overlapsFinder := (i == 0 && j == 0) || (i == 0 && j == count-1) || (i == count-1 && j == 0)
+			if !overlapsFinder {
+				qr.drawAlignmentPattern(positions[i], positions[j])
+			}
+		}
+	}
+
+	// reserve the format areas, the real bits are drawn once the mask is chosen
+	qr.drawFormatBits(0)
+	qr.drawVersion()
+}
+
+func (qr *qrCode) drawFinderPattern(x int, y int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			distance := maxInt(absInt(dx), absInt(dy))
+			xx, yy := x+dx, y+dy
+			if xx >= 0 && xx < qr.size && yy >= 0 && yy < qr.size {
+				qr.setFunctionModule(xx, yy, distance != 2 && distance != 4)
+			}
+		}
+	}
+}
+
+func (qr *qrCode) drawAlignmentPattern(x int, y int) {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			qr.setFunctionModule(x+dx, y+dy, maxInt(absInt(dx), absInt(dy)) != 1)
+		}
+	}
+}
+
+func (qr *qrCode) drawFormatBits(mask int) {
+	bits := qrFormatBits(mask)
+
+	for i := 0; i <= 5; i++ {
+		qr.setFunctionModule(8, i, qrBit(bits, i))
+	}
+	qr.setFunctionModule(8, 7, qrBit(bits, 6))
+	qr.setFunctionModule(8, 8, qrBit(bits, 7))
+	qr.setFunctionModule(7, 8, qrBit(bits, 8))
+	for i := 9; i < 15; i++ {
+		qr.setFunctionModule(14-i, 8, qrBit(bits, i))
+	}
+
+	for i := 0; i < 8; i++ {
+		qr.setFunctionModule(qr.size-1-i, 8, qrBit(bits, i))
+	}
+	for i := 8; i < 15; i++ {
+		qr.setFunctionModule(8, qr.size-15+i, qrBit(bits, i))
+	}
+	qr.setFunctionModule(8, qr.size-8, true)
+}
+
+func (qr *qrCode) drawVersion() {
+	if qr.version < 7 {
+		return
+	}
+
+	remainder := qr.version
+	for i := 0; i < 12; i++ {
+		remainder = (remainder << 1) ^ ((remainder >> 11) * 0x1F25)
+	}
+	bits := qr.version<<12 | remainder
+
+	for i := 0; i < 18; i++ {
+		bit := qrBit(bits, i)
+		a := qr.size - 11 + i%3
+		b := i / 3
+		qr.setFunctionModule(a, b, bit)
+		qr.setFunctionModule(b, a, bit)
+	}
+}
+
+func (qr *qrCode) drawCodewords(data []byte) {
+	i := 0
+	for right := qr.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+
+		for vertical := 0; vertical < qr.size; vertical++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				upward := (right+1)&2 == 0
+				y := vertical
+				if upward {
+					y = qr.size - 1 - vertical
+				}
+
+				if !qr.isFunction[y][x] && i < len(data)*8 {
+					qr.modules[y][x] = qrBit(int(data[i>>3]), 7-(i&7))
+					i++
+				}
+			}
+		}
+	}
+}
+
+func (qr *qrCode) applyMask(mask int) {
+	for y := 0; y < qr.size; y++ {
+		for x := 0; x < qr.size; x++ {
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+
+			if invert && !qr.isFunction[y][x] {
+				qr.modules[y][x] = !qr.modules[y][x]
+			}
+		}
+	}
+}
+
+func (qr *qrCode) penaltyScore() int {
+	result := 0
+
+	line := make([]bool, qr.size)
+	for y := 0; y < qr.size; y++ {
+		for x := 0; x < qr.size; x++ {
+			line[x] = qr.modules[y][x]
+		}
+		result += qrLinePenalty(line)
+	}
+
+	for x := 0; x < qr.size; x++ {
+		for y := 0; y < qr.size; y++ {
+			line[y] = qr.modules[y][x]
+		}
+		result += qrLinePenalty(line)
+	}
+
+	dark := 0
+	for y := 0; y < qr.size; y++ {
+		for x := 0; x < qr.size; x++ {
+			if qr.modules[y][x] {
+				dark++
+			}
+
+			if x+1 < qr.size && y+1 < qr.size {
+				color := qr.modules[y][x]
+				if color == qr.modules[y][x+1] && color == qr.modules[y+1][x] && color == qr.modules[y+1][x+1] {
+					result += qrPenaltyN2
+				}
+			}
+		}
+	}
+
+	total := qr.size * qr.size
+	k := (absInt(dark*20-total*10)+total-1)/total - 1
+	result += k * qrPenaltyN4
+
+	return result
+}
+
+// qrLinePenalty scores a single row or column for long runs of the same color and finder-like patterns.
+func qrLinePenalty(line []bool) int {
+	result := 0
+
+	run := 1
+	for i := 1; i <= len(line); i++ {
+		if i < len(line) && line[i] == line[i-1] {
+			run++
+			continue
+		}
+
+		if run >= 5 {
+			result += qrPenaltyN1 + run - 5
+		}
+		run = 1
+	}
+
+	finderLike := []bool{true, false, true, true, true, false, true}
+	for i := 0; i+len(finderLike) <= len(line); i++ {
+		matched := true
+		for j, dark := range finderLike {
+			if line[i+j] != dark {
+				matched = false
+				break
+			}
+		}
+
+		if !matched {
+			continue
+		}
+
+		if qrIsLightRun(line, i-4, i) || qrIsLightRun(line, i+len(finderLike), i+len(finderLike)+4) {
+			result += qrPenaltyN3
+		}
+	}
+
+	return result
+}
+
+// qrIsLightRun reports whether every module in [from, to) is light, treating modules outside the symbol as light.
+func qrIsLightRun(line []bool, from int, to int) bool {
+	for i := from; i < to; i++ {
+		if i >= 0 && i < len(line) && line[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func qrFormatBits(mask int) int {
+	data := qrFormatBitsM<<3 | mask
+	remainder := data
+	for i := 0; i < 10; i++ {
+		remainder = (remainder << 1) ^ ((remainder >> 9) * 0x537)
+	}
+
+	return (data<<10 | remainder) ^ 0x5412
+}
+
+func qrBit(value int, index int) bool {
+	return (value>>uint(index))&1 != 0
+}
+
+func absInt(value int) int {
+	if value < 0 {
+		return -value
+	}
+
+	return value
+}
+
+func maxInt(a int, b int) int {
+	if a > b {
+		return a
+	}
+
+	return b
+}
@@ -0,0 +1,92 @@
+package helpers
+
+import (
+	"bytes"
+	"testing"
+)
+
+func Test_qrFormatBits(t *testing.T) {
+	type args struct {
+		mask int
+	}
+	tests := []struct {
+		name string
+		args args
+		want int
+	}{
+		{name: "success_level_m_mask_0", args: args{mask: 0}, want: 0b101010000010010},
+		{name: "success_level_m_mask_1", args: args{mask: 1}, want: 0b101000100100101},
+		{name: "success_level_m_mask_2", args: args{mask: 2}, want: 0b101111001111100},
+		{name: "success_level_m_mask_3", args: args{mask: 3}, want: 0b101101101001011},
+		{name: "success_level_m_mask_4", args: args{mask: 4}, want: 0b100010111111001},
+		{name: "success_level_m_mask_5", args: args{mask: 5}, want: 0b100000011001110},
+		{name: "success_level_m_mask_6", args: args{mask: 6}, want: 0b100111110010111},
+		{name: "success_level_m_mask_7", args: args{mask: 7}, want: 0b100101010100000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := qrFormatBits(tt.args.mask); got != tt.want {
+				t.Errorf("qrFormatBits() = %015b, want %015b", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_qrReedSolomonRemainder(t *testing.T) {
+	type args struct {
+		data   []byte
+		degree int
+	}
+	tests := []struct {
+		name string
+		args args
+		want []byte
+	}{
+		{
+			name: "success_hello_world_version_1_m",
+			args: args{
+				data:   []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17},
+				degree: 10,
+			},
+			want: []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := qrReedSolomonRemainder(tt.args.data, qrReedSolomonDivisor(tt.args.degree))
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("qrReedSolomonRemainder() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateQRCodePNG(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{
+			name: "success_otpauth_uri",
+			data: "otpauth://totp/Apollo:public-user%40gmail.com?algorithm=SHA1&digits=6&issuer=Apollo&period=30&secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ",
+		},
+		{
+			name:    "failed_data_too_long",
+			data:    string(make([]byte, 300)),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := GenerateQRCodePNG(tt.data, 4)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GenerateQRCodePNG() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !tt.wantErr && !bytes.HasPrefix(got, []byte("\x89PNG")) {
+				t.Errorf("GenerateQRCodePNG() did not return a png image")
+			}
+		})
+	}
+}
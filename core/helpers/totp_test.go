@@ -0,0 +1,112 @@
+package helpers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGenerateTOTPCode(t *testing.T) {
+	// RFC 6238 appendix B vectors for SHA1, truncated to six digits
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+	type args struct {
+		unixTime int64
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{
+			name: "success_time_59",
+			args: args{unixTime: 59},
+			want: "287082",
+		},
+		{
+			name: "success_time_1111111109",
+			args: args{unixTime: 1111111109},
+			want: "081804",
+		},
+		{
+			name: "success_time_1111111111",
+			args: args{unixTime: 1111111111},
+			want: "050471",
+		},
+		{
+			name: "success_time_1234567890",
+			args: args{unixTime: 1234567890},
+			want: "005924",
+		},
+		{
+			name: "success_time_2000000000",
+			args: args{unixTime: 2000000000},
+			want: "279037",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := GenerateTOTPCode(secret, time.Unix(tt.args.unixTime, 0))
+			if err != nil {
+				t.Fatalf("GenerateTOTPCode() error = %v", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("GenerateTOTPCode() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateTOTPCode(t *testing.T) {
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	now := time.Unix(1111111111, 0)
+
+	type args struct {
+		code string
+	}
+	tests := []struct {
+		name  string
+		args  args
+		want  bool
+		wantS int64
+	}{
+		{
+			name:  "success_current_step",
+			args:  args{code: "050471"},
+			want:  true,
+			wantS: TOTPTimeStep(now),
+		},
+		{
+			name:  "success_previous_step",
+			args:  args{code: "081804"},
+			want:  true,
+			wantS: TOTPTimeStep(now) - 1,
+		},
+		{
+			name: "failed_wrong_code",
+			args: args{code: "123456"},
+			want: false,
+		},
+		{
+			name: "failed_wrong_length",
+			args: args{code: "0504"},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, got, err := ValidateTOTPCode(secret, tt.args.code, now)
+			if err != nil {
+				t.Fatalf("ValidateTOTPCode() error = %v", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("ValidateTOTPCode() = %v, want %v", got, tt.want)
+			}
+
+			if got && step != tt.wantS {
+				t.Errorf("ValidateTOTPCode() step = %v, want %v", step, tt.wantS)
+			}
+		})
+	}
+}
@@ -3,6 +3,9 @@ package helpers
 import (
 	"bytes"
 	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -81,6 +84,27 @@ func GetUserIDFromContext(ctx *fiber.Ctx) (id int64, err error) {
 	return id, nil
 }
 
+func GetSessionIDFromContext(ctx *fiber.Ctx) (sessionID string, err error) {
+	if localSessionID, ok := ctx.Locals("sid").(string); ok && localSessionID != "" {
+		sessionID = localSessionID
+	} else {
+		return "", errors.New("no session id")
+	}
+
+	return sessionID, nil
+}
+
+// GetClaimsFromContext returns the claims of the access token that authenticated the request.
+func GetClaimsFromContext(ctx *fiber.Ctx) (claims *JWTClaims, err error) {
+	if localClaims, ok := ctx.Locals("claims").(*JWTClaims); ok {
+		claims = localClaims
+	} else {
+		return nil, errors.New("no token claims")
+	}
+
+	return claims, nil
+}
+
 func FormatUnixTime(unixTime int64) *time.Time {
 	if unixTime == 0 {
 		return nil
@@ -125,6 +149,27 @@ func GenerateOTP(length int) (res *string, err error) {
 	return &otp, nil
 }
 
+// GenerateRandomToken returns a URL-safe random token built from length random bytes.
+func GenerateRandomToken(length int) (string, error) {
+	if length < 16 {
+		length = 16
+	}
+
+	buffer := make([]byte, length)
+	_, err := rand.Read(buffer)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(buffer), nil
+}
+
+// HashToken returns the hex encoded SHA-256 digest of token, used to store tokens without keeping them in plaintext.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
 func FormatDuration(duration time.Duration) string {
 	if duration < time.Second {
 		return fmt.Sprintf("%d nanoseconds", duration.Nanoseconds())
@@ -138,7 +183,11 @@ func FormatDuration(duration time.Duration) string {
 		return fmt.Sprintf("%.0f minutes", duration.Minutes())
 	}
 
-	return fmt.Sprintf("%.0f hours", duration.Hours())
+	if duration < 24*time.Hour {
+		return fmt.Sprintf("%.0f hours", duration.Hours())
+	}
+
+	return fmt.Sprintf("%.0f days", duration.Hours()/24)
 }
 
 func IsEmailValid(email string) bool {
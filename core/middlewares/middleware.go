@@ -1,31 +1,63 @@
 package middlewares
 
 import (
+	"crypto/subtle"
 	"encoding/json"
 	"errors"
 	"github.com/gofiber/fiber/v2"
+	"github.com/winartodev/apollo/core"
+	"github.com/winartodev/apollo/core/configs"
 	"github.com/winartodev/apollo/core/helpers"
 	"github.com/winartodev/apollo/core/responses"
+	apiKeyController "github.com/winartodev/apollo/modules/apikey/controllers"
+	apiKeyEntity "github.com/winartodev/apollo/modules/apikey/entities"
+	roleController "github.com/winartodev/apollo/modules/role/controllers"
+	sessionController "github.com/winartodev/apollo/modules/session/controllers"
 	userController "github.com/winartodev/apollo/modules/user/controllers"
+	userEntity "github.com/winartodev/apollo/modules/user/entities"
+	"os"
+	"slices"
 	"strings"
+	"time"
 )
 
 const (
 	protected = "protected"
 	internal  = "internal"
+
+	headerAPIKey = "X-API-Key"
+
+	scopeActionRead  = "read"
+	scopeActionWrite = "write"
+
+	defaultProtectedMaxAge = 15 * time.Minute
 )
 
 var (
-	errorInvalidPublicAccess   = errors.New("public resource cannot be accessed due to invalid request")
-	errorInvalidInternalAccess = errors.New("internal resource cannot be accessed due to invalid request")
-	errorInvalidToken          = errors.New("provided token is invalid or expired")
-	errorFailedInstanceJWT     = errors.New("failed to create instance JWT")
-	errorMissingToken          = errors.New("authentication token is missing or improperly formatted. Expected 'Bearer <token>'")
-	errorUserNotFound          = errors.New("user not found")
+	errorInvalidPublicAccess    = errors.New("public resource cannot be accessed due to invalid request")
+	errorInvalidInternalAccess  = errors.New("internal resource cannot be accessed due to invalid request")
+	errorInvalidToken           = errors.New("provided token is invalid or expired")
+	errorFailedInstanceJWT      = errors.New("failed to create instance JWT")
+	errorMissingToken           = errors.New("authentication token is missing or improperly formatted. Expected 'Bearer <token>'")
+	errorUserNotFound           = errors.New("user not found")
+	errorTokenRevoked           = errors.New("token has been revoked")
+	errorInvalidAPIKey          = errors.New("api key is missing or invalid")
+	errorClientToken            = errors.New("token was issued to an oauth client and can not be used for this api")
+	errorMissingPermission      = errors.New("token does not grant the permission required by this api")
+	errorMissingAPIKeyScope     = errors.New("api key does not grant the scope required by this api")
+	errorInvalidProtectedAccess = errors.New("protected resource cannot be accessed due to invalid request")
+	errorUnverifiedUser         = errors.New("email and phone number have to be verified to access this resource")
+	errorStepUpRequired         = errors.New("authentication is too old, confirm your password at the step up endpoint")
 )
 
 type Middleware struct {
-	UserController userController.UserControllerItf
+	UserController    userController.UserControllerItf
+	SessionController sessionController.SessionControllerItf
+	RoleController    roleController.RoleControllerItf
+	APIKeyController  apiKeyController.APIKeyControllerItf
+	Protected         *configs.Protected
+	OTP               *configs.OTP
+	RateLimiter       *RateLimiter
 }
 
 func (m *Middleware) HandlePublicAccess() fiber.Handler {
@@ -42,16 +74,71 @@ func (m *Middleware) HandlePublicAccess() fiber.Handler {
 				return responses.FailedResponse(c, fiber.StatusUnauthorized, "Unauthorized", err)
 			}
 
+			if claim.ClientID != "" {
+				return responses.FailedResponse(c, fiber.StatusUnauthorized, "Unauthorized", errorClientToken)
+			}
+
+			err = m.verifyNotRevoked(c, claim)
+			if err != nil {
+				return responses.FailedResponse(c, fiber.StatusUnauthorized, "Unauthorized", err)
+			}
+
 			c.Locals("id", claim.ID)
 			c.Locals("username", claim.Username)
 			c.Locals("email", claim.Email)
+			c.Locals("sid", claim.SessionID)
+			c.Locals("claims", claim)
 		}
 
 		return c.Next()
 	}
 }
 
+// HandleInternalAccess guards internal routes of signed in users and service clients. A service token needs the
+// scope of the route, see routeScope, tokens issued to oauth clients acting for a user are turned away.
 func (m *Middleware) HandleInternalAccess() fiber.Handler {
+	return m.handleInternalAccess(func(c *fiber.Ctx, claim *helpers.JWTClaims) error {
+		if claim.IsServiceToken() {
+			return authorizeScopes(claim, []string{routeScope(c)})
+		}
+
+		if claim.ClientID != "" {
+			return errorClientToken
+		}
+
+		return nil
+	})
+}
+
+// RequirePermission guards a route with permissions. Users hold the permissions of their roles and service clients
+// of the client_credentials grant those of their scope. Behind HandleInternalAccess it reuses the verified token.
+// Requests without a bearer token may send an api key holding the permissions as scopes instead.
+func (m *Middleware) RequirePermission(permissions ...string) fiber.Handler {
+	authorize := m.authorizePermissions(permissions)
+	guard := m.handleInternalAccess(authorize)
+	apiKeyGuard := m.RequireAPIKey(permissions...)
+
+	return func(c *fiber.Ctx) error {
+		claim, ok := c.Locals("claims").(*helpers.JWTClaims)
+		if !ok {
+			var token string
+			if !isAuthHeaderExists(c, &token) && c.Get(headerAPIKey) != "" {
+				return apiKeyGuard(c)
+			}
+
+			return guard(c)
+		}
+
+		err := authorize(c, claim)
+		if err != nil {
+			return authorizationFailed(c, err)
+		}
+
+		return c.Next()
+	}
+}
+
+func (m *Middleware) handleInternalAccess(authorize func(c *fiber.Ctx, claim *helpers.JWTClaims) error) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		access := getAccessFromPath(c)
 		if access != internal {
@@ -65,6 +152,28 @@ func (m *Middleware) HandleInternalAccess() fiber.Handler {
 				return responses.FailedResponse(c, fiber.StatusUnauthorized, "Unauthorized", err)
 			}
 
+			err = m.verifyNotRevoked(c, claim)
+			if err != nil {
+				return responses.FailedResponse(c, fiber.StatusUnauthorized, "Unauthorized", err)
+			}
+
+			// every token is issued either to a user or to a service client
+			if claim.ID == 0 && !claim.IsServiceToken() {
+				return responses.FailedResponse(c, fiber.StatusUnauthorized, "Unauthorized", errorInvalidToken)
+			}
+
+			err = authorize(c, claim)
+			if err != nil {
+				return authorizationFailed(c, err)
+			}
+
+			// a service token acts as its client, there is no user behind it
+			if claim.IsServiceToken() {
+				c.Locals("client_id", claim.ClientID)
+				c.Locals("claims", claim)
+				return c.Next()
+			}
+
 			context := c.Context()
 			user, err := m.UserController.GetUserByID(context, claim.ID)
 			if err != nil {
@@ -75,9 +184,15 @@ func (m *Middleware) HandleInternalAccess() fiber.Handler {
 				return responses.FailedResponse(c, fiber.StatusUnauthorized, "Unauthorized", errorUserNotFound)
 			}
 
+			if user.IsSuspended {
+				return responses.FailedResponse(c, fiber.StatusForbidden, "Access Denied", userController.ErrorUserSuspended)
+			}
+
 			c.Locals("id", claim.ID)
 			c.Locals("username", claim.Username)
 			c.Locals("email", claim.Email)
+			c.Locals("sid", claim.SessionID)
+			c.Locals("claims", claim)
 		} else {
 			return responses.FailedResponse(c, fiber.StatusForbidden, "Access Denied", errorMissingToken)
 		}
@@ -86,6 +201,273 @@ func (m *Middleware) HandleInternalAccess() fiber.Handler {
 	}
 }
 
+// HandleProtectedAccess guards sensitive routes of signed in users. Besides a valid user token it requires a session
+// that signed in or stepped up within the configured max age and, while otp verification is enabled, a verified
+// email and phone number.
+func (m *Middleware) HandleProtectedAccess() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		access := getAccessFromPath(c)
+		if access != protected {
+			return responses.FailedResponse(c, fiber.StatusForbidden, "Access Denied", errorInvalidProtectedAccess)
+		}
+
+		var token string
+		if !isAuthHeaderExists(c, &token) {
+			return responses.FailedResponse(c, fiber.StatusForbidden, "Access Denied", errorMissingToken)
+		}
+
+		claim, err := verifyAuthHeader(token)
+		if err != nil {
+			return responses.FailedResponse(c, fiber.StatusUnauthorized, "Unauthorized", err)
+		}
+
+		if claim.ClientID != "" {
+			return responses.FailedResponse(c, fiber.StatusForbidden, "Access Denied", errorClientToken)
+		}
+
+		err = m.verifyNotRevoked(c, claim)
+		if err != nil {
+			return responses.FailedResponse(c, fiber.StatusUnauthorized, "Unauthorized", err)
+		}
+
+		context := c.Context()
+		user, err := m.UserController.GetUserByID(context, claim.ID)
+		if err != nil {
+			return responses.FailedResponse(c, fiber.StatusUnauthorized, "Unauthorized", err)
+		}
+
+		if user == nil {
+			return responses.FailedResponse(c, fiber.StatusUnauthorized, "Unauthorized", errorUserNotFound)
+		}
+
+		if user.IsSuspended {
+			return responses.FailedResponse(c, fiber.StatusForbidden, "Access Denied", userController.ErrorUserSuspended)
+		}
+
+		if !m.isVerified(user) {
+			return responses.FailedResponse(c, fiber.StatusForbidden, "Access Denied", errorUnverifiedUser)
+		}
+
+		session, err := m.SessionController.GetSession(context, claim.SessionID)
+		if errors.Is(err, sessionController.ErrorSessionNotFound) {
+			return responses.FailedResponse(c, fiber.StatusUnauthorized, "Unauthorized", err)
+		}
+
+		if err != nil {
+			return responses.FailedResponse(c, fiber.StatusInternalServerError, "Internal Server Error", err)
+		}
+
+		if session.IsRevoked {
+			return responses.FailedResponse(c, fiber.StatusUnauthorized, "Unauthorized", errorTokenRevoked)
+		}
+
+		if session.AuthenticatedAt == nil || time.Since(*session.AuthenticatedAt) > m.getProtectedMaxAge() {
+			return responses.FailedResponse(c, fiber.StatusUnauthorized, "Step Up Required", errorStepUpRequired)
+		}
+
+		c.Locals("id", claim.ID)
+		c.Locals("username", claim.Username)
+		c.Locals("email", claim.Email)
+		c.Locals("sid", claim.SessionID)
+		c.Locals("claims", claim)
+
+		return c.Next()
+	}
+}
+
+// isVerified tells whether the user verified the email and phone number they have. Without otp verification nobody
+// can verify them, and users created through an identity provider may have no phone number at all.
+func (m *Middleware) isVerified(user *userEntity.User) bool {
+	if m.OTP == nil || !m.OTP.Enable {
+		return true
+	}
+
+	if user.Email != "" && !user.IsEmailVerified {
+		return false
+	}
+
+	if user.PhoneNumber != "" && !user.IsPhoneVerified {
+		return false
+	}
+
+	return true
+}
+
+// HandleAdminAccess guards internal administration routes with the Apollo API key sent in the X-API-Key header, or
+// with an api key holding the admin scope.
+func (m *Middleware) HandleAdminAccess() fiber.Handler {
+	apiKeyGuard := m.RequireAPIKey(core.ScopeAdmin)
+
+	return func(c *fiber.Ctx) error {
+		access := getAccessFromPath(c)
+		if access != internal {
+			return responses.FailedResponse(c, fiber.StatusForbidden, "Access Denied", errorInvalidInternalAccess)
+		}
+
+		apiKey := os.Getenv(core.ApolloAPIKey)
+		providedKey := c.Get(headerAPIKey)
+		if apiKey != "" && subtle.ConstantTimeCompare([]byte(apiKey), []byte(providedKey)) == 1 {
+			c.Locals("admin_key", true)
+			return c.Next()
+		}
+
+		return apiKeyGuard(c)
+	}
+}
+
+// RequireAPIKey guards internal routes of backend consumers with an api key sent in the X-API-Key header, the key
+// needs every one of scopes. A key that was already checked for the request is not looked up again.
+func (m *Middleware) RequireAPIKey(scopes ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		access := getAccessFromPath(c)
+		if access != internal {
+			return responses.FailedResponse(c, fiber.StatusForbidden, "Access Denied", errorInvalidInternalAccess)
+		}
+
+		apiKey, ok := c.Locals("api_key").(*apiKeyEntity.APIKey)
+		if !ok {
+			providedKey := c.Get(headerAPIKey)
+			if providedKey == "" || m.APIKeyController == nil {
+				return responses.FailedResponse(c, fiber.StatusUnauthorized, "Unauthorized", errorInvalidAPIKey)
+			}
+
+			var err error
+			apiKey, err = m.APIKeyController.Authenticate(c.Context(), providedKey)
+			if errors.Is(err, apiKeyController.ErrorInvalidAPIKey) || errors.Is(err, apiKeyController.ErrorAPIKeyRevoked) || errors.Is(err, apiKeyController.ErrorAPIKeyExpired) {
+				return responses.FailedResponse(c, fiber.StatusUnauthorized, "Unauthorized", err)
+			}
+
+			if err != nil {
+				return responses.FailedResponse(c, fiber.StatusInternalServerError, "Internal Server Error", err)
+			}
+
+			c.Locals("api_key", apiKey)
+			c.Locals("api_key_id", apiKey.ID)
+		}
+
+		for _, scope := range scopes {
+			if !slices.Contains(apiKey.Scopes, scope) {
+				return responses.FailedResponse(c, fiber.StatusForbidden, "Access Denied", errorMissingAPIKeyScope)
+			}
+		}
+
+		return c.Next()
+	}
+}
+
+// VerifyAccessToken checks a user token the way the route guards do, for endpoints that authenticate requests on
+// behalf of another app. Tokens issued to oauth clients are rejected.
+func (m *Middleware) VerifyAccessToken(c *fiber.Ctx, token string) (*helpers.JWTClaims, error) {
+	claim, err := verifyAuthHeader(token)
+	if err != nil {
+		return nil, err
+	}
+
+	if claim.ClientID != "" {
+		return nil, errorClientToken
+	}
+
+	err = m.verifyNotRevoked(c, claim)
+	if err != nil {
+		return nil, err
+	}
+
+	return claim, nil
+}
+
+// verifyNotRevoked rejects access tokens that were revoked before they expired, either on their own, through
+// their session or by a cutoff for every token of the user.
+func (m *Middleware) verifyNotRevoked(c *fiber.Ctx, claim *helpers.JWTClaims) error {
+	if m.SessionController == nil {
+		return nil
+	}
+
+	revoked, err := m.SessionController.IsAccessTokenRevoked(c.Context(), claim)
+	if err != nil {
+		return err
+	}
+
+	if revoked {
+		return errorTokenRevoked
+	}
+
+	return nil
+}
+
+// authorizePermissions checks the permissions of a user against its roles and of a service client against its
+// scope. Tokens of oauth clients acting for a user only serve the userinfo endpoint.
+func (m *Middleware) authorizePermissions(permissions []string) func(c *fiber.Ctx, claim *helpers.JWTClaims) error {
+	return func(c *fiber.Ctx, claim *helpers.JWTClaims) error {
+		if claim.IsServiceToken() {
+			return authorizeScopes(claim, permissions)
+		}
+
+		if claim.ClientID != "" {
+			return errorClientToken
+		}
+
+		if m.RoleController == nil {
+			return errorMissingPermission
+		}
+
+		ok, err := m.RoleController.HasPermissions(c.Context(), claim.Roles, permissions)
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			return errorMissingPermission
+		}
+
+		return nil
+	}
+}
+
+// authorizeScopes checks that the scope of a service token grants every permission.
+func authorizeScopes(claim *helpers.JWTClaims, permissions []string) error {
+	granted := strings.Fields(claim.Scope)
+	for _, permission := range permissions {
+		if !slices.Contains(granted, permission) {
+			return errorMissingPermission
+		}
+	}
+
+	return nil
+}
+
+// routeScope is the scope a service token needs for an internal route: the resource is the path segment after
+// the access tier and the action read for GET and HEAD requests, write otherwise. GET /api/v1/internal/users/1
+// needs users:read.
+func routeScope(c *fiber.Ctx) string {
+	var resource string
+	if paths := strings.Split(c.Path(), "/"); len(paths) > 4 {
+		resource = paths[4]
+	}
+
+	action := scopeActionWrite
+	if c.Method() == fiber.MethodGet || c.Method() == fiber.MethodHead {
+		action = scopeActionRead
+	}
+
+	return resource + ":" + action
+}
+
+func (m *Middleware) getProtectedMaxAge() time.Duration {
+	if m.Protected == nil || m.Protected.MaxAge <= 0 {
+		return defaultProtectedMaxAge
+	}
+
+	return time.Duration(m.Protected.MaxAge) * time.Minute
+}
+
+func authorizationFailed(c *fiber.Ctx, err error) error {
+	if errors.Is(err, errorClientToken) || errors.Is(err, errorMissingPermission) {
+		return responses.FailedResponse(c, fiber.StatusForbidden, "Access Denied", err)
+	}
+
+	return responses.FailedResponse(c, fiber.StatusInternalServerError, "Internal Server Error", err)
+}
+
 func isAuthHeaderExists(c *fiber.Ctx, token *string) bool {
 	authHeader := c.Get("Authorization")
 
@@ -112,7 +494,7 @@ func verifyAuthHeader(token string) (res *helpers.JWTClaims, err error) {
 		return nil, errorFailedInstanceJWT
 	}
 
-	claims, isValid, err := jwt.VerifyToken(jwt.AccessToken.SecretKey, token)
+	claims, isValid, err := jwt.VerifyToken(helpers.TokenKindAccess, token)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,436 @@
+package middlewares
+
+import (
+	"context"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gofiber/fiber/v2"
+	"github.com/winartodev/apollo/core"
+	"github.com/winartodev/apollo/core/configs"
+	"github.com/winartodev/apollo/core/helpers"
+	apiKeyController "github.com/winartodev/apollo/modules/apikey/controllers"
+	apiKeyEntity "github.com/winartodev/apollo/modules/apikey/entities"
+	roleController "github.com/winartodev/apollo/modules/role/controllers"
+	sessionController "github.com/winartodev/apollo/modules/session/controllers"
+	sessionEntity "github.com/winartodev/apollo/modules/session/entities"
+	userController "github.com/winartodev/apollo/modules/user/controllers"
+	userEntity "github.com/winartodev/apollo/modules/user/entities"
+)
+
+type fakeUserController struct {
+	userController.UserControllerItf
+	user *userEntity.User
+}
+
+func (f *fakeUserController) GetUserByID(ctx context.Context, id int64) (res *userEntity.User, err error) {
+	return f.user, nil
+}
+
+type fakeSessionController struct {
+	sessionController.SessionControllerItf
+	session *sessionEntity.Session
+}
+
+func (f *fakeSessionController) IsAccessTokenRevoked(ctx context.Context, claims *helpers.JWTClaims) (revoked bool, err error) {
+	return false, nil
+}
+
+func (f *fakeSessionController) GetSession(ctx context.Context, sessionID string) (res *sessionEntity.Session, err error) {
+	if f.session == nil || f.session.ID != sessionID {
+		return nil, sessionController.ErrorSessionNotFound
+	}
+
+	return f.session, nil
+}
+
+type fakeRoleController struct {
+	roleController.RoleControllerItf
+	permissions []string
+}
+
+func (f *fakeRoleController) HasPermissions(ctx context.Context, roles []string, permissions []string) (ok bool, err error) {
+	for _, permission := range permissions {
+		if len(roles) == 0 || !slices.Contains(f.permissions, permission) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+type fakeAPIKeyController struct {
+	apiKeyController.APIKeyControllerItf
+	keys map[string]*apiKeyEntity.APIKey
+}
+
+func (f *fakeAPIKeyController) Authenticate(ctx context.Context, key string) (res *apiKeyEntity.APIKey, err error) {
+	res, ok := f.keys[key]
+	if !ok {
+		return nil, apiKeyController.ErrorInvalidAPIKey
+	}
+
+	return res, nil
+}
+
+func TestMiddleware_AccessTiers(t *testing.T) {
+	t.Setenv(core.JwtAccessTokenSecretKey, "access-secret")
+	t.Setenv(core.JwtRefreshTokenSecretKey, "refresh-secret")
+
+	j, err := helpers.NewJWT()
+	if err != nil {
+		t.Fatalf("NewJWT() error = %v", err)
+	}
+
+	const sessionID = "session-1"
+
+	verifiedUser := &userEntity.User{ID: 1, Username: "apollo", Email: "apollo@gmail.com", IsEmailVerified: true, IsPhoneVerified: true, Roles: []string{"internal"}}
+	unverifiedUser := &userEntity.User{ID: 1, Username: "apollo", Email: "apollo@gmail.com", PhoneNumber: "+6281234567890", IsEmailVerified: true}
+	identityProviderUser := &userEntity.User{ID: 1, Username: "apollo", Email: "apollo@gmail.com", IsEmailVerified: true}
+
+	userToken, err := j.GenerateToken(verifiedUser, sessionID)
+	if err != nil {
+		t.Fatalf("GenerateToken() error = %v", err)
+	}
+
+	clientToken, err := j.GenerateClientAccessToken(verifiedUser, sessionID, "wiki", "openid")
+	if err != nil {
+		t.Fatalf("GenerateClientAccessToken() error = %v", err)
+	}
+
+	serviceToken, err := j.GenerateServiceToken("billing", core.PermissionUsersRead)
+	if err != nil {
+		t.Fatalf("GenerateServiceToken() error = %v", err)
+	}
+
+	revokerToken, err := j.GenerateServiceToken("gateway", core.PermissionSessionsRevoke)
+	if err != nil {
+		t.Fatalf("GenerateServiceToken() error = %v", err)
+	}
+
+	// signed with the access secret but not typed as an access token, like an id token
+	untypedToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, helpers.JWTClaims{
+		SessionID:      sessionID,
+		StandardClaims: jwt.StandardClaims{ExpiresAt: time.Now().Add(time.Minute).Unix()},
+	}).SignedString([]byte("access-secret"))
+	if err != nil {
+		t.Fatalf("SignedString() error = %v", err)
+	}
+
+	// typed as an access token but neither issued to a user nor to a service client
+	anonymousToken := jwt.NewWithClaims(jwt.SigningMethodHS256, helpers.JWTClaims{
+		SessionID:      sessionID,
+		Scope:          core.PermissionUsersRead,
+		StandardClaims: jwt.StandardClaims{ExpiresAt: time.Now().Add(time.Minute).Unix()},
+	})
+	anonymousToken.Header["typ"] = helpers.AccessTokenType
+	anonymousTokenString, err := anonymousToken.SignedString([]byte("access-secret"))
+	if err != nil {
+		t.Fatalf("SignedString() error = %v", err)
+	}
+
+	recently := time.Now().Add(-time.Minute)
+	longAgo := time.Now().Add(-time.Hour)
+
+	tests := []struct {
+		name            string
+		path            string
+		token           string
+		user            *userEntity.User
+		authenticatedAt *time.Time
+		permissions     []string
+		otpEnabled      bool
+		wantStatus      int
+	}{
+		{
+			name:       "success_public_without_token",
+			path:       "/api/v1/users/auth",
+			wantStatus: fiber.StatusOK,
+		},
+		{
+			name:       "success_public_with_user_token",
+			path:       "/api/v1/users/auth",
+			token:      userToken.AccessToken,
+			wantStatus: fiber.StatusOK,
+		},
+		{
+			name:       "failed_public_with_client_token",
+			path:       "/api/v1/users/auth",
+			token:      clientToken,
+			wantStatus: fiber.StatusUnauthorized,
+		},
+		{
+			name:       "failed_public_on_protected_path",
+			path:       "/api/v1/protected/public",
+			wantStatus: fiber.StatusForbidden,
+		},
+		{
+			name:       "success_internal_with_user_token",
+			path:       "/api/v1/internal/users/me",
+			token:      userToken.AccessToken,
+			user:       verifiedUser,
+			wantStatus: fiber.StatusOK,
+		},
+		{
+			name:       "failed_internal_without_token",
+			path:       "/api/v1/internal/users/me",
+			wantStatus: fiber.StatusForbidden,
+		},
+		{
+			name:       "failed_internal_with_client_token",
+			path:       "/api/v1/internal/users/me",
+			token:      clientToken,
+			user:       verifiedUser,
+			wantStatus: fiber.StatusForbidden,
+		},
+		{
+			name:       "success_internal_with_service_token_scope",
+			path:       "/api/v1/internal/users/me",
+			token:      serviceToken,
+			wantStatus: fiber.StatusOK,
+		},
+		{
+			name:       "failed_internal_with_service_token_missing_scope",
+			path:       "/api/v1/internal/users/me",
+			token:      revokerToken,
+			wantStatus: fiber.StatusForbidden,
+		},
+		{
+			name:       "failed_internal_with_untyped_token",
+			path:       "/api/v1/internal/users/me",
+			token:      untypedToken,
+			user:       verifiedUser,
+			wantStatus: fiber.StatusUnauthorized,
+		},
+		{
+			name:       "failed_internal_with_token_without_user_or_client",
+			path:       "/api/v1/internal/users/2",
+			token:      anonymousTokenString,
+			wantStatus: fiber.StatusUnauthorized,
+		},
+		{
+			name:        "success_permission_granted_by_role",
+			path:        "/api/v1/internal/users/2",
+			token:       userToken.AccessToken,
+			user:        verifiedUser,
+			permissions: []string{core.PermissionUsersRead},
+			wantStatus:  fiber.StatusOK,
+		},
+		{
+			name:       "success_permission_granted_by_scope",
+			path:       "/api/v1/internal/users/2",
+			token:      serviceToken,
+			wantStatus: fiber.StatusOK,
+		},
+		{
+			name:       "failed_permission_missing",
+			path:       "/api/v1/internal/users/2",
+			token:      userToken.AccessToken,
+			user:       verifiedUser,
+			wantStatus: fiber.StatusForbidden,
+		},
+		{
+			name:       "failed_permission_scope_missing",
+			path:       "/api/v1/internal/users/2",
+			token:      revokerToken,
+			wantStatus: fiber.StatusForbidden,
+		},
+		{
+			name:            "success_protected_recently_authenticated",
+			path:            "/api/v1/protected/users/auth/password",
+			token:           userToken.AccessToken,
+			user:            verifiedUser,
+			authenticatedAt: &recently,
+			wantStatus:      fiber.StatusOK,
+		},
+		{
+			name:            "failed_protected_step_up_required",
+			path:            "/api/v1/protected/users/auth/password",
+			token:           userToken.AccessToken,
+			user:            verifiedUser,
+			authenticatedAt: &longAgo,
+			wantStatus:      fiber.StatusUnauthorized,
+		},
+		{
+			name:            "failed_protected_unverified_phone",
+			path:            "/api/v1/protected/users/auth/password",
+			token:           userToken.AccessToken,
+			user:            unverifiedUser,
+			authenticatedAt: &recently,
+			otpEnabled:      true,
+			wantStatus:      fiber.StatusForbidden,
+		},
+		{
+			name:            "success_protected_unverified_with_otp_disabled",
+			path:            "/api/v1/protected/users/auth/password",
+			token:           userToken.AccessToken,
+			user:            unverifiedUser,
+			authenticatedAt: &recently,
+			wantStatus:      fiber.StatusOK,
+		},
+		{
+			name:            "success_protected_user_without_phone_number",
+			path:            "/api/v1/protected/users/auth/password",
+			token:           userToken.AccessToken,
+			user:            identityProviderUser,
+			authenticatedAt: &recently,
+			otpEnabled:      true,
+			wantStatus:      fiber.StatusOK,
+		},
+		{
+			name:            "failed_protected_with_service_token",
+			path:            "/api/v1/protected/users/auth/password",
+			token:           serviceToken,
+			authenticatedAt: &recently,
+			wantStatus:      fiber.StatusForbidden,
+		},
+		{
+			name:            "failed_protected_on_internal_path",
+			path:            "/api/v1/internal/password",
+			token:           userToken.AccessToken,
+			user:            verifiedUser,
+			authenticatedAt: &recently,
+			wantStatus:      fiber.StatusForbidden,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := Middleware{
+				UserController: &fakeUserController{user: tt.user},
+				SessionController: &fakeSessionController{session: &sessionEntity.Session{
+					ID:              sessionID,
+					AuthenticatedAt: tt.authenticatedAt,
+				}},
+				RoleController: &fakeRoleController{permissions: tt.permissions},
+				Protected:      &configs.Protected{MaxAge: 15},
+				OTP:            &configs.OTP{Enable: tt.otpEnabled},
+			}
+
+			ok := func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) }
+
+			app := fiber.New()
+			app.Get("/api/v1/users/auth", m.HandlePublicAccess(), ok)
+			app.Get("/api/v1/protected/public", m.HandlePublicAccess(), ok)
+			app.Get("/api/v1/internal/users/me", m.HandleInternalAccess(), ok)
+			app.Get("/api/v1/internal/users/:id", m.RequirePermission(core.PermissionUsersRead), ok)
+			app.Get("/api/v1/protected/users/auth/password", m.HandleProtectedAccess(), ok)
+			app.Get("/api/v1/internal/password", m.HandleProtectedAccess(), ok)
+
+			req := httptest.NewRequest(fiber.MethodGet, tt.path, nil)
+			if tt.token != "" {
+				req.Header.Set(fiber.HeaderAuthorization, "Bearer "+tt.token)
+			}
+
+			res, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("app.Test() error = %v", err)
+			}
+
+			if res.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", res.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestMiddleware_APIKeyAccess(t *testing.T) {
+	t.Setenv(core.ApolloAPIKey, "global-key")
+
+	m := Middleware{
+		APIKeyController: &fakeAPIKeyController{keys: map[string]*apiKeyEntity.APIKey{
+			"admin-key":  {ID: 1, Scopes: []string{core.ScopeAdmin}},
+			"reader-key": {ID: 2, Scopes: []string{core.PermissionUsersRead}},
+		}},
+	}
+
+	ok := func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) }
+
+	app := fiber.New()
+	app.Post("/api/v1/internal/admin/users/1/suspend", m.HandleAdminAccess(), ok)
+	app.Get("/api/v1/internal/users/:id", m.RequirePermission(core.PermissionUsersRead), ok)
+	app.Get("/api/v1/users/1", m.RequireAPIKey(core.PermissionUsersRead), ok)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		apiKey     string
+		wantStatus int
+	}{
+		{
+			name:       "success_admin_with_global_key",
+			method:     fiber.MethodPost,
+			path:       "/api/v1/internal/admin/users/1/suspend",
+			apiKey:     "global-key",
+			wantStatus: fiber.StatusOK,
+		},
+		{
+			name:       "success_admin_with_admin_scope",
+			method:     fiber.MethodPost,
+			path:       "/api/v1/internal/admin/users/1/suspend",
+			apiKey:     "admin-key",
+			wantStatus: fiber.StatusOK,
+		},
+		{
+			name:       "failed_admin_without_admin_scope",
+			method:     fiber.MethodPost,
+			path:       "/api/v1/internal/admin/users/1/suspend",
+			apiKey:     "reader-key",
+			wantStatus: fiber.StatusForbidden,
+		},
+		{
+			name:       "failed_admin_with_unknown_key",
+			method:     fiber.MethodPost,
+			path:       "/api/v1/internal/admin/users/1/suspend",
+			apiKey:     "unknown-key",
+			wantStatus: fiber.StatusUnauthorized,
+		},
+		{
+			name:       "failed_admin_without_key",
+			method:     fiber.MethodPost,
+			path:       "/api/v1/internal/admin/users/1/suspend",
+			wantStatus: fiber.StatusUnauthorized,
+		},
+		{
+			name:       "success_permission_granted_by_api_key",
+			method:     fiber.MethodGet,
+			path:       "/api/v1/internal/users/2",
+			apiKey:     "reader-key",
+			wantStatus: fiber.StatusOK,
+		},
+		{
+			name:       "failed_permission_missing_in_api_key",
+			method:     fiber.MethodGet,
+			path:       "/api/v1/internal/users/2",
+			apiKey:     "admin-key",
+			wantStatus: fiber.StatusForbidden,
+		},
+		{
+			name:       "failed_api_key_on_public_path",
+			method:     fiber.MethodGet,
+			path:       "/api/v1/users/1",
+			apiKey:     "reader-key",
+			wantStatus: fiber.StatusForbidden,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.apiKey != "" {
+				req.Header.Set(headerAPIKey, tt.apiKey)
+			}
+
+			res, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("app.Test() error = %v", err)
+			}
+
+			if res.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", res.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+}
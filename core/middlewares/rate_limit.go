@@ -0,0 +1,212 @@
+package middlewares
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/google/uuid"
+	"github.com/winartodev/apollo/core/configs"
+	"github.com/winartodev/apollo/core/helpers"
+	"github.com/winartodev/apollo/core/responses"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	rateLimitKey = "rate_limit:%s:%d:%s:%s"
+
+	headerRateLimitLimit     = "X-RateLimit-Limit"
+	headerRateLimitRemaining = "X-RateLimit-Remaining"
+	headerRateLimitReset     = "X-RateLimit-Reset"
+)
+
+var (
+	errorRateLimitExceeded = errors.New("too many requests, please try again later")
+)
+
+// slidingWindowScript counts the requests of the last window in a sorted set scored by their time in milliseconds.
+// The request is only added when it is allowed, so rejected requests do not extend the wait.
+// It returns whether the request is allowed, the number of counted requests and when the oldest one leaves the window.
+var slidingWindowScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+
+redis.call('ZREMRANGEBYSCORE', KEYS[1], 0, now - window)
+
+local count = redis.call('ZCARD', KEYS[1])
+local allowed = 0
+if count < limit then
+	redis.call('ZADD', KEYS[1], now, ARGV[4])
+	count = count + 1
+	allowed = 1
+end
+
+redis.call('PEXPIRE', KEYS[1], window)
+
+local reset = now + window
+local oldest = redis.call('ZRANGE', KEYS[1], 0, 0, 'WITHSCORES')
+if oldest[2] then
+	reset = tonumber(oldest[2]) + window
+end
+
+return {allowed, count, reset}
+`)
+
+type RateLimiter struct {
+	Redis     redis.Scripter
+	RateLimit *configs.RateLimit
+}
+
+type rateLimitResult struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	ResetAt   time.Time
+}
+
+func NewRateLimiter(limiter RateLimiter) *RateLimiter {
+	return &RateLimiter{
+		Redis:     limiter.Redis,
+		RateLimit: limiter.RateLimit,
+	}
+}
+
+// HandleRateLimit enforces the named policy from the rate limit configuration and reports the most restrictive
+// rule in the X-RateLimit-* headers. Routes whose policy is not configured are not limited.
+func (m *Middleware) HandleRateLimit(policyName string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if m.RateLimiter == nil {
+			return c.Next()
+		}
+
+		policy, ok := m.RateLimiter.RateLimit.GetPolicy(policyName)
+		if !ok {
+			return c.Next()
+		}
+
+		result, err := m.RateLimiter.allow(c.Context(), policyName, policy, c)
+		if err != nil {
+			log.Errorf("failed to check rate limit %s: %v", policyName, err)
+			return responses.FailedResponse(c, fiber.StatusInternalServerError, "Failed to check rate limit", err)
+		}
+
+		c.Set(headerRateLimitLimit, strconv.Itoa(result.Limit))
+		c.Set(headerRateLimitRemaining, strconv.Itoa(result.Remaining))
+		c.Set(headerRateLimitReset, strconv.FormatInt(result.ResetAt.Unix(), 10))
+
+		if !result.Allowed {
+			retryAfter := int64(time.Until(result.ResetAt).Seconds()) + 1
+			c.Set(fiber.HeaderRetryAfter, strconv.FormatInt(retryAfter, 10))
+			return responses.FailedResponse(c, fiber.StatusTooManyRequests, "Too Many Requests", errorRateLimitExceeded)
+		}
+
+		return c.Next()
+	}
+}
+
+// allow checks every rule of the policy. Once a rule rejects the request the remaining rules are not counted.
+func (r *RateLimiter) allow(ctx context.Context, policyName string, policy configs.RateLimitPolicy, c *fiber.Ctx) (res *rateLimitResult, err error) {
+	now := time.Now()
+	member := uuid.New().String()
+
+	for i, rule := range policy.Rules {
+		if rule.Limit <= 0 || rule.Window <= 0 {
+			continue
+		}
+
+		keyBy, value := getRateLimitKeyValue(c, rule.KeyBy)
+		key := fmt.Sprintf(rateLimitKey, policyName, i, keyBy, helpers.HashToken(value))
+		window := time.Duration(rule.Window) * time.Second
+
+		values, err := slidingWindowScript.Run(ctx, r.Redis, []string{key}, now.UnixMilli(), window.Milliseconds(), rule.Limit, member).Int64Slice()
+		if err != nil {
+			return nil, err
+		}
+
+		result := &rateLimitResult{
+			Allowed:   values[0] == 1,
+			Limit:     rule.Limit,
+			Remaining: rule.Limit - int(values[1]),
+			ResetAt:   time.UnixMilli(values[2]),
+		}
+
+		if res == nil || result.Remaining < res.Remaining {
+			res = result
+		}
+
+		if !result.Allowed {
+			return result, nil
+		}
+	}
+
+	if res == nil {
+		res = &rateLimitResult{Allowed: true}
+	}
+
+	return res, nil
+}
+
+// getRateLimitKeyValue resolves the value a rule is keyed on. Requests without the field, or anonymous requests
+// for a user rule, fall back to the ip address so they cannot bypass the limit.
+func getRateLimitKeyValue(c *fiber.Ctx, keyBy string) (string, string) {
+	value := strings.ToLower(strings.TrimSpace(getRateLimitFieldValue(c, keyBy)))
+	if value == "" {
+		return configs.RateLimitKeyIP, c.IP()
+	}
+
+	return keyBy, value
+}
+
+func getRateLimitFieldValue(c *fiber.Ctx, keyBy string) (value string) {
+	source, name, _ := strings.Cut(keyBy, ":")
+
+	switch source {
+	case configs.RateLimitKeyUser:
+		if id, err := helpers.GetUserIDFromContext(c); err == nil {
+			value = strconv.FormatInt(id, 10)
+		}
+	case configs.RateLimitKeyQuery:
+		value = c.Query(name)
+	case configs.RateLimitKeyHeader:
+		value = c.Get(name)
+	case configs.RateLimitKeyBody:
+		value = getRateLimitBodyValue(c, name)
+	case configs.RateLimitKeyPhone:
+		value = getRateLimitFieldValue(c, name)
+		if phone, err := helpers.FormatIndonesianPhoneNumber(value); err == nil {
+			value = phone
+		}
+	}
+
+	return value
+}
+
+// getRateLimitBodyValue reads a field of a form encoded, multipart or JSON body, so the limit applies whichever
+// content type the client sends.
+func getRateLimitBodyValue(c *fiber.Ctx, name string) (value string) {
+	contentType := strings.ToLower(string(c.Request().Header.ContentType()))
+
+	switch {
+	case strings.HasPrefix(contentType, fiber.MIMEApplicationForm):
+		value = string(c.Request().PostArgs().Peek(name))
+	case strings.HasPrefix(contentType, fiber.MIMEMultipartForm):
+		if form, err := c.MultipartForm(); err == nil && len(form.Value[name]) > 0 {
+			value = form.Value[name][0]
+		}
+	default:
+		var body map[string]interface{}
+		if err := json.Unmarshal(c.Body(), &body); err == nil {
+			if field, ok := body[name]; ok && field != nil {
+				value = fmt.Sprint(field)
+			}
+		}
+	}
+
+	return value
+}
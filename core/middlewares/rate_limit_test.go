@@ -0,0 +1,197 @@
+package middlewares
+
+import (
+	"context"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/gofiber/fiber/v2"
+	"github.com/winartodev/apollo/core/configs"
+	"github.com/winartodev/apollo/core/helpers"
+)
+
+// fakeScripter runs the sliding window in memory, elapsed moves its clock forward.
+type fakeScripter struct {
+	redis.Scripter
+	elapsed time.Duration
+	windows map[string][]int64
+}
+
+func (f *fakeScripter) EvalSha(ctx context.Context, sha1 string, keys []string, args ...interface{}) *redis.Cmd {
+	now := args[0].(int64) + f.elapsed.Milliseconds()
+	window := args[1].(int64)
+	limit := args[2].(int)
+
+	var requests []int64
+	for _, request := range f.windows[keys[0]] {
+		if request > now-window {
+			requests = append(requests, request)
+		}
+	}
+
+	allowed := int64(0)
+	if len(requests) < limit {
+		requests = append(requests, now)
+		allowed = 1
+	}
+	sort.Slice(requests, func(i, j int) bool { return requests[i] < requests[j] })
+	f.windows[keys[0]] = requests
+
+	reset := requests[0] + window - f.elapsed.Milliseconds()
+	return redis.NewCmdResult([]interface{}{allowed, int64(len(requests)), reset}, nil)
+}
+
+func TestMiddleware_HandleRateLimit(t *testing.T) {
+	type request struct {
+		phone   string
+		elapsed time.Duration
+	}
+
+	tests := []struct {
+		name          string
+		requests      []request
+		wantStatus    []int
+		wantRemaining string
+	}{
+		{
+			name:          "success_counts_requests",
+			requests:      []request{{phone: "0812345678"}, {phone: "0812345678"}},
+			wantStatus:    []int{fiber.StatusOK, fiber.StatusOK},
+			wantRemaining: "0",
+		},
+		{
+			name:          "success_other_numbers_are_counted_apart",
+			requests:      []request{{phone: "0812345678"}, {phone: "0812345678"}, {phone: "0898765432"}},
+			wantStatus:    []int{fiber.StatusOK, fiber.StatusOK, fiber.StatusOK},
+			wantRemaining: "1",
+		},
+		{
+			name:          "success_window_reset",
+			requests:      []request{{phone: "0812345678"}, {phone: "0812345678"}, {phone: "0812345678", elapsed: 61 * time.Second}},
+			wantStatus:    []int{fiber.StatusOK, fiber.StatusOK, fiber.StatusOK},
+			wantRemaining: "1",
+		},
+		{
+			name:          "failed_phone_number_variants_share_limit",
+			requests:      []request{{phone: "0812345678"}, {phone: "+62812345678"}, {phone: "0812-345-678"}},
+			wantStatus:    []int{fiber.StatusOK, fiber.StatusOK, fiber.StatusTooManyRequests},
+			wantRemaining: "0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scripter := &fakeScripter{windows: map[string][]int64{}}
+			m := &Middleware{
+				RateLimiter: NewRateLimiter(RateLimiter{
+					Redis: scripter,
+					RateLimit: &configs.RateLimit{
+						Enable: true,
+						Policies: map[string]configs.RateLimitPolicy{
+							"otpPhone": {Rules: []configs.RateLimitRule{{Limit: 2, Window: 60, KeyBy: "phone:query:phone"}}},
+						},
+					},
+				}),
+			}
+
+			app := fiber.New()
+			app.Get("/otp", m.HandleRateLimit("otpPhone"), func(c *fiber.Ctx) error {
+				return c.SendStatus(fiber.StatusOK)
+			})
+
+			for i, req := range tt.requests {
+				scripter.elapsed = req.elapsed
+
+				resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/otp?phone="+url.QueryEscape(req.phone), nil))
+				if err != nil {
+					t.Fatalf("app.Test() error = %v", err)
+				}
+
+				if resp.StatusCode != tt.wantStatus[i] {
+					t.Fatalf("request %d status = %v, want %v", i, resp.StatusCode, tt.wantStatus[i])
+				}
+
+				retryAfter := resp.Header.Get(fiber.HeaderRetryAfter)
+				if resp.StatusCode == fiber.StatusTooManyRequests {
+					seconds, err := strconv.Atoi(retryAfter)
+					if err != nil || seconds < 1 || seconds > 61 {
+						t.Errorf("request %d Retry-After = %q, want the seconds until the window frees up", i, retryAfter)
+					}
+				} else if retryAfter != "" {
+					t.Errorf("request %d Retry-After = %q, want none", i, retryAfter)
+				}
+
+				if i == len(tt.requests)-1 && resp.Header.Get(headerRateLimitRemaining) != tt.wantRemaining {
+					t.Errorf("X-RateLimit-Remaining = %v, want %v", resp.Header.Get(headerRateLimitRemaining), tt.wantRemaining)
+				}
+			}
+		})
+	}
+}
+
+func TestMiddleware_HandleRateLimit_Body(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+	}{
+		{
+			name:        "success_json_body",
+			contentType: fiber.MIMEApplicationJSON,
+			body:        `{"email":"Apollo@Gmail.com"}`,
+		},
+		{
+			name:        "success_form_body",
+			contentType: fiber.MIMEApplicationForm,
+			body:        "email=Apollo%40Gmail.com",
+		},
+		{
+			name:        "success_multipart_body",
+			contentType: fiber.MIMEMultipartForm + "; boundary=apollo",
+			body:        "--apollo\r\nContent-Disposition: form-data; name=\"email\"\r\n\r\nApollo@Gmail.com\r\n--apollo--\r\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scripter := &fakeScripter{windows: map[string][]int64{}}
+			m := &Middleware{
+				RateLimiter: NewRateLimiter(RateLimiter{
+					Redis: scripter,
+					RateLimit: &configs.RateLimit{
+						Enable: true,
+						Policies: map[string]configs.RateLimitPolicy{
+							"magicLink": {Rules: []configs.RateLimitRule{{Limit: 1, Window: 60, KeyBy: "body:email"}}},
+						},
+					},
+				}),
+			}
+
+			app := fiber.New()
+			app.Post("/magic-link", m.HandleRateLimit("magicLink"), func(c *fiber.Ctx) error {
+				return c.SendStatus(fiber.StatusOK)
+			})
+
+			req := httptest.NewRequest(fiber.MethodPost, "/magic-link", strings.NewReader(tt.body))
+			req.Header.Set(fiber.HeaderContentType, tt.contentType)
+
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("app.Test() error = %v", err)
+			}
+
+			if resp.StatusCode != fiber.StatusOK {
+				t.Fatalf("status = %v, want %v", resp.StatusCode, fiber.StatusOK)
+			}
+
+			wantKey := "rate_limit:magicLink:0:body:email:" + helpers.HashToken("apollo@gmail.com")
+			if _, ok := scripter.windows[wantKey]; !ok || len(scripter.windows) != 1 {
+				t.Errorf("rate limit keys = %v, want %v", scripter.windows, wantKey)
+			}
+		})
+	}
+}
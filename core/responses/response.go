@@ -5,6 +5,7 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/winartodev/apollo/core"
 	"github.com/winartodev/apollo/core/helpers"
+	"strings"
 )
 
 const (
@@ -60,7 +61,12 @@ func generateLink(link string, page int64, limit int64) string {
 		return ""
 	}
 
-	return fmt.Sprintf("%s?page=%d&limit=%d", link, page, limit)
+	separator := "?"
+	if strings.Contains(link, "?") {
+		separator = "&"
+	}
+
+	return fmt.Sprintf("%s%spage=%d&limit=%d", link, separator, page, limit)
 }
 
 func BuildPaginate(totalItems int64, link string, paginate *helpers.Paginate) *PaginateResponse {
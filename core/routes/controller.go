@@ -2,28 +2,66 @@ package routes
 
 import (
 	"github.com/winartodev/apollo/core/configs"
+	"github.com/winartodev/apollo/core/helpers"
+	apiKeyController "github.com/winartodev/apollo/modules/apikey/controllers"
+	auditController "github.com/winartodev/apollo/modules/audit/controllers"
 	authController "github.com/winartodev/apollo/modules/auth/controllers"
+	forwardAuthController "github.com/winartodev/apollo/modules/forwardauth/controllers"
+	oauthController "github.com/winartodev/apollo/modules/oauth/controllers"
+	"github.com/winartodev/apollo/modules/oauth/providers"
+	roleController "github.com/winartodev/apollo/modules/role/controllers"
+	sessionController "github.com/winartodev/apollo/modules/session/controllers"
 	userController "github.com/winartodev/apollo/modules/user/controllers"
+	webAuthnController "github.com/winartodev/apollo/modules/webauthn/controllers"
 )
 
 type ControllerDependency struct {
-	OTP        *configs.OTP
-	SMTPClient *configs.SMTPClient
-	Twilio     *configs.TwilioClient
-	Repository *Repository
+	OTP            *configs.OTP
+	Auth           *configs.Auth
+	SMTPClient     *configs.SMTPClient
+	Twilio         *configs.TwilioClient
+	GeoIP          *helpers.MMDBReader
+	OAuthProviders map[string]providers.ProviderItf
+	Repository     *Repository
 }
 
 type Controller struct {
 	UserController         userController.UserControllerItf
 	VerificationController authController.VerificationControllerItf
 	AuthController         authController.AuthControllerItf
+	MFAController          authController.MFAControllerItf
+	OIDCController         authController.OIDCControllerItf
+	SessionController      sessionController.SessionControllerItf
+	OAuthController        oauthController.OAuthControllerItf
+	ForwardAuthController  forwardAuthController.ForwardAuthControllerItf
+	RoleController         roleController.RoleControllerItf
+	APIKeyController       apiKeyController.APIKeyControllerItf
+	WebAuthnController     webAuthnController.WebAuthnControllerItf
+	AuditController        auditController.AuditControllerItf
 }
 
 func NewController(dependency ControllerDependency) *Controller {
 	repository := dependency.Repository
 
+	newAuditController := auditController.NewAuditController(auditController.AuditController{
+		AuditRepository: repository.AuditRepository,
+	})
+
+	newSessionController := sessionController.NewSessionController(sessionController.SessionController{
+		SessionRepository: repository.SessionRepository,
+		AuditController:   newAuditController,
+	})
+
 	newUserController := userController.NewUserController(userController.UserController{
-		UserRepository: repository.UserRepository,
+		UserRepository:    repository.UserRepository,
+		SessionController: newSessionController,
+		AuditController:   newAuditController,
+	})
+
+	newRoleController := roleController.NewRoleController(roleController.RoleController{
+		RoleRepository:    repository.RoleRepository,
+		SessionController: newSessionController,
+		UserController:    newUserController,
 	})
 
 	newVerificationController := authController.NewVerificationController(authController.VerificationController{
@@ -31,17 +69,95 @@ func NewController(dependency ControllerDependency) *Controller {
 		SmtpClient:             dependency.SMTPClient,
 		TwilioClient:           dependency.Twilio,
 		VerificationRepository: repository.VerificationRepository,
+		AuditController:        newAuditController,
 	})
 
-	newAuthController := authController.NewAuthController(authController.AuthController{
+	newMFAController := authController.NewMFAController(authController.MFAController{
+		MFA:            &dependency.Auth.MFA,
+		MFARepository:  repository.MFARepository,
+		UserController: newUserController,
+	})
+
+	newLockoutController := authController.NewLockoutController(authController.LockoutController{
+		Lockout:           &dependency.Auth.Lockout,
+		LockoutRepository: repository.LockoutRepository,
+	})
+
+	newLoginAlertController := authController.NewLoginAlertController(authController.LoginAlertController{
+		LoginAlert:             &dependency.Auth.LoginAlert,
+		GeoIP:                  dependency.GeoIP,
+		VerificationController: newVerificationController,
+		UserController:         newUserController,
+		AuditController:        newAuditController,
+	})
+
+	authControllerDependency := authController.AuthController{
 		OTP:                    dependency.OTP,
+		PasswordReset:          &dependency.Auth.PasswordReset,
+		MagicLink:              &dependency.Auth.MagicLink,
 		VerificationController: newVerificationController,
+		MFAController:          newMFAController,
+		LockoutController:      newLockoutController,
+		LoginAlertController:   newLoginAlertController,
+		SessionController:      newSessionController,
 		UserController:         newUserController,
+		RoleController:         newRoleController,
+		AuditController:        newAuditController,
+	}
+
+	// The webauthn and oauth controllers sign users in through the auth controller, which in turn removes their
+	// passkeys and identities when a sign in is denied. They get an auth controller built without them.
+	signInController := authController.NewAuthController(authControllerDependency)
+
+	newOAuthController := oauthController.NewOAuthController(oauthController.OAuthController{
+		OAuth:             &dependency.Auth.OAuth,
+		Providers:         dependency.OAuthProviders,
+		OAuthRepository:   repository.OAuthRepository,
+		AuthController:    signInController,
+		SessionController: newSessionController,
+		UserController:    newUserController,
+	})
+
+	newWebAuthnController := webAuthnController.NewWebAuthnController(webAuthnController.WebAuthnController{
+		WebAuthn:           &dependency.Auth.WebAuthn,
+		WebAuthnRepository: repository.WebAuthnRepository,
+		UserController:     newUserController,
+		AuthController:     signInController,
+	})
+
+	authControllerDependency.WebAuthnController = newWebAuthnController
+	authControllerDependency.OAuthController = newOAuthController
+	newAuthController := authController.NewAuthController(authControllerDependency)
+
+	newOIDCController := authController.NewOIDCController(authController.OIDCController{
+		OIDC:                  &dependency.Auth.OIDC,
+		OAuthClientRepository: repository.OAuthClientRepository,
+		AuthController:        newAuthController,
+		SessionController:     newSessionController,
+		UserController:        newUserController,
+	})
+
+	newForwardAuthController := forwardAuthController.NewForwardAuthController(forwardAuthController.ForwardAuthController{
+		ForwardAuth:           &dependency.Auth.ForwardAuth,
+		ForwardAuthRepository: repository.ForwardAuthRepository,
+		UserController:        newUserController,
+	})
+	newAPIKeyController := apiKeyController.NewAPIKeyController(apiKeyController.APIKeyController{
+		APIKeyRepository: repository.APIKeyRepository,
 	})
 
 	return &Controller{
 		UserController:         newUserController,
 		VerificationController: newVerificationController,
 		AuthController:         newAuthController,
+		MFAController:          newMFAController,
+		OIDCController:         newOIDCController,
+		SessionController:      newSessionController,
+		OAuthController:        newOAuthController,
+		ForwardAuthController:  newForwardAuthController,
+		RoleController:         newRoleController,
+		APIKeyController:       newAPIKeyController,
+		WebAuthnController:     newWebAuthnController,
+		AuditController:        newAuditController,
 	}
 }
@@ -2,44 +2,110 @@ package routes
 
 import (
 	"fmt"
+	"github.com/go-redis/redis/v8"
 	"github.com/gofiber/fiber/v2"
 	"github.com/winartodev/apollo/core"
+	"github.com/winartodev/apollo/core/configs"
 	"github.com/winartodev/apollo/core/middlewares"
+	apiKeyHandler "github.com/winartodev/apollo/modules/apikey/handlers"
+	auditHandler "github.com/winartodev/apollo/modules/audit/handlers"
 	authHandler "github.com/winartodev/apollo/modules/auth/handlers"
+	forwardAuthHandler "github.com/winartodev/apollo/modules/forwardauth/handlers"
+	oauthHandler "github.com/winartodev/apollo/modules/oauth/handlers"
+	roleHandler "github.com/winartodev/apollo/modules/role/handlers"
 	userHandler "github.com/winartodev/apollo/modules/user/handlers"
+	webAuthnHandler "github.com/winartodev/apollo/modules/webauthn/handlers"
 	"time"
 )
 
 type HandlerDependency struct {
 	Controller *Controller
+	Redis      *redis.Client
+	RateLimit  *configs.RateLimit
+	Protected  *configs.Protected
+	OTP        *configs.OTP
 }
 
 type Handler struct {
-	AuthHandler authHandler.AuthHandler
-	UserHandler userHandler.UserHandler
+	AuthHandler        authHandler.AuthHandler
+	OIDCHandler        authHandler.OIDCHandler
+	UserHandler        userHandler.UserHandler
+	OAuthHandler       oauthHandler.OAuthHandler
+	ForwardAuthHandler forwardAuthHandler.ForwardAuthHandler
+	RoleHandler        roleHandler.RoleHandler
+	APIKeyHandler      apiKeyHandler.APIKeyHandler
+	WebAuthnHandler    webAuthnHandler.WebAuthnHandler
+	AuditHandler       auditHandler.AuditHandler
 }
 
 func NewHandler(dependency HandlerDependency) *Handler {
 	controller := dependency.Controller
 
 	middleware := middlewares.Middleware{
-		UserController: controller.UserController,
+		UserController:    controller.UserController,
+		SessionController: controller.SessionController,
+		RoleController:    controller.RoleController,
+		APIKeyController:  controller.APIKeyController,
+		Protected:         dependency.Protected,
+		OTP:               dependency.OTP,
+		RateLimiter: middlewares.NewRateLimiter(middlewares.RateLimiter{
+			Redis:     dependency.Redis,
+			RateLimit: dependency.RateLimit,
+		}),
 	}
 
 	newAuthHandler := authHandler.NewAuthHandler(authHandler.AuthHandler{
 		Middleware:             middleware,
 		VerificationController: controller.VerificationController,
 		AuthController:         controller.AuthController,
+		MFAController:          controller.MFAController,
+	})
+
+	newOIDCHandler := authHandler.NewOIDCHandler(authHandler.OIDCHandler{
+		Middleware:     middleware,
+		OIDCController: controller.OIDCController,
 	})
 
 	newUserHandler := userHandler.NewUserHandler(userHandler.UserHandler{
+		Middleware:        middleware,
+		UserController:    controller.UserController,
+		SessionController: controller.SessionController,
+	})
+	newOAuthHandler := oauthHandler.NewOAuthHandler(oauthHandler.OAuthHandler{
+		Middleware:      middleware,
+		OAuthController: controller.OAuthController,
+	})
+	newForwardAuthHandler := forwardAuthHandler.NewForwardAuthHandler(forwardAuthHandler.ForwardAuthHandler{
+		Middleware:            middleware,
+		ForwardAuthController: controller.ForwardAuthController,
+	})
+	newRoleHandler := roleHandler.NewRoleHandler(roleHandler.RoleHandler{
 		Middleware:     middleware,
-		UserController: controller.UserController,
+		RoleController: controller.RoleController,
+	})
+	newAPIKeyHandler := apiKeyHandler.NewAPIKeyHandler(apiKeyHandler.APIKeyHandler{
+		Middleware:       middleware,
+		APIKeyController: controller.APIKeyController,
+	})
+	newWebAuthnHandler := webAuthnHandler.NewWebAuthnHandler(webAuthnHandler.WebAuthnHandler{
+		Middleware:         middleware,
+		WebAuthnController: controller.WebAuthnController,
+	})
+	newAuditHandler := auditHandler.NewAuditHandler(auditHandler.AuditHandler{
+		Middleware:      middleware,
+		AuditController: controller.AuditController,
 	})
 
 	return &Handler{
-		AuthHandler: newAuthHandler,
-		UserHandler: newUserHandler,
+		AuthHandler:        newAuthHandler,
+		OIDCHandler:        newOIDCHandler,
+		UserHandler:        newUserHandler,
+		OAuthHandler:       newOAuthHandler,
+		ForwardAuthHandler: newForwardAuthHandler,
+		RoleHandler:        newRoleHandler,
+		APIKeyHandler:      newAPIKeyHandler,
+		WebAuthnHandler:    newWebAuthnHandler,
+		AuditHandler:       newAuditHandler,
 	}
 }
 
@@ -50,7 +116,14 @@ type RegisterHandlerItf interface {
 func GetRegisters(handler *Handler) []RegisterHandlerItf {
 	return []RegisterHandlerItf{
 		&handler.AuthHandler,
+		&handler.OIDCHandler,
 		&handler.UserHandler,
+		&handler.OAuthHandler,
+		&handler.ForwardAuthHandler,
+		&handler.RoleHandler,
+		&handler.APIKeyHandler,
+		&handler.WebAuthnHandler,
+		&handler.AuditHandler,
 	}
 }
 
@@ -70,5 +143,11 @@ func RegisterHandler(router fiber.Router, handler *Handler) error {
 
 	api.Get("/healthz", healthHandler.HealthZ)
 
+	jwksHandler := JWKSHandler{}
+
+	router.Get("/.well-known/jwks.json", jwksHandler.JWKS)
+
+	handler.OIDCHandler.RegisterProvider(router)
+
 	return nil
 }
@@ -0,0 +1,20 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/winartodev/apollo/core/helpers"
+)
+
+const (
+	jwksCacheControl = "public, max-age=300"
+)
+
+type JWKSHandler struct{}
+
+// JWKS publishes the public signing keys in the RFC 7517 format, so other services can verify access tokens
+// without knowing any secret.
+func (h *JWKSHandler) JWKS(ctx *fiber.Ctx) error {
+	ctx.Set(fiber.HeaderCacheControl, jwksCacheControl)
+
+	return ctx.Status(fiber.StatusOK).JSON(helpers.GetJWKS())
+}
@@ -3,8 +3,15 @@ package routes
 import (
 	"database/sql"
 	"github.com/go-redis/redis/v8"
+	apiKeyRepo "github.com/winartodev/apollo/modules/apikey/repositories"
+	auditRepo "github.com/winartodev/apollo/modules/audit/repositories"
 	authRepo "github.com/winartodev/apollo/modules/auth/repositories"
+	forwardAuthRepo "github.com/winartodev/apollo/modules/forwardauth/repositories"
+	oauthRepo "github.com/winartodev/apollo/modules/oauth/repositories"
+	roleRepo "github.com/winartodev/apollo/modules/role/repositories"
+	sessionRepo "github.com/winartodev/apollo/modules/session/repositories"
 	userRepo "github.com/winartodev/apollo/modules/user/repositories"
+	webAuthnRepo "github.com/winartodev/apollo/modules/webauthn/repositories"
 )
 
 type RepositoryDependency struct {
@@ -15,6 +22,16 @@ type RepositoryDependency struct {
 type Repository struct {
 	UserRepository         userRepo.UserRepositoryItf
 	VerificationRepository authRepo.VerificationRepositoryItf
+	MFARepository          authRepo.MFARepositoryItf
+	LockoutRepository      authRepo.LockoutRepositoryItf
+	OAuthClientRepository  authRepo.OAuthClientRepositoryItf
+	SessionRepository      sessionRepo.SessionRepositoryItf
+	OAuthRepository        oauthRepo.OAuthRepositoryItf
+	ForwardAuthRepository  forwardAuthRepo.ForwardAuthRepositoryItf
+	RoleRepository         roleRepo.RoleRepositoryItf
+	APIKeyRepository       apiKeyRepo.APIKeyRepositoryItf
+	WebAuthnRepository     webAuthnRepo.WebAuthnRepositoryItf
+	AuditRepository        auditRepo.AuditRepositoryItf
 }
 
 func NewRepository(dependency RepositoryDependency) *Repository {
@@ -22,9 +39,54 @@ func NewRepository(dependency RepositoryDependency) *Repository {
 		Redis: dependency.Redis,
 	})
 	newUserRepository := userRepo.NewUserRepository(dependency.DB)
+	newMFARepository := authRepo.NewMFARepository(authRepo.MFARepository{
+		DB:    dependency.DB,
+		Redis: dependency.Redis,
+	})
+	newLockoutRepository := authRepo.NewLockoutRepository(authRepo.LockoutRepository{
+		Redis: dependency.Redis,
+	})
+	newOAuthClientRepository := authRepo.NewOAuthClientRepository(authRepo.OAuthClientRepository{
+		DB:    dependency.DB,
+		Redis: dependency.Redis,
+	})
+	newSessionRepository := sessionRepo.NewSessionRepository(sessionRepo.SessionRepository{
+		DB:    dependency.DB,
+		Redis: dependency.Redis,
+	})
+	newOAuthRepository := oauthRepo.NewOAuthRepository(oauthRepo.OAuthRepository{
+		DB:    dependency.DB,
+		Redis: dependency.Redis,
+	})
+	newForwardAuthRepository := forwardAuthRepo.NewForwardAuthRepository(forwardAuthRepo.ForwardAuthRepository{
+		Redis: dependency.Redis,
+	})
+	newRoleRepository := roleRepo.NewRoleRepository(roleRepo.RoleRepository{
+		DB: dependency.DB,
+	})
+	newAPIKeyRepository := apiKeyRepo.NewAPIKeyRepository(apiKeyRepo.APIKeyRepository{
+		DB: dependency.DB,
+	})
+	newWebAuthnRepository := webAuthnRepo.NewWebAuthnRepository(webAuthnRepo.WebAuthnRepository{
+		DB:    dependency.DB,
+		Redis: dependency.Redis,
+	})
+	newAuditRepository := auditRepo.NewAuditRepository(auditRepo.AuditRepository{
+		DB: dependency.DB,
+	})
 
 	return &Repository{
 		VerificationRepository: newVerificationRepo,
 		UserRepository:         newUserRepository,
+		MFARepository:          newMFARepository,
+		LockoutRepository:      newLockoutRepository,
+		OAuthClientRepository:  newOAuthClientRepository,
+		SessionRepository:      newSessionRepository,
+		OAuthRepository:        newOAuthRepository,
+		ForwardAuthRepository:  newForwardAuthRepository,
+		RoleRepository:         newRoleRepository,
+		APIKeyRepository:       newAPIKeyRepository,
+		WebAuthnRepository:     newWebAuthnRepository,
+		AuditRepository:        newAuditRepository,
 	}
 }
@@ -0,0 +1,267 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/winartodev/apollo/core"
+	"github.com/winartodev/apollo/core/helpers"
+	apiKeyEntity "github.com/winartodev/apollo/modules/apikey/entities"
+	apiKeyRepo "github.com/winartodev/apollo/modules/apikey/repositories"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	apiKeyIdentifier    = "apollo"
+	apiKeyPrefixLength  = 6
+	apiKeySecretLength  = 32
+	maxAPIKeyNameLength = 100
+
+	defaultAPIKeyLifetimeDays = 90
+	maxAPIKeyLifetimeDays     = 365
+
+	// lastUsedInterval limits how often the last use of a key is written
+	lastUsedInterval = time.Minute
+)
+
+var (
+	ErrorAPIKeyNotFound      = errors.New("api key not found")
+	ErrorAPIKeyRevoked       = errors.New("api key has been revoked")
+	ErrorAPIKeyExpired       = errors.New("api key has expired")
+	ErrorInvalidAPIKey       = errors.New("api key is missing or invalid")
+	ErrorInvalidAPIKeyName   = errors.New("api key name is required and must not exceed 100 characters")
+	ErrorInvalidAPIKeyScope  = errors.New("api keys need at least one scope and only supported scopes")
+	ErrorInvalidAPIKeyExpiry = errors.New("api key expiry must be between 1 and 365 days")
+
+	supportedScopes = []string{core.ScopeAdmin, core.PermissionUsersRead}
+)
+
+type APIKeyControllerItf interface {
+	CreateAPIKey(ctx context.Context, data *apiKeyEntity.CreateAPIKeyRequest) (res *apiKeyEntity.APIKeyCredentials, err error)
+	GetAPIKeys(ctx context.Context) (res []apiKeyEntity.APIKey, err error)
+	RotateAPIKey(ctx context.Context, id int64) (res *apiKeyEntity.APIKeyCredentials, err error)
+	RevokeAPIKey(ctx context.Context, id int64) (err error)
+	Authenticate(ctx context.Context, key string) (res *apiKeyEntity.APIKey, err error)
+}
+
+type APIKeyController struct {
+	APIKeyRepository apiKeyRepo.APIKeyRepositoryItf
+}
+
+func NewAPIKeyController(controller APIKeyController) APIKeyControllerItf {
+	return &APIKeyController{
+		APIKeyRepository: controller.APIKeyRepository,
+	}
+}
+
+// CreateAPIKey issues a key for a backend consumer. The key is only part of the response, the service keeps its hash.
+func (ac *APIKeyController) CreateAPIKey(ctx context.Context, data *apiKeyEntity.CreateAPIKeyRequest) (res *apiKeyEntity.APIKeyCredentials, err error) {
+	name := strings.TrimSpace(data.Name)
+	if name == "" || len(name) > maxAPIKeyNameLength {
+		return nil, ErrorInvalidAPIKeyName
+	}
+
+	if len(data.Scopes) == 0 {
+		return nil, ErrorInvalidAPIKeyScope
+	}
+
+	for _, scope := range data.Scopes {
+		if !slices.Contains(supportedScopes, scope) {
+			return nil, ErrorInvalidAPIKeyScope
+		}
+	}
+
+	lifetimeDays := data.ExpiresInDays
+	if lifetimeDays == 0 {
+		lifetimeDays = defaultAPIKeyLifetimeDays
+	}
+
+	if lifetimeDays < 0 || lifetimeDays > maxAPIKeyLifetimeDays {
+		return nil, ErrorInvalidAPIKeyExpiry
+	}
+
+	prefix, key, err := generateAPIKey()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	expiresAt := now.AddDate(0, 0, lifetimeDays)
+	apiKey := &apiKeyEntity.APIKey{
+		Name:      name,
+		Prefix:    prefix,
+		KeyHash:   helpers.HashToken(key),
+		Scopes:    data.Scopes,
+		ExpiresAt: &expiresAt,
+		CreatedAt: &now,
+		UpdatedAt: &now,
+	}
+
+	apiKey.ID, err = ac.APIKeyRepository.CreateAPIKeyDB(ctx, apiKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &apiKeyEntity.APIKeyCredentials{
+		APIKey: apiKey,
+		Key:    key,
+	}, nil
+}
+
+func (ac *APIKeyController) GetAPIKeys(ctx context.Context) (res []apiKeyEntity.APIKey, err error) {
+	return ac.APIKeyRepository.GetAPIKeysDB(ctx)
+}
+
+// RotateAPIKey replaces the key and keeps its name, scopes and expiry. The previous key stops working at once.
+func (ac *APIKeyController) RotateAPIKey(ctx context.Context, id int64) (res *apiKeyEntity.APIKeyCredentials, err error) {
+	apiKey, err := ac.getAPIKey(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if apiKey.IsRevoked {
+		return nil, ErrorAPIKeyRevoked
+	}
+
+	if isExpired(apiKey) {
+		return nil, ErrorAPIKeyExpired
+	}
+
+	prefix, key, err := generateAPIKey()
+	if err != nil {
+		return nil, err
+	}
+
+	updated, err := ac.APIKeyRepository.UpdateAPIKeySecretDB(ctx, apiKey.ID, prefix, helpers.HashToken(key))
+	if err != nil {
+		return nil, err
+	}
+
+	if !updated {
+		return nil, ErrorAPIKeyRevoked
+	}
+
+	now := time.Now()
+	apiKey.Prefix = prefix
+	apiKey.UpdatedAt = &now
+
+	return &apiKeyEntity.APIKeyCredentials{
+		APIKey: apiKey,
+		Key:    key,
+	}, nil
+}
+
+func (ac *APIKeyController) RevokeAPIKey(ctx context.Context, id int64) (err error) {
+	apiKey, err := ac.getAPIKey(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if apiKey.IsRevoked {
+		return ErrorAPIKeyRevoked
+	}
+
+	revoked, err := ac.APIKeyRepository.RevokeAPIKeyDB(ctx, apiKey.ID)
+	if err != nil {
+		return err
+	}
+
+	if !revoked {
+		return ErrorAPIKeyRevoked
+	}
+
+	return nil
+}
+
+// Authenticate returns the api key a request was sent with, as long as it is neither revoked nor expired, and
+// records its use.
+func (ac *APIKeyController) Authenticate(ctx context.Context, key string) (res *apiKeyEntity.APIKey, err error) {
+	prefix, ok := parseAPIKey(key)
+	if !ok {
+		return nil, ErrorInvalidAPIKey
+	}
+
+	res, err = ac.APIKeyRepository.GetAPIKeyByPrefixDB(ctx, prefix)
+	if err == sql.ErrNoRows {
+		return nil, ErrorInvalidAPIKey
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(res.KeyHash), []byte(helpers.HashToken(key))) != 1 {
+		return nil, ErrorInvalidAPIKey
+	}
+
+	if res.IsRevoked {
+		return nil, ErrorAPIKeyRevoked
+	}
+
+	if isExpired(res) {
+		return nil, ErrorAPIKeyExpired
+	}
+
+	now := time.Now()
+	if res.LastUsedAt == nil || now.Sub(*res.LastUsedAt) >= lastUsedInterval {
+		err = ac.APIKeyRepository.UpdateAPIKeyLastUsedDB(ctx, res.ID, now)
+		if err != nil {
+			return nil, err
+		}
+
+		res.LastUsedAt = &now
+	}
+
+	return res, nil
+}
+
+func (ac *APIKeyController) getAPIKey(ctx context.Context, id int64) (res *apiKeyEntity.APIKey, err error) {
+	res, err = ac.APIKeyRepository.GetAPIKeyByIDDB(ctx, id)
+	if err == sql.ErrNoRows {
+		return nil, ErrorAPIKeyNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func isExpired(apiKey *apiKeyEntity.APIKey) bool {
+	return apiKey.ExpiresAt == nil || !time.Now().Before(*apiKey.ExpiresAt)
+}
+
+// generateAPIKey returns a key of the form apollo_<prefix>_<secret>, the prefix is stored in plain text to look
+// the key up.
+func generateAPIKey() (prefix string, key string, err error) {
+	buffer := make([]byte, apiKeyPrefixLength)
+	_, err = rand.Read(buffer)
+	if err != nil {
+		return "", "", err
+	}
+
+	prefix = hex.EncodeToString(buffer)
+
+	secret, err := helpers.GenerateRandomToken(apiKeySecretLength)
+	if err != nil {
+		return "", "", err
+	}
+
+	return prefix, fmt.Sprintf("%s_%s_%s", apiKeyIdentifier, prefix, secret), nil
+}
+
+func parseAPIKey(key string) (prefix string, ok bool) {
+	parts := strings.SplitN(key, "_", 3)
+	if len(parts) != 3 || parts[0] != apiKeyIdentifier || len(parts[1]) != apiKeyPrefixLength*2 || parts[2] == "" {
+		return "", false
+	}
+
+	return parts[1], true
+}
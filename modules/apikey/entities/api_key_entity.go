@@ -0,0 +1,31 @@
+package entities
+
+import "time"
+
+// APIKey authenticates a backend consumer in the X-API-Key header. Only the hash of the key is stored, the prefix
+// is part of the key and used to look it up.
+type APIKey struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	KeyHash    string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	IsRevoked  bool       `json:"is_revoked"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	CreatedAt  *time.Time `json:"created_at,omitempty"`
+	UpdatedAt  *time.Time `json:"updated_at,omitempty"`
+}
+
+type CreateAPIKeyRequest struct {
+	Name          string   `json:"name"`
+	Scopes        []string `json:"scopes"`
+	ExpiresInDays int      `json:"expires_in_days"`
+}
+
+// APIKeyCredentials is returned once when a key is created or rotated, the key can not be read again.
+type APIKeyCredentials struct {
+	*APIKey
+	Key string `json:"key"`
+}
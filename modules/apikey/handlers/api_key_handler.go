@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/winartodev/apollo/core"
+	"github.com/winartodev/apollo/core/middlewares"
+	"github.com/winartodev/apollo/core/responses"
+	apiKeyController "github.com/winartodev/apollo/modules/apikey/controllers"
+	apiKeyEntity "github.com/winartodev/apollo/modules/apikey/entities"
+	"strconv"
+)
+
+type APIKeyHandler struct {
+	middlewares.Middleware
+	APIKeyController apiKeyController.APIKeyControllerItf
+}
+
+func NewAPIKeyHandler(handler APIKeyHandler) APIKeyHandler {
+	return APIKeyHandler{
+		Middleware:       handler.Middleware,
+		APIKeyController: handler.APIKeyController,
+	}
+}
+
+func (h *APIKeyHandler) CreateAPIKey(ctx *fiber.Ctx) error {
+	context := ctx.Context()
+
+	req := apiKeyEntity.CreateAPIKeyRequest{}
+	err := ctx.BodyParser(&req)
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusBadRequest, "Failed Create API Key", err)
+	}
+
+	res, err := h.APIKeyController.CreateAPIKey(context, &req)
+	if errors.Is(err, apiKeyController.ErrorInvalidAPIKeyName) || errors.Is(err, apiKeyController.ErrorInvalidAPIKeyScope) || errors.Is(err, apiKeyController.ErrorInvalidAPIKeyExpiry) {
+		return responses.FailedResponse(ctx, fiber.StatusBadRequest, "Failed Create API Key", err)
+	}
+
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusInternalServerError, "Failed Create API Key", err)
+	}
+
+	return responses.SuccessResponse(ctx, fiber.StatusCreated, "Success Create API Key", res, nil)
+}
+
+func (h *APIKeyHandler) GetAPIKeys(ctx *fiber.Ctx) error {
+	context := ctx.Context()
+
+	res, err := h.APIKeyController.GetAPIKeys(context)
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusInternalServerError, "Failed Get API Keys", err)
+	}
+
+	return responses.SuccessResponse(ctx, fiber.StatusOK, "Success Get API Keys", res, nil)
+}
+
+func (h *APIKeyHandler) RotateAPIKey(ctx *fiber.Ctx) error {
+	context := ctx.Context()
+
+	id, err := strconv.ParseInt(ctx.Params("id"), 10, 64)
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusBadRequest, "Failed Rotate API Key", err)
+	}
+
+	res, err := h.APIKeyController.RotateAPIKey(context, id)
+	if errors.Is(err, apiKeyController.ErrorAPIKeyNotFound) {
+		return responses.FailedResponse(ctx, fiber.StatusNotFound, "Failed Rotate API Key", err)
+	}
+
+	if errors.Is(err, apiKeyController.ErrorAPIKeyRevoked) || errors.Is(err, apiKeyController.ErrorAPIKeyExpired) {
+		return responses.FailedResponse(ctx, fiber.StatusConflict, "Failed Rotate API Key", err)
+	}
+
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusInternalServerError, "Failed Rotate API Key", err)
+	}
+
+	return responses.SuccessResponse(ctx, fiber.StatusOK, "Success Rotate API Key", res, nil)
+}
+
+func (h *APIKeyHandler) RevokeAPIKey(ctx *fiber.Ctx) error {
+	context := ctx.Context()
+
+	id, err := strconv.ParseInt(ctx.Params("id"), 10, 64)
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusBadRequest, "Failed Revoke API Key", err)
+	}
+
+	err = h.APIKeyController.RevokeAPIKey(context, id)
+	if errors.Is(err, apiKeyController.ErrorAPIKeyNotFound) {
+		return responses.FailedResponse(ctx, fiber.StatusNotFound, "Failed Revoke API Key", err)
+	}
+
+	if errors.Is(err, apiKeyController.ErrorAPIKeyRevoked) {
+		return responses.FailedResponse(ctx, fiber.StatusConflict, "Failed Revoke API Key", err)
+	}
+
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusInternalServerError, "Failed Revoke API Key", err)
+	}
+
+	return responses.SuccessResponse(ctx, fiber.StatusOK, "Success", "api key revoked successfully", nil)
+}
+
+func (h *APIKeyHandler) Register(router fiber.Router) error {
+	v1 := router.Group(core.V1)
+
+	admin := v1.Group(core.AccessInternal).Group("/admin", h.HandleAdminAccess())
+	admin.Get("/api-keys", h.GetAPIKeys)
+	admin.Post("/api-keys", h.CreateAPIKey)
+	admin.Post("/api-keys/:id/rotate", h.RotateAPIKey)
+	admin.Delete("/api-keys/:id", h.RevokeAPIKey)
+
+	return nil
+}
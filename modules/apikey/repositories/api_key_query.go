@@ -0,0 +1,83 @@
+package repositories
+
+const (
+	InsertAPIKeyDBQuery = `
+		INSERT INTO api_keys
+		    (
+				name,
+				prefix,
+				key_hash,
+				scopes,
+				expires_at,
+				created_at,
+				updated_at
+			) VALUES (
+						$1, -- name
+						$2, -- prefix
+						$3, -- key_hash
+						$4, -- scopes
+						$5, -- expires_at
+						$6, -- created_at
+						$6  -- updated_at
+					)
+			  RETURNING id;
+	`
+
+	GetAPIKeysDBQuery = `
+		SELECT
+			id,
+			name,
+			prefix,
+			key_hash,
+			scopes,
+			is_revoked,
+			revoked_at,
+			last_used_at,
+			expires_at,
+			created_at,
+			updated_at
+		FROM api_keys
+	`
+
+	GetAPIKeyByIDDBQuery = GetAPIKeysDBQuery + `
+		WHERE id = $1
+	`
+
+	GetAPIKeyByPrefixDBQuery = GetAPIKeysDBQuery + `
+		WHERE prefix = $1
+	`
+
+	ListAPIKeysDBQuery = GetAPIKeysDBQuery + `
+		ORDER BY id
+	`
+
+	UpdateAPIKeySecretDBQuery = `
+		UPDATE api_keys 
+		SET 
+		    prefix = $1,
+		    key_hash = $2,
+		    updated_at = $3
+		WHERE 
+		    id = $4 
+		  AND is_revoked = FALSE;
+	`
+
+	UpdateAPIKeyLastUsedDBQuery = `
+		UPDATE api_keys 
+		SET 
+		    last_used_at = $1
+		WHERE 
+		    id = $2;
+	`
+
+	RevokeAPIKeyDBQuery = `
+		UPDATE api_keys 
+		SET 
+		    is_revoked = TRUE,
+		    revoked_at = $1,
+		    updated_at = $1
+		WHERE 
+		    id = $2 
+		  AND is_revoked = FALSE;
+	`
+)
@@ -0,0 +1,146 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"github.com/winartodev/apollo/core/helpers"
+	apiKeyEntity "github.com/winartodev/apollo/modules/apikey/entities"
+	"strings"
+	"time"
+)
+
+type APIKeyRepositoryItf interface {
+	CreateAPIKeyDB(ctx context.Context, data *apiKeyEntity.APIKey) (id int64, err error)
+	GetAPIKeysDB(ctx context.Context) (res []apiKeyEntity.APIKey, err error)
+	GetAPIKeyByIDDB(ctx context.Context, id int64) (res *apiKeyEntity.APIKey, err error)
+	GetAPIKeyByPrefixDB(ctx context.Context, prefix string) (res *apiKeyEntity.APIKey, err error)
+	UpdateAPIKeySecretDB(ctx context.Context, id int64, prefix string, keyHash string) (updated bool, err error)
+	UpdateAPIKeyLastUsedDB(ctx context.Context, id int64, lastUsedAt time.Time) (err error)
+	RevokeAPIKeyDB(ctx context.Context, id int64) (revoked bool, err error)
+}
+
+type APIKeyRepository struct {
+	DB *sql.DB
+}
+
+func NewAPIKeyRepository(repository APIKeyRepository) APIKeyRepositoryItf {
+	return &APIKeyRepository{
+		DB: repository.DB,
+	}
+}
+
+func (ar *APIKeyRepository) CreateAPIKeyDB(ctx context.Context, data *apiKeyEntity.APIKey) (id int64, err error) {
+	err = ar.DB.QueryRowContext(ctx, InsertAPIKeyDBQuery,
+		data.Name,
+		data.Prefix,
+		data.KeyHash,
+		strings.Join(data.Scopes, " "),
+		data.ExpiresAt.Unix(),
+		data.CreatedAt.Unix(),
+	).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+func (ar *APIKeyRepository) GetAPIKeysDB(ctx context.Context) (res []apiKeyEntity.APIKey, err error) {
+	rows, err := ar.DB.QueryContext(ctx, ListAPIKeysDBQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res = []apiKeyEntity.APIKey{}
+	for rows.Next() {
+		apiKey, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, *apiKey)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (ar *APIKeyRepository) GetAPIKeyByIDDB(ctx context.Context, id int64) (res *apiKeyEntity.APIKey, err error) {
+	return scanAPIKey(ar.DB.QueryRowContext(ctx, GetAPIKeyByIDDBQuery, id))
+}
+
+func (ar *APIKeyRepository) GetAPIKeyByPrefixDB(ctx context.Context, prefix string) (res *apiKeyEntity.APIKey, err error) {
+	return scanAPIKey(ar.DB.QueryRowContext(ctx, GetAPIKeyByPrefixDBQuery, prefix))
+}
+
+// UpdateAPIKeySecretDB replaces the key of an api key that is not revoked, the previous key stops working at once.
+func (ar *APIKeyRepository) UpdateAPIKeySecretDB(ctx context.Context, id int64, prefix string, keyHash string) (updated bool, err error) {
+	return ar.exec(ctx, UpdateAPIKeySecretDBQuery, prefix, keyHash, time.Now().Unix(), id)
+}
+
+func (ar *APIKeyRepository) UpdateAPIKeyLastUsedDB(ctx context.Context, id int64, lastUsedAt time.Time) (err error) {
+	_, err = ar.DB.ExecContext(ctx, UpdateAPIKeyLastUsedDBQuery, lastUsedAt.Unix(), id)
+	return err
+}
+
+func (ar *APIKeyRepository) RevokeAPIKeyDB(ctx context.Context, id int64) (revoked bool, err error) {
+	return ar.exec(ctx, RevokeAPIKeyDBQuery, time.Now().Unix(), id)
+}
+
+func (ar *APIKeyRepository) exec(ctx context.Context, query string, args ...interface{}) (affected bool, err error) {
+	result, err := ar.DB.ExecContext(ctx, query, args...)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows > 0, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanAPIKey(row rowScanner) (res *apiKeyEntity.APIKey, err error) {
+	var scopes string
+	var revokedAtUnix int64
+	var lastUsedAtUnix int64
+	var expiresAtUnix int64
+	var createdAtUnix int64
+	var updatedAtUnix int64
+
+	res = &apiKeyEntity.APIKey{}
+	err = row.Scan(
+		&res.ID,
+		&res.Name,
+		&res.Prefix,
+		&res.KeyHash,
+		&scopes,
+		&res.IsRevoked,
+		&revokedAtUnix,
+		&lastUsedAtUnix,
+		&expiresAtUnix,
+		&createdAtUnix,
+		&updatedAtUnix,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	res.Scopes = strings.Fields(scopes)
+	res.RevokedAt = helpers.FormatUnixTime(revokedAtUnix)
+	res.LastUsedAt = helpers.FormatUnixTime(lastUsedAtUnix)
+	res.ExpiresAt = helpers.FormatUnixTime(expiresAtUnix)
+	res.CreatedAt = helpers.FormatUnixTime(createdAtUnix)
+	res.UpdatedAt = helpers.FormatUnixTime(updatedAtUnix)
+
+	return res, nil
+}
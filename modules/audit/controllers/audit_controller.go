@@ -0,0 +1,124 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/winartodev/apollo/core/helpers"
+	auditEntity "github.com/winartodev/apollo/modules/audit/entities"
+	auditRepo "github.com/winartodev/apollo/modules/audit/repositories"
+	"net"
+	"strconv"
+	"time"
+)
+
+const (
+	maxUserAgentLength = 255
+)
+
+var (
+	ErrorInvalidTimeRange = errors.New("the start of the time range must be before its end")
+)
+
+type AuditControllerItf interface {
+	Record(ctx context.Context, event *auditEntity.Event, err error)
+	GetEvents(ctx context.Context, filter auditEntity.EventFilter, paginate *helpers.Paginate) (res []auditEntity.Event, total int64, err error)
+}
+
+type AuditController struct {
+	AuditRepository auditRepo.AuditRepositoryItf
+}
+
+func NewAuditController(controller AuditController) AuditControllerItf {
+	return &AuditController{
+		AuditRepository: controller.AuditRepository,
+	}
+}
+
+// requestContext is implemented by the fasthttp request context the handlers pass down as context.Context, the
+// client of the request is read from it.
+type requestContext interface {
+	RemoteIP() net.IP
+	UserAgent() []byte
+}
+
+// Record stores event with the outcome err, nil for success. The actor and client are taken from the request
+// in ctx unless the event already names them. Recording never fails the action it audits, errors are logged.
+func (ac *AuditController) Record(ctx context.Context, event *auditEntity.Event, err error) {
+	now := time.Now()
+	event.CreatedAt = &now
+
+	if event.Metadata == nil {
+		event.Metadata = map[string]interface{}{}
+	}
+
+	event.Result = auditEntity.ResultSuccess
+	if err != nil {
+		event.Result = auditEntity.ResultFailure
+		event.Metadata["error"] = err.Error()
+	}
+
+	if event.ActorType == "" {
+		event.ActorType, event.ActorID = actorFromContext(ctx)
+	}
+
+	if event.UserID == 0 && event.ActorType == auditEntity.ActorUser {
+		event.UserID, _ = strconv.ParseInt(event.ActorID, 10, 64)
+	}
+
+	if request, ok := ctx.(requestContext); ok {
+		if event.IPAddress == "" {
+			event.IPAddress = request.RemoteIP().String()
+		}
+
+		if event.UserAgent == "" {
+			event.UserAgent = string(request.UserAgent())
+		}
+	}
+
+	if len(event.UserAgent) > maxUserAgentLength {
+		event.UserAgent = event.UserAgent[:maxUserAgentLength]
+	}
+
+	_, err = ac.AuditRepository.CreateEventDB(ctx, event)
+	if err != nil {
+		log.Errorf("failed to record audit event %s: %v", event.Action, err)
+	}
+}
+
+// GetEvents reads a page of the audit trail, newest first unless paginate asks otherwise.
+func (ac *AuditController) GetEvents(ctx context.Context, filter auditEntity.EventFilter, paginate *helpers.Paginate) (res []auditEntity.Event, total int64, err error) {
+	if filter.From != nil && filter.To != nil && filter.From.After(*filter.To) {
+		return nil, 0, ErrorInvalidTimeRange
+	}
+
+	if paginate.SortBy == nil || *paginate.SortBy == "" {
+		sort := "desc"
+		paginate.SortBy = &sort
+	}
+
+	paginate.Validate()
+
+	return ac.AuditRepository.GetEventsDB(ctx, filter, paginate)
+}
+
+// actorFromContext identifies who made the request from the locals the access middlewares set.
+func actorFromContext(ctx context.Context) (actorType string, actorID string) {
+	if id, ok := ctx.Value("id").(int64); ok && id != 0 {
+		return auditEntity.ActorUser, strconv.FormatInt(id, 10)
+	}
+
+	if clientID, ok := ctx.Value("client_id").(string); ok && clientID != "" {
+		return auditEntity.ActorClient, clientID
+	}
+
+	if apiKeyID, ok := ctx.Value("api_key_id").(int64); ok {
+		return auditEntity.ActorAPIKey, strconv.FormatInt(apiKeyID, 10)
+	}
+
+	if adminKey, ok := ctx.Value("admin_key").(bool); ok && adminKey {
+		return auditEntity.ActorAdminKey, ""
+	}
+
+	return auditEntity.ActorAnonymous, ""
+}
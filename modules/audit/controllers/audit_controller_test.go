@@ -0,0 +1,147 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"github.com/winartodev/apollo/core/helpers"
+	auditEntity "github.com/winartodev/apollo/modules/audit/entities"
+	"testing"
+)
+
+type fakeAuditRepository struct {
+	events []auditEntity.Event
+	err    error
+}
+
+func (f *fakeAuditRepository) CreateEventDB(ctx context.Context, data *auditEntity.Event) (id int64, err error) {
+	if f.err != nil {
+		return 0, f.err
+	}
+
+	f.events = append(f.events, *data)
+	return int64(len(f.events)), nil
+}
+
+func (f *fakeAuditRepository) GetEventsDB(ctx context.Context, filter auditEntity.EventFilter, paginate *helpers.Paginate) (res []auditEntity.Event, total int64, err error) {
+	return f.events, int64(len(f.events)), nil
+}
+
+func TestAuditController_Record(t *testing.T) {
+	tests := []struct {
+		name          string
+		ctx           context.Context
+		event         auditEntity.Event
+		err           error
+		wantActorType string
+		wantActorID   string
+		wantUserID    int64
+		wantResult    string
+	}{
+		{
+			name:          "success_anonymous_sign_in",
+			ctx:           context.Background(),
+			event:         auditEntity.Event{Action: auditEntity.ActionSignIn, UserID: 3},
+			wantActorType: auditEntity.ActorAnonymous,
+			wantUserID:    3,
+			wantResult:    auditEntity.ResultSuccess,
+		},
+		{
+			name:          "success_user_actor_is_user_of_event",
+			ctx:           context.WithValue(context.Background(), "id", int64(7)),
+			event:         auditEntity.Event{Action: auditEntity.ActionPasswordChange},
+			wantActorType: auditEntity.ActorUser,
+			wantActorID:   "7",
+			wantUserID:    7,
+			wantResult:    auditEntity.ResultSuccess,
+		},
+		{
+			name:          "success_api_key_acting_on_user",
+			ctx:           context.WithValue(context.Background(), "api_key_id", int64(2)),
+			event:         auditEntity.Event{Action: auditEntity.ActionUserSuspend, TargetType: auditEntity.TargetUser, TargetID: "9", UserID: 9},
+			wantActorType: auditEntity.ActorAPIKey,
+			wantActorID:   "2",
+			wantUserID:    9,
+			wantResult:    auditEntity.ResultSuccess,
+		},
+		{
+			name:          "failed_sign_in_records_error",
+			ctx:           context.Background(),
+			event:         auditEntity.Event{Action: auditEntity.ActionSignIn, TargetType: auditEntity.TargetEmail, TargetID: "apollo@gmail.com"},
+			err:           errors.New("invalid password"),
+			wantActorType: auditEntity.ActorAnonymous,
+			wantResult:    auditEntity.ResultFailure,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repository := &fakeAuditRepository{}
+			controller := NewAuditController(AuditController{AuditRepository: repository})
+
+			controller.Record(tt.ctx, &tt.event, tt.err)
+
+			if len(repository.events) != 1 {
+				t.Fatalf("Record() stored %d events, want 1", len(repository.events))
+			}
+
+			got := repository.events[0]
+			if got.ActorType != tt.wantActorType || got.ActorID != tt.wantActorID || got.UserID != tt.wantUserID || got.Result != tt.wantResult {
+				t.Errorf("Record() = %+v", got)
+			}
+
+			if tt.err != nil && got.Metadata["error"] != tt.err.Error() {
+				t.Errorf("Record() metadata = %v, want error %v", got.Metadata, tt.err)
+			}
+
+			if got.CreatedAt == nil {
+				t.Errorf("Record() did not set created_at")
+			}
+		})
+	}
+}
+
+func TestAuditController_GetEvents(t *testing.T) {
+	tests := []struct {
+		name     string
+		filter   auditEntity.EventFilter
+		sort     string
+		wantSort string
+		wantErr  error
+	}{
+		{
+			name:     "success_newest_first_by_default",
+			wantSort: "desc",
+		},
+		{
+			name:     "success_oldest_first",
+			sort:     "asc",
+			wantSort: "asc",
+		},
+		{
+			name: "failed_time_range_reversed",
+			filter: func() auditEntity.EventFilter {
+				from, to := helpers.FormatUnixTime(200), helpers.FormatUnixTime(100)
+				return auditEntity.EventFilter{From: from, To: to}
+			}(),
+			wantErr: ErrorInvalidTimeRange,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			controller := NewAuditController(AuditController{AuditRepository: &fakeAuditRepository{}})
+
+			paginate := &helpers.Paginate{}
+			if tt.sort != "" {
+				paginate.SortBy = &tt.sort
+			}
+
+			_, _, err := controller.GetEvents(context.Background(), tt.filter, paginate)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetEvents() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr == nil && *paginate.SortBy != tt.wantSort {
+				t.Errorf("GetEvents() sort = %v, want %v", *paginate.SortBy, tt.wantSort)
+			}
+		})
+	}
+}
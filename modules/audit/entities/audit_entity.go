@@ -0,0 +1,74 @@
+package entities
+
+import "time"
+
+const (
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+
+	ActorAnonymous = "anonymous"
+	ActorUser      = "user"
+	ActorClient    = "client"
+	ActorAPIKey    = "api_key"
+	ActorAdminKey  = "admin_key"
+
+	TargetUser        = "user"
+	TargetEmail       = "email"
+	TargetPhoneNumber = "phone_number"
+
+	ActionSignIn             = "auth.sign_in"
+	ActionMFAVerify          = "auth.mfa_verify"
+	ActionSignUp             = "auth.sign_up"
+	ActionSignOut            = "auth.sign_out"
+	ActionTokenRefresh       = "auth.token_refresh"
+	ActionStepUp             = "auth.step_up"
+	ActionPasswordForgot     = "auth.password_forgot"
+	ActionPasswordReset      = "auth.password_reset"
+	ActionPasswordChange     = "auth.password_change"
+	ActionMagicLinkRequest   = "auth.magic_link_request"
+	ActionPhoneSignInRequest = "auth.phone_sign_in_request"
+	ActionAccountUnlock      = "auth.account_unlock"
+	ActionLoginAlert         = "auth.login_alert"
+	ActionLoginDeny          = "auth.login_deny"
+	ActionOTPSend            = "verification.otp_send"
+	ActionOTPVerify          = "verification.otp_verify"
+	ActionUserCreate         = "user.create"
+	ActionUserSuspend        = "user.suspend"
+	ActionUserReinstate      = "user.reinstate"
+
+	// security events such as a reused refresh token or a locked account are recorded as security.<event type>
+	ActionSecurityPrefix = "security."
+
+	// sign in methods, recorded in the method metadata of ActionSignIn and in the login history of the user
+	MethodPassword         = "password"
+	MethodMagicLink        = "magic_link"
+	MethodPhone            = "phone"
+	MethodIdentityProvider = "identity_provider"
+	MethodPasskey          = "passkey"
+	MethodMFA              = "mfa"
+)
+
+// Event is one entry of the audit trail. The actor is who made the request, the target what it was about and
+// UserID the user the event concerns, so the trail of a user can be read whether they acted or were acted upon.
+type Event struct {
+	ID         int64                  `json:"id"`
+	ActorType  string                 `json:"actor_type"`
+	ActorID    string                 `json:"actor_id,omitempty"`
+	Action     string                 `json:"action"`
+	TargetType string                 `json:"target_type,omitempty"`
+	TargetID   string                 `json:"target_id,omitempty"`
+	UserID     int64                  `json:"user_id,omitempty"`
+	IPAddress  string                 `json:"ip_address,omitempty"`
+	UserAgent  string                 `json:"user_agent,omitempty"`
+	Result     string                 `json:"result"`
+	Metadata   map[string]interface{} `json:"metadata"`
+	CreatedAt  *time.Time             `json:"created_at,omitempty"`
+}
+
+// EventFilter narrows the audit trail, zero fields do not filter.
+type EventFilter struct {
+	UserID int64
+	Action string
+	From   *time.Time
+	To     *time.Time
+}
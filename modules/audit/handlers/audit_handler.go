@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"github.com/winartodev/apollo/core"
+	"github.com/winartodev/apollo/core/helpers"
+	"github.com/winartodev/apollo/core/middlewares"
+	"github.com/winartodev/apollo/core/responses"
+	auditController "github.com/winartodev/apollo/modules/audit/controllers"
+	auditEntity "github.com/winartodev/apollo/modules/audit/entities"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+type AuditHandler struct {
+	middlewares.Middleware
+	AuditController auditController.AuditControllerItf
+}
+
+func NewAuditHandler(handler AuditHandler) AuditHandler {
+	return AuditHandler{
+		Middleware:      handler.Middleware,
+		AuditController: handler.AuditController,
+	}
+}
+
+// GetEvents lists the audit trail filtered by the user_id, action, from and to query parameters, from and to are
+// RFC 3339 timestamps.
+func (h *AuditHandler) GetEvents(ctx *fiber.Ctx) error {
+	context := ctx.Context()
+
+	filter, query, err := parseEventFilter(ctx)
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusBadRequest, "Failed Get Audit Events", err)
+	}
+
+	paginate := helpers.NewPaginateFromQuery(ctx)
+
+	res, total, err := h.AuditController.GetEvents(context, filter, paginate)
+	if errors.Is(err, auditController.ErrorInvalidTimeRange) {
+		return responses.FailedResponse(ctx, fiber.StatusBadRequest, "Failed Get Audit Events", err)
+	}
+
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusInternalServerError, "Failed Get Audit Events", err)
+	}
+
+	link := ctx.Path()
+	if len(query) > 0 {
+		link = fmt.Sprintf("%s?%s", link, query.Encode())
+	}
+
+	return responses.SuccessResponse(ctx, fiber.StatusOK, "Success Get Audit Events", res, responses.BuildPaginate(total, link, paginate))
+}
+
+// parseEventFilter reads the filter of the request, query holds the parameters it was read from so the page links
+// keep filtering.
+func parseEventFilter(ctx *fiber.Ctx) (filter auditEntity.EventFilter, query url.Values, err error) {
+	query = url.Values{}
+
+	if value := ctx.Query("user_id"); value != "" {
+		filter.UserID, err = strconv.ParseInt(value, 10, 64)
+		if err != nil || filter.UserID < 1 {
+			return filter, nil, errors.New("user_id must be a user id")
+		}
+
+		query.Set("user_id", value)
+	}
+
+	if value := ctx.Query("action"); value != "" {
+		filter.Action = value
+		query.Set("action", value)
+	}
+
+	for _, param := range []struct {
+		key   string
+		value **time.Time
+	}{
+		{key: "from", value: &filter.From},
+		{key: "to", value: &filter.To},
+	} {
+		value := ctx.Query(param.key)
+		if value == "" {
+			continue
+		}
+
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return filter, nil, fmt.Errorf("%s must be an RFC 3339 timestamp", param.key)
+		}
+
+		*param.value = &parsed
+		query.Set(param.key, value)
+	}
+
+	if value := ctx.Query("sort"); value != "" {
+		query.Set("sort", value)
+	}
+
+	return filter, query, nil
+}
+
+func (h *AuditHandler) Register(router fiber.Router) error {
+	v1 := router.Group(core.V1)
+
+	admin := v1.Group(core.AccessInternal).Group("/admin", h.HandleAdminAccess())
+	admin.Get("/audit-events", h.GetEvents)
+
+	return nil
+}
@@ -0,0 +1,54 @@
+package repositories
+
+const (
+	InsertEventDBQuery = `
+		INSERT INTO audit_events
+		    (
+				actor_type,
+				actor_id,
+				action,
+				target_type,
+				target_id,
+				user_id,
+				ip_address,
+				user_agent,
+				result,
+				metadata,
+				created_at
+			) VALUES (
+						$1,  -- actor_type
+						$2,  -- actor_id
+						$3,  -- action
+						$4,  -- target_type
+						$5,  -- target_id
+						$6,  -- user_id
+						$7,  -- ip_address
+						$8,  -- user_agent
+						$9,  -- result
+						$10, -- metadata
+						$11  -- created_at
+					)
+			  RETURNING id;
+	`
+
+	GetEventsDBQuery = `
+		SELECT
+			id,
+			actor_type,
+			COALESCE(actor_id, ''),
+			action,
+			COALESCE(target_type, ''),
+			COALESCE(target_id, ''),
+			COALESCE(user_id, 0),
+			COALESCE(ip_address, ''),
+			COALESCE(user_agent, ''),
+			result,
+			metadata,
+			created_at
+		FROM audit_events
+	`
+
+	CountEventsDBQuery = `
+		SELECT COUNT(*) FROM audit_events
+	`
+)
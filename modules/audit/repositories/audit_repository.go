@@ -0,0 +1,146 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/winartodev/apollo/core/helpers"
+	auditEntity "github.com/winartodev/apollo/modules/audit/entities"
+	"strings"
+)
+
+type AuditRepositoryItf interface {
+	CreateEventDB(ctx context.Context, data *auditEntity.Event) (id int64, err error)
+	GetEventsDB(ctx context.Context, filter auditEntity.EventFilter, paginate *helpers.Paginate) (res []auditEntity.Event, total int64, err error)
+}
+
+type AuditRepository struct {
+	DB *sql.DB
+}
+
+func NewAuditRepository(repository AuditRepository) AuditRepositoryItf {
+	return &AuditRepository{
+		DB: repository.DB,
+	}
+}
+
+func (ar *AuditRepository) CreateEventDB(ctx context.Context, data *auditEntity.Event) (id int64, err error) {
+	metadata, err := json.Marshal(data.Metadata)
+	if err != nil {
+		return 0, err
+	}
+
+	err = ar.DB.QueryRowContext(ctx, InsertEventDBQuery,
+		data.ActorType,
+		nullString(data.ActorID),
+		data.Action,
+		nullString(data.TargetType),
+		nullString(data.TargetID),
+		sql.NullInt64{Int64: data.UserID, Valid: data.UserID != 0},
+		nullString(data.IPAddress),
+		nullString(data.UserAgent),
+		data.Result,
+		metadata,
+		data.CreatedAt.Unix(),
+	).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// GetEventsDB reads one page of the events matching filter ordered by id in the sort order of paginate, together
+// with the number of matching events. paginate must be validated.
+func (ar *AuditRepository) GetEventsDB(ctx context.Context, filter auditEntity.EventFilter, paginate *helpers.Paginate) (res []auditEntity.Event, total int64, err error) {
+	var conditions []string
+	var args []interface{}
+
+	if filter.UserID != 0 {
+		args = append(args, filter.UserID)
+		conditions = append(conditions, fmt.Sprintf("user_id = $%d", len(args)))
+	}
+
+	if filter.Action != "" {
+		args = append(args, filter.Action)
+		conditions = append(conditions, fmt.Sprintf("action = $%d", len(args)))
+	}
+
+	if filter.From != nil {
+		args = append(args, filter.From.Unix())
+		conditions = append(conditions, fmt.Sprintf("created_at >= $%d", len(args)))
+	}
+
+	if filter.To != nil {
+		args = append(args, filter.To.Unix())
+		conditions = append(conditions, fmt.Sprintf("created_at <= $%d", len(args)))
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	err = ar.DB.QueryRowContext(ctx, CountEventsDBQuery+where, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	sort := "ASC"
+	if strings.EqualFold(*paginate.SortBy, "desc") {
+		sort = "DESC"
+	}
+
+	args = append(args, *paginate.Limit, paginate.CalculateOffset(paginate.Offset, paginate.Limit))
+	query := fmt.Sprintf("%s%s ORDER BY id %s LIMIT $%d OFFSET $%d", GetEventsDBQuery, where, sort, len(args)-1, len(args))
+
+	rows, err := ar.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	res = []auditEntity.Event{}
+	for rows.Next() {
+		var metadata []byte
+		var createdAtUnix int64
+
+		event := auditEntity.Event{}
+		err = rows.Scan(
+			&event.ID,
+			&event.ActorType,
+			&event.ActorID,
+			&event.Action,
+			&event.TargetType,
+			&event.TargetID,
+			&event.UserID,
+			&event.IPAddress,
+			&event.UserAgent,
+			&event.Result,
+			&metadata,
+			&createdAtUnix,
+		)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		err = json.Unmarshal(metadata, &event.Metadata)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		event.CreatedAt = helpers.FormatUnixTime(createdAtUnix)
+		res = append(res, event)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return res, total, nil
+}
+
+func nullString(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: value != ""}
+}
@@ -5,70 +5,366 @@ import (
 	"errors"
 	"fmt"
 	"github.com/gofiber/fiber/v2/log"
-	"github.com/winartodev/apollo/core"
+	"github.com/google/uuid"
 	"github.com/winartodev/apollo/core/configs"
 	"github.com/winartodev/apollo/core/helpers"
+	auditController "github.com/winartodev/apollo/modules/audit/controllers"
+	auditEntity "github.com/winartodev/apollo/modules/audit/entities"
 	authEnum "github.com/winartodev/apollo/modules/auth/emums"
 	authEntity "github.com/winartodev/apollo/modules/auth/entities"
+	roleController "github.com/winartodev/apollo/modules/role/controllers"
+	sessionController "github.com/winartodev/apollo/modules/session/controllers"
+	sessionEntity "github.com/winartodev/apollo/modules/session/entities"
 	userController "github.com/winartodev/apollo/modules/user/controllers"
 	userEntity "github.com/winartodev/apollo/modules/user/entities"
+	"net/url"
+	"strconv"
+	"time"
 )
 
+const (
+	minPasswordLength = 8
+
+	passwordResetMailHtmlTemplate = "modules/auth/files/password-reset-mail-template.html"
+	magicLinkMailHtmlTemplate     = "modules/auth/files/magic-link-mail-template.html"
+
+	magicLinkNonceLength = 32
+)
+
+var (
+	ErrorInvalidCurrentPassword = errors.New("current password is invalid")
+	errorInvalidPassword        = errors.New("invalid password")
+	errorPasswordTooShort       = fmt.Errorf("password must be at least %d characters", minPasswordLength)
+	errorPasswordNotChanged     = errors.New("new password must be different from the current password")
+	ErrorPhoneSignInDisabled    = errors.New("phone number sign in is not available")
+	ErrorInvalidPhoneNumber     = errors.New("invalid phone number")
+	ErrorInvalidSignInCode      = errors.New("sign in code is invalid or expired")
+	ErrorSignInCodeLimitReached = errors.New("too many sign in codes requested, please try again later")
+)
+
+type PasswordResetMailTemplate struct {
+	RecipientName string
+	ResetLink     string
+	Token         string
+	Duration      string
+}
+
+type MagicLinkMailTemplate struct {
+	RecipientName string
+	SignInLink    string
+	Token         string
+	Duration      string
+}
+
 type AuthControllerItf interface {
-	SignIn(ctx context.Context, data *authEntity.SignInRequest) (res *authEntity.AuthResponse, err error)
+	SignIn(ctx context.Context, data *authEntity.SignInRequest, client sessionEntity.ClientInfo) (res *authEntity.AuthResponse, challenge *authEntity.MFAChallenge, err error)
+	VerifyMFA(ctx context.Context, data *authEntity.MFAVerifyRequest, client sessionEntity.ClientInfo) (res *authEntity.AuthResponse, err error)
 	SignUp(ctx context.Context, data *authEntity.SignUpRequest) (res *userEntity.User, err error)
-	SignOut(ctx context.Context, id int64) (success bool, err error)
-	RefreshToken(ctx context.Context, providedRefreshToken string) (res *authEntity.AuthResponse, err error)
+	SignOut(ctx context.Context, claims *helpers.JWTClaims) (success bool, err error)
+	RefreshToken(ctx context.Context, providedRefreshToken string, client sessionEntity.ClientInfo) (res *authEntity.AuthResponse, err error)
+	ForgotPassword(ctx context.Context, email string) (err error)
+	ResetPassword(ctx context.Context, data *authEntity.ResetPasswordRequest) (err error)
+	ChangePassword(ctx context.Context, id int64, sessionID string, data *authEntity.ChangePasswordRequest) (err error)
+	StepUp(ctx context.Context, id int64, sessionID string, data *authEntity.StepUpRequest, client sessionEntity.ClientInfo) (err error)
+	UnlockUser(ctx context.Context, id int64, data *authEntity.UnlockUserRequest, client sessionEntity.ClientInfo) (err error)
+	RequestMagicLink(ctx context.Context, email string) (nonce *authEntity.MagicLinkNonce, err error)
+	SignInWithMagicLink(ctx context.Context, token string, nonce string, client sessionEntity.ClientInfo) (res *authEntity.AuthResponse, challenge *authEntity.MFAChallenge, err error)
+	RequestPhoneSignIn(ctx context.Context, phoneNumber string) (err error)
+	SignInWithPhone(ctx context.Context, data *authEntity.PhoneSignInVerifyRequest, client sessionEntity.ClientInfo) (res *authEntity.AuthResponse, challenge *authEntity.MFAChallenge, err error)
+	SignInUser(ctx context.Context, user *userEntity.User, client sessionEntity.ClientInfo) (res *authEntity.AuthResponse, challenge *authEntity.MFAChallenge, err error)
+	SignInVerifiedUser(ctx context.Context, user *userEntity.User, client sessionEntity.ClientInfo) (res *authEntity.AuthResponse, err error)
+	DenyLogin(ctx context.Context, token string, client sessionEntity.ClientInfo) (err error)
+}
+
+// PasskeyControllerItf is the part of the webauthn controller the auth controller uses. The webauthn module signs
+// users in through this one, so it cannot be imported here.
+type PasskeyControllerItf interface {
+	DeleteCredentialsCreatedSince(ctx context.Context, userID int64, since time.Time) (deleted int64, err error)
+}
+
+// IdentityControllerItf is the part of the oauth controller the auth controller uses, see PasskeyControllerItf.
+type IdentityControllerItf interface {
+	DeleteIdentitiesCreatedSince(ctx context.Context, userID int64, since time.Time) (deleted int64, err error)
 }
 
 type AuthController struct {
 	OTP                    *configs.OTP
+	PasswordReset          *configs.PasswordReset
+	MagicLink              *configs.MagicLink
 	VerificationController VerificationControllerItf
+	MFAController          MFAControllerItf
+	LockoutController      LockoutControllerItf
+	LoginAlertController   LoginAlertControllerItf
+	SessionController      sessionController.SessionControllerItf
 	UserController         userController.UserControllerItf
+	RoleController         roleController.RoleControllerItf
+	AuditController        auditController.AuditControllerItf
+	WebAuthnController     PasskeyControllerItf
+	OAuthController        IdentityControllerItf
 }
 
 func NewAuthController(controller AuthController) AuthControllerItf {
 	return &AuthController{
 		OTP:                    controller.OTP,
+		PasswordReset:          controller.PasswordReset,
+		MagicLink:              controller.MagicLink,
 		VerificationController: controller.VerificationController,
+		MFAController:          controller.MFAController,
+		LockoutController:      controller.LockoutController,
+		LoginAlertController:   controller.LoginAlertController,
+		SessionController:      controller.SessionController,
 		UserController:         controller.UserController,
+		RoleController:         controller.RoleController,
+		AuditController:        controller.AuditController,
+		WebAuthnController:     controller.WebAuthnController,
+		OAuthController:        controller.OAuthController,
 	}
 }
 
-func (ac *AuthController) SignIn(ctx context.Context, data *authEntity.SignInRequest) (res *authEntity.AuthResponse, err error) {
+// SignIn checks the credentials and issues tokens. When the user has two-factor authentication enabled,
+// no tokens are issued and a pending challenge is returned instead, to be completed through VerifyMFA.
+// Failed attempts are counted, a LockoutError is returned while the account or ip address is blocked.
+func (ac *AuthController) SignIn(ctx context.Context, data *authEntity.SignInRequest, client sessionEntity.ClientInfo) (res *authEntity.AuthResponse, challenge *authEntity.MFAChallenge, err error) {
+	event := newSignInEvent(auditEntity.MethodPassword, auditEntity.TargetEmail, data.Email)
+	defer func() { ac.recordSignIn(ctx, event, client, challenge, err) }()
+
+	err = ac.LockoutController.Check(ctx, data.Email, client.IPAddress)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	passwordHash, err := ac.UserController.GetPasswordByEmail(ctx, data.Email)
+	if errors.Is(err, userController.ErrorUserNotFound) {
+		return nil, nil, ac.recordSignInFailure(ctx, data.Email, client, err, ac.UserController.GetUserByEmail)
+	}
+
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	verified := helpers.VerifyPassword(data.Password, *passwordHash)
 	if !verified {
-		return nil, errors.New("invalid password")
+		return nil, nil, ac.recordSignInFailure(ctx, data.Email, client, errorInvalidPassword, ac.UserController.GetUserByEmail)
+	}
+
+	err = ac.LockoutController.Reset(ctx, data.Email)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	user, err := ac.UserController.GetUserByEmail(ctx, data.Email)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	event.UserID = user.ID
+
+	return ac.completeSignIn(ctx, user, client)
+}
+
+// SignInUser completes a sign in whose first factor was verified outside the auth module, e.g. by an external
+// identity provider.
+func (ac *AuthController) SignInUser(ctx context.Context, user *userEntity.User, client sessionEntity.ClientInfo) (res *authEntity.AuthResponse, challenge *authEntity.MFAChallenge, err error) {
+	event := newSignInEvent(auditEntity.MethodIdentityProvider, auditEntity.TargetUser, strconv.FormatInt(user.ID, 10))
+	event.UserID = user.ID
+	defer func() { ac.recordSignIn(ctx, event, client, challenge, err) }()
+
+	return ac.completeSignIn(ctx, user, client)
+}
+
+// SignInVerifiedUser issues tokens for a user whose every factor was verified outside the auth module, e.g. by a
+// passkey with user verification, so no two-factor challenge is raised.
+func (ac *AuthController) SignInVerifiedUser(ctx context.Context, user *userEntity.User, client sessionEntity.ClientInfo) (res *authEntity.AuthResponse, err error) {
+	event := newSignInEvent(auditEntity.MethodPasskey, auditEntity.TargetUser, strconv.FormatInt(user.ID, 10))
+	event.UserID = user.ID
+	defer func() { ac.recordSignIn(ctx, event, client, nil, err) }()
+
+	return ac.generateAuthResponse(ctx, user, client)
+}
+
+// newSignInEvent describes a sign in through method for the audit trail, the target is what the user signs in
+// with.
+func newSignInEvent(method string, targetType string, targetID string) *auditEntity.Event {
+	return &auditEntity.Event{
+		Action:     auditEntity.ActionSignIn,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Metadata:   map[string]interface{}{"method": method},
+	}
+}
+
+// recordSignIn records the outcome of a sign in in the audit trail and the login history of the user, one that is
+// waiting for the second factor is recorded with mfa_required. A failed sign in is attributed to the owner of the
+// email or phone number it was tried with.
+func (ac *AuthController) recordSignIn(ctx context.Context, event *auditEntity.Event, client sessionEntity.ClientInfo, challenge *authEntity.MFAChallenge, err error) {
+	if challenge != nil {
+		event.Metadata["mfa_required"] = true
+	}
+
+	if event.UserID == 0 && err != nil {
+		var user *userEntity.User
+		switch event.TargetType {
+		case auditEntity.TargetEmail:
+			user, _ = ac.UserController.GetUserByEmail(ctx, event.TargetID)
+		case auditEntity.TargetPhoneNumber:
+			user, _ = ac.UserController.GetUserByPhoneNumber(ctx, event.TargetID)
+		}
+
+		if user != nil {
+			event.UserID = user.ID
+		}
+	}
+
+	ac.AuditController.Record(ctx, event, err)
+
+	method, _ := event.Metadata["method"].(string)
+	ac.recordLogin(ctx, event.UserID, method, client, challenge, err)
+}
+
+// recordLogin adds a sign in attempt to the login history of the user, after its device and network were inspected
+// for a login alert. Failing to record it does not fail the sign in.
+func (ac *AuthController) recordLogin(ctx context.Context, userID int64, method string, client sessionEntity.ClientInfo, challenge *authEntity.MFAChallenge, err error) {
+	if userID == 0 {
+		return
+	}
+
+	outcome := userEntity.LoginOutcomeSuccess
+	if err != nil {
+		outcome = userEntity.LoginOutcomeFailure
+	} else if challenge != nil {
+		outcome = userEntity.LoginOutcomeMFARequired
+	}
+
+	login := &userEntity.LoginHistory{
+		UserID:    userID,
+		Method:    method,
+		Outcome:   outcome,
+		IPAddress: client.IPAddress,
+		UserAgent: client.UserAgent,
+	}
+
+	alertErr := ac.LoginAlertController.Inspect(ctx, login)
+	if alertErr != nil {
+		log.Errorf("failed to inspect login of user %d: %v", userID, alertErr)
+	}
+
+	recordErr := ac.UserController.RecordLogin(ctx, login)
+	if recordErr != nil {
+		log.Errorf("failed to record login of user %d: %v", userID, recordErr)
+	}
+}
+
+// completeSignIn issues tokens for a user whose first factor was verified, or a pending challenge when the user
+// has two-factor authentication enabled.
+func (ac *AuthController) completeSignIn(ctx context.Context, user *userEntity.User, client sessionEntity.ClientInfo) (res *authEntity.AuthResponse, challenge *authEntity.MFAChallenge, err error) {
+	if user.IsSuspended {
+		return nil, nil, userController.ErrorUserSuspended
+	}
+
+	mfaEnabled, err := ac.MFAController.IsEnabled(ctx, user.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if mfaEnabled {
+		challenge, err = ac.MFAController.CreateChallenge(ctx, user.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return nil, challenge, nil
+	}
+
+	res, err = ac.generateAuthResponse(ctx, user, client)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return res, nil, nil
+}
+
+// recordSignInFailure counts the failed attempt against identifier, the email or phone number the user signs in
+// with, and returns cause, or the error that prevented counting it. getUser resolves identifier to the user.
+func (ac *AuthController) recordSignInFailure(ctx context.Context, identifier string, client sessionEntity.ClientInfo, cause error, getUser func(ctx context.Context, identifier string) (*userEntity.User, error)) (err error) {
+	locked, err := ac.LockoutController.RecordFailure(ctx, identifier, client.IPAddress)
+	if err != nil {
+		return err
+	}
+
+	if !locked {
+		return cause
+	}
+
+	user, err := getUser(ctx, identifier)
+	if errors.Is(err, userController.ErrorUserNotFound) {
+		return cause
+	}
+
+	if err != nil {
+		return err
+	}
+
+	err = ac.SessionController.RecordSecurityEvent(ctx, user.ID, "", sessionEntity.SecurityEventAccountLocked, client)
+	if err != nil {
+		return err
+	}
+
+	return cause
+}
+
+func (ac *AuthController) VerifyMFA(ctx context.Context, data *authEntity.MFAVerifyRequest, client sessionEntity.ClientInfo) (res *authEntity.AuthResponse, err error) {
+	event := &auditEntity.Event{Action: auditEntity.ActionMFAVerify}
+	defer func() {
+		ac.AuditController.Record(ctx, event, err)
+		ac.recordLogin(ctx, event.UserID, auditEntity.MethodMFA, client, nil, err)
+	}()
+
+	userID, err := ac.MFAController.VerifyChallenge(ctx, data.MFAToken, data.Code)
+	event.UserID = userID
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := ac.UserController.GetUserByID(ctx, userID)
 	if err != nil {
 		return nil, err
 	}
 
+	return ac.generateAuthResponse(ctx, user, client)
+}
+
+// generateAuthResponse starts a new session for the device described by client, every sign in gets its own
+// session so the user can stay signed in on several devices at once.
+func (ac *AuthController) generateAuthResponse(ctx context.Context, user *userEntity.User, client sessionEntity.ClientInfo) (res *authEntity.AuthResponse, err error) {
+	if user.IsSuspended {
+		return nil, userController.ErrorUserSuspended
+	}
+
 	jwt, err := helpers.NewJWT()
 	if err != nil {
 		return nil, err
 	}
 
-	token, err := jwt.GenerateToken(user)
+	sessionID, err := uuid.NewRandom()
 	if err != nil {
 		return nil, err
 	}
 
-	err = ac.UserController.UpdateRefreshToken(ctx, false, user.ID, &token.RefreshToken)
-	if err != nil && err != core.ErrRefreshTokenExists {
-		log.Debug(err)
+	// roles travel in the access token, so they are read on every sign in and refresh
+	user.Roles, err = ac.RoleController.GetUserRoles(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := jwt.GenerateToken(user, sessionID.String())
+	if err != nil {
 		return nil, err
 	}
 
-	if err == core.ErrRefreshTokenExists {
-		token.RefreshToken = ""
+	_, err = ac.SessionController.CreateSession(ctx, user.ID, sessionID.String(), token.RefreshToken, client)
+	if err != nil {
+		log.Debug(err)
+		return nil, err
 	}
 
 	res = &authEntity.AuthResponse{
@@ -80,6 +376,9 @@ func (ac *AuthController) SignIn(ctx context.Context, data *authEntity.SignInReq
 }
 
 func (ac *AuthController) SignUp(ctx context.Context, data *authEntity.SignUpRequest) (res *userEntity.User, err error) {
+	event := &auditEntity.Event{Action: auditEntity.ActionSignUp, TargetType: auditEntity.TargetEmail, TargetID: data.Email}
+	defer func() { ac.AuditController.Record(ctx, event, err) }()
+
 	newPhone, err := helpers.FormatIndonesianPhoneNumber(data.PhoneNumber)
 	if err != nil {
 		return nil, err
@@ -113,6 +412,8 @@ func (ac *AuthController) SignUp(ctx context.Context, data *authEntity.SignUpReq
 		return nil, errors.New("user can't created")
 	}
 
+	event.UserID = newUser.ID
+
 	err = ac.VerificationController.DeleteOTP(ctx, authEnum.VerificationPhone, user.PhoneNumber)
 	if err != nil && err != ErrorOTPDataEmpty {
 		return nil, err
@@ -126,17 +427,33 @@ func (ac *AuthController) SignUp(ctx context.Context, data *authEntity.SignUpReq
 	return newUser, nil
 }
 
-func (ac *AuthController) SignOut(ctx context.Context, id int64) (success bool, err error) {
-	// remove refresh_token from database by user id
-	err = ac.UserController.UpdateRefreshToken(ctx, true, id, nil)
+// SignOut revokes the access token and the session it was issued for, other devices stay signed in.
+func (ac *AuthController) SignOut(ctx context.Context, claims *helpers.JWTClaims) (success bool, err error) {
+	event := &auditEntity.Event{Action: auditEntity.ActionSignOut, UserID: claims.ID, Metadata: map[string]interface{}{"session_id": claims.SessionID}}
+	defer func() { ac.AuditController.Record(ctx, event, err) }()
+
+	err = ac.SessionController.RevokeAccessToken(ctx, claims)
 	if err != nil {
 		return false, err
 	}
 
-	return true, err
+	// tokens issued before sessions existed carry no session id, there is no session to revoke for them
+	if claims.SessionID == "" {
+		return true, nil
+	}
+
+	err = ac.SessionController.RevokeSession(ctx, claims.ID, claims.SessionID)
+	if err != nil && !errors.Is(err, sessionController.ErrorSessionNotFound) {
+		return false, err
+	}
+
+	return true, nil
 }
 
-func (ac *AuthController) RefreshToken(ctx context.Context, providedRefreshToken string) (res *authEntity.AuthResponse, err error) {
+func (ac *AuthController) RefreshToken(ctx context.Context, providedRefreshToken string, client sessionEntity.ClientInfo) (res *authEntity.AuthResponse, err error) {
+	event := &auditEntity.Event{Action: auditEntity.ActionTokenRefresh, Metadata: map[string]interface{}{}}
+	defer func() { ac.AuditController.Record(ctx, event, err) }()
+
 	if providedRefreshToken == "" {
 		return nil, errors.New("refresh token is required")
 	}
@@ -146,7 +463,7 @@ func (ac *AuthController) RefreshToken(ctx context.Context, providedRefreshToken
 		return nil, errors.New("failed to initialize JWT instance")
 	}
 
-	claims, valid, err := jwt.VerifyToken(jwt.RefreshToken.SecretKey, providedRefreshToken)
+	claims, valid, err := jwt.VerifyToken(helpers.TokenKindRefresh, providedRefreshToken)
 	if err != nil {
 		return nil, fmt.Errorf("failed to verify refresh token: %v", err)
 	}
@@ -160,26 +477,35 @@ func (ac *AuthController) RefreshToken(ctx context.Context, providedRefreshToken
 		return nil, errors.New("invalid refresh token")
 	}
 
-	userRefreshToken, err := ac.UserController.GetRefreshTokenByID(ctx, int64(userID))
+	event.UserID = int64(userID)
+
+	sessionID, ok := claims["sid"].(string)
+	if !ok || sessionID == "" {
+		return nil, sessionController.ErrorInvalidRefreshToken
+	}
+
+	event.Metadata["session_id"] = sessionID
+
+	user, err := ac.UserController.GetUserByID(ctx, int64(userID))
 	if err != nil {
 		return nil, err
 	}
 
-	if userRefreshToken == nil || *userRefreshToken != providedRefreshToken {
-		return nil, errors.New("invalid refresh token")
+	if user.IsSuspended {
+		return nil, userController.ErrorUserSuspended
 	}
 
-	user, err := ac.UserController.GetUserByID(ctx, int64(userID))
+	user.Roles, err = ac.RoleController.GetUserRoles(ctx, user.ID)
 	if err != nil {
 		return nil, err
 	}
 
-	token, err := jwt.GenerateToken(user)
+	token, err := jwt.GenerateToken(user, sessionID)
 	if err != nil {
 		return nil, err
 	}
 
-	err = ac.UserController.UpdateRefreshToken(ctx, true, user.ID, &token.RefreshToken)
+	err = ac.SessionController.RotateRefreshToken(ctx, user.ID, sessionID, providedRefreshToken, token.RefreshToken, client)
 	if err != nil {
 		return nil, err
 	}
@@ -192,6 +518,477 @@ func (ac *AuthController) RefreshToken(ctx context.Context, providedRefreshToken
 	return res, err
 }
 
+// ForgotPassword sends a reset token to the email owner. It returns no error when the email is not registered,
+// so the caller can not use it to find out which emails have an account.
+func (ac *AuthController) ForgotPassword(ctx context.Context, email string) (err error) {
+	event := &auditEntity.Event{Action: auditEntity.ActionPasswordForgot, TargetType: auditEntity.TargetEmail, TargetID: email}
+	defer func() { ac.AuditController.Record(ctx, event, err) }()
+
+	if !helpers.IsEmailValid(email) {
+		return ErrorInvalidEmail
+	}
+
+	user, err := ac.UserController.GetUserByEmail(ctx, email)
+	if err != nil && !errors.Is(err, userController.ErrorUserNotFound) {
+		return err
+	}
+
+	if user == nil {
+		return nil
+	}
+
+	event.UserID = user.ID
+
+	return ac.sendPasswordReset(ctx, user)
+}
+
+// sendPasswordReset emails the user a token to choose a new password with.
+func (ac *AuthController) sendPasswordReset(ctx context.Context, user *userEntity.User) (err error) {
+	token, err := ac.VerificationController.CreatePasswordResetToken(ctx, user.ID, user.Email)
+	if err != nil {
+		return err
+	}
+
+	mailTemplate := PasswordResetMailTemplate{
+		RecipientName: user.Email,
+		ResetLink:     ac.buildPasswordResetLink(token),
+		Token:         token,
+		Duration:      helpers.FormatDuration(passwordResetExpiration),
+	}
+
+	go func(templateData PasswordResetMailTemplate) {
+		err := ac.VerificationController.SendMailTemplate(templateData.RecipientName, "Reset Your Password", passwordResetMailHtmlTemplate, templateData)
+		if err != nil {
+			log.Errorf("SendPasswordResetEmail err: %v", err)
+		}
+	}(mailTemplate)
+
+	return nil
+}
+
+// DenyLogin handles the "this wasn't me" report of a login alert. The password is replaced by a random one and
+// every session revoked, so nobody can sign in with the password until the user resets it from the email sent to
+// them. Passkeys and identity providers added since the reported sign in are removed as well, they would let
+// whoever signed in back in without the password.
+func (ac *AuthController) DenyLogin(ctx context.Context, token string, client sessionEntity.ClientInfo) (err error) {
+	event := &auditEntity.Event{Action: auditEntity.ActionLoginDeny}
+	defer func() { ac.AuditController.Record(ctx, event, err) }()
+
+	alertData, err := ac.VerificationController.ConsumeLoginAlertToken(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	event.UserID = alertData.UserID
+
+	user, err := ac.UserController.GetUserByID(ctx, alertData.UserID)
+	if err != nil {
+		return err
+	}
+
+	password, err := helpers.GenerateRandomToken(passwordResetTokenLength)
+	if err != nil {
+		return err
+	}
+
+	err = ac.UserController.UpdatePassword(ctx, user.ID, password)
+	if err != nil {
+		return err
+	}
+
+	err = ac.SessionController.RevokeAllSessions(ctx, user.ID, "")
+	if err != nil {
+		return err
+	}
+
+	err = ac.SessionController.RevokeUserTokens(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	signedInAt := time.Unix(alertData.SignedInAt, 0)
+	removedPasskeys, err := ac.WebAuthnController.DeleteCredentialsCreatedSince(ctx, user.ID, signedInAt)
+	if err != nil {
+		return err
+	}
+
+	removedIdentities, err := ac.OAuthController.DeleteIdentitiesCreatedSince(ctx, user.ID, signedInAt)
+	if err != nil {
+		return err
+	}
+
+	event.Metadata = map[string]interface{}{
+		"removed_passkeys":   removedPasskeys,
+		"removed_identities": removedIdentities,
+	}
+
+	err = ac.SessionController.RecordSecurityEvent(ctx, user.ID, "", sessionEntity.SecurityEventLoginDenied, client)
+	if err != nil {
+		return err
+	}
+
+	return ac.sendPasswordReset(ctx, user)
+}
+
+func (ac *AuthController) ResetPassword(ctx context.Context, data *authEntity.ResetPasswordRequest) (err error) {
+	event := &auditEntity.Event{Action: auditEntity.ActionPasswordReset}
+	defer func() { ac.AuditController.Record(ctx, event, err) }()
+
+	if len(data.Password) < minPasswordLength {
+		return errorPasswordTooShort
+	}
+
+	resetData, err := ac.VerificationController.ConsumePasswordResetToken(ctx, data.Token)
+	if err != nil {
+		return err
+	}
+
+	event.UserID = resetData.UserID
+
+	err = ac.UserController.UpdatePassword(ctx, resetData.UserID, data.Password)
+	if err != nil {
+		return err
+	}
+
+	// revoke every session and access token so each device has to sign in with the new password
+	err = ac.SessionController.RevokeAllSessions(ctx, resetData.UserID, "")
+	if err != nil {
+		return err
+	}
+
+	err = ac.SessionController.RevokeUserTokens(ctx, resetData.UserID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ChangePassword replaces the password of a signed-in user. Sessions on other devices are revoked, the session
+// the request was made from stays signed in.
+func (ac *AuthController) ChangePassword(ctx context.Context, id int64, sessionID string, data *authEntity.ChangePasswordRequest) (err error) {
+	event := &auditEntity.Event{Action: auditEntity.ActionPasswordChange, UserID: id}
+	defer func() { ac.AuditController.Record(ctx, event, err) }()
+
+	if len(data.NewPassword) < minPasswordLength {
+		return errorPasswordTooShort
+	}
+
+	if data.CurrentPassword == data.NewPassword {
+		return errorPasswordNotChanged
+	}
+
+	passwordHash, err := ac.UserController.GetPasswordByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if !helpers.VerifyPassword(data.CurrentPassword, *passwordHash) {
+		return ErrorInvalidCurrentPassword
+	}
+
+	err = ac.UserController.UpdatePassword(ctx, id, data.NewPassword)
+	if err != nil {
+		return err
+	}
+
+	return ac.SessionController.RevokeAllSessions(ctx, id, sessionID)
+}
+
+// StepUp confirms the password, and the second factor when it is enabled, of a signed in user and marks the
+// session as freshly authenticated. Failures count towards the sign in lockout.
+func (ac *AuthController) StepUp(ctx context.Context, id int64, sessionID string, data *authEntity.StepUpRequest, client sessionEntity.ClientInfo) (err error) {
+	event := &auditEntity.Event{Action: auditEntity.ActionStepUp, UserID: id, Metadata: map[string]interface{}{"session_id": sessionID}}
+	defer func() { ac.AuditController.Record(ctx, event, err) }()
+
+	user, err := ac.UserController.GetUserByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	err = ac.LockoutController.Check(ctx, user.Email, client.IPAddress)
+	if err != nil {
+		return err
+	}
+
+	passwordHash, err := ac.UserController.GetPasswordByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if !helpers.VerifyPassword(data.Password, *passwordHash) {
+		return ac.recordSignInFailure(ctx, user.Email, client, ErrorInvalidCurrentPassword, ac.UserController.GetUserByEmail)
+	}
+
+	mfaEnabled, err := ac.MFAController.IsEnabled(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if mfaEnabled {
+		err = ac.MFAController.VerifyCode(ctx, id, data.Code)
+		if errors.Is(err, ErrorInvalidMFACode) {
+			return ac.recordSignInFailure(ctx, user.Email, client, err, ac.UserController.GetUserByEmail)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	err = ac.LockoutController.Reset(ctx, user.Email)
+	if err != nil {
+		return err
+	}
+
+	err = ac.SessionController.MarkAuthenticated(ctx, id, sessionID)
+	if err != nil {
+		return err
+	}
+
+	return ac.SessionController.RecordSecurityEvent(ctx, id, sessionID, sessionEntity.SecurityEventStepUp, client)
+}
+
+// UnlockUser lifts the sign in lockout of the user before it expires, both the lockout of the email and of the
+// phone number. The lockout of the ip address is only lifted when the request names it.
+func (ac *AuthController) UnlockUser(ctx context.Context, id int64, data *authEntity.UnlockUserRequest, client sessionEntity.ClientInfo) (err error) {
+	event := &auditEntity.Event{Action: auditEntity.ActionAccountUnlock, TargetType: auditEntity.TargetUser, TargetID: strconv.FormatInt(id, 10), UserID: id}
+	defer func() { ac.AuditController.Record(ctx, event, err) }()
+
+	user, err := ac.UserController.GetUserByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	err = ac.LockoutController.Reset(ctx, user.Email)
+	if err != nil {
+		return err
+	}
+
+	if phone, formatErr := helpers.FormatIndonesianPhoneNumber(user.PhoneNumber); formatErr == nil {
+		err = ac.LockoutController.Reset(ctx, phone)
+		if err != nil {
+			return err
+		}
+	}
+
+	if data != nil && data.IPAddress != "" {
+		err = ac.LockoutController.ResetIPAddress(ctx, data.IPAddress)
+		if err != nil {
+			return err
+		}
+	}
+
+	return ac.SessionController.RecordSecurityEvent(ctx, user.ID, "", sessionEntity.SecurityEventAccountUnlocked, client)
+}
+
+// RequestMagicLink emails a single use sign in link to the email owner. The returned nonce has to be kept by the
+// requesting browser, the link only signs in together with it. Like ForgotPassword it does not reveal whether the
+// email is registered, a nonce is returned either way.
+func (ac *AuthController) RequestMagicLink(ctx context.Context, email string) (nonce *authEntity.MagicLinkNonce, err error) {
+	event := &auditEntity.Event{Action: auditEntity.ActionMagicLinkRequest, TargetType: auditEntity.TargetEmail, TargetID: email}
+	defer func() { ac.AuditController.Record(ctx, event, err) }()
+
+	if !helpers.IsEmailValid(email) {
+		return nil, ErrorInvalidEmail
+	}
+
+	value, err := helpers.GenerateRandomToken(magicLinkNonceLength)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce = &authEntity.MagicLinkNonce{
+		Value:     value,
+		ExpiresIn: int64(magicLinkExpiration.Seconds()),
+	}
+
+	user, err := ac.UserController.GetUserByEmail(ctx, email)
+	if err != nil && !errors.Is(err, userController.ErrorUserNotFound) {
+		return nil, err
+	}
+
+	if user == nil || user.IsSuspended {
+		return nonce, nil
+	}
+
+	event.UserID = user.ID
+
+	token, err := ac.VerificationController.CreateMagicLinkToken(ctx, user.ID, user.Email, nonce.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	mailTemplate := MagicLinkMailTemplate{
+		RecipientName: user.Email,
+		SignInLink:    ac.buildMagicLink(token),
+		Token:         token,
+		Duration:      helpers.FormatDuration(magicLinkExpiration),
+	}
+
+	go func(templateData MagicLinkMailTemplate) {
+		err := ac.VerificationController.SendMailTemplate(templateData.RecipientName, "Your Sign In Link", magicLinkMailHtmlTemplate, templateData)
+		if err != nil {
+			log.Errorf("SendMagicLinkEmail err: %v", err)
+		}
+	}(mailTemplate)
+
+	return nonce, nil
+}
+
+// SignInWithMagicLink exchanges a magic link token for tokens through the same path as SignIn, so two-factor
+// authentication is still enforced.
+func (ac *AuthController) SignInWithMagicLink(ctx context.Context, token string, nonce string, client sessionEntity.ClientInfo) (res *authEntity.AuthResponse, challenge *authEntity.MFAChallenge, err error) {
+	event := newSignInEvent(auditEntity.MethodMagicLink, "", "")
+	defer func() { ac.recordSignIn(ctx, event, client, challenge, err) }()
+
+	data, err := ac.VerificationController.ConsumeMagicLinkToken(ctx, token, nonce)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	event.TargetType = auditEntity.TargetEmail
+	event.TargetID = data.Email
+	event.UserID = data.UserID
+
+	user, err := ac.UserController.GetUserByID(ctx, data.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// the email may have changed after the link was sent
+	if user.Email != data.Email {
+		return nil, nil, ErrorInvalidMagicLink
+	}
+
+	return ac.completeSignIn(ctx, user, client)
+}
+
+// RequestPhoneSignIn texts a sign in code to the owner of the phone number. Unknown numbers get no message but no
+// error either, so the caller can not use it to find out which numbers have an account.
+func (ac *AuthController) RequestPhoneSignIn(ctx context.Context, phoneNumber string) (err error) {
+	event := &auditEntity.Event{Action: auditEntity.ActionPhoneSignInRequest, TargetType: auditEntity.TargetPhoneNumber, TargetID: phoneNumber}
+	defer func() { ac.AuditController.Record(ctx, event, err) }()
+
+	if ac.OTP == nil || !ac.OTP.Enable {
+		return ErrorPhoneSignInDisabled
+	}
+
+	phone, err := helpers.FormatIndonesianPhoneNumber(phoneNumber)
+	if err != nil {
+		return ErrorInvalidPhoneNumber
+	}
+
+	event.TargetID = phone
+
+	user, err := ac.UserController.GetUserByPhoneNumber(ctx, phone)
+	if err != nil && !errors.Is(err, userController.ErrorUserNotFound) {
+		return err
+	}
+
+	// a number nobody verified may belong to someone else than the account holder
+	if user == nil || user.IsSuspended || !user.IsPhoneVerified {
+		return nil
+	}
+
+	event.UserID = user.ID
+
+	err = ac.VerificationController.CreateOTP(ctx, authEnum.VerificationPhoneSignIn, phone)
+	if errors.Is(err, errorOTPAlreadyExists) {
+		err = ac.VerificationController.ResendOTP(ctx, authEnum.VerificationPhoneSignIn, phone)
+	}
+
+	if errors.Is(err, errorOTPMaxAttempts) {
+		return ErrorSignInCodeLimitReached
+	}
+
+	return err
+}
+
+// SignInWithPhone exchanges a sign in code for tokens through the same path as SignIn. Wrong codes count towards
+// the lockout of the phone number like wrong passwords do for an email.
+func (ac *AuthController) SignInWithPhone(ctx context.Context, data *authEntity.PhoneSignInVerifyRequest, client sessionEntity.ClientInfo) (res *authEntity.AuthResponse, challenge *authEntity.MFAChallenge, err error) {
+	event := newSignInEvent(auditEntity.MethodPhone, auditEntity.TargetPhoneNumber, data.PhoneNumber)
+	defer func() { ac.recordSignIn(ctx, event, client, challenge, err) }()
+
+	if ac.OTP == nil || !ac.OTP.Enable {
+		return nil, nil, ErrorPhoneSignInDisabled
+	}
+
+	phone, err := helpers.FormatIndonesianPhoneNumber(data.PhoneNumber)
+	if err != nil {
+		return nil, nil, ErrorInvalidPhoneNumber
+	}
+
+	event.TargetID = phone
+
+	err = ac.LockoutController.Check(ctx, phone, client.IPAddress)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// a sign in code is single use, it is removed by the request that redeems it
+	err = ac.VerificationController.ConsumeOTP(ctx, authEnum.VerificationPhoneSignIn, phone, data.OTP)
+	if errors.Is(err, ErrorOTPDataEmpty) || errors.Is(err, errorOTPDataExpired) || errors.Is(err, errorOTPNotMatch) {
+		return nil, nil, ac.recordSignInFailure(ctx, phone, client, ErrorInvalidSignInCode, ac.UserController.GetUserByPhoneNumber)
+	}
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	user, err := ac.UserController.GetUserByPhoneNumber(ctx, phone)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !user.IsPhoneVerified {
+		return nil, nil, ErrorInvalidSignInCode
+	}
+
+	err = ac.LockoutController.Reset(ctx, phone)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	event.UserID = user.ID
+
+	return ac.completeSignIn(ctx, user, client)
+}
+
+func (ac *AuthController) buildPasswordResetLink(token string) string {
+	if ac.PasswordReset == nil {
+		return ""
+	}
+
+	return buildTokenLink(ac.PasswordReset.URL, token)
+}
+
+func (ac *AuthController) buildMagicLink(token string) string {
+	if ac.MagicLink == nil {
+		return ""
+	}
+
+	return buildTokenLink(ac.MagicLink.URL, token)
+}
+
+func buildTokenLink(rawURL string, token string) string {
+	if rawURL == "" {
+		return ""
+	}
+
+	linkURL, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+
+	query := linkURL.Query()
+	query.Set("token", token)
+	linkURL.RawQuery = query.Encode()
+
+	return linkURL.String()
+}
+
 func (ac *AuthController) CheckOTPVerificationOTP(ctx context.Context, user *userEntity.User) (err error) {
 	data := *user
 	otpPhone, err := ac.VerificationController.GetOTP(ctx, authEnum.VerificationPhone, data.PhoneNumber)
@@ -0,0 +1,217 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"github.com/winartodev/apollo/core/configs"
+	authEntity "github.com/winartodev/apollo/modules/auth/entities"
+	sessionEntity "github.com/winartodev/apollo/modules/session/entities"
+	"slices"
+	"testing"
+	"time"
+)
+
+func (f *fakeVerificationController) ConsumeLoginAlertToken(ctx context.Context, token string) (data *authEntity.LoginAlertData, err error) {
+	if f.alert == nil || token != "token" {
+		return nil, ErrorInvalidLoginAlert
+	}
+
+	return f.alert, nil
+}
+
+func (f *fakeVerificationController) CreatePasswordResetToken(ctx context.Context, userID int64, email string) (token string, err error) {
+	f.tokens++
+	return "reset", nil
+}
+
+func (f *fakeUserController) UpdatePassword(ctx context.Context, id int64, password string) (err error) {
+	f.passwordUpdates++
+	return nil
+}
+
+func (f *fakeSessionController) RevokeAllSessions(ctx context.Context, userID int64, exceptSessionID string) (err error) {
+	f.revokedSessions = append(f.revokedSessions, "*")
+	return nil
+}
+
+func (f *fakeSessionController) RevokeUserTokens(ctx context.Context, userID int64) (err error) {
+	f.revokedTokens = append(f.revokedTokens, "*")
+	return nil
+}
+
+func (f *fakeSessionController) RecordSecurityEvent(ctx context.Context, userID int64, sessionID string, eventType string, client sessionEntity.ClientInfo) (err error) {
+	f.events = append(f.events, eventType)
+	return nil
+}
+
+// fakeWebAuthnController holds the creation times of the passkeys of the user.
+type fakeWebAuthnController struct {
+	credentials []time.Time
+}
+
+func (f *fakeWebAuthnController) DeleteCredentialsCreatedSince(ctx context.Context, userID int64, since time.Time) (deleted int64, err error) {
+	f.credentials, deleted = deleteCreatedSince(f.credentials, since)
+	return deleted, nil
+}
+
+// fakeOAuthController holds the creation times of the identities of the user.
+type fakeOAuthController struct {
+	identities []time.Time
+}
+
+func (f *fakeOAuthController) DeleteIdentitiesCreatedSince(ctx context.Context, userID int64, since time.Time) (deleted int64, err error) {
+	f.identities, deleted = deleteCreatedSince(f.identities, since)
+	return deleted, nil
+}
+
+func deleteCreatedSince(createdAt []time.Time, since time.Time) (kept []time.Time, deleted int64) {
+	for _, created := range createdAt {
+		if created.Before(since) {
+			kept = append(kept, created)
+		} else {
+			deleted++
+		}
+	}
+
+	return kept, deleted
+}
+
+func TestAuthController_DenyLogin(t *testing.T) {
+	signedInAt := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	tests := []struct {
+		name              string
+		alert             *authEntity.LoginAlertData
+		wantErr           error
+		wantPasskeys      int
+		wantIdentities    int
+		wantSecuredUser   bool
+		wantPasswordReset bool
+	}{
+		{
+			name:              "success_removes_what_was_added_since_the_sign_in",
+			alert:             &authEntity.LoginAlertData{UserID: 1, Email: "apollo@gmail.com", SignedInAt: signedInAt.Unix()},
+			wantPasskeys:      1,
+			wantIdentities:    1,
+			wantSecuredUser:   true,
+			wantPasswordReset: true,
+		},
+		{
+			name:           "failed_invalid_token",
+			wantErr:        ErrorInvalidLoginAlert,
+			wantPasskeys:   2,
+			wantIdentities: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verification := &fakeVerificationController{alert: tt.alert}
+			user := &fakeUserController{}
+			session := &fakeSessionController{}
+			webAuthn := &fakeWebAuthnController{credentials: []time.Time{signedInAt.Add(-24 * time.Hour), signedInAt.Add(time.Minute)}}
+			oauth := &fakeOAuthController{identities: []time.Time{signedInAt.Add(-time.Minute), signedInAt}}
+
+			controller := NewAuthController(AuthController{
+				PasswordReset:          &configs.PasswordReset{URL: "http://localhost/reset"},
+				VerificationController: verification,
+				SessionController:      session,
+				UserController:         user,
+				AuditController:        &fakeAuditController{},
+				WebAuthnController:     webAuthn,
+				OAuthController:        oauth,
+			})
+
+			err := controller.DenyLogin(context.Background(), "token", sessionEntity.ClientInfo{})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("DenyLogin() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if len(webAuthn.credentials) != tt.wantPasskeys || len(oauth.identities) != tt.wantIdentities {
+				t.Errorf("DenyLogin() kept %d passkeys and %d identities, want %d and %d", len(webAuthn.credentials), len(oauth.identities), tt.wantPasskeys, tt.wantIdentities)
+			}
+
+			secured := user.passwordUpdates == 1 && len(session.revokedSessions) == 1 && len(session.revokedTokens) == 1 &&
+				slices.Contains(session.events, sessionEntity.SecurityEventLoginDenied)
+			if secured != tt.wantSecuredUser {
+				t.Errorf("DenyLogin() secured user = %v, want %v", secured, tt.wantSecuredUser)
+			}
+
+			if (verification.tokens == 1) != tt.wantPasswordReset {
+				t.Errorf("DenyLogin() sent password reset = %v, want %v", verification.tokens == 1, tt.wantPasswordReset)
+			}
+		})
+	}
+}
+
+func TestAuthController_UnlockUser(t *testing.T) {
+	const ipAddress = "203.0.113.7"
+
+	tests := []struct {
+		name          string
+		phoneNumber   string
+		request       *authEntity.UnlockUserRequest
+		wantPhoneLock bool
+		wantIPLock    bool
+	}{
+		{
+			name:        "success_lifts_email_phone_and_ip_address_lockouts",
+			phoneNumber: "0812-3456-7890",
+			request:     &authEntity.UnlockUserRequest{IPAddress: ipAddress},
+		},
+		{
+			name:        "success_keeps_ip_address_lockout_not_named",
+			phoneNumber: "0812-3456-7890",
+			request:     &authEntity.UnlockUserRequest{},
+			wantIPLock:  true,
+		},
+		{
+			name:          "success_user_without_phone_number",
+			request:       &authEntity.UnlockUserRequest{IPAddress: ipAddress},
+			wantPhoneLock: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lockout := NewLockoutController(LockoutController{
+				Lockout:           &configs.Lockout{MaxAttempts: 2, IPMaxAttempts: 4, Duration: 15},
+				LockoutRepository: newFakeLockoutRepository(),
+			})
+
+			for _, value := range []string{"apollo@gmail.com", "apollo@gmail.com", "+6281234567890", "+6281234567890"} {
+				_, err := lockout.RecordFailure(context.Background(), value, ipAddress)
+				if err != nil {
+					t.Fatalf("RecordFailure() error = %v", err)
+				}
+			}
+
+			session := &fakeSessionController{}
+			controller := NewAuthController(AuthController{
+				LockoutController: lockout,
+				SessionController: session,
+				UserController:    &fakeUserController{phoneNumber: tt.phoneNumber},
+				AuditController:   &fakeAuditController{},
+			})
+
+			err := controller.UnlockUser(context.Background(), 1, tt.request, sessionEntity.ClientInfo{})
+			if err != nil {
+				t.Fatalf("UnlockUser() error = %v", err)
+			}
+
+			if err = lockout.Check(context.Background(), "apollo@gmail.com", ""); err != nil {
+				t.Errorf("Check() email error = %v, want nil", err)
+			}
+
+			if err = lockout.Check(context.Background(), "+6281234567890", ""); (err != nil) != tt.wantPhoneLock {
+				t.Errorf("Check() phone number error = %v, want locked %v", err, tt.wantPhoneLock)
+			}
+
+			if err = lockout.Check(context.Background(), "other@gmail.com", ipAddress); (err != nil) != tt.wantIPLock {
+				t.Errorf("Check() ip address error = %v, want locked %v", err, tt.wantIPLock)
+			}
+
+			if !slices.Contains(session.events, sessionEntity.SecurityEventAccountUnlocked) {
+				t.Errorf("UnlockUser() events = %v, want %q", session.events, sessionEntity.SecurityEventAccountUnlocked)
+			}
+		})
+	}
+}
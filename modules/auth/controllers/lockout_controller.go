@@ -0,0 +1,216 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"github.com/winartodev/apollo/core/configs"
+	authRepo "github.com/winartodev/apollo/modules/auth/repositories"
+	"math"
+	"strings"
+	"time"
+)
+
+const (
+	lockoutScopeAccount = "account"
+	lockoutScopeIP      = "ip"
+
+	defaultLockoutMaxAttempts   = 5
+	defaultLockoutIPMaxAttempts = 50
+	defaultLockoutWindow        = 15 * time.Minute
+	defaultLockoutDuration      = 15 * time.Minute
+	defaultLockoutDelayAfter    = 3
+	defaultLockoutBaseDelay     = time.Second
+	defaultLockoutMaxDelay      = 30 * time.Second
+)
+
+// LockoutError is returned while sign in is blocked. Locked tells an account lockout apart from a delay or an
+// ip lockout, RetryAfter is how long the caller has to wait.
+type LockoutError struct {
+	Locked     bool
+	RetryAfter time.Duration
+}
+
+func (e *LockoutError) Error() string {
+	if e.Locked {
+		return fmt.Sprintf("account is temporarily locked, try again in %s", e.RetryAfter.Round(time.Second))
+	}
+
+	return fmt.Sprintf("too many failed sign in attempts, try again in %s", e.RetryAfter.Round(time.Second))
+}
+
+// RetryAfterSeconds rounds up so the caller never retries before the block is lifted.
+func (e *LockoutError) RetryAfterSeconds() int64 {
+	return int64(math.Ceil(e.RetryAfter.Seconds()))
+}
+
+type LockoutControllerItf interface {
+	Check(ctx context.Context, email string, ipAddress string) (err error)
+	RecordFailure(ctx context.Context, email string, ipAddress string) (locked bool, err error)
+	Reset(ctx context.Context, email string) (err error)
+	ResetIPAddress(ctx context.Context, ipAddress string) (err error)
+}
+
+type LockoutController struct {
+	Lockout           *configs.Lockout
+	LockoutRepository authRepo.LockoutRepositoryItf
+}
+
+func NewLockoutController(controller LockoutController) LockoutControllerItf {
+	return &LockoutController{
+		Lockout:           controller.Lockout,
+		LockoutRepository: controller.LockoutRepository,
+	}
+}
+
+// Check returns a LockoutError when the account or the ip address may not attempt to sign in right now.
+func (lc *LockoutController) Check(ctx context.Context, email string, ipAddress string) (err error) {
+	lockTTL, delayTTL, err := lc.LockoutRepository.GetBlockRedis(ctx, lockoutScopeAccount, normalizeLockoutEmail(email))
+	if err != nil {
+		return err
+	}
+
+	if lockTTL > 0 {
+		return &LockoutError{Locked: true, RetryAfter: lockTTL}
+	}
+
+	if ipAddress != "" {
+		ipLockTTL, _, err := lc.LockoutRepository.GetBlockRedis(ctx, lockoutScopeIP, ipAddress)
+		if err != nil {
+			return err
+		}
+
+		if ipLockTTL > 0 {
+			return &LockoutError{RetryAfter: ipLockTTL}
+		}
+	}
+
+	if delayTTL > 0 {
+		return &LockoutError{RetryAfter: delayTTL}
+	}
+
+	return nil
+}
+
+// RecordFailure counts a failed attempt for the account and the ip address. Every failure past DelayAfter makes
+// the next attempt wait twice as long, reaching MaxAttempts locks the account for the lockout duration.
+func (lc *LockoutController) RecordFailure(ctx context.Context, email string, ipAddress string) (locked bool, err error) {
+	email = normalizeLockoutEmail(email)
+
+	count, err := lc.LockoutRepository.IncrementFailureRedis(ctx, lockoutScopeAccount, email, lc.window())
+	if err != nil {
+		return false, err
+	}
+
+	if count >= int64(lc.maxAttempts()) {
+		err = lc.LockoutRepository.SetLockRedis(ctx, lockoutScopeAccount, email, lc.duration())
+		if err != nil {
+			return false, err
+		}
+
+		locked = true
+	} else if count >= int64(lc.delayAfter()) {
+		err = lc.LockoutRepository.SetDelayRedis(ctx, lockoutScopeAccount, email, lc.delay(count))
+		if err != nil {
+			return false, err
+		}
+	}
+
+	if ipAddress == "" {
+		return locked, nil
+	}
+
+	ipCount, err := lc.LockoutRepository.IncrementFailureRedis(ctx, lockoutScopeIP, ipAddress, lc.window())
+	if err != nil {
+		return false, err
+	}
+
+	if ipCount >= int64(lc.ipMaxAttempts()) {
+		err = lc.LockoutRepository.SetLockRedis(ctx, lockoutScopeIP, ipAddress, lc.duration())
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return locked, nil
+}
+
+// Reset clears the failures, delay and lock of the account. Failures of the ip address are kept, so signing in
+// to one account does not reset the attempts made against others.
+func (lc *LockoutController) Reset(ctx context.Context, email string) (err error) {
+	return lc.LockoutRepository.DeleteLockoutRedis(ctx, lockoutScopeAccount, normalizeLockoutEmail(email))
+}
+
+// ResetIPAddress clears the failures and lock of the ip address.
+func (lc *LockoutController) ResetIPAddress(ctx context.Context, ipAddress string) (err error) {
+	return lc.LockoutRepository.DeleteLockoutRedis(ctx, lockoutScopeIP, ipAddress)
+}
+
+func (lc *LockoutController) delay(count int64) time.Duration {
+	exponent := float64(count - int64(lc.delayAfter()))
+	delay := time.Duration(float64(lc.baseDelay()) * math.Pow(2, exponent))
+	if delay <= 0 || delay > lc.maxDelay() {
+		return lc.maxDelay()
+	}
+
+	return delay
+}
+
+func (lc *LockoutController) maxAttempts() int {
+	if lc.Lockout == nil || lc.Lockout.MaxAttempts <= 0 {
+		return defaultLockoutMaxAttempts
+	}
+
+	return lc.Lockout.MaxAttempts
+}
+
+func (lc *LockoutController) ipMaxAttempts() int {
+	if lc.Lockout == nil || lc.Lockout.IPMaxAttempts <= 0 {
+		return defaultLockoutIPMaxAttempts
+	}
+
+	return lc.Lockout.IPMaxAttempts
+}
+
+func (lc *LockoutController) window() time.Duration {
+	if lc.Lockout == nil || lc.Lockout.Window <= 0 {
+		return defaultLockoutWindow
+	}
+
+	return time.Duration(lc.Lockout.Window) * time.Minute
+}
+
+func (lc *LockoutController) duration() time.Duration {
+	if lc.Lockout == nil || lc.Lockout.Duration <= 0 {
+		return defaultLockoutDuration
+	}
+
+	return time.Duration(lc.Lockout.Duration) * time.Minute
+}
+
+func (lc *LockoutController) delayAfter() int {
+	if lc.Lockout == nil || lc.Lockout.DelayAfter <= 0 {
+		return defaultLockoutDelayAfter
+	}
+
+	return lc.Lockout.DelayAfter
+}
+
+func (lc *LockoutController) baseDelay() time.Duration {
+	if lc.Lockout == nil || lc.Lockout.BaseDelay <= 0 {
+		return defaultLockoutBaseDelay
+	}
+
+	return time.Duration(lc.Lockout.BaseDelay) * time.Second
+}
+
+func (lc *LockoutController) maxDelay() time.Duration {
+	if lc.Lockout == nil || lc.Lockout.MaxDelay <= 0 {
+		return defaultLockoutMaxDelay
+	}
+
+	return time.Duration(lc.Lockout.MaxDelay) * time.Second
+}
+
+func normalizeLockoutEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
@@ -0,0 +1,150 @@
+package controllers
+
+import (
+	"context"
+	"github.com/winartodev/apollo/core/configs"
+	authRepo "github.com/winartodev/apollo/modules/auth/repositories"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fakeLockoutRepository keeps the failures, locks and delays keyed by scope and value, blocks never run out.
+type fakeLockoutRepository struct {
+	authRepo.LockoutRepositoryItf
+	failures map[string]int64
+	locks    map[string]time.Duration
+	delays   map[string]time.Duration
+}
+
+func newFakeLockoutRepository() *fakeLockoutRepository {
+	return &fakeLockoutRepository{
+		failures: map[string]int64{},
+		locks:    map[string]time.Duration{},
+		delays:   map[string]time.Duration{},
+	}
+}
+
+func (f *fakeLockoutRepository) IncrementFailureRedis(ctx context.Context, scope string, value string, window time.Duration) (count int64, err error) {
+	f.failures[scope+":"+value]++
+	return f.failures[scope+":"+value], nil
+}
+
+func (f *fakeLockoutRepository) SetLockRedis(ctx context.Context, scope string, value string, ttl time.Duration) (err error) {
+	f.locks[scope+":"+value] = ttl
+	return nil
+}
+
+func (f *fakeLockoutRepository) SetDelayRedis(ctx context.Context, scope string, value string, ttl time.Duration) (err error) {
+	f.delays[scope+":"+value] = ttl
+	return nil
+}
+
+func (f *fakeLockoutRepository) GetBlockRedis(ctx context.Context, scope string, value string) (lockTTL time.Duration, delayTTL time.Duration, err error) {
+	return f.locks[scope+":"+value], f.delays[scope+":"+value], nil
+}
+
+func (f *fakeLockoutRepository) DeleteLockoutRedis(ctx context.Context, scope string, value string) (err error) {
+	delete(f.failures, scope+":"+value)
+	delete(f.locks, scope+":"+value)
+	delete(f.delays, scope+":"+value)
+	return nil
+}
+
+func TestLockoutController_RecordFailure(t *testing.T) {
+	lockout := &configs.Lockout{
+		MaxAttempts:   5,
+		IPMaxAttempts: 8,
+		Duration:      15,
+		DelayAfter:    3,
+		BaseDelay:     1,
+		MaxDelay:      3,
+	}
+
+	tests := []struct {
+		name       string
+		failures   []string
+		reset      bool
+		email      string
+		wantLocked bool
+		wantErr    *LockoutError
+	}{
+		{
+			name:     "success_failures_below_delay",
+			failures: []string{"apollo@gmail.com", "apollo@gmail.com"},
+			email:    "apollo@gmail.com",
+		},
+		{
+			name:     "failed_delay_after_failures",
+			failures: []string{"apollo@gmail.com", "apollo@gmail.com", "apollo@gmail.com"},
+			email:    "apollo@gmail.com",
+			wantErr:  &LockoutError{RetryAfter: time.Second},
+		},
+		{
+			name:     "failed_delay_doubles_up_to_max_delay",
+			failures: []string{"apollo@gmail.com", "apollo@gmail.com", "apollo@gmail.com", "Apollo@Gmail.com"},
+			email:    "apollo@gmail.com",
+			wantErr:  &LockoutError{RetryAfter: 2 * time.Second},
+		},
+		{
+			name:       "failed_account_locked",
+			failures:   []string{"apollo@gmail.com", "apollo@gmail.com", "apollo@gmail.com", "apollo@gmail.com", "apollo@gmail.com"},
+			email:      "apollo@gmail.com",
+			wantLocked: true,
+			wantErr:    &LockoutError{Locked: true, RetryAfter: 15 * time.Minute},
+		},
+		{
+			name:     "failed_ip_address_locked",
+			failures: []string{"a@gmail.com", "b@gmail.com", "c@gmail.com", "d@gmail.com", "e@gmail.com", "f@gmail.com", "g@gmail.com", "h@gmail.com"},
+			email:    "apollo@gmail.com",
+			wantErr:  &LockoutError{RetryAfter: 15 * time.Minute},
+		},
+		{
+			name:       "success_reset_after_lock",
+			failures:   []string{"apollo@gmail.com", "apollo@gmail.com", "apollo@gmail.com", "apollo@gmail.com", "apollo@gmail.com"},
+			reset:      true,
+			email:      "apollo@gmail.com",
+			wantLocked: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			controller := NewLockoutController(LockoutController{
+				Lockout:           lockout,
+				LockoutRepository: newFakeLockoutRepository(),
+			})
+
+			var locked bool
+			for _, email := range tt.failures {
+				var err error
+				locked, err = controller.RecordFailure(context.Background(), email, "203.0.113.7")
+				if err != nil {
+					t.Fatalf("RecordFailure() error = %v", err)
+				}
+			}
+
+			if locked != tt.wantLocked {
+				t.Errorf("RecordFailure() locked = %v, want %v", locked, tt.wantLocked)
+			}
+
+			if tt.reset {
+				err := controller.Reset(context.Background(), tt.email)
+				if err != nil {
+					t.Fatalf("Reset() error = %v", err)
+				}
+			}
+
+			err := controller.Check(context.Background(), tt.email, "203.0.113.7")
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Errorf("Check() error = %v, want nil", err)
+				}
+				return
+			}
+
+			if !reflect.DeepEqual(err, tt.wantErr) {
+				t.Errorf("Check() error = %#v, want %#v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,294 @@
+package controllers
+
+import (
+	"context"
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/winartodev/apollo/core/configs"
+	"github.com/winartodev/apollo/core/helpers"
+	auditController "github.com/winartodev/apollo/modules/audit/controllers"
+	auditEntity "github.com/winartodev/apollo/modules/audit/entities"
+	userController "github.com/winartodev/apollo/modules/user/controllers"
+	userEntity "github.com/winartodev/apollo/modules/user/entities"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	loginAlertMailHtmlTemplate = "modules/auth/files/login-alert-mail-template.html"
+	loginAlertTimeFormat       = "Monday, 2 January 2006 15:04 MST"
+
+	// sign ins from the same network share the first bits of their address
+	ipv4RangeBits = 24
+	ipv6RangeBits = 48
+
+	maxLocationLength  = 255
+	maxUserAgentLength = 255
+
+	unknownLocation = "Unknown location"
+	unknownDevice   = "Unknown device"
+)
+
+var (
+	// browsers update their version on their own, the device stays the same
+	userAgentVersion = regexp.MustCompile(`[0-9]+([._][0-9]+)*`)
+
+	// checked in order, the user agents of browsers and systems name the ones they are compatible with as well
+	userAgentBrowsers = []userAgentName{
+		{token: "Edg", name: "Edge"},
+		{token: "OPR/", name: "Opera"},
+		{token: "SamsungBrowser", name: "Samsung Internet"},
+		{token: "Firefox/", name: "Firefox"},
+		{token: "FxiOS", name: "Firefox"},
+		{token: "CriOS", name: "Chrome"},
+		{token: "Chrome/", name: "Chrome"},
+		{token: "Safari/", name: "Safari"},
+	}
+	userAgentSystems = []userAgentName{
+		{token: "Windows", name: "Windows"},
+		{token: "iPhone", name: "iOS"},
+		{token: "iPad", name: "iPadOS"},
+		{token: "Android", name: "Android"},
+		{token: "CrOS", name: "ChromeOS"},
+		{token: "Mac OS X", name: "macOS"},
+		{token: "Linux", name: "Linux"},
+	}
+)
+
+type userAgentName struct {
+	token string
+	name  string
+}
+
+type LoginAlertMailTemplate struct {
+	RecipientName string
+	Time          string
+	Location      string
+	Device        string
+	IPAddress     string
+	DenyLink      string
+	Token         string
+	Duration      string
+}
+
+type LoginAlertControllerItf interface {
+	Inspect(ctx context.Context, login *userEntity.LoginHistory) (err error)
+}
+
+type LoginAlertController struct {
+	LoginAlert             *configs.LoginAlert
+	GeoIP                  *helpers.MMDBReader
+	VerificationController VerificationControllerItf
+	UserController         userController.UserControllerItf
+	AuditController        auditController.AuditControllerItf
+}
+
+func NewLoginAlertController(controller LoginAlertController) LoginAlertControllerItf {
+	return &LoginAlertController{
+		LoginAlert:             controller.LoginAlert,
+		GeoIP:                  controller.GeoIP,
+		VerificationController: controller.VerificationController,
+		UserController:         controller.UserController,
+		AuditController:        controller.AuditController,
+	}
+}
+
+// Inspect identifies the device, network and location of a sign in attempt. The user is emailed about a
+// successful one from a device or network none of their previous sign ins came from, so it has to be inspected
+// before it is recorded in the login history.
+func (lc *LoginAlertController) Inspect(ctx context.Context, login *userEntity.LoginHistory) (err error) {
+	login.DeviceID = deviceID(login.UserAgent)
+	login.IPRange = ipRange(login.IPAddress)
+	login.Location = lc.locate(login.IPAddress)
+
+	if lc.LoginAlert == nil || !lc.LoginAlert.Enabled || login.Outcome != userEntity.LoginOutcomeSuccess {
+		return nil
+	}
+
+	recognition, err := lc.UserController.RecognizeLogin(ctx, login)
+	if err != nil {
+		return err
+	}
+
+	// the first identified sign in only sets what is known
+	if !recognition.HasHistory || (recognition.KnownDevice && recognition.KnownIPRange) {
+		return nil
+	}
+
+	return lc.sendAlert(ctx, login, recognition)
+}
+
+// sendAlert emails the user the details of login together with the token that reports it as not theirs.
+func (lc *LoginAlertController) sendAlert(ctx context.Context, login *userEntity.LoginHistory, recognition *userEntity.LoginRecognition) (err error) {
+	user, err := lc.UserController.GetUserByID(ctx, login.UserID)
+	if err != nil {
+		return err
+	}
+
+	// users signing in with their phone number only have nowhere to be emailed
+	if user.Email == "" {
+		return nil
+	}
+
+	event := &auditEntity.Event{
+		Action:     auditEntity.ActionLoginAlert,
+		TargetType: auditEntity.TargetEmail,
+		TargetID:   user.Email,
+		UserID:     user.ID,
+		Metadata: map[string]interface{}{
+			"new_device":  !recognition.KnownDevice,
+			"new_network": !recognition.KnownIPRange,
+		},
+	}
+	defer func() { lc.AuditController.Record(ctx, event, err) }()
+
+	now := time.Now()
+	token, err := lc.VerificationController.CreateLoginAlertToken(ctx, user.ID, user.Email, now)
+	if err != nil {
+		return err
+	}
+
+	location := login.Location
+	if location == "" {
+		location = unknownLocation
+	}
+
+	mailTemplate := LoginAlertMailTemplate{
+		RecipientName: user.Email,
+		Time:          now.UTC().Format(loginAlertTimeFormat),
+		Location:      location,
+		Device:        describeDevice(login.UserAgent),
+		IPAddress:     login.IPAddress,
+		DenyLink:      buildTokenLink(lc.LoginAlert.URL, token),
+		Token:         token,
+		Duration:      helpers.FormatDuration(loginAlertExpiration),
+	}
+
+	go func(templateData LoginAlertMailTemplate) {
+		err := lc.VerificationController.SendMailTemplate(templateData.RecipientName, "New Sign In to Your Account", loginAlertMailHtmlTemplate, templateData)
+		if err != nil {
+			log.Errorf("SendLoginAlertEmail err: %v", err)
+		}
+	}(mailTemplate)
+
+	return nil
+}
+
+// locate describes where ipAddress is as city, region and country, empty when there is no GeoIP database or it
+// does not know the address.
+func (lc *LoginAlertController) locate(ipAddress string) string {
+	ip := net.ParseIP(ipAddress)
+	if lc.GeoIP == nil || ip == nil {
+		return ""
+	}
+
+	record, err := lc.GeoIP.Lookup(ip)
+	if err != nil {
+		log.Errorf("failed to look up location of %s: %v", ipAddress, err)
+		return ""
+	}
+
+	var parts []string
+	for _, path := range [][]interface{}{
+		{"city"},
+		{"subdivisions", 0},
+		{"country"},
+	} {
+		name := geoName(record, path...)
+		if name != "" && (len(parts) == 0 || parts[len(parts)-1] != name) {
+			parts = append(parts, name)
+		}
+	}
+
+	location := strings.Join(parts, ", ")
+	if len(location) > maxLocationLength {
+		location = location[:maxLocationLength]
+	}
+
+	return location
+}
+
+// geoName reads the english name of the place at path of a GeoIP record, map keys are strings and array indexes
+// ints.
+func geoName(record interface{}, path ...interface{}) string {
+	value := record
+	for _, key := range append(path, "names", "en") {
+		switch key := key.(type) {
+		case string:
+			item, ok := value.(map[string]interface{})
+			if !ok {
+				return ""
+			}
+
+			value = item[key]
+		case int:
+			items, ok := value.([]interface{})
+			if !ok || key >= len(items) {
+				return ""
+			}
+
+			value = items[key]
+		}
+	}
+
+	name, _ := value.(string)
+	return name
+}
+
+// deviceID fingerprints the device a user agent belongs to, versions are left out so updating the browser does not
+// make a new device.
+func deviceID(userAgent string) string {
+	return helpers.HashToken(userAgentVersion.ReplaceAllString(strings.ToLower(userAgent), ""))
+}
+
+// ipRange is the network ipAddress belongs to, /24 for IPv4 and /48 for IPv6, empty when it is not an address.
+func ipRange(ipAddress string) string {
+	ip := net.ParseIP(ipAddress)
+	if ip == nil {
+		return ""
+	}
+
+	mask := net.CIDRMask(ipv6RangeBits, 8*net.IPv6len)
+	if ipv4 := ip.To4(); ipv4 != nil {
+		ip = ipv4
+		mask = net.CIDRMask(ipv4RangeBits, 8*net.IPv4len)
+	}
+
+	network := net.IPNet{IP: ip.Mask(mask), Mask: mask}
+	return network.String()
+}
+
+// describeDevice names the browser and operating system of a user agent for people to recognize, e.g. "Chrome on
+// Windows".
+func describeDevice(userAgent string) string {
+	browser := matchUserAgent(userAgent, userAgentBrowsers)
+	system := matchUserAgent(userAgent, userAgentSystems)
+
+	switch {
+	case browser != "" && system != "":
+		return browser + " on " + system
+	case browser != "":
+		return browser
+	case system != "":
+		return system
+	case userAgent != "":
+		if len(userAgent) > maxUserAgentLength {
+			return userAgent[:maxUserAgentLength]
+		}
+
+		return userAgent
+	default:
+		return unknownDevice
+	}
+}
+
+func matchUserAgent(userAgent string, names []userAgentName) string {
+	for _, name := range names {
+		if strings.Contains(userAgent, name.token) {
+			return name.name
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,145 @@
+package controllers
+
+import (
+	"context"
+	"github.com/winartodev/apollo/core/configs"
+	auditController "github.com/winartodev/apollo/modules/audit/controllers"
+	auditEntity "github.com/winartodev/apollo/modules/audit/entities"
+	authEntity "github.com/winartodev/apollo/modules/auth/entities"
+	userController "github.com/winartodev/apollo/modules/user/controllers"
+	userEntity "github.com/winartodev/apollo/modules/user/entities"
+	"testing"
+	"time"
+)
+
+type fakeUserController struct {
+	userController.UserControllerItf
+	recognition     *userEntity.LoginRecognition
+	passwordUpdates int
+	phoneNumber     string
+}
+
+func (f *fakeUserController) RecognizeLogin(ctx context.Context, data *userEntity.LoginHistory) (res *userEntity.LoginRecognition, err error) {
+	return f.recognition, nil
+}
+
+func (f *fakeUserController) GetUserByID(ctx context.Context, id int64) (res *userEntity.User, err error) {
+	return &userEntity.User{ID: id, UUID: "5f0c6a2e-8d1b-4c3a-9e7f-2b4d6a8c0e1f", Username: "apollo", Email: "apollo@gmail.com", PhoneNumber: f.phoneNumber}, nil
+}
+
+type fakeVerificationController struct {
+	VerificationControllerItf
+	tokens int
+	alert  *authEntity.LoginAlertData
+}
+
+func (f *fakeVerificationController) CreateLoginAlertToken(ctx context.Context, userID int64, email string, signedInAt time.Time) (token string, err error) {
+	f.tokens++
+	return "token", nil
+}
+
+func (f *fakeVerificationController) SendMailTemplate(to string, subject string, templatePath string, data any) (err error) {
+	return nil
+}
+
+type fakeAuditController struct {
+	auditController.AuditControllerItf
+}
+
+func (f *fakeAuditController) Record(ctx context.Context, event *auditEntity.Event, err error) {}
+
+func TestLoginAlertController_Inspect(t *testing.T) {
+	tests := []struct {
+		name        string
+		enabled     bool
+		outcome     string
+		recognition userEntity.LoginRecognition
+		wantAlert   bool
+	}{
+		{
+			name:        "success_new_device_is_alerted",
+			enabled:     true,
+			outcome:     userEntity.LoginOutcomeSuccess,
+			recognition: userEntity.LoginRecognition{HasHistory: true, KnownIPRange: true},
+			wantAlert:   true,
+		},
+		{
+			name:        "success_new_network_is_alerted",
+			enabled:     true,
+			outcome:     userEntity.LoginOutcomeSuccess,
+			recognition: userEntity.LoginRecognition{HasHistory: true, KnownDevice: true},
+			wantAlert:   true,
+		},
+		{
+			name:        "success_known_device_and_network",
+			enabled:     true,
+			outcome:     userEntity.LoginOutcomeSuccess,
+			recognition: userEntity.LoginRecognition{HasHistory: true, KnownDevice: true, KnownIPRange: true},
+		},
+		{
+			name:    "success_first_login_is_not_alerted",
+			enabled: true,
+			outcome: userEntity.LoginOutcomeSuccess,
+		},
+		{
+			name:        "success_failed_login_is_not_alerted",
+			enabled:     true,
+			outcome:     userEntity.LoginOutcomeFailure,
+			recognition: userEntity.LoginRecognition{HasHistory: true},
+		},
+		{
+			name:        "success_alerts_disabled",
+			outcome:     userEntity.LoginOutcomeSuccess,
+			recognition: userEntity.LoginRecognition{HasHistory: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verification := &fakeVerificationController{}
+			controller := NewLoginAlertController(LoginAlertController{
+				LoginAlert:             &configs.LoginAlert{Enabled: tt.enabled},
+				VerificationController: verification,
+				UserController:         &fakeUserController{recognition: &tt.recognition},
+				AuditController:        &fakeAuditController{},
+			})
+
+			login := &userEntity.LoginHistory{
+				UserID:    1,
+				Outcome:   tt.outcome,
+				IPAddress: "203.0.113.7",
+				UserAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/129.0.0.0 Safari/537.36",
+			}
+
+			err := controller.Inspect(context.Background(), login)
+			if err != nil {
+				t.Fatalf("Inspect() error = %v", err)
+			}
+
+			if login.DeviceID == "" || login.IPRange != "203.0.113.0/24" {
+				t.Errorf("Inspect() device = %q, ip range = %q", login.DeviceID, login.IPRange)
+			}
+
+			if (verification.tokens == 1) != tt.wantAlert {
+				t.Errorf("Inspect() alerted = %v, want %v", verification.tokens == 1, tt.wantAlert)
+			}
+		})
+	}
+}
+
+func TestDeviceID(t *testing.T) {
+	chrome129 := "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/129.0.0.0 Safari/537.36"
+	chrome130 := "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/130.0.6723.58 Safari/537.36"
+	firefox := "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:131.0) Gecko/20100101 Firefox/131.0"
+
+	if deviceID(chrome129) != deviceID(chrome130) {
+		t.Errorf("deviceID() changed with the browser version")
+	}
+
+	if deviceID(chrome129) == deviceID(firefox) {
+		t.Errorf("deviceID() did not tell browsers apart")
+	}
+
+	if got := describeDevice(chrome130); got != "Chrome on Windows" {
+		t.Errorf("describeDevice() = %v, want Chrome on Windows", got)
+	}
+}
@@ -0,0 +1,344 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/winartodev/apollo/core/configs"
+	"github.com/winartodev/apollo/core/helpers"
+	authEntity "github.com/winartodev/apollo/modules/auth/entities"
+	authRepo "github.com/winartodev/apollo/modules/auth/repositories"
+	userController "github.com/winartodev/apollo/modules/user/controllers"
+	"strings"
+	"time"
+)
+
+const (
+	defaultMFAIssuer = "Apollo"
+
+	mfaChallengeExpiration  = 5 * time.Minute
+	mfaChallengeTokenLength = 32
+	maxMFAChallengeAttempts = 5
+
+	recoveryCodeCount  = 10
+	recoveryCodeLength = 10
+	recoveryCodeChars  = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+	qrCodeScale = 6
+)
+
+var (
+	ErrorMFAAlreadyEnabled      = errors.New("two-factor authentication is already enabled")
+	ErrorMFANotEnabled          = errors.New("two-factor authentication is not enabled")
+	ErrorInvalidMFACode         = errors.New("invalid two-factor authentication code")
+	ErrorInvalidMFAChallenge    = errors.New("mfa token is invalid or expired")
+	errorMFAEnrollmentNotFound  = errors.New("two-factor authentication enrollment not found")
+	errorMFAMaxAttemptsExceeded = errors.New("too many invalid two-factor authentication codes")
+)
+
+type MFAControllerItf interface {
+	Enroll(ctx context.Context, userID int64) (res *authEntity.MFAEnrollment, err error)
+	ConfirmEnrollment(ctx context.Context, userID int64, code string) (recoveryCodes []string, err error)
+	Disable(ctx context.Context, userID int64, code string) (err error)
+	IsEnabled(ctx context.Context, userID int64) (enabled bool, err error)
+	VerifyCode(ctx context.Context, userID int64, code string) (err error)
+	CreateChallenge(ctx context.Context, userID int64) (res *authEntity.MFAChallenge, err error)
+	VerifyChallenge(ctx context.Context, mfaToken string, code string) (userID int64, err error)
+}
+
+type MFAController struct {
+	MFA            *configs.MFA
+	MFARepository  authRepo.MFARepositoryItf
+	UserController userController.UserControllerItf
+}
+
+func NewMFAController(controller MFAController) MFAControllerItf {
+	return &MFAController{
+		MFA:            controller.MFA,
+		MFARepository:  controller.MFARepository,
+		UserController: controller.UserController,
+	}
+}
+
+func (mc *MFAController) Enroll(ctx context.Context, userID int64) (res *authEntity.MFAEnrollment, err error) {
+	current, err := mc.getUserMFA(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if current != nil && current.IsEnabled {
+		return nil, ErrorMFAAlreadyEnabled
+	}
+
+	user, err := mc.UserController.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := helpers.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	encryptedSecret, err := helpers.Encrypt(mc.MFA.EncryptionKey, secret)
+	if err != nil {
+		return nil, err
+	}
+
+	err = mc.MFARepository.UpsertUserMFADB(ctx, userID, encryptedSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	uri := helpers.BuildTOTPURI(mc.issuer(), user.Email, secret)
+	qrCode, err := helpers.GenerateQRCodePNG(uri, qrCodeScale)
+	if err != nil {
+		return nil, err
+	}
+
+	return &authEntity.MFAEnrollment{
+		Secret:     secret,
+		OTPAuthURI: uri,
+		QRCode:     fmt.Sprintf("data:image/png;base64,%s", base64.StdEncoding.EncodeToString(qrCode)),
+	}, nil
+}
+
+// ConfirmEnrollment enables two-factor authentication once the user proves the authenticator app is set up,
+// and returns the recovery codes. The codes are only stored hashed, so this is the only time they are shown.
+func (mc *MFAController) ConfirmEnrollment(ctx context.Context, userID int64, code string) (recoveryCodes []string, err error) {
+	current, err := mc.getUserMFA(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if current == nil {
+		return nil, errorMFAEnrollmentNotFound
+	}
+
+	if current.IsEnabled {
+		return nil, ErrorMFAAlreadyEnabled
+	}
+
+	secret, err := helpers.Decrypt(mc.MFA.EncryptionKey, current.Secret)
+	if err != nil {
+		return nil, err
+	}
+
+	step, valid, err := helpers.ValidateTOTPCode(secret, code, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	if !valid {
+		return nil, ErrorInvalidMFACode
+	}
+
+	recoveryCodeHashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		recoveryCode, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+
+		recoveryCodes = append(recoveryCodes, recoveryCode)
+		recoveryCodeHashes = append(recoveryCodeHashes, helpers.HashToken(normalizeRecoveryCode(recoveryCode)))
+	}
+
+	err = mc.MFARepository.EnableUserMFADB(ctx, userID, step, recoveryCodeHashes)
+	if err != nil {
+		return nil, err
+	}
+
+	return recoveryCodes, nil
+}
+
+func (mc *MFAController) Disable(ctx context.Context, userID int64, code string) (err error) {
+	err = mc.VerifyCode(ctx, userID, code)
+	if err != nil {
+		return err
+	}
+
+	return mc.MFARepository.DeleteUserMFADB(ctx, userID)
+}
+
+func (mc *MFAController) IsEnabled(ctx context.Context, userID int64) (enabled bool, err error) {
+	current, err := mc.getUserMFA(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+
+	return current != nil && current.IsEnabled, nil
+}
+
+// VerifyCode accepts either a code from the authenticator app or one of the unused recovery codes.
+func (mc *MFAController) VerifyCode(ctx context.Context, userID int64, code string) (err error) {
+	current, err := mc.getUserMFA(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if current == nil || !current.IsEnabled {
+		return ErrorMFANotEnabled
+	}
+
+	code = strings.TrimSpace(code)
+	if len(code) == helpers.TOTPDigits {
+		secret, err := helpers.Decrypt(mc.MFA.EncryptionKey, current.Secret)
+		if err != nil {
+			return err
+		}
+
+		step, valid, err := helpers.ValidateTOTPCode(secret, code, time.Now())
+		if err != nil {
+			return err
+		}
+
+		if !valid {
+			return ErrorInvalidMFACode
+		}
+
+		updated, err := mc.MFARepository.UpdateLastUsedStepDB(ctx, userID, step)
+		if err != nil {
+			return err
+		}
+
+		if !updated {
+			return ErrorInvalidMFACode
+		}
+
+		return nil
+	}
+
+	used, err := mc.MFARepository.UseRecoveryCodeDB(ctx, userID, helpers.HashToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		return err
+	}
+
+	if !used {
+		return ErrorInvalidMFACode
+	}
+
+	return nil
+}
+
+func (mc *MFAController) CreateChallenge(ctx context.Context, userID int64) (res *authEntity.MFAChallenge, err error) {
+	token, err := helpers.GenerateRandomToken(mfaChallengeTokenLength)
+	if err != nil {
+		return nil, err
+	}
+
+	data := authEntity.MFAChallengeData{
+		UserID: userID,
+		Expire: time.Now().Add(mfaChallengeExpiration).Unix(),
+	}
+
+	ttl := mfaChallengeExpiration
+
+	err = mc.MFARepository.SetMFAChallengeRedis(ctx, helpers.HashToken(token), data, &ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	return &authEntity.MFAChallenge{
+		Status:    authEntity.MFAStatusPending,
+		MFAToken:  token,
+		ExpiresIn: int64(mfaChallengeExpiration.Seconds()),
+	}, nil
+}
+
+// VerifyChallenge exchanges a pending challenge and a valid code for the user id the challenge was issued to.
+// The challenge is removed once it succeeds or after too many invalid codes. The user id is also returned with
+// an invalid code, so the failed attempt can be attributed to the user.
+func (mc *MFAController) VerifyChallenge(ctx context.Context, mfaToken string, code string) (userID int64, err error) {
+	if mfaToken == "" {
+		return 0, ErrorInvalidMFAChallenge
+	}
+
+	tokenHash := helpers.HashToken(mfaToken)
+
+	data, err := mc.MFARepository.GetMFAChallengeRedis(ctx, tokenHash)
+	if err != nil {
+		return 0, err
+	}
+
+	if data == nil || time.Now().After(time.Unix(data.Expire, 0)) {
+		return 0, ErrorInvalidMFAChallenge
+	}
+
+	err = mc.VerifyCode(ctx, data.UserID, code)
+	if errors.Is(err, ErrorInvalidMFACode) {
+		attempts, err := mc.MFARepository.IncrementMFAChallengeAttemptsRedis(ctx, tokenHash, time.Until(time.Unix(data.Expire, 0)))
+		if err != nil {
+			return 0, err
+		}
+
+		if attempts >= maxMFAChallengeAttempts {
+			_, err = mc.MFARepository.DeleteMFAChallengeRedis(ctx, tokenHash)
+			if err != nil {
+				return 0, err
+			}
+
+			return data.UserID, errorMFAMaxAttemptsExceeded
+		}
+
+		return data.UserID, ErrorInvalidMFACode
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	deleted, err := mc.MFARepository.DeleteMFAChallengeRedis(ctx, tokenHash)
+	if err != nil {
+		return 0, err
+	}
+
+	if !deleted {
+		return 0, ErrorInvalidMFAChallenge
+	}
+
+	return data.UserID, nil
+}
+
+func (mc *MFAController) getUserMFA(ctx context.Context, userID int64) (res *authEntity.UserMFA, err error) {
+	res, err = mc.MFARepository.GetUserMFAByUserIDDB(ctx, userID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (mc *MFAController) issuer() string {
+	if mc.MFA == nil || mc.MFA.Issuer == "" {
+		return defaultMFAIssuer
+	}
+
+	return mc.MFA.Issuer
+}
+
+// generateRecoveryCode returns a code formatted as XXXXX-XXXXX using characters that are hard to mistake for each other.
+func generateRecoveryCode() (string, error) {
+	buffer := make([]byte, recoveryCodeLength)
+	_, err := rand.Read(buffer)
+	if err != nil {
+		return "", err
+	}
+
+	var builder strings.Builder
+	for i, b := range buffer {
+		if i == recoveryCodeLength/2 {
+			builder.WriteByte('-')
+		}
+		builder.WriteByte(recoveryCodeChars[int(b)%len(recoveryCodeChars)])
+	}
+
+	return builder.String(), nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
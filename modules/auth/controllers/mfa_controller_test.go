@@ -0,0 +1,128 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"github.com/winartodev/apollo/core/configs"
+	authEntity "github.com/winartodev/apollo/modules/auth/entities"
+	authRepo "github.com/winartodev/apollo/modules/auth/repositories"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeMFARepository keeps a single challenge, its attempts are counted atomically as in Redis.
+type fakeMFARepository struct {
+	authRepo.MFARepositoryItf
+	mutex     sync.Mutex
+	challenge *authEntity.MFAChallengeData
+	attempts  int64
+}
+
+func (f *fakeMFARepository) GetUserMFAByUserIDDB(ctx context.Context, userID int64) (res *authEntity.UserMFA, err error) {
+	return &authEntity.UserMFA{UserID: userID, IsEnabled: true}, nil
+}
+
+func (f *fakeMFARepository) UseRecoveryCodeDB(ctx context.Context, userID int64, codeHash string) (used bool, err error) {
+	return false, nil
+}
+
+func (f *fakeMFARepository) GetMFAChallengeRedis(ctx context.Context, tokenHash string) (res *authEntity.MFAChallengeData, err error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.challenge == nil {
+		return nil, nil
+	}
+
+	challenge := *f.challenge
+	return &challenge, nil
+}
+
+func (f *fakeMFARepository) DeleteMFAChallengeRedis(ctx context.Context, tokenHash string) (deleted bool, err error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	deleted = f.challenge != nil
+	f.challenge = nil
+	return deleted, nil
+}
+
+func (f *fakeMFARepository) IncrementMFAChallengeAttemptsRedis(ctx context.Context, tokenHash string, ttl time.Duration) (attempts int64, err error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.attempts++
+	return f.attempts, nil
+}
+
+func TestMFAController_VerifyChallenge(t *testing.T) {
+	tests := []struct {
+		name              string
+		attempts          int
+		concurrent        bool
+		wantInvalidCodes  int
+		wantChallengeLeft bool
+	}{
+		{
+			name:              "success_invalid_codes_below_limit",
+			attempts:          maxMFAChallengeAttempts - 1,
+			wantInvalidCodes:  maxMFAChallengeAttempts - 1,
+			wantChallengeLeft: true,
+		},
+		{
+			name:             "failed_too_many_invalid_codes",
+			attempts:         maxMFAChallengeAttempts,
+			wantInvalidCodes: maxMFAChallengeAttempts - 1,
+		},
+		{
+			name:             "failed_too_many_concurrent_invalid_codes",
+			attempts:         4 * maxMFAChallengeAttempts,
+			concurrent:       true,
+			wantInvalidCodes: maxMFAChallengeAttempts - 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repository := &fakeMFARepository{
+				challenge: &authEntity.MFAChallengeData{UserID: 1, Expire: time.Now().Add(time.Minute).Unix()},
+			}
+			controller := NewMFAController(MFAController{
+				MFA:           &configs.MFA{},
+				MFARepository: repository,
+			})
+
+			var mutex sync.Mutex
+			var wg sync.WaitGroup
+			invalidCodes := 0
+			verify := func() {
+				defer wg.Done()
+
+				_, err := controller.VerifyChallenge(context.Background(), "mfa-token", "WRONG-CODE")
+				if errors.Is(err, ErrorInvalidMFACode) {
+					mutex.Lock()
+					invalidCodes++
+					mutex.Unlock()
+				}
+			}
+
+			for i := 0; i < tt.attempts; i++ {
+				wg.Add(1)
+				if tt.concurrent {
+					go verify()
+				} else {
+					verify()
+				}
+			}
+			wg.Wait()
+
+			if invalidCodes != tt.wantInvalidCodes {
+				t.Errorf("VerifyChallenge() invalid codes = %d, want %d", invalidCodes, tt.wantInvalidCodes)
+			}
+
+			if (repository.challenge != nil) != tt.wantChallengeLeft {
+				t.Errorf("VerifyChallenge() challenge left = %v, want %v", repository.challenge != nil, tt.wantChallengeLeft)
+			}
+		})
+	}
+}
@@ -0,0 +1,1017 @@
+package controllers
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/winartodev/apollo/core"
+	"github.com/winartodev/apollo/core/configs"
+	"github.com/winartodev/apollo/core/helpers"
+	authEntity "github.com/winartodev/apollo/modules/auth/entities"
+	authRepo "github.com/winartodev/apollo/modules/auth/repositories"
+	sessionController "github.com/winartodev/apollo/modules/session/controllers"
+	sessionEntity "github.com/winartodev/apollo/modules/session/entities"
+	userController "github.com/winartodev/apollo/modules/user/controllers"
+	userEntity "github.com/winartodev/apollo/modules/user/entities"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	ScopeOpenID  = "openid"
+	ScopeProfile = "profile"
+	ScopeEmail   = "email"
+	ScopePhone   = "phone"
+
+	GrantTypeAuthorizationCode = "authorization_code"
+	GrantTypeClientCredentials = "client_credentials"
+	ResponseTypeCode           = "code"
+	TokenTypeBearer            = "Bearer"
+
+	authorizationCodeExpiration = 5 * time.Minute
+	idTokenExpiration           = time.Hour
+	authorizationCodeLength     = 32
+	ssoTokenLength              = 32
+	clientIDLength              = 16
+	clientSecretLength          = 32
+	maxClientNameLength         = 100
+)
+
+var (
+	supportedScopes = []string{ScopeOpenID, ScopeProfile, ScopeEmail, ScopePhone}
+	serviceScopes   = []string{core.PermissionUsersRead, core.PermissionUsersWrite, core.PermissionSessionsRevoke}
+
+	ErrorOIDCNotConfigured   = errors.New("openid connect issuer is not configured")
+	ErrorInvalidOAuthClient  = errors.New("unknown client")
+	ErrorInvalidRedirectURI  = errors.New("redirect uri is not registered for the client")
+	ErrorInvalidClientName   = fmt.Errorf("client name is required and must be at most %d characters", maxClientNameLength)
+	ErrorInvalidClientScope  = errors.New("client scopes must include openid and only contain supported scopes")
+	ErrorInvalidServiceScope = errors.New("service clients need at least one scope and only service scopes")
+	ErrorInvalidGrantTypes   = errors.New("a client uses either the authorization_code or the client_credentials grant")
+	ErrorPublicServiceClient = errors.New("service clients must be confidential")
+	ErrorMissingRedirectURI  = errors.New("at least one redirect uri is required")
+	ErrorInvalidSignIn       = errors.New("email or password is incorrect")
+
+	errorInvalidClientAuthentication = &authEntity.OAuthError{Code: authEntity.OAuthErrorInvalidClient, Description: "client authentication failed"}
+	errorInvalidAuthorizationCode    = &authEntity.OAuthError{Code: authEntity.OAuthErrorInvalidGrant, Description: "authorization code is invalid or expired"}
+	errorInvalidCodeVerifier         = &authEntity.OAuthError{Code: authEntity.OAuthErrorInvalidGrant, Description: "code verifier does not match the code challenge"}
+	errorUnsupportedGrantType        = &authEntity.OAuthError{Code: authEntity.OAuthErrorUnsupportedGrantType}
+	errorGrantTypeNotAllowed         = &authEntity.OAuthError{Code: authEntity.OAuthErrorUnauthorizedClient, Description: "the client is not allowed to use this grant type"}
+	errorUnsupportedResponseType     = &authEntity.OAuthError{Code: authEntity.OAuthErrorUnsupportedResponseType, Description: "only the code response type is supported"}
+	errorMissingCodeChallenge        = &authEntity.OAuthError{Code: authEntity.OAuthErrorInvalidRequest, Description: "a code challenge with the S256 method is required"}
+	errorInvalidPrompt               = &authEntity.OAuthError{Code: authEntity.OAuthErrorInvalidRequest, Description: "unsupported prompt value"}
+	errorMissingOpenIDScope          = &authEntity.OAuthError{Code: authEntity.OAuthErrorInvalidScope, Description: "the openid scope is required"}
+	errorScopeNotAllowed             = &authEntity.OAuthError{Code: authEntity.OAuthErrorInvalidScope, Description: "requested scope is not allowed for the client"}
+	errorInvalidAccessToken          = &authEntity.OAuthError{Code: authEntity.OAuthErrorInvalidToken, Description: "access token is invalid or expired"}
+	errorConfidentialClientRequired  = &authEntity.OAuthError{Code: authEntity.OAuthErrorUnauthorizedClient, Description: "only confidential clients can use this endpoint"}
+	errorMissingToken                = &authEntity.OAuthError{Code: authEntity.OAuthErrorInvalidRequest, Description: "the token parameter is required"}
+	errorTokenNotIssuedToClient      = &authEntity.OAuthError{Code: authEntity.OAuthErrorUnauthorizedClient, Description: "the token was not issued to the client"}
+)
+
+type OIDCControllerItf interface {
+	GetConfiguration() (res *authEntity.OpenIDConfiguration, err error)
+	CreateClient(ctx context.Context, data *authEntity.CreateOAuthClientRequest) (res *authEntity.OAuthClientCredentials, err error)
+	ValidateAuthorizationRequest(ctx context.Context, data *authEntity.AuthorizationRequest) (client *authEntity.OAuthClient, err error)
+	SignIn(ctx context.Context, data *authEntity.SignInRequest, client sessionEntity.ClientInfo) (ssoToken string, challenge *authEntity.MFAChallenge, err error)
+	VerifyMFA(ctx context.Context, data *authEntity.MFAVerifyRequest, client sessionEntity.ClientInfo) (ssoToken string, err error)
+	GetSSOSession(ctx context.Context, ssoToken string) (res *authEntity.SSOSession, err error)
+	Authorize(ctx context.Context, data *authEntity.AuthorizationRequest, sso *authEntity.SSOSession) (redirectURL string, err error)
+	Exchange(ctx context.Context, data *authEntity.TokenRequest) (res *authEntity.TokenResponse, err error)
+	GetUserInfo(ctx context.Context, accessToken string) (res *authEntity.UserInfo, err error)
+	Introspect(ctx context.Context, data *authEntity.IntrospectionRequest) (res *authEntity.IntrospectionResponse, err error)
+	Revoke(ctx context.Context, data *authEntity.RevocationRequest) (err error)
+}
+
+type OIDCController struct {
+	OIDC                  *configs.OIDC
+	OAuthClientRepository authRepo.OAuthClientRepositoryItf
+	AuthController        AuthControllerItf
+	SessionController     sessionController.SessionControllerItf
+	UserController        userController.UserControllerItf
+}
+
+func NewOIDCController(controller OIDCController) OIDCControllerItf {
+	return &OIDCController{
+		OIDC:                  controller.OIDC,
+		OAuthClientRepository: controller.OAuthClientRepository,
+		AuthController:        controller.AuthController,
+		SessionController:     controller.SessionController,
+		UserController:        controller.UserController,
+	}
+}
+
+func (oc *OIDCController) GetConfiguration() (res *authEntity.OpenIDConfiguration, err error) {
+	issuer, err := oc.getIssuer()
+	if err != nil {
+		return nil, err
+	}
+
+	jwt, err := helpers.NewJWT()
+	if err != nil {
+		return nil, err
+	}
+
+	algorithms := []string{helpers.AlgorithmRS256, helpers.AlgorithmEdDSA}
+	if algorithm := jwt.SigningAlgorithm(); algorithm != "" {
+		algorithms = []string{algorithm}
+	}
+
+	return &authEntity.OpenIDConfiguration{
+		Issuer:                            issuer,
+		AuthorizationEndpoint:             issuer + "/oauth2/authorize",
+		TokenEndpoint:                     issuer + "/oauth2/token",
+		UserInfoEndpoint:                  issuer + "/oauth2/userinfo",
+		IntrospectionEndpoint:             issuer + "/oauth2/introspect",
+		RevocationEndpoint:                issuer + "/oauth2/revoke",
+		JWKSURI:                           issuer + "/.well-known/jwks.json",
+		ResponseTypesSupported:            []string{ResponseTypeCode},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  algorithms,
+		ScopesSupported:                   slices.Concat(supportedScopes, serviceScopes),
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		GrantTypesSupported:               []string{GrantTypeAuthorizationCode, GrantTypeClientCredentials},
+		CodeChallengeMethodsSupported:     []string{helpers.PKCEMethodS256},
+		PromptValuesSupported:             []string{authEntity.PromptNone, authEntity.PromptLogin, authEntity.PromptConsent},
+		ClaimsSupported: []string{
+			"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "sid",
+			"name", "given_name", "family_name", "preferred_username", "picture", "updated_at",
+			"email", "email_verified", "phone_number", "phone_number_verified",
+		},
+	}, nil
+}
+
+// CreateClient registers an application or a service. Confidential clients get a secret that is only returned
+// here, public clients authenticate with PKCE alone.
+func (oc *OIDCController) CreateClient(ctx context.Context, data *authEntity.CreateOAuthClientRequest) (res *authEntity.OAuthClientCredentials, err error) {
+	name := strings.TrimSpace(data.Name)
+	if name == "" || len(name) > maxClientNameLength {
+		return nil, ErrorInvalidClientName
+	}
+
+	grantTypes := data.GrantTypes
+	if len(grantTypes) == 0 {
+		grantTypes = []string{GrantTypeAuthorizationCode}
+	}
+
+	if len(grantTypes) != 1 || (grantTypes[0] != GrantTypeAuthorizationCode && grantTypes[0] != GrantTypeClientCredentials) {
+		return nil, ErrorInvalidGrantTypes
+	}
+
+	scopes := data.Scopes
+	if grantTypes[0] == GrantTypeClientCredentials {
+		err = validateServiceClient(data)
+	} else {
+		scopes, err = validateLoginClient(data)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	clientID, err := helpers.GenerateRandomToken(clientIDLength)
+	if err != nil {
+		return nil, err
+	}
+
+	var clientSecret string
+	var clientSecretHash string
+	if !data.Public {
+		clientSecret, err = helpers.GenerateRandomToken(clientSecretLength)
+		if err != nil {
+			return nil, err
+		}
+
+		clientSecretHash = helpers.HashToken(clientSecret)
+	}
+
+	now := time.Now()
+	client := &authEntity.OAuthClient{
+		ClientID:         clientID,
+		ClientSecretHash: clientSecretHash,
+		Name:             name,
+		RedirectURIs:     data.RedirectURIs,
+		Scopes:           scopes,
+		SkipConsent:      data.SkipConsent,
+		GrantTypes:       grantTypes,
+		CreatedAt:        &now,
+		UpdatedAt:        &now,
+	}
+
+	client.ID, err = oc.OAuthClientRepository.CreateClientDB(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+
+	return &authEntity.OAuthClientCredentials{
+		OAuthClient:  client,
+		ClientSecret: clientSecret,
+	}, nil
+}
+
+// ValidateAuthorizationRequest checks the request of a client. ErrorInvalidOAuthClient and ErrorInvalidRedirectURI
+// must be shown to the user, the redirect uri can not be trusted, any other OAuthError is sent back to the client.
+func (oc *OIDCController) ValidateAuthorizationRequest(ctx context.Context, data *authEntity.AuthorizationRequest) (client *authEntity.OAuthClient, err error) {
+	client, err = oc.OAuthClientRepository.GetClientDB(ctx, data.ClientID)
+	if err == sql.ErrNoRows {
+		return nil, ErrorInvalidOAuthClient
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !slices.Contains(client.RedirectURIs, data.RedirectURI) {
+		return nil, ErrorInvalidRedirectURI
+	}
+
+	if data.ResponseType != ResponseTypeCode {
+		return client, errorUnsupportedResponseType
+	}
+
+	if !slices.Contains(client.GrantTypes, GrantTypeAuthorizationCode) {
+		return client, errorGrantTypeNotAllowed
+	}
+
+	scopes := strings.Fields(data.Scope)
+	if !slices.Contains(scopes, ScopeOpenID) {
+		return client, errorMissingOpenIDScope
+	}
+
+	for _, scope := range scopes {
+		if !slices.Contains(client.Scopes, scope) {
+			return client, errorScopeNotAllowed
+		}
+	}
+
+	if data.CodeChallenge == "" || data.CodeChallengeMethod != helpers.PKCEMethodS256 {
+		return client, errorMissingCodeChallenge
+	}
+
+	switch data.Prompt {
+	case "", authEntity.PromptNone, authEntity.PromptLogin, authEntity.PromptConsent:
+	default:
+		return client, errorInvalidPrompt
+	}
+
+	return client, nil
+}
+
+// SignIn checks the credentials entered on the sign in page through the regular sign in, so lockout and two-factor
+// authentication apply as well. The session it starts becomes the single sign-on session of the browser.
+func (oc *OIDCController) SignIn(ctx context.Context, data *authEntity.SignInRequest, client sessionEntity.ClientInfo) (ssoToken string, challenge *authEntity.MFAChallenge, err error) {
+	res, challenge, err := oc.AuthController.SignIn(ctx, data, client)
+	if errors.Is(err, errorInvalidPassword) || errors.Is(err, userController.ErrorUserNotFound) {
+		return "", nil, ErrorInvalidSignIn
+	}
+
+	if err != nil || challenge != nil {
+		return "", challenge, err
+	}
+
+	ssoToken, err = oc.startSSOSession(ctx, res)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return ssoToken, nil, nil
+}
+
+func (oc *OIDCController) VerifyMFA(ctx context.Context, data *authEntity.MFAVerifyRequest, client sessionEntity.ClientInfo) (ssoToken string, err error) {
+	res, err := oc.AuthController.VerifyMFA(ctx, data, client)
+	if err != nil {
+		return "", err
+	}
+
+	return oc.startSSOSession(ctx, res)
+}
+
+// startSSOSession binds a new single sign-on token to the session of the access token, the tokens themselves are
+// not handed to the browser.
+func (oc *OIDCController) startSSOSession(ctx context.Context, res *authEntity.AuthResponse) (ssoToken string, err error) {
+	claims, err := parseAccessToken(res.AccessToken)
+	if err != nil {
+		return "", err
+	}
+
+	ssoToken, err = helpers.GenerateRandomToken(ssoTokenLength)
+	if err != nil {
+		return "", err
+	}
+
+	err = oc.OAuthClientRepository.SetSSOSessionRedis(ctx, helpers.HashToken(ssoToken), authEntity.SSOSession{
+		UserID:    claims.ID,
+		SessionID: claims.SessionID,
+		AuthTime:  claims.IssuedAt,
+	}, helpers.RefreshTokenExpiration)
+	if err != nil {
+		return "", err
+	}
+
+	return ssoToken, nil
+}
+
+// GetSSOSession returns the single sign-on session of the browser, or nil when it has none or its session was
+// revoked, e.g. because the user signed out.
+func (oc *OIDCController) GetSSOSession(ctx context.Context, ssoToken string) (res *authEntity.SSOSession, err error) {
+	if ssoToken == "" {
+		return nil, nil
+	}
+
+	tokenHash := helpers.HashToken(ssoToken)
+	res, err = oc.OAuthClientRepository.GetSSOSessionRedis(ctx, tokenHash)
+	if err != nil || res == nil {
+		return nil, err
+	}
+
+	active, err := oc.isSessionActive(ctx, res.SessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !active {
+		return nil, oc.OAuthClientRepository.DeleteSSOSessionRedis(ctx, tokenHash)
+	}
+
+	return res, nil
+}
+
+// Authorize issues an authorization code for a validated request and returns where to redirect the browser.
+func (oc *OIDCController) Authorize(ctx context.Context, data *authEntity.AuthorizationRequest, sso *authEntity.SSOSession) (redirectURL string, err error) {
+	code, err := helpers.GenerateRandomToken(authorizationCodeLength)
+	if err != nil {
+		return "", err
+	}
+
+	err = oc.OAuthClientRepository.SetAuthorizationCodeRedis(ctx, helpers.HashToken(code), authEntity.AuthorizationCode{
+		ClientID:      data.ClientID,
+		UserID:        sso.UserID,
+		SessionID:     sso.SessionID,
+		RedirectURI:   data.RedirectURI,
+		Scope:         data.Scope,
+		Nonce:         data.Nonce,
+		CodeChallenge: data.CodeChallenge,
+		AuthTime:      sso.AuthTime,
+		Expire:        time.Now().Add(authorizationCodeExpiration).Unix(),
+	}, authorizationCodeExpiration)
+	if err != nil {
+		return "", err
+	}
+
+	return BuildAuthorizationRedirect(data.RedirectURI, url.Values{
+		"code":  {code},
+		"state": {data.State},
+	}), nil
+}
+
+// Exchange issues tokens for the grant of the request: an authorization code is redeemed for an access token and
+// an id token, a service client gets an access token of its own.
+func (oc *OIDCController) Exchange(ctx context.Context, data *authEntity.TokenRequest) (res *authEntity.TokenResponse, err error) {
+	if data.GrantType != GrantTypeAuthorizationCode && data.GrantType != GrantTypeClientCredentials {
+		return nil, errorUnsupportedGrantType
+	}
+
+	client, err := oc.authenticateClient(ctx, data.ClientID, data.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	if !slices.Contains(client.GrantTypes, data.GrantType) {
+		return nil, errorGrantTypeNotAllowed
+	}
+
+	if data.GrantType == GrantTypeClientCredentials {
+		return oc.exchangeClientCredentials(client, data.Scope)
+	}
+
+	return oc.exchangeAuthorizationCode(ctx, client, data)
+}
+
+func (oc *OIDCController) exchangeAuthorizationCode(ctx context.Context, client *authEntity.OAuthClient, data *authEntity.TokenRequest) (res *authEntity.TokenResponse, err error) {
+	issuer, err := oc.getIssuer()
+	if err != nil {
+		return nil, err
+	}
+
+	code, err := oc.consumeAuthorizationCode(ctx, data.Code)
+	if err != nil {
+		return nil, err
+	}
+
+	if code.ClientID != client.ClientID || code.RedirectURI != data.RedirectURI {
+		return nil, errorInvalidAuthorizationCode
+	}
+
+	challenge := helpers.CodeChallengeS256(data.CodeVerifier)
+	if data.CodeVerifier == "" || subtle.ConstantTimeCompare([]byte(challenge), []byte(code.CodeChallenge)) != 1 {
+		return nil, errorInvalidCodeVerifier
+	}
+
+	active, err := oc.isSessionActive(ctx, code.SessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !active {
+		return nil, errorInvalidAuthorizationCode
+	}
+
+	user, err := oc.UserController.GetUserByID(ctx, code.UserID)
+	if errors.Is(err, userController.ErrorUserNotFound) {
+		return nil, errorInvalidAuthorizationCode
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if user.IsSuspended {
+		return nil, errorInvalidAuthorizationCode
+	}
+
+	jwt, err := helpers.NewJWT()
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, err := jwt.GenerateClientAccessToken(user, code.SessionID, client.ClientID, code.Scope)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	idToken, err := jwt.GenerateIDToken(helpers.IDTokenClaims{
+		UserInfoClaims: buildUserInfoClaims(user, strings.Fields(code.Scope)),
+		Nonce:          code.Nonce,
+		AuthTime:       code.AuthTime,
+		SessionID:      code.SessionID,
+		StandardClaims: newIDTokenStandardClaims(issuer, user.UUID, client.ClientID, now),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &authEntity.TokenResponse{
+		AccessToken: accessToken,
+		TokenType:   TokenTypeBearer,
+		ExpiresIn:   int64(helpers.AccessTokenExpiration.Seconds()),
+		IDToken:     idToken,
+		Scope:       code.Scope,
+	}, nil
+}
+
+// exchangeClientCredentials issues a short lived token to a confidential service client. Without a scope parameter
+// the token gets every scope the client is allowed.
+func (oc *OIDCController) exchangeClientCredentials(client *authEntity.OAuthClient, scope string) (res *authEntity.TokenResponse, err error) {
+	if !client.IsConfidential() {
+		return nil, errorGrantTypeNotAllowed
+	}
+
+	scopes := strings.Fields(scope)
+	if len(scopes) == 0 {
+		scopes = client.Scopes
+	}
+
+	for _, scope := range scopes {
+		if !slices.Contains(client.Scopes, scope) {
+			return nil, errorScopeNotAllowed
+		}
+	}
+
+	jwt, err := helpers.NewJWT()
+	if err != nil {
+		return nil, err
+	}
+
+	grantedScope := strings.Join(scopes, " ")
+	accessToken, err := jwt.GenerateServiceToken(client.ClientID, grantedScope)
+	if err != nil {
+		return nil, err
+	}
+
+	return &authEntity.TokenResponse{
+		AccessToken: accessToken,
+		TokenType:   TokenTypeBearer,
+		ExpiresIn:   int64(helpers.ServiceTokenExpiration.Seconds()),
+		Scope:       grantedScope,
+	}, nil
+}
+
+// GetUserInfo returns the claims of the user an access token was issued for, as far as its scopes allow.
+func (oc *OIDCController) GetUserInfo(ctx context.Context, accessToken string) (res *authEntity.UserInfo, err error) {
+	claims, err := parseAccessToken(accessToken)
+	if err != nil {
+		return nil, errorInvalidAccessToken
+	}
+
+	scopes := strings.Fields(claims.Scope)
+	if claims.ClientID == "" || !slices.Contains(scopes, ScopeOpenID) {
+		return nil, errorInvalidAccessToken
+	}
+
+	revoked, err := oc.SessionController.IsAccessTokenRevoked(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	if revoked {
+		return nil, errorInvalidAccessToken
+	}
+
+	user, err := oc.UserController.GetUserByID(ctx, claims.ID)
+	if errors.Is(err, userController.ErrorUserNotFound) {
+		return nil, errorInvalidAccessToken
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if user.IsSuspended {
+		return nil, errorInvalidAccessToken
+	}
+
+	return &authEntity.UserInfo{
+		Subject:        user.UUID,
+		UserInfoClaims: buildUserInfoClaims(user, scopes),
+	}, nil
+}
+
+// Introspect tells a confidential client whether an access or refresh token is still active. Unlike the offline
+// signature check it sees sign outs, revoked sessions and suspended users.
+func (oc *OIDCController) Introspect(ctx context.Context, data *authEntity.IntrospectionRequest) (res *authEntity.IntrospectionResponse, err error) {
+	_, err = oc.authenticateConfidentialClient(ctx, data.ClientID, data.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	if data.Token == "" {
+		return nil, errorMissingToken
+	}
+
+	introspectors := []func(ctx context.Context, token string) (*authEntity.IntrospectionResponse, error){
+		oc.introspectAccessToken,
+		oc.introspectRefreshToken,
+	}
+	if data.TokenTypeHint == authEntity.TokenTypeHintRefreshToken {
+		slices.Reverse(introspectors)
+	}
+
+	for _, introspect := range introspectors {
+		res, err = introspect(ctx, data.Token)
+		if err != nil || res != nil {
+			return res, err
+		}
+	}
+
+	return &authEntity.IntrospectionResponse{Active: false}, nil
+}
+
+// Revoke ends the session of a refresh token, or puts a single access token on the denylist. A client may only
+// revoke the tokens issued to it, tokens of the sign in of Apollo itself carry no client and are left to clients
+// granted the sessions:revoke scope. Tokens that are invalid or unknown are ignored.
+func (oc *OIDCController) Revoke(ctx context.Context, data *authEntity.RevocationRequest) (err error) {
+	client, err := oc.authenticateConfidentialClient(ctx, data.ClientID, data.ClientSecret)
+	if err != nil {
+		return err
+	}
+
+	if data.Token == "" {
+		return errorMissingToken
+	}
+
+	if data.TokenTypeHint != authEntity.TokenTypeHintAccessToken {
+		revoked, err := oc.revokeRefreshToken(ctx, client, data.Token)
+		if err != nil || revoked {
+			return err
+		}
+	}
+
+	claims, err := parseAccessToken(data.Token)
+	if err == nil {
+		if !isIssuedTo(client, claims.ClientID) {
+			return errorTokenNotIssuedToClient
+		}
+
+		return oc.SessionController.RevokeAccessToken(ctx, claims)
+	}
+
+	if data.TokenTypeHint == authEntity.TokenTypeHintAccessToken {
+		_, err = oc.revokeRefreshToken(ctx, client, data.Token)
+		return err
+	}
+
+	return nil
+}
+
+// introspectAccessToken returns nil when the token is not an active access token.
+func (oc *OIDCController) introspectAccessToken(ctx context.Context, token string) (res *authEntity.IntrospectionResponse, err error) {
+	claims, err := parseAccessToken(token)
+	if err != nil {
+		return nil, nil
+	}
+
+	revoked, err := oc.SessionController.IsAccessTokenRevoked(ctx, claims)
+	if err != nil || revoked {
+		return nil, err
+	}
+
+	res = &authEntity.IntrospectionResponse{
+		Active:    true,
+		Scope:     claims.Scope,
+		ClientID:  claims.ClientID,
+		TokenType: authEntity.TokenTypeHintAccessToken,
+		Exp:       claims.ExpiresAt,
+		Iat:       claims.IssuedAt,
+		Subject:   claims.Subject,
+		SessionID: claims.SessionID,
+		JTI:       claims.Id,
+	}
+
+	// service tokens have no user behind them
+	if claims.IsServiceToken() {
+		return res, nil
+	}
+
+	user, err := oc.getActiveUser(ctx, claims.ID)
+	if err != nil || user == nil {
+		return nil, err
+	}
+
+	res.Subject = user.UUID
+	res.Username = user.Username
+
+	return res, nil
+}
+
+// introspectRefreshToken returns nil when the token is not a refresh token that can still be exchanged.
+func (oc *OIDCController) introspectRefreshToken(ctx context.Context, token string) (res *authEntity.IntrospectionResponse, err error) {
+	claims, session, err := oc.getRefreshTokenSession(ctx, token)
+	if err != nil || session == nil {
+		return nil, err
+	}
+
+	user, err := oc.getActiveUser(ctx, session.UserID)
+	if err != nil || user == nil {
+		return nil, err
+	}
+
+	return &authEntity.IntrospectionResponse{
+		Active:    true,
+		Username:  user.Username,
+		TokenType: authEntity.TokenTypeHintRefreshToken,
+		Exp:       claims.ExpiresAt,
+		Iat:       claims.IssuedAt,
+		Subject:   user.UUID,
+		SessionID: session.ID,
+		JTI:       claims.Id,
+	}, nil
+}
+
+// revokeRefreshToken revokes the session of the refresh token, together with every token issued to it.
+func (oc *OIDCController) revokeRefreshToken(ctx context.Context, client *authEntity.OAuthClient, token string) (revoked bool, err error) {
+	claims, session, err := oc.getRefreshTokenSession(ctx, token)
+	if err != nil || session == nil {
+		return false, err
+	}
+
+	if !isIssuedTo(client, claims.ClientID) {
+		return false, errorTokenNotIssuedToClient
+	}
+
+	err = oc.SessionController.RevokeSession(ctx, session.UserID, session.ID)
+	if errors.Is(err, sessionController.ErrorSessionNotFound) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// getRefreshTokenSession verifies the refresh token and finds its session in storage, it returns no session when
+// the token is not active.
+func (oc *OIDCController) getRefreshTokenSession(ctx context.Context, token string) (claims *helpers.JWTClaims, session *sessionEntity.Session, err error) {
+	claims, err = parseRefreshToken(token)
+	if err != nil {
+		return nil, nil, nil
+	}
+
+	session, err = oc.SessionController.GetRefreshTokenSession(ctx, token)
+	if errors.Is(err, sessionController.ErrorInvalidRefreshToken) {
+		return nil, nil, nil
+	}
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if session.ID != claims.SessionID || session.UserID != claims.ID {
+		return nil, nil, nil
+	}
+
+	return claims, session, nil
+}
+
+// getActiveUser returns nil when the user no longer exists or is suspended.
+func (oc *OIDCController) getActiveUser(ctx context.Context, userID int64) (res *userEntity.User, err error) {
+	res, err = oc.UserController.GetUserByID(ctx, userID)
+	if errors.Is(err, userController.ErrorUserNotFound) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if res.IsSuspended {
+		return nil, nil
+	}
+
+	return res, nil
+}
+
+// authenticateConfidentialClient only accepts clients that proved their identity with a secret, public clients
+// could otherwise probe tokens of anyone.
+func (oc *OIDCController) authenticateConfidentialClient(ctx context.Context, clientID string, clientSecret string) (res *authEntity.OAuthClient, err error) {
+	res, err = oc.authenticateClient(ctx, clientID, clientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	if !res.IsConfidential() {
+		return nil, errorConfidentialClientRequired
+	}
+
+	return res, nil
+}
+
+// authenticateClient checks the secret of a confidential client, a public client must not send one.
+func (oc *OIDCController) authenticateClient(ctx context.Context, clientID string, clientSecret string) (res *authEntity.OAuthClient, err error) {
+	if clientID == "" {
+		return nil, errorInvalidClientAuthentication
+	}
+
+	res, err = oc.OAuthClientRepository.GetClientDB(ctx, clientID)
+	if err == sql.ErrNoRows {
+		return nil, errorInvalidClientAuthentication
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !res.IsConfidential() {
+		if clientSecret != "" {
+			return nil, errorInvalidClientAuthentication
+		}
+
+		return res, nil
+	}
+
+	secretHash := helpers.HashToken(clientSecret)
+	if clientSecret == "" || subtle.ConstantTimeCompare([]byte(secretHash), []byte(res.ClientSecretHash)) != 1 {
+		return nil, errorInvalidClientAuthentication
+	}
+
+	return res, nil
+}
+
+func (oc *OIDCController) consumeAuthorizationCode(ctx context.Context, code string) (res *authEntity.AuthorizationCode, err error) {
+	if code == "" {
+		return nil, errorInvalidAuthorizationCode
+	}
+
+	codeHash := helpers.HashToken(code)
+	res, err = oc.OAuthClientRepository.GetAuthorizationCodeRedis(ctx, codeHash)
+	if err != nil {
+		return nil, err
+	}
+
+	if res == nil {
+		return nil, errorInvalidAuthorizationCode
+	}
+
+	deleted, err := oc.OAuthClientRepository.DeleteAuthorizationCodeRedis(ctx, codeHash)
+	if err != nil {
+		return nil, err
+	}
+
+	if !deleted || time.Now().After(time.Unix(res.Expire, 0)) {
+		return nil, errorInvalidAuthorizationCode
+	}
+
+	return res, nil
+}
+
+func (oc *OIDCController) isSessionActive(ctx context.Context, sessionID string) (active bool, err error) {
+	session, err := oc.SessionController.GetSession(ctx, sessionID)
+	if errors.Is(err, sessionController.ErrorSessionNotFound) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	if session.IsRevoked || (session.ExpiresAt != nil && time.Now().After(*session.ExpiresAt)) {
+		return false, nil
+	}
+
+	return true, nil
+}
+
+func (oc *OIDCController) getIssuer() (string, error) {
+	if oc.OIDC == nil || oc.OIDC.Issuer == "" {
+		return "", ErrorOIDCNotConfigured
+	}
+
+	return strings.TrimSuffix(oc.OIDC.Issuer, "/"), nil
+}
+
+// BuildAuthorizationRedirect appends the response parameters to the redirect uri of the client, keeping its own
+// query. Empty values are left out.
+func BuildAuthorizationRedirect(redirectURI string, values url.Values) string {
+	parsedURL, err := url.Parse(redirectURI)
+	if err != nil {
+		return redirectURI
+	}
+
+	query := parsedURL.Query()
+	for key, value := range values {
+		if len(value) > 0 && value[0] != "" {
+			query.Set(key, value[0])
+		}
+	}
+
+	parsedURL.RawQuery = query.Encode()
+
+	return parsedURL.String()
+}
+
+// isIssuedTo tells whether client may act on a token carrying clientID. Tokens without a client were issued to the
+// sign in of Apollo itself.
+func isIssuedTo(client *authEntity.OAuthClient, clientID string) bool {
+	if clientID == "" {
+		return slices.Contains(client.Scopes, core.PermissionSessionsRevoke)
+	}
+
+	return clientID == client.ClientID
+}
+
+// validateLoginClient checks a client that signs users in, it returns its scopes with openid as default.
+func validateLoginClient(data *authEntity.CreateOAuthClientRequest) (scopes []string, err error) {
+	if len(data.RedirectURIs) == 0 {
+		return nil, ErrorMissingRedirectURI
+	}
+
+	for _, redirectURI := range data.RedirectURIs {
+		err = validateRedirectURI(redirectURI)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	scopes = data.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{ScopeOpenID}
+	}
+
+	if !slices.Contains(scopes, ScopeOpenID) {
+		return nil, ErrorInvalidClientScope
+	}
+
+	for _, scope := range scopes {
+		if !slices.Contains(supportedScopes, scope) {
+			return nil, ErrorInvalidClientScope
+		}
+	}
+
+	return scopes, nil
+}
+
+// validateServiceClient checks a client of the client_credentials grant, it never sees a browser so it has no
+// redirect uris and it must keep a secret.
+func validateServiceClient(data *authEntity.CreateOAuthClientRequest) (err error) {
+	if data.Public {
+		return ErrorPublicServiceClient
+	}
+
+	if len(data.RedirectURIs) > 0 {
+		return ErrorInvalidGrantTypes
+	}
+
+	if len(data.Scopes) == 0 {
+		return ErrorInvalidServiceScope
+	}
+
+	for _, scope := range data.Scopes {
+		if !slices.Contains(serviceScopes, scope) {
+			return ErrorInvalidServiceScope
+		}
+	}
+
+	return nil
+}
+
+// validateRedirectURI accepts absolute https urls, plain http only for loopback addresses used during development.
+func validateRedirectURI(redirectURI string) error {
+	parsedURL, err := url.Parse(redirectURI)
+	if err != nil || parsedURL.Host == "" || parsedURL.Fragment != "" {
+		return fmt.Errorf("%w: %s", ErrorInvalidRedirectURI, redirectURI)
+	}
+
+	host := parsedURL.Hostname()
+	isLoopback := host == "localhost" || host == "127.0.0.1" || host == "::1"
+	if parsedURL.Scheme != "https" && !(parsedURL.Scheme == "http" && isLoopback) {
+		return fmt.Errorf("%w: %s", ErrorInvalidRedirectURI, redirectURI)
+	}
+
+	return nil
+}
+
+// buildUserInfoClaims releases the claims of user that belong to the granted scopes.
+func buildUserInfoClaims(user *userEntity.User, scopes []string) (res helpers.UserInfoClaims) {
+	if slices.Contains(scopes, ScopeProfile) {
+		res.Name = strings.TrimSpace(user.FirstName + " " + user.LastName)
+		res.GivenName = user.FirstName
+		res.FamilyName = user.LastName
+		res.PreferredUsername = user.Username
+		res.Picture = user.ProfilePicture
+		if user.UpdatedAt != nil {
+			res.UpdatedAt = user.UpdatedAt.Unix()
+		}
+	}
+
+	if slices.Contains(scopes, ScopeEmail) {
+		res.Email = user.Email
+		res.EmailVerified = &user.IsEmailVerified
+	}
+
+	if slices.Contains(scopes, ScopePhone) && user.PhoneNumber != "" {
+		res.PhoneNumber = user.PhoneNumber
+		res.PhoneNumberVerified = &user.IsPhoneVerified
+	}
+
+	return res
+}
+
+func newIDTokenStandardClaims(issuer string, subject string, audience string, now time.Time) jwt.StandardClaims {
+	return jwt.StandardClaims{
+		Issuer:    issuer,
+		Subject:   subject,
+		Audience:  audience,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(idTokenExpiration).Unix(),
+	}
+}
+
+// parseAccessToken verifies an access token issued by Apollo and returns its claims.
+func parseAccessToken(accessToken string) (res *helpers.JWTClaims, err error) {
+	jwt, err := helpers.NewJWT()
+	if err != nil {
+		return nil, err
+	}
+
+	return parseToken(jwt, helpers.TokenKindAccess, accessToken)
+}
+
+func parseRefreshToken(refreshToken string) (res *helpers.JWTClaims, err error) {
+	jwt, err := helpers.NewJWT()
+	if err != nil {
+		return nil, err
+	}
+
+	return parseToken(jwt, helpers.TokenKindRefresh, refreshToken)
+}
+
+func parseToken(jwt *helpers.JWT, kind helpers.TokenKind, token string) (res *helpers.JWTClaims, err error) {
+	claims, isValid, err := jwt.VerifyToken(kind, token)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isValid {
+		return nil, errorInvalidAccessToken
+	}
+
+	claimByte, err := json.Marshal(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	res = &helpers.JWTClaims{}
+	err = json.Unmarshal(claimByte, res)
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
@@ -0,0 +1,264 @@
+package controllers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/winartodev/apollo/core"
+	"github.com/winartodev/apollo/core/helpers"
+	authEntity "github.com/winartodev/apollo/modules/auth/entities"
+	authRepo "github.com/winartodev/apollo/modules/auth/repositories"
+	sessionController "github.com/winartodev/apollo/modules/session/controllers"
+	sessionEntity "github.com/winartodev/apollo/modules/session/entities"
+	userEntity "github.com/winartodev/apollo/modules/user/entities"
+	"testing"
+)
+
+const oidcTestSessionID = "session-1"
+
+type fakeOAuthClientRepository struct {
+	authRepo.OAuthClientRepositoryItf
+}
+
+func (f *fakeOAuthClientRepository) GetClientDB(ctx context.Context, clientID string) (res *authEntity.OAuthClient, err error) {
+	secretHash := helpers.HashToken("secret")
+
+	switch clientID {
+	case "gateway":
+		return &authEntity.OAuthClient{ClientID: clientID, ClientSecretHash: secretHash}, nil
+	case "admin-gateway":
+		return &authEntity.OAuthClient{ClientID: clientID, ClientSecretHash: secretHash, Scopes: []string{core.PermissionSessionsRevoke}}, nil
+	case "spa":
+		return &authEntity.OAuthClient{ClientID: clientID}, nil
+	default:
+		return nil, sql.ErrNoRows
+	}
+}
+
+type fakeSessionController struct {
+	sessionController.SessionControllerItf
+	revokedTokens   []string
+	revokedSessions []string
+	events          []string
+}
+
+func (f *fakeSessionController) IsAccessTokenRevoked(ctx context.Context, claims *helpers.JWTClaims) (revoked bool, err error) {
+	return false, nil
+}
+
+func (f *fakeSessionController) RevokeAccessToken(ctx context.Context, claims *helpers.JWTClaims) (err error) {
+	f.revokedTokens = append(f.revokedTokens, claims.Id)
+	return nil
+}
+
+func (f *fakeSessionController) GetRefreshTokenSession(ctx context.Context, refreshToken string) (res *sessionEntity.Session, err error) {
+	return &sessionEntity.Session{ID: oidcTestSessionID, UserID: 1}, nil
+}
+
+func (f *fakeSessionController) RevokeSession(ctx context.Context, userID int64, sessionID string) (err error) {
+	f.revokedSessions = append(f.revokedSessions, sessionID)
+	return nil
+}
+
+// generateOIDCTestTokens returns the token pair of the sign in of Apollo itself and access tokens issued to the
+// gateway and the wiki clients.
+func generateOIDCTestTokens(t *testing.T) (userToken *helpers.JWTResponse, gatewayToken string, wikiToken string) {
+	t.Setenv(core.JwtAccessTokenSecretKey, "access-secret")
+	t.Setenv(core.JwtRefreshTokenSecretKey, "refresh-secret")
+
+	j, err := helpers.NewJWT()
+	if err != nil {
+		t.Fatalf("NewJWT() error = %v", err)
+	}
+
+	user := &userEntity.User{ID: 1, Username: "apollo", Email: "apollo@gmail.com"}
+	userToken, err = j.GenerateToken(user, oidcTestSessionID)
+	if err != nil {
+		t.Fatalf("GenerateToken() error = %v", err)
+	}
+
+	gatewayToken, err = j.GenerateClientAccessToken(user, oidcTestSessionID, "gateway", ScopeOpenID)
+	if err != nil {
+		t.Fatalf("GenerateClientAccessToken() error = %v", err)
+	}
+
+	wikiToken, err = j.GenerateClientAccessToken(user, oidcTestSessionID, "wiki", ScopeOpenID)
+	if err != nil {
+		t.Fatalf("GenerateClientAccessToken() error = %v", err)
+	}
+
+	return userToken, gatewayToken, wikiToken
+}
+
+func TestOIDCController_Introspect(t *testing.T) {
+	userToken, _, wikiToken := generateOIDCTestTokens(t)
+
+	tests := []struct {
+		name     string
+		clientID string
+		token    string
+		hint     string
+		want     *authEntity.IntrospectionResponse
+		wantErr  error
+	}{
+		{
+			name:     "success_client_access_token",
+			clientID: "gateway",
+			token:    wikiToken,
+			want: &authEntity.IntrospectionResponse{
+				Active:    true,
+				Scope:     ScopeOpenID,
+				ClientID:  "wiki",
+				Username:  "apollo",
+				TokenType: authEntity.TokenTypeHintAccessToken,
+				Subject:   "5f0c6a2e-8d1b-4c3a-9e7f-2b4d6a8c0e1f",
+				SessionID: oidcTestSessionID,
+			},
+		},
+		{
+			name:     "success_refresh_token",
+			clientID: "gateway",
+			token:    userToken.RefreshToken,
+			hint:     authEntity.TokenTypeHintRefreshToken,
+			want: &authEntity.IntrospectionResponse{
+				Active:    true,
+				Username:  "apollo",
+				TokenType: authEntity.TokenTypeHintRefreshToken,
+				Subject:   "5f0c6a2e-8d1b-4c3a-9e7f-2b4d6a8c0e1f",
+				SessionID: oidcTestSessionID,
+			},
+		},
+		{
+			name:     "success_invalid_token_is_inactive",
+			clientID: "gateway",
+			token:    "invalid",
+			want:     &authEntity.IntrospectionResponse{Active: false},
+		},
+		{
+			name:     "failed_public_client",
+			clientID: "spa",
+			token:    wikiToken,
+			wantErr:  errorConfidentialClientRequired,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			controller := NewOIDCController(OIDCController{
+				OAuthClientRepository: &fakeOAuthClientRepository{},
+				SessionController:     &fakeSessionController{},
+				UserController:        &fakeUserController{},
+			})
+
+			secret := "secret"
+			if tt.clientID == "spa" {
+				secret = ""
+			}
+
+			got, err := controller.Introspect(context.Background(), &authEntity.IntrospectionRequest{
+				Token:         tt.token,
+				TokenTypeHint: tt.hint,
+				ClientID:      tt.clientID,
+				ClientSecret:  secret,
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Introspect() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr != nil {
+				return
+			}
+
+			// the times and the id of the token are not known up front
+			got.Exp, got.Iat, got.JTI = 0, 0, ""
+			if *got != *tt.want {
+				t.Errorf("Introspect() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOIDCController_Revoke(t *testing.T) {
+	userToken, gatewayToken, wikiToken := generateOIDCTestTokens(t)
+
+	tests := []struct {
+		name             string
+		clientID         string
+		token            string
+		hint             string
+		wantErr          error
+		wantTokenRevoked bool
+		wantSessionEnded bool
+	}{
+		{
+			name:             "success_own_access_token",
+			clientID:         "gateway",
+			token:            gatewayToken,
+			hint:             authEntity.TokenTypeHintAccessToken,
+			wantTokenRevoked: true,
+		},
+		{
+			name:             "success_sign_in_refresh_token_with_revoke_scope",
+			clientID:         "admin-gateway",
+			token:            userToken.RefreshToken,
+			wantSessionEnded: true,
+		},
+		{
+			name:             "success_sign_in_access_token_with_revoke_scope",
+			clientID:         "admin-gateway",
+			token:            userToken.AccessToken,
+			wantTokenRevoked: true,
+		},
+		{
+			name:     "success_invalid_token_is_ignored",
+			clientID: "gateway",
+			token:    "invalid",
+		},
+		{
+			name:     "failed_access_token_of_another_client",
+			clientID: "gateway",
+			token:    wikiToken,
+			wantErr:  errorTokenNotIssuedToClient,
+		},
+		{
+			name:     "failed_sign_in_access_token_without_revoke_scope",
+			clientID: "gateway",
+			token:    userToken.AccessToken,
+			wantErr:  errorTokenNotIssuedToClient,
+		},
+		{
+			name:     "failed_sign_in_refresh_token_without_revoke_scope",
+			clientID: "gateway",
+			token:    userToken.RefreshToken,
+			hint:     authEntity.TokenTypeHintRefreshToken,
+			wantErr:  errorTokenNotIssuedToClient,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session := &fakeSessionController{}
+			controller := NewOIDCController(OIDCController{
+				OAuthClientRepository: &fakeOAuthClientRepository{},
+				SessionController:     session,
+				UserController:        &fakeUserController{},
+			})
+
+			err := controller.Revoke(context.Background(), &authEntity.RevocationRequest{
+				Token:         tt.token,
+				TokenTypeHint: tt.hint,
+				ClientID:      tt.clientID,
+				ClientSecret:  "secret",
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Revoke() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if (len(session.revokedTokens) == 1) != tt.wantTokenRevoked {
+				t.Errorf("Revoke() revoked tokens = %v, want revoked %v", session.revokedTokens, tt.wantTokenRevoked)
+			}
+
+			if (len(session.revokedSessions) == 1) != tt.wantSessionEnded {
+				t.Errorf("Revoke() ended sessions = %v, want ended %v", session.revokedSessions, tt.wantSessionEnded)
+			}
+		})
+	}
+}
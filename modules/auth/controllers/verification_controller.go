@@ -3,11 +3,14 @@ package controllers
 import (
 	"bytes"
 	"context"
+	"crypto/subtle"
 	"errors"
 	"fmt"
 	"github.com/gofiber/fiber/v2/log"
 	"github.com/winartodev/apollo/core/configs"
 	"github.com/winartodev/apollo/core/helpers"
+	auditController "github.com/winartodev/apollo/modules/audit/controllers"
+	auditEntity "github.com/winartodev/apollo/modules/audit/entities"
 	authEnum "github.com/winartodev/apollo/modules/auth/emums"
 	authEntity "github.com/winartodev/apollo/modules/auth/entities"
 	authRepo "github.com/winartodev/apollo/modules/auth/repositories"
@@ -18,23 +21,35 @@ const (
 	defaultOTPLength = 6
 	maxRefreshOTP    = 3
 
-	otpEmailExpiration = 60 * time.Second
-	otpPhoneExpiration = 15 * time.Minute
-	defaultTTL         = 30 * time.Minute
+	otpEmailExpiration      = 60 * time.Second
+	otpPhoneExpiration      = 15 * time.Minute
+	passwordResetExpiration = 15 * time.Minute
+	magicLinkExpiration     = 10 * time.Minute
+	loginAlertExpiration    = 7 * 24 * time.Hour
+	defaultTTL              = 30 * time.Minute
+
+	passwordResetTokenLength = 32
+	magicLinkTokenLength     = 32
+	loginAlertTokenLength    = 32
 
 	otpMailHtmlTemplate = "modules/auth/files/otp-mail-template.html"
 	phoneMessageFormat  = "[%s] Your verification code is %s, valid for (%s)"
+
+	phoneSignInMessageFormat = "[%s] Your sign in code is %s, valid for (%s). Do not share it with anyone"
 )
 
 var (
 	ErrorOTPAlreadyVerified      = errors.New("otp already verified")
-	errorInvalidEmail            = errors.New("invalid email")
+	ErrorInvalidEmail            = errors.New("invalid email")
 	errorInvalidVerificationType = errors.New("invalid verification type")
 	errorOTPAlreadyExists        = errors.New("otp already exists")
 	ErrorOTPDataEmpty            = errors.New("OTP not found")
 	errorOTPDataExpired          = errors.New("OTP is expired")
 	errorOTPNotMatch             = errors.New("otp code not match")
 	errorOTPMaxAttempts          = errors.New("OTP max attempts exceeded")
+	ErrorInvalidResetToken       = errors.New("reset token is invalid or expired")
+	ErrorInvalidMagicLink        = errors.New("magic link is invalid or expired")
+	ErrorInvalidLoginAlert       = errors.New("login alert is invalid or expired")
 )
 
 type OTPMailTemplate struct {
@@ -47,8 +62,16 @@ type VerificationControllerItf interface {
 	GetOTP(ctx context.Context, verificationType int, value string) (data *authEntity.OTPData, err error)
 	CreateOTP(ctx context.Context, verificationType int, value string) (err error)
 	VerifyOTP(ctx context.Context, verificationType int, value string, code string) (err error)
+	ConsumeOTP(ctx context.Context, verificationType int, value string, code string) (err error)
 	ResendOTP(ctx context.Context, verificationType int, value string) (err error)
 	DeleteOTP(ctx context.Context, verificationType int, value string) (err error)
+	SendMailTemplate(to string, subject string, templatePath string, data any) (err error)
+	CreatePasswordResetToken(ctx context.Context, userID int64, email string) (token string, err error)
+	ConsumePasswordResetToken(ctx context.Context, token string) (data *authEntity.PasswordResetData, err error)
+	CreateMagicLinkToken(ctx context.Context, userID int64, email string, nonce string) (token string, err error)
+	ConsumeMagicLinkToken(ctx context.Context, token string, nonce string) (data *authEntity.MagicLinkData, err error)
+	CreateLoginAlertToken(ctx context.Context, userID int64, email string, signedInAt time.Time) (token string, err error)
+	ConsumeLoginAlertToken(ctx context.Context, token string) (data *authEntity.LoginAlertData, err error)
 }
 
 type VerificationController struct {
@@ -56,6 +79,7 @@ type VerificationController struct {
 	SmtpClient             *configs.SMTPClient
 	TwilioClient           *configs.TwilioClient
 	VerificationRepository authRepo.VerificationRepositoryItf
+	AuditController        auditController.AuditControllerItf
 }
 
 func NewVerificationController(controller VerificationController) VerificationControllerItf {
@@ -64,7 +88,27 @@ func NewVerificationController(controller VerificationController) VerificationCo
 		SmtpClient:             controller.SmtpClient,
 		TwilioClient:           controller.TwilioClient,
 		VerificationRepository: controller.VerificationRepository,
+		AuditController:        controller.AuditController,
+	}
+}
+
+// newOTPEvent describes an otp action on value, the email or phone number the otp is sent to, for the audit trail.
+func newOTPEvent(action string, verificationType int, value string) *auditEntity.Event {
+	event := &auditEntity.Event{
+		Action:     action,
+		TargetType: auditEntity.TargetPhoneNumber,
+		TargetID:   value,
+		Metadata:   map[string]interface{}{"purpose": "verification"},
 	}
+
+	switch verificationType {
+	case authEnum.VerificationEmail:
+		event.TargetType = auditEntity.TargetEmail
+	case authEnum.VerificationPhoneSignIn:
+		event.Metadata["purpose"] = "sign_in"
+	}
+
+	return event
 }
 
 func (vc *VerificationController) GenerateAndStoreOTP(ctx context.Context, verificationType int, value string) (err error) {
@@ -82,8 +126,8 @@ func (vc *VerificationController) GenerateAndStoreOTP(ctx context.Context, verif
 	switch verificationType {
 	case authEnum.VerificationEmail:
 		return vc.handleSendEmailOTP(ctx, data)
-	case authEnum.VerificationPhone:
-		return vc.handleSendPhoneOTP(ctx, data)
+	case authEnum.VerificationPhone, authEnum.VerificationPhoneSignIn:
+		return vc.handleSendPhoneOTP(ctx, verificationType, data)
 	default:
 		return nil
 	}
@@ -91,7 +135,7 @@ func (vc *VerificationController) GenerateAndStoreOTP(ctx context.Context, verif
 
 func (vc *VerificationController) handleSendEmailOTP(ctx context.Context, data authEntity.OTPData) (err error) {
 	if !helpers.IsEmailValid(data.Value) {
-		return errorInvalidEmail
+		return ErrorInvalidEmail
 	}
 
 	data.Expire = time.Now().Add(otpEmailExpiration).Unix()
@@ -119,18 +163,23 @@ func (vc *VerificationController) handleSendEmailOTP(ctx context.Context, data a
 	return nil
 }
 
-func (vc *VerificationController) handleSendPhoneOTP(ctx context.Context, data authEntity.OTPData) (err error) {
+func (vc *VerificationController) handleSendPhoneOTP(ctx context.Context, verificationType int, data authEntity.OTPData) (err error) {
 	ttl := defaultTTL
 
 	data.Expire = time.Now().Add(otpPhoneExpiration).Unix()
 
-	err = vc.VerificationRepository.SetPhoneOTPRedis(ctx, data.Value, data, &ttl)
+	err = vc.setOTP(ctx, verificationType, data.Value, data, &ttl)
 	if err != nil {
 		return err
 	}
 
+	messageFormat := phoneMessageFormat
+	if verificationType == authEnum.VerificationPhoneSignIn {
+		messageFormat = phoneSignInMessageFormat
+	}
+
 	expireStr := helpers.FormatDuration(otpPhoneExpiration)
-	message := fmt.Sprintf(phoneMessageFormat, "APOLLO", data.OTP, expireStr)
+	message := fmt.Sprintf(messageFormat, "APOLLO", data.OTP, expireStr)
 
 	go func() {
 		err = vc.TwilioClient.SendSMS(data.Value, message)
@@ -142,6 +191,45 @@ func (vc *VerificationController) handleSendPhoneOTP(ctx context.Context, data a
 	return nil
 }
 
+// ConsumeOTP verifies a single use code and removes it in one step, so two requests racing with the same code can
+// not both redeem it. Only sign in codes are single use.
+func (vc *VerificationController) ConsumeOTP(ctx context.Context, verificationType int, value string, code string) (err error) {
+	if !vc.OTP.Enable {
+		return ErrorOTPDataEmpty
+	}
+
+	event := newOTPEvent(auditEntity.ActionOTPVerify, verificationType, value)
+	defer func() { vc.AuditController.Record(ctx, event, err) }()
+
+	var data *authEntity.OTPData
+	var matched bool
+	switch verificationType {
+	case authEnum.VerificationPhoneSignIn:
+		data, matched, err = vc.VerificationRepository.ConsumePhoneSignInOTPRedis(ctx, value, code)
+	default:
+		return errorInvalidVerificationType
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if data == nil {
+		return ErrorOTPDataEmpty
+	}
+
+	if !matched {
+		return errorOTPNotMatch
+	}
+
+	expirationTime := time.Unix(data.Expire, 0)
+	if time.Now().After(expirationTime) {
+		return errorOTPDataExpired
+	}
+
+	return nil
+}
+
 func (vc *VerificationController) GetOTP(ctx context.Context, verificationType int, value string) (data *authEntity.OTPData, err error) {
 	if !vc.OTP.Enable {
 		return nil, nil
@@ -152,6 +240,8 @@ func (vc *VerificationController) GetOTP(ctx context.Context, verificationType i
 		return vc.VerificationRepository.GetEmailOTPRedis(ctx, value)
 	case authEnum.VerificationPhone:
 		return vc.VerificationRepository.GetPhoneOTPRedis(ctx, value)
+	case authEnum.VerificationPhoneSignIn:
+		return vc.VerificationRepository.GetPhoneSignInOTPRedis(ctx, value)
 	default:
 		return nil, errorInvalidVerificationType
 	}
@@ -162,6 +252,9 @@ func (vc *VerificationController) CreateOTP(ctx context.Context, verificationTyp
 		return nil
 	}
 
+	event := newOTPEvent(auditEntity.ActionOTPSend, verificationType, value)
+	defer func() { vc.AuditController.Record(ctx, event, err) }()
+
 	data, err := vc.GetOTP(ctx, verificationType, value)
 	if err != nil {
 		return err
@@ -179,6 +272,9 @@ func (vc *VerificationController) VerifyOTP(ctx context.Context, verificationTyp
 		return nil
 	}
 
+	event := newOTPEvent(auditEntity.ActionOTPVerify, verificationType, value)
+	defer func() { vc.AuditController.Record(ctx, event, err) }()
+
 	data, err := vc.GetOTP(ctx, verificationType, value)
 	if err != nil {
 		return err
@@ -205,14 +301,20 @@ func (vc *VerificationController) VerifyOTP(ctx context.Context, verificationTyp
 
 	ttl := defaultTTL
 
+	return vc.setOTP(ctx, verificationType, value, *data, &ttl)
+}
+
+func (vc *VerificationController) setOTP(ctx context.Context, verificationType int, value string, data authEntity.OTPData, ttl *time.Duration) (err error) {
 	switch verificationType {
 	case authEnum.VerificationEmail:
-		return vc.VerificationRepository.SetEmailOTPRedis(ctx, value, *data, &ttl)
+		return vc.VerificationRepository.SetEmailOTPRedis(ctx, value, data, ttl)
 	case authEnum.VerificationPhone:
-		return vc.VerificationRepository.SetPhoneOTPRedis(ctx, value, *data, &ttl)
+		return vc.VerificationRepository.SetPhoneOTPRedis(ctx, value, data, ttl)
+	case authEnum.VerificationPhoneSignIn:
+		return vc.VerificationRepository.SetPhoneSignInOTPRedis(ctx, value, data, ttl)
+	default:
+		return errorInvalidVerificationType
 	}
-
-	return nil
 }
 
 func (vc *VerificationController) ResendOTP(ctx context.Context, verificationType int, value string) (err error) {
@@ -220,6 +322,10 @@ func (vc *VerificationController) ResendOTP(ctx context.Context, verificationTyp
 		return nil
 	}
 
+	event := newOTPEvent(auditEntity.ActionOTPSend, verificationType, value)
+	event.Metadata["resend"] = true
+	defer func() { vc.AuditController.Record(ctx, event, err) }()
+
 	data, err := vc.GetOTP(ctx, verificationType, value)
 	if err != nil {
 		return err
@@ -235,7 +341,7 @@ func (vc *VerificationController) ResendOTP(ctx context.Context, verificationTyp
 
 	ttl := defaultTTL
 
-	attempt, err := vc.VerificationRepository.SetResendAttemptRedis(ctx, value, &ttl)
+	attempt, err := vc.VerificationRepository.SetResendAttemptRedis(ctx, fmt.Sprintf("%d:%s", verificationType, value), &ttl)
 	if err != nil {
 		return err
 	}
@@ -248,7 +354,11 @@ func (vc *VerificationController) ResendOTP(ctx context.Context, verificationTyp
 }
 
 func (vc *VerificationController) SendOTPToEmail(data OTPMailTemplate) error {
-	completePath, err := helpers.GetCompletePath(otpMailHtmlTemplate)
+	return vc.SendMailTemplate(data.RecipientName, "Email OTP Verification Code", otpMailHtmlTemplate, data)
+}
+
+func (vc *VerificationController) SendMailTemplate(to string, subject string, templatePath string, data any) (err error) {
+	completePath, err := helpers.GetCompletePath(templatePath)
 	if err != nil {
 		return err
 	}
@@ -260,8 +370,8 @@ func (vc *VerificationController) SendOTPToEmail(data OTPMailTemplate) error {
 	}
 
 	emailData := &configs.Email{
-		To:      data.RecipientName,
-		Subject: "Email OTP Verification Code",
+		To:      to,
+		Subject: subject,
 		Body:    body.String(),
 		HTML:    true,
 	}
@@ -274,6 +384,199 @@ func (vc *VerificationController) SendOTPToEmail(data OTPMailTemplate) error {
 	return nil
 }
 
+// CreatePasswordResetToken issues a new reset token for the user, invalidating any token issued before it.
+// Only the token hash is stored, the plain token is returned so it can be delivered to the user.
+func (vc *VerificationController) CreatePasswordResetToken(ctx context.Context, userID int64, email string) (token string, err error) {
+	previous, err := vc.VerificationRepository.GetPasswordResetByUserIDRedis(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+
+	if previous != nil {
+		_, err = vc.VerificationRepository.DeletePasswordResetRedis(ctx, *previous, userID)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	token, err = helpers.GenerateRandomToken(passwordResetTokenLength)
+	if err != nil {
+		return "", err
+	}
+
+	data := authEntity.PasswordResetData{
+		UserID: userID,
+		Email:  email,
+		Expire: time.Now().Add(passwordResetExpiration).Unix(),
+	}
+
+	ttl := passwordResetExpiration
+
+	err = vc.VerificationRepository.SetPasswordResetRedis(ctx, helpers.HashToken(token), data, &ttl)
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// ConsumePasswordResetToken validates the reset token and removes it, so the same token can not be used twice.
+func (vc *VerificationController) ConsumePasswordResetToken(ctx context.Context, token string) (data *authEntity.PasswordResetData, err error) {
+	if token == "" {
+		return nil, ErrorInvalidResetToken
+	}
+
+	tokenHash := helpers.HashToken(token)
+
+	data, err = vc.VerificationRepository.GetPasswordResetRedis(ctx, tokenHash)
+	if err != nil {
+		return nil, err
+	}
+
+	if data == nil {
+		return nil, ErrorInvalidResetToken
+	}
+
+	deleted, err := vc.VerificationRepository.DeletePasswordResetRedis(ctx, tokenHash, data.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !deleted {
+		return nil, ErrorInvalidResetToken
+	}
+
+	expirationTime := time.Unix(data.Expire, 0)
+	if time.Now().After(expirationTime) {
+		return nil, ErrorInvalidResetToken
+	}
+
+	return data, nil
+}
+
+// CreateMagicLinkToken issues a single use sign in token for the user, only usable together with nonce.
+// Only the hashes of the token and the nonce are stored.
+func (vc *VerificationController) CreateMagicLinkToken(ctx context.Context, userID int64, email string, nonce string) (token string, err error) {
+	token, err = helpers.GenerateRandomToken(magicLinkTokenLength)
+	if err != nil {
+		return "", err
+	}
+
+	data := authEntity.MagicLinkData{
+		UserID:    userID,
+		Email:     email,
+		NonceHash: helpers.HashToken(nonce),
+		Expire:    time.Now().Add(magicLinkExpiration).Unix(),
+	}
+
+	ttl := magicLinkExpiration
+
+	err = vc.VerificationRepository.SetMagicLinkRedis(ctx, helpers.HashToken(token), data, &ttl)
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// ConsumeMagicLinkToken validates the token against the nonce of the requesting browser and removes it. A token
+// presented with the wrong nonce is kept, so whoever intercepted the link can not burn it for its owner.
+func (vc *VerificationController) ConsumeMagicLinkToken(ctx context.Context, token string, nonce string) (data *authEntity.MagicLinkData, err error) {
+	if token == "" || nonce == "" {
+		return nil, ErrorInvalidMagicLink
+	}
+
+	tokenHash := helpers.HashToken(token)
+
+	data, err = vc.VerificationRepository.GetMagicLinkRedis(ctx, tokenHash)
+	if err != nil {
+		return nil, err
+	}
+
+	if data == nil {
+		return nil, ErrorInvalidMagicLink
+	}
+
+	if subtle.ConstantTimeCompare([]byte(data.NonceHash), []byte(helpers.HashToken(nonce))) != 1 {
+		return nil, ErrorInvalidMagicLink
+	}
+
+	deleted, err := vc.VerificationRepository.DeleteMagicLinkRedis(ctx, tokenHash)
+	if err != nil {
+		return nil, err
+	}
+
+	if !deleted {
+		return nil, ErrorInvalidMagicLink
+	}
+
+	expirationTime := time.Unix(data.Expire, 0)
+	if time.Now().After(expirationTime) {
+		return nil, ErrorInvalidMagicLink
+	}
+
+	return data, nil
+}
+
+// CreateLoginAlertToken issues the single use token a login alert reports the sign in at signedInAt with, only its
+// hash is stored.
+func (vc *VerificationController) CreateLoginAlertToken(ctx context.Context, userID int64, email string, signedInAt time.Time) (token string, err error) {
+	token, err = helpers.GenerateRandomToken(loginAlertTokenLength)
+	if err != nil {
+		return "", err
+	}
+
+	data := authEntity.LoginAlertData{
+		UserID:     userID,
+		Email:      email,
+		SignedInAt: signedInAt.Unix(),
+		Expire:     time.Now().Add(loginAlertExpiration).Unix(),
+	}
+
+	ttl := loginAlertExpiration
+
+	err = vc.VerificationRepository.SetLoginAlertRedis(ctx, helpers.HashToken(token), data, &ttl)
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// ConsumeLoginAlertToken validates the token of a login alert and removes it.
+func (vc *VerificationController) ConsumeLoginAlertToken(ctx context.Context, token string) (data *authEntity.LoginAlertData, err error) {
+	if token == "" {
+		return nil, ErrorInvalidLoginAlert
+	}
+
+	tokenHash := helpers.HashToken(token)
+
+	data, err = vc.VerificationRepository.GetLoginAlertRedis(ctx, tokenHash)
+	if err != nil {
+		return nil, err
+	}
+
+	if data == nil {
+		return nil, ErrorInvalidLoginAlert
+	}
+
+	deleted, err := vc.VerificationRepository.DeleteLoginAlertRedis(ctx, tokenHash)
+	if err != nil {
+		return nil, err
+	}
+
+	if !deleted {
+		return nil, ErrorInvalidLoginAlert
+	}
+
+	expirationTime := time.Unix(data.Expire, 0)
+	if time.Now().After(expirationTime) {
+		return nil, ErrorInvalidLoginAlert
+	}
+
+	return data, nil
+}
+
 func (vc *VerificationController) DeleteOTP(ctx context.Context, verificationType int, value string) (err error) {
 	if !vc.OTP.Enable {
 		return nil
@@ -293,6 +596,8 @@ func (vc *VerificationController) DeleteOTP(ctx context.Context, verificationTyp
 		return vc.VerificationRepository.DeleteEmailOTPRedis(ctx, value)
 	case authEnum.VerificationPhone:
 		return vc.VerificationRepository.DeletePhoneOTPRedis(ctx, value)
+	case authEnum.VerificationPhoneSignIn:
+		return vc.VerificationRepository.DeletePhoneSignInOTPRedis(ctx, value)
 	default:
 		return errorInvalidVerificationType
 	}
@@ -3,4 +3,5 @@ package emums
 const (
 	VerificationEmail = iota + 1
 	VerificationPhone
+	VerificationPhoneSignIn
 )
@@ -71,3 +71,49 @@ type AuthResponse struct {
 type RefreshTokenRequest struct {
 	RefreshToken string `json:"refresh_token"`
 }
+
+type PhoneSignInRequest struct {
+	PhoneNumber string `json:"phone_number" form:"phone_number"`
+}
+
+type PhoneSignInVerifyRequest struct {
+	PhoneNumber string `json:"phone_number" form:"phone_number"`
+	OTP         string `json:"otp" form:"otp"`
+}
+
+type MagicLinkRequest struct {
+	Email string `json:"email" form:"email"`
+}
+
+type MagicLinkVerifyRequest struct {
+	Token string `json:"token" form:"token"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" form:"email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" form:"token"`
+	Password string `json:"password" form:"password"`
+}
+
+// UnlockUserRequest optionally names the ip address the failed attempts came from, its lockout is lifted too.
+type UnlockUserRequest struct {
+	IPAddress string `json:"ip_address" form:"ip_address"`
+}
+
+// StepUpRequest proves again who the signed in user is, Code is only needed with two-factor authentication.
+type LoginAlertDenyRequest struct {
+	Token string `json:"token" form:"token"`
+}
+
+type StepUpRequest struct {
+	Password string `json:"password" form:"password"`
+	Code     string `json:"code" form:"code"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" form:"current_password"`
+	NewPassword     string `json:"new_password" form:"new_password"`
+}
@@ -0,0 +1,47 @@
+package entities
+
+import "time"
+
+const (
+	MFAStatusPending = "mfa_pending"
+)
+
+type UserMFA struct {
+	UserID       int64      `json:"user_id"`
+	Secret       string     `json:"-"`
+	IsEnabled    bool       `json:"is_enabled"`
+	LastUsedStep int64      `json:"-"`
+	EnabledAt    *time.Time `json:"enabled_at,omitempty"`
+	CreatedAt    *time.Time `json:"created_at,omitempty"`
+	UpdatedAt    *time.Time `json:"updated_at,omitempty"`
+}
+
+type MFAEnrollment struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+	QRCode     string `json:"qr_code"`
+}
+
+type MFAChallenge struct {
+	Status    string `json:"status"`
+	MFAToken  string `json:"mfa_token"`
+	ExpiresIn int64  `json:"expires_in"`
+}
+
+type MFAChallengeData struct {
+	UserID int64 `json:"user_id"`
+	Expire int64 `json:"expire"`
+}
+
+type MFACodeRequest struct {
+	Code string `json:"code" form:"code"`
+}
+
+type MFAVerifyRequest struct {
+	MFAToken string `json:"mfa_token" form:"mfa_token"`
+	Code     string `json:"code" form:"code"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
@@ -0,0 +1,205 @@
+package entities
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/winartodev/apollo/core/helpers"
+	"time"
+)
+
+const (
+	OAuthErrorInvalidRequest          = "invalid_request"
+	OAuthErrorInvalidClient           = "invalid_client"
+	OAuthErrorInvalidGrant            = "invalid_grant"
+	OAuthErrorInvalidScope            = "invalid_scope"
+	OAuthErrorInvalidToken            = "invalid_token"
+	OAuthErrorUnauthorizedClient      = "unauthorized_client"
+	OAuthErrorUnsupportedGrantType    = "unsupported_grant_type"
+	OAuthErrorUnsupportedResponseType = "unsupported_response_type"
+	OAuthErrorAccessDenied            = "access_denied"
+	OAuthErrorLoginRequired           = "login_required"
+	OAuthErrorConsentRequired         = "consent_required"
+
+	TokenTypeHintAccessToken  = "access_token"
+	TokenTypeHintRefreshToken = "refresh_token"
+
+	PromptNone    = "none"
+	PromptLogin   = "login"
+	PromptConsent = "consent"
+)
+
+// OAuthError is an error of the OAuth 2.0 protocol, it is sent to the client as is.
+type OAuthError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+func (e *OAuthError) Error() string {
+	if e.Description == "" {
+		return e.Code
+	}
+
+	return e.Code + ": " + e.Description
+}
+
+// OAuthClient is an application that signs its users in through Apollo, or a service that authenticates as itself
+// with the client_credentials grant. Only the hash of the secret is stored, public clients such as single page apps
+// have none.
+type OAuthClient struct {
+	ID               int64      `json:"id"`
+	ClientID         string     `json:"client_id"`
+	ClientSecretHash string     `json:"-"`
+	Name             string     `json:"name"`
+	RedirectURIs     []string   `json:"redirect_uris"`
+	Scopes           []string   `json:"scopes"`
+	SkipConsent      bool       `json:"skip_consent"`
+	GrantTypes       []string   `json:"grant_types"`
+	CreatedAt        *time.Time `json:"created_at,omitempty"`
+	UpdatedAt        *time.Time `json:"updated_at,omitempty"`
+}
+
+func (c *OAuthClient) IsConfidential() bool {
+	return c.ClientSecretHash != ""
+}
+
+type CreateOAuthClientRequest struct {
+	Name         string   `json:"name"`
+	RedirectURIs []string `json:"redirect_uris"`
+	Scopes       []string `json:"scopes"`
+	SkipConsent  bool     `json:"skip_consent"`
+	Public       bool     `json:"public"`
+	GrantTypes   []string `json:"grant_types"`
+}
+
+// OAuthClientCredentials is returned once when a client is registered, the secret can not be read again.
+type OAuthClientCredentials struct {
+	*OAuthClient
+	ClientSecret string `json:"client_secret,omitempty"`
+}
+
+type AuthorizationRequest struct {
+	ResponseType        string `json:"response_type" form:"response_type"`
+	ClientID            string `json:"client_id" form:"client_id"`
+	RedirectURI         string `json:"redirect_uri" form:"redirect_uri"`
+	Scope               string `json:"scope" form:"scope"`
+	State               string `json:"state" form:"state"`
+	Nonce               string `json:"nonce" form:"nonce"`
+	CodeChallenge       string `json:"code_challenge" form:"code_challenge"`
+	CodeChallengeMethod string `json:"code_challenge_method" form:"code_challenge_method"`
+	Prompt              string `json:"prompt" form:"prompt"`
+}
+
+// BuildFromValue reads the request from the query of the redirect, or from the form posted by the sign in page.
+func (ar *AuthorizationRequest) BuildFromValue(ctx *fiber.Ctx) (res *AuthorizationRequest) {
+	value := func(key string) string {
+		if value := ctx.Query(key); value != "" {
+			return value
+		}
+
+		return ctx.FormValue(key)
+	}
+
+	return &AuthorizationRequest{
+		ResponseType:        value("response_type"),
+		ClientID:            value("client_id"),
+		RedirectURI:         value("redirect_uri"),
+		Scope:               value("scope"),
+		State:               value("state"),
+		Nonce:               value("nonce"),
+		CodeChallenge:       value("code_challenge"),
+		CodeChallengeMethod: value("code_challenge_method"),
+		Prompt:              value("prompt"),
+	}
+}
+
+// AuthorizationCode is what an issued code stands for, it is kept in Redis under the hash of the code.
+type AuthorizationCode struct {
+	ClientID      string `json:"client_id"`
+	UserID        int64  `json:"user_id"`
+	SessionID     string `json:"session_id"`
+	RedirectURI   string `json:"redirect_uri"`
+	Scope         string `json:"scope"`
+	Nonce         string `json:"nonce"`
+	CodeChallenge string `json:"code_challenge"`
+	AuthTime      int64  `json:"auth_time"`
+	Expire        int64  `json:"expire"`
+}
+
+// SSOSession keeps the browser signed in at Apollo, so every client after the first one skips the sign in page.
+// It belongs to a regular session and ends with it.
+type SSOSession struct {
+	UserID    int64  `json:"user_id"`
+	SessionID string `json:"session_id"`
+	AuthTime  int64  `json:"auth_time"`
+}
+
+type TokenRequest struct {
+	GrantType    string `json:"grant_type" form:"grant_type"`
+	Code         string `json:"code" form:"code"`
+	RedirectURI  string `json:"redirect_uri" form:"redirect_uri"`
+	ClientID     string `json:"client_id" form:"client_id"`
+	ClientSecret string `json:"client_secret" form:"client_secret"`
+	CodeVerifier string `json:"code_verifier" form:"code_verifier"`
+	Scope        string `json:"scope" form:"scope"`
+}
+
+type TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+	IDToken     string `json:"id_token,omitempty"`
+	Scope       string `json:"scope,omitempty"`
+}
+
+// IntrospectionRequest asks whether a token is still active. The hint only decides which kind of token is tried
+// first.
+type IntrospectionRequest struct {
+	Token         string `json:"token" form:"token"`
+	TokenTypeHint string `json:"token_type_hint" form:"token_type_hint"`
+	ClientID      string `json:"client_id" form:"client_id"`
+	ClientSecret  string `json:"client_secret" form:"client_secret"`
+}
+
+// IntrospectionResponse describes an active token, an inactive token is only reported as not active.
+type IntrospectionResponse struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Username  string `json:"username,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	Exp       int64  `json:"exp,omitempty"`
+	Iat       int64  `json:"iat,omitempty"`
+	Subject   string `json:"sub,omitempty"`
+	SessionID string `json:"sid,omitempty"`
+	JTI       string `json:"jti,omitempty"`
+}
+
+type RevocationRequest struct {
+	Token         string `json:"token" form:"token"`
+	TokenTypeHint string `json:"token_type_hint" form:"token_type_hint"`
+	ClientID      string `json:"client_id" form:"client_id"`
+	ClientSecret  string `json:"client_secret" form:"client_secret"`
+}
+
+type UserInfo struct {
+	Subject string `json:"sub"`
+	helpers.UserInfoClaims
+}
+
+type OpenIDConfiguration struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserInfoEndpoint                  string   `json:"userinfo_endpoint"`
+	IntrospectionEndpoint             string   `json:"introspection_endpoint"`
+	RevocationEndpoint                string   `json:"revocation_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	PromptValuesSupported             []string `json:"prompt_values_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+}
@@ -6,3 +6,30 @@ type OTPData struct {
 	IsVerified bool   `json:"is_verified"`
 	Expire     int64  `json:"expire"`
 }
+
+type PasswordResetData struct {
+	UserID int64  `json:"user_id"`
+	Email  string `json:"email"`
+	Expire int64  `json:"expire"`
+}
+
+// MagicLinkData binds a magic link to the browser that requested it through the hash of a nonce kept in a cookie.
+type MagicLinkData struct {
+	UserID    int64  `json:"user_id"`
+	Email     string `json:"email"`
+	NonceHash string `json:"nonce_hash"`
+	Expire    int64  `json:"expire"`
+}
+
+// LoginAlertData is the sign in a login alert was sent for, the token of the alert reports it as not the user's.
+type LoginAlertData struct {
+	UserID     int64  `json:"user_id"`
+	Email      string `json:"email"`
+	SignedInAt int64  `json:"signed_in_at"`
+	Expire     int64  `json:"expire"`
+}
+
+type MagicLinkNonce struct {
+	Value     string
+	ExpiresIn int64
+}
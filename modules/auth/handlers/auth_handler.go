@@ -10,12 +10,24 @@ import (
 	authController "github.com/winartodev/apollo/modules/auth/controllers"
 	"github.com/winartodev/apollo/modules/auth/emums"
 	authEntity "github.com/winartodev/apollo/modules/auth/entities"
+	sessionController "github.com/winartodev/apollo/modules/session/controllers"
+	sessionEntity "github.com/winartodev/apollo/modules/session/entities"
+	userController "github.com/winartodev/apollo/modules/user/controllers"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	magicLinkNonceCookie = "apollo_magic_link_nonce"
+	magicLinkCookiePath  = core.API + core.V1 + "/auth/magic-link"
 )
 
 type AuthHandler struct {
 	middlewares.Middleware
 	VerificationController authController.VerificationControllerItf
 	AuthController         authController.AuthControllerItf
+	MFAController          authController.MFAControllerItf
 }
 
 func NewAuthHandler(handler AuthHandler) AuthHandler {
@@ -23,6 +35,7 @@ func NewAuthHandler(handler AuthHandler) AuthHandler {
 		Middleware:             handler.Middleware,
 		VerificationController: handler.VerificationController,
 		AuthController:         handler.AuthController,
+		MFAController:          handler.MFAController,
 	}
 }
 
@@ -35,11 +48,49 @@ func (h *AuthHandler) SignIn(ctx *fiber.Ctx) error {
 		return responses.FailedResponse(ctx, fiber.StatusBadRequest, "Failed to sign in", err)
 	}
 
-	res, err := h.AuthController.SignIn(context, &req)
+	res, challenge, err := h.AuthController.SignIn(context, &req, sessionEntity.NewClientInfo(ctx))
+	var lockoutErr *authController.LockoutError
+	if errors.As(err, &lockoutErr) {
+		return lockoutFailedResponse(ctx, "Failed to sign in", lockoutErr)
+	}
+
+	if errors.Is(err, userController.ErrorUserSuspended) {
+		return responses.FailedResponse(ctx, fiber.StatusForbidden, "Failed to sign in", err)
+	}
+
 	if err != nil {
 		return responses.FailedResponse(ctx, fiber.StatusInternalServerError, "Failed to sign in", err)
 	}
 
+	if challenge != nil {
+		return responses.SuccessResponse(ctx, fiber.StatusOK, "Two-factor authentication required", challenge, nil)
+	}
+
+	return responses.SuccessResponse(ctx, fiber.StatusOK, "Success", res, nil)
+}
+
+func (h *AuthHandler) VerifyMFA(ctx *fiber.Ctx) error {
+	context := ctx.Context()
+
+	req := authEntity.MFAVerifyRequest{}
+	err := ctx.BodyParser(&req)
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusBadRequest, "Failed to verify two-factor authentication", err)
+	}
+
+	res, err := h.AuthController.VerifyMFA(context, &req, sessionEntity.NewClientInfo(ctx))
+	if errors.Is(err, authController.ErrorInvalidMFACode) || errors.Is(err, authController.ErrorInvalidMFAChallenge) {
+		return responses.FailedResponse(ctx, fiber.StatusUnauthorized, "Failed to verify two-factor authentication", err)
+	}
+
+	if errors.Is(err, userController.ErrorUserSuspended) {
+		return responses.FailedResponse(ctx, fiber.StatusForbidden, "Failed to verify two-factor authentication", err)
+	}
+
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusInternalServerError, "Failed to verify two-factor authentication", err)
+	}
+
 	return responses.SuccessResponse(ctx, fiber.StatusOK, "Success", res, nil)
 }
 
@@ -63,13 +114,13 @@ func (h *AuthHandler) SignUp(ctx *fiber.Ctx) error {
 func (h *AuthHandler) SignOut(ctx *fiber.Ctx) error {
 	context := ctx.Context()
 
-	id, err := helpers.GetUserIDFromContext(ctx)
+	claims, err := helpers.GetClaimsFromContext(ctx)
 	if err != nil {
 		return responses.FailedResponse(ctx, fiber.StatusBadRequest, "Failed Sign Out", err)
 	}
 
-	if id > 0 {
-		_, err = h.AuthController.SignOut(context, id)
+	if claims.ID > 0 {
+		_, err = h.AuthController.SignOut(context, claims)
 		if err != nil {
 			return responses.FailedResponse(ctx, fiber.StatusInternalServerError, "Failed Sign Out", err)
 		}
@@ -86,7 +137,15 @@ func (h *AuthHandler) RefreshToken(ctx *fiber.Ctx) error {
 		return responses.FailedResponse(ctx, fiber.StatusBadRequest, "Failed Refresh Token", err)
 	}
 
-	res, err := h.AuthController.RefreshToken(context, req.RefreshToken)
+	res, err := h.AuthController.RefreshToken(context, req.RefreshToken, sessionEntity.NewClientInfo(ctx))
+	if errors.Is(err, sessionController.ErrorInvalidRefreshToken) || errors.Is(err, sessionController.ErrorRefreshTokenReused) {
+		return responses.FailedResponse(ctx, fiber.StatusUnauthorized, "Failed to refresh token", err)
+	}
+
+	if errors.Is(err, userController.ErrorUserSuspended) {
+		return responses.FailedResponse(ctx, fiber.StatusForbidden, "Failed to refresh token", err)
+	}
+
 	if err != nil {
 		return responses.FailedResponse(ctx, fiber.StatusInternalServerError, "Failed to refresh token", err)
 	}
@@ -94,6 +153,335 @@ func (h *AuthHandler) RefreshToken(ctx *fiber.Ctx) error {
 	return responses.SuccessResponse(ctx, fiber.StatusOK, "Success", res, nil)
 }
 
+// RequestMagicLink sends the sign in link and keeps the nonce it is bound to in an http only cookie, so the link
+// only works in the browser that requested it.
+func (h *AuthHandler) RequestMagicLink(ctx *fiber.Ctx) error {
+	context := ctx.Context()
+
+	req := authEntity.MagicLinkRequest{}
+	err := ctx.BodyParser(&req)
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusBadRequest, "Failed to request magic link", err)
+	}
+
+	nonce, err := h.AuthController.RequestMagicLink(context, req.Email)
+	if errors.Is(err, authController.ErrorInvalidEmail) {
+		return responses.FailedResponse(ctx, fiber.StatusBadRequest, "Failed to request magic link", err)
+	}
+
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusInternalServerError, "Failed to request magic link", err)
+	}
+
+	ctx.Cookie(&fiber.Cookie{
+		Name:     magicLinkNonceCookie,
+		Value:    nonce.Value,
+		Path:     magicLinkCookiePath,
+		MaxAge:   int(nonce.ExpiresIn),
+		Secure:   true,
+		HTTPOnly: true,
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+
+	return responses.SuccessResponse(ctx, fiber.StatusOK, "Success", "If the email is registered, a magic link has been sent", nil)
+}
+
+func (h *AuthHandler) VerifyMagicLink(ctx *fiber.Ctx) error {
+	context := ctx.Context()
+
+	req := authEntity.MagicLinkVerifyRequest{}
+	err := ctx.BodyParser(&req)
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusBadRequest, "Failed to sign in with magic link", err)
+	}
+
+	nonce := ctx.Cookies(magicLinkNonceCookie)
+
+	res, challenge, err := h.AuthController.SignInWithMagicLink(context, req.Token, nonce, sessionEntity.NewClientInfo(ctx))
+	if errors.Is(err, authController.ErrorInvalidMagicLink) {
+		return responses.FailedResponse(ctx, fiber.StatusUnauthorized, "Failed to sign in with magic link", err)
+	}
+
+	if errors.Is(err, userController.ErrorUserSuspended) {
+		return responses.FailedResponse(ctx, fiber.StatusForbidden, "Failed to sign in with magic link", err)
+	}
+
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusInternalServerError, "Failed to sign in with magic link", err)
+	}
+
+	// the nonce is single use as well
+	ctx.Cookie(&fiber.Cookie{
+		Name:     magicLinkNonceCookie,
+		Path:     magicLinkCookiePath,
+		Expires:  time.Unix(0, 0),
+		Secure:   true,
+		HTTPOnly: true,
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+
+	if challenge != nil {
+		return responses.SuccessResponse(ctx, fiber.StatusOK, "Two-factor authentication required", challenge, nil)
+	}
+
+	return responses.SuccessResponse(ctx, fiber.StatusOK, "Success", res, nil)
+}
+
+func (h *AuthHandler) RequestPhoneSignIn(ctx *fiber.Ctx) error {
+	context := ctx.Context()
+
+	req := authEntity.PhoneSignInRequest{}
+	err := ctx.BodyParser(&req)
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusBadRequest, "Failed to request sign in code", err)
+	}
+
+	err = h.AuthController.RequestPhoneSignIn(context, req.PhoneNumber)
+	if errors.Is(err, authController.ErrorInvalidPhoneNumber) {
+		return responses.FailedResponse(ctx, fiber.StatusBadRequest, "Failed to request sign in code", err)
+	}
+
+	if errors.Is(err, authController.ErrorPhoneSignInDisabled) {
+		return responses.FailedResponse(ctx, fiber.StatusNotFound, "Failed to request sign in code", err)
+	}
+
+	if errors.Is(err, authController.ErrorSignInCodeLimitReached) {
+		return responses.FailedResponse(ctx, fiber.StatusTooManyRequests, "Failed to request sign in code", err)
+	}
+
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusInternalServerError, "Failed to request sign in code", err)
+	}
+
+	return responses.SuccessResponse(ctx, fiber.StatusOK, "Success", "If the phone number is registered, a sign in code has been sent", nil)
+}
+
+func (h *AuthHandler) VerifyPhoneSignIn(ctx *fiber.Ctx) error {
+	context := ctx.Context()
+
+	req := authEntity.PhoneSignInVerifyRequest{}
+	err := ctx.BodyParser(&req)
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusBadRequest, "Failed to sign in", err)
+	}
+
+	res, challenge, err := h.AuthController.SignInWithPhone(context, &req, sessionEntity.NewClientInfo(ctx))
+	var lockoutErr *authController.LockoutError
+	if errors.As(err, &lockoutErr) {
+		return lockoutFailedResponse(ctx, "Failed to sign in", lockoutErr)
+	}
+
+	if errors.Is(err, authController.ErrorInvalidPhoneNumber) {
+		return responses.FailedResponse(ctx, fiber.StatusBadRequest, "Failed to sign in", err)
+	}
+
+	if errors.Is(err, authController.ErrorInvalidSignInCode) {
+		return responses.FailedResponse(ctx, fiber.StatusUnauthorized, "Failed to sign in", err)
+	}
+
+	if errors.Is(err, authController.ErrorPhoneSignInDisabled) {
+		return responses.FailedResponse(ctx, fiber.StatusNotFound, "Failed to sign in", err)
+	}
+
+	if errors.Is(err, userController.ErrorUserSuspended) {
+		return responses.FailedResponse(ctx, fiber.StatusForbidden, "Failed to sign in", err)
+	}
+
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusInternalServerError, "Failed to sign in", err)
+	}
+
+	if challenge != nil {
+		return responses.SuccessResponse(ctx, fiber.StatusOK, "Two-factor authentication required", challenge, nil)
+	}
+
+	return responses.SuccessResponse(ctx, fiber.StatusOK, "Success", res, nil)
+}
+
+func (h *AuthHandler) ForgotPassword(ctx *fiber.Ctx) error {
+	context := ctx.Context()
+
+	req := authEntity.ForgotPasswordRequest{}
+	err := ctx.BodyParser(&req)
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusBadRequest, "Failed to request password reset", err)
+	}
+
+	err = h.AuthController.ForgotPassword(context, req.Email)
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusInternalServerError, "Failed to request password reset", err)
+	}
+
+	return responses.SuccessResponse(ctx, fiber.StatusOK, "Success", "If the email is registered, a password reset link has been sent", nil)
+}
+
+func (h *AuthHandler) ResetPassword(ctx *fiber.Ctx) error {
+	context := ctx.Context()
+
+	req := authEntity.ResetPasswordRequest{}
+	err := ctx.BodyParser(&req)
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusBadRequest, "Failed to reset password", err)
+	}
+
+	err = h.AuthController.ResetPassword(context, &req)
+	if errors.Is(err, authController.ErrorInvalidResetToken) {
+		return responses.FailedResponse(ctx, fiber.StatusBadRequest, "Failed to reset password", err)
+	}
+
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusInternalServerError, "Failed to reset password", err)
+	}
+
+	return responses.SuccessResponse(ctx, fiber.StatusOK, "Success", "password reset successfully", nil)
+}
+
+// StepUp confirms the password, and the two-factor code when enabled, of the signed in user to renew the
+// authentication of its session for the protected routes.
+func (h *AuthHandler) StepUp(ctx *fiber.Ctx) error {
+	context := ctx.Context()
+
+	id, err := helpers.GetUserIDFromContext(ctx)
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusUnauthorized, "Failed to step up", err)
+	}
+
+	sessionID, err := helpers.GetSessionIDFromContext(ctx)
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusUnauthorized, "Failed to step up", err)
+	}
+
+	req := authEntity.StepUpRequest{}
+	err = ctx.BodyParser(&req)
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusBadRequest, "Failed to step up", err)
+	}
+
+	err = h.AuthController.StepUp(context, id, sessionID, &req, sessionEntity.NewClientInfo(ctx))
+	var lockoutErr *authController.LockoutError
+	if errors.As(err, &lockoutErr) {
+		return lockoutFailedResponse(ctx, "Failed to step up", lockoutErr)
+	}
+
+	if errors.Is(err, authController.ErrorInvalidCurrentPassword) || errors.Is(err, authController.ErrorInvalidMFACode) {
+		return responses.FailedResponse(ctx, fiber.StatusBadRequest, "Failed to step up", err)
+	}
+
+	if errors.Is(err, sessionController.ErrorSessionNotFound) {
+		return responses.FailedResponse(ctx, fiber.StatusUnauthorized, "Failed to step up", err)
+	}
+
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusInternalServerError, "Failed to step up", err)
+	}
+
+	return responses.SuccessResponse(ctx, fiber.StatusOK, "Success", "authentication confirmed", nil)
+}
+
+func (h *AuthHandler) ChangePassword(ctx *fiber.Ctx) error {
+	context := ctx.Context()
+
+	id, err := helpers.GetUserIDFromContext(ctx)
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusUnauthorized, "Failed to change password", err)
+	}
+
+	req := authEntity.ChangePasswordRequest{}
+	err = ctx.BodyParser(&req)
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusBadRequest, "Failed to change password", err)
+	}
+
+	sessionID, _ := helpers.GetSessionIDFromContext(ctx)
+
+	err = h.AuthController.ChangePassword(context, id, sessionID, &req)
+	if errors.Is(err, authController.ErrorInvalidCurrentPassword) {
+		return responses.FailedResponse(ctx, fiber.StatusBadRequest, "Failed to change password", err)
+	}
+
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusInternalServerError, "Failed to change password", err)
+	}
+
+	return responses.SuccessResponse(ctx, fiber.StatusOK, "Success", "password changed successfully", nil)
+}
+
+func (h *AuthHandler) EnrollMFA(ctx *fiber.Ctx) error {
+	context := ctx.Context()
+
+	id, err := helpers.GetUserIDFromContext(ctx)
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusUnauthorized, "Failed to enroll two-factor authentication", err)
+	}
+
+	res, err := h.MFAController.Enroll(context, id)
+	if errors.Is(err, authController.ErrorMFAAlreadyEnabled) {
+		return responses.FailedResponse(ctx, fiber.StatusConflict, "Failed to enroll two-factor authentication", err)
+	}
+
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusInternalServerError, "Failed to enroll two-factor authentication", err)
+	}
+
+	return responses.SuccessResponse(ctx, fiber.StatusOK, "Success", res, nil)
+}
+
+func (h *AuthHandler) ConfirmMFA(ctx *fiber.Ctx) error {
+	context := ctx.Context()
+
+	id, err := helpers.GetUserIDFromContext(ctx)
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusUnauthorized, "Failed to confirm two-factor authentication", err)
+	}
+
+	req := authEntity.MFACodeRequest{}
+	err = ctx.BodyParser(&req)
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusBadRequest, "Failed to confirm two-factor authentication", err)
+	}
+
+	recoveryCodes, err := h.MFAController.ConfirmEnrollment(context, id, req.Code)
+	if errors.Is(err, authController.ErrorInvalidMFACode) {
+		return responses.FailedResponse(ctx, fiber.StatusBadRequest, "Failed to confirm two-factor authentication", err)
+	}
+
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusInternalServerError, "Failed to confirm two-factor authentication", err)
+	}
+
+	res := authEntity.RecoveryCodesResponse{
+		RecoveryCodes: recoveryCodes,
+	}
+
+	return responses.SuccessResponse(ctx, fiber.StatusOK, "Two-factor authentication enabled", res, nil)
+}
+
+func (h *AuthHandler) DisableMFA(ctx *fiber.Ctx) error {
+	context := ctx.Context()
+
+	id, err := helpers.GetUserIDFromContext(ctx)
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusUnauthorized, "Failed to disable two-factor authentication", err)
+	}
+
+	req := authEntity.MFACodeRequest{}
+	err = ctx.BodyParser(&req)
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusBadRequest, "Failed to disable two-factor authentication", err)
+	}
+
+	err = h.MFAController.Disable(context, id, req.Code)
+	if errors.Is(err, authController.ErrorInvalidMFACode) || errors.Is(err, authController.ErrorMFANotEnabled) {
+		return responses.FailedResponse(ctx, fiber.StatusBadRequest, "Failed to disable two-factor authentication", err)
+	}
+
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusInternalServerError, "Failed to disable two-factor authentication", err)
+	}
+
+	return responses.SuccessResponse(ctx, fiber.StatusOK, "Success", "two-factor authentication disabled", nil)
+}
+
 func (h *AuthHandler) GenerateEmailOTP(ctx *fiber.Ctx) error {
 	context := ctx.Context()
 
@@ -227,6 +615,83 @@ func (h *AuthHandler) ResendPhoneOTP(ctx *fiber.Ctx) error {
 	return responses.SuccessResponse(ctx, fiber.StatusOK, "Success", "resend OTP successfully", nil)
 }
 
+func (h *AuthHandler) UnlockUser(ctx *fiber.Ctx) error {
+	context := ctx.Context()
+
+	id, err := strconv.ParseInt(ctx.Params("id"), 10, 64)
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusBadRequest, "Failed to unlock user", err)
+	}
+
+	req := authEntity.UnlockUserRequest{}
+	if len(ctx.Body()) > 0 {
+		err = ctx.BodyParser(&req)
+		if err != nil {
+			return responses.FailedResponse(ctx, fiber.StatusBadRequest, "Failed to unlock user", err)
+		}
+	}
+
+	err = h.AuthController.UnlockUser(context, id, &req, sessionEntity.NewClientInfo(ctx))
+	if errors.Is(err, userController.ErrorUserNotFound) {
+		return responses.FailedResponse(ctx, fiber.StatusNotFound, "Failed to unlock user", err)
+	}
+
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusInternalServerError, "Failed to unlock user", err)
+	}
+
+	return responses.SuccessResponse(ctx, fiber.StatusOK, "Success", "user unlocked successfully", nil)
+}
+
+// DenyLogin reports the sign in of a login alert as not the user's, the user is signed out everywhere and has to
+// reset the password.
+func (h *AuthHandler) DenyLogin(ctx *fiber.Ctx) error {
+	context := ctx.Context()
+
+	req := authEntity.LoginAlertDenyRequest{}
+	err := ctx.BodyParser(&req)
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusBadRequest, "Failed to deny login", err)
+	}
+
+	err = h.AuthController.DenyLogin(context, req.Token, sessionEntity.NewClientInfo(ctx))
+	if errors.Is(err, authController.ErrorInvalidLoginAlert) {
+		return responses.FailedResponse(ctx, fiber.StatusBadRequest, "Failed to deny login", err)
+	}
+
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusInternalServerError, "Failed to deny login", err)
+	}
+
+	return responses.SuccessResponse(ctx, fiber.StatusOK, "Success", "login denied, check your email to reset your password", nil)
+}
+
+// lockoutFailedResponse answers 423 for a locked account and 429 for a delay or a locked ip address.
+func lockoutFailedResponse(ctx *fiber.Ctx, message string, err *authController.LockoutError) error {
+	ctx.Set(fiber.HeaderRetryAfter, strconv.FormatInt(err.RetryAfterSeconds(), 10))
+
+	status := fiber.StatusTooManyRequests
+	if err.Locked {
+		status = fiber.StatusLocked
+	}
+
+	return responses.FailedResponse(ctx, status, message, err)
+}
+
+// routeMovedTo serves a route that moved from path from to path to, the request is routed again at its new path
+// so it passes the guards of the new route.
+func routeMovedTo(from string, to string) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		path := strings.TrimRight(ctx.Path(), "/")
+		if !strings.HasSuffix(path, from) {
+			return ctx.SendStatus(fiber.StatusNotFound)
+		}
+
+		ctx.Path(strings.TrimSuffix(path, from) + to)
+		return ctx.RestartRouting()
+	}
+}
+
 func (h *AuthHandler) Register(router fiber.Router) error {
 	v1 := router.Group(core.V1)
 
@@ -236,17 +701,46 @@ func (h *AuthHandler) Register(router fiber.Router) error {
 	auth.Post("/sign-up", h.SignUp)
 	auth.Post("/refresh", h.RefreshToken)
 
+	auth.Post("/mfa/verify", h.VerifyMFA)
+
+	auth.Post("/magic-link", h.HandleRateLimit(core.RateLimitMagicLink), h.RequestMagicLink)
+	auth.Post("/magic-link/verify", h.VerifyMagicLink)
+
+	auth.Post("/phone/sign-in", h.HandleRateLimit(core.RateLimitPhoneSignIn), h.RequestPhoneSignIn)
+	auth.Post("/phone/sign-in/verify", h.VerifyPhoneSignIn)
+
+	password := auth.Group("/password")
+	password.Post("/forgot", h.ForgotPassword)
+	password.Post("/reset", h.ResetPassword)
+
+	auth.Post("/login-alerts/deny", h.DenyLogin)
+
 	otp := auth.Group("/otp")
-	otp.Post("/email", h.GenerateEmailOTP)
+	otp.Post("/email", h.HandleRateLimit(core.RateLimitOTPEmail), h.GenerateEmailOTP)
 	otp.Post("/email/validate", h.ValidateEmailOTP)
-	otp.Post("/email/resend", h.ResendEmailOTP)
+	otp.Post("/email/resend", h.HandleRateLimit(core.RateLimitOTPEmail), h.ResendEmailOTP)
 
-	otp.Post("/phone", h.GeneratePhoneOTP)
+	otp.Post("/phone", h.HandleRateLimit(core.RateLimitOTPPhone), h.GeneratePhoneOTP)
 	otp.Post("/phone/validate", h.ValidatePhoneOTP)
-	otp.Post("/phone/resend", h.ResendPhoneOTP)
+	otp.Post("/phone/resend", h.HandleRateLimit(core.RateLimitOTPPhone), h.ResendPhoneOTP)
 
 	userAuth := v1.Group("/users/auth", h.HandlePublicAccess())
 	userAuth.Post("/sign-out", h.SignOut)
+	userAuth.Post("/step-up", h.StepUp)
+
+	// change password moved behind the protected tier, clients of its original path are routed there
+	userAuth.Post("/password", routeMovedTo("/users/auth/password", core.AccessProtected+"/users/auth/password"))
+
+	mfa := userAuth.Group("/mfa")
+	mfa.Post("/enroll", h.EnrollMFA)
+	mfa.Post("/confirm", h.ConfirmMFA)
+
+	protected := v1.Group(core.AccessProtected+"/users/auth", h.HandleProtectedAccess())
+	protected.Post("/password", h.ChangePassword)
+	protected.Post("/mfa/disable", h.DisableMFA)
+
+	admin := v1.Group(core.AccessInternal).Group("/admin", h.HandleAdminAccess())
+	admin.Post("/users/:id/unlock", h.UnlockUser)
 
 	return nil
 }
@@ -0,0 +1,515 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/winartodev/apollo/core"
+	"github.com/winartodev/apollo/core/helpers"
+	"github.com/winartodev/apollo/core/middlewares"
+	"github.com/winartodev/apollo/core/responses"
+	authController "github.com/winartodev/apollo/modules/auth/controllers"
+	authEntity "github.com/winartodev/apollo/modules/auth/entities"
+	sessionEntity "github.com/winartodev/apollo/modules/session/entities"
+	userController "github.com/winartodev/apollo/modules/user/controllers"
+	"net/url"
+	"strings"
+)
+
+const (
+	authorizePageHtmlTemplate = "modules/auth/files/authorize-page-template.html"
+
+	ssoCookie       = "apollo_sso"
+	csrfCookie      = "apollo_oidc_csrf"
+	oidcCookiePath  = "/oauth2"
+	csrfTokenLength = 32
+
+	authorizeStepLogin   = "login"
+	authorizeStepMFA     = "mfa"
+	authorizeStepConsent = "consent"
+	authorizeStepError   = "error"
+
+	discoveryCacheControl = "public, max-age=300"
+)
+
+var (
+	scopeDescriptions = map[string]string{
+		authController.ScopeOpenID:  "Sign you in with your Apollo account",
+		authController.ScopeProfile: "See your name, username and profile picture",
+		authController.ScopeEmail:   "See your email address",
+		authController.ScopePhone:   "See your phone number",
+	}
+
+	errorInvalidCSRFToken    = errors.New("the sign in form has expired, please try again")
+	errorMFAChallengeExpired = errors.New("two-factor authentication has expired, please sign in again")
+	errorSignInFailed        = errors.New("something went wrong, please try again")
+)
+
+type authorizePage struct {
+	Step       string
+	ClientName string
+	Scopes     []string
+	Request    *authEntity.AuthorizationRequest
+	CSRFToken  string
+	MFAToken   string
+	Email      string
+	Error      string
+}
+
+type OIDCHandler struct {
+	middlewares.Middleware
+	OIDCController authController.OIDCControllerItf
+}
+
+func NewOIDCHandler(handler OIDCHandler) OIDCHandler {
+	return OIDCHandler{
+		Middleware:     handler.Middleware,
+		OIDCController: handler.OIDCController,
+	}
+}
+
+func (h *OIDCHandler) OpenIDConfiguration(ctx *fiber.Ctx) error {
+	res, err := h.OIDCController.GetConfiguration()
+	if errors.Is(err, authController.ErrorOIDCNotConfigured) {
+		return responses.FailedResponse(ctx, fiber.StatusNotFound, "Failed to get openid configuration", err)
+	}
+
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusInternalServerError, "Failed to get openid configuration", err)
+	}
+
+	ctx.Set(fiber.HeaderCacheControl, discoveryCacheControl)
+
+	return ctx.Status(fiber.StatusOK).JSON(res)
+}
+
+// Authorize shows the sign in page, or the consent page when the browser is already signed in, and redirects
+// back to the client with a code once the user is signed in and agreed.
+func (h *OIDCHandler) Authorize(ctx *fiber.Ctx) error {
+	context := ctx.Context()
+
+	req := (&authEntity.AuthorizationRequest{}).BuildFromValue(ctx)
+	client, err := h.OIDCController.ValidateAuthorizationRequest(context, req)
+	if err != nil {
+		return h.authorizationFailed(ctx, req, err)
+	}
+
+	sso, err := h.OIDCController.GetSSOSession(context, ctx.Cookies(ssoCookie))
+	if err != nil {
+		return h.authorizationFailed(ctx, req, err)
+	}
+
+	if sso == nil || req.Prompt == authEntity.PromptLogin {
+		if req.Prompt == authEntity.PromptNone {
+			return redirectWithOAuthError(ctx, req, &authEntity.OAuthError{Code: authEntity.OAuthErrorLoginRequired})
+		}
+
+		return h.renderAuthorizePage(ctx, fiber.StatusOK, authorizePage{Step: authorizeStepLogin, ClientName: client.Name, Request: req})
+	}
+
+	return h.continueAuthorization(ctx, req, client, sso)
+}
+
+// SubmitAuthorize handles the forms of the sign in page, every form carries the authorization request along.
+func (h *OIDCHandler) SubmitAuthorize(ctx *fiber.Ctx) error {
+	context := ctx.Context()
+
+	req := (&authEntity.AuthorizationRequest{}).BuildFromValue(ctx)
+	client, err := h.OIDCController.ValidateAuthorizationRequest(context, req)
+	if err != nil {
+		return h.authorizationFailed(ctx, req, err)
+	}
+
+	if !isCSRFTokenValid(ctx) {
+		return h.renderAuthorizePage(ctx, fiber.StatusForbidden, authorizePage{Step: authorizeStepLogin, ClientName: client.Name, Request: req, Error: errorInvalidCSRFToken.Error()})
+	}
+
+	page := authorizePage{ClientName: client.Name, Request: req}
+	clientInfo := sessionEntity.NewClientInfo(ctx)
+
+	switch ctx.FormValue("action") {
+	case authorizeStepLogin:
+		page.Step = authorizeStepLogin
+		page.Email = ctx.FormValue("email")
+
+		ssoToken, challenge, err := h.OIDCController.SignIn(context, &authEntity.SignInRequest{
+			Email:    page.Email,
+			Password: ctx.FormValue("password"),
+		}, clientInfo)
+		if err != nil {
+			return h.signInFailed(ctx, page, err)
+		}
+
+		if challenge != nil {
+			page.Step = authorizeStepMFA
+			page.MFAToken = challenge.MFAToken
+			return h.renderAuthorizePage(ctx, fiber.StatusOK, page)
+		}
+
+		return h.completeSignIn(ctx, req, client, ssoToken)
+	case authorizeStepMFA:
+		page.Step = authorizeStepMFA
+		page.MFAToken = ctx.FormValue("mfa_token")
+
+		ssoToken, err := h.OIDCController.VerifyMFA(context, &authEntity.MFAVerifyRequest{
+			MFAToken: page.MFAToken,
+			Code:     ctx.FormValue("code"),
+		}, clientInfo)
+		if errors.Is(err, authController.ErrorInvalidMFAChallenge) {
+			page.Step = authorizeStepLogin
+			page.Error = errorMFAChallengeExpired.Error()
+			return h.renderAuthorizePage(ctx, fiber.StatusUnauthorized, page)
+		}
+
+		if err != nil {
+			return h.signInFailed(ctx, page, err)
+		}
+
+		return h.completeSignIn(ctx, req, client, ssoToken)
+	case authorizeStepConsent:
+		sso, err := h.OIDCController.GetSSOSession(context, ctx.Cookies(ssoCookie))
+		if err != nil {
+			return h.authorizationFailed(ctx, req, err)
+		}
+
+		if sso == nil {
+			page.Step = authorizeStepLogin
+			return h.renderAuthorizePage(ctx, fiber.StatusOK, page)
+		}
+
+		if ctx.FormValue("decision") != "allow" {
+			return redirectWithOAuthError(ctx, req, &authEntity.OAuthError{Code: authEntity.OAuthErrorAccessDenied, Description: "the user denied the request"})
+		}
+
+		return h.issueCode(ctx, req, sso)
+	default:
+		page.Step = authorizeStepLogin
+		return h.renderAuthorizePage(ctx, fiber.StatusBadRequest, page)
+	}
+}
+
+func (h *OIDCHandler) completeSignIn(ctx *fiber.Ctx, req *authEntity.AuthorizationRequest, client *authEntity.OAuthClient, ssoToken string) error {
+	ctx.Cookie(&fiber.Cookie{
+		Name:     ssoCookie,
+		Value:    ssoToken,
+		Path:     oidcCookiePath,
+		MaxAge:   int(helpers.RefreshTokenExpiration.Seconds()),
+		Secure:   true,
+		HTTPOnly: true,
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+
+	sso, err := h.OIDCController.GetSSOSession(ctx.Context(), ssoToken)
+	if err != nil || sso == nil {
+		return h.authorizationFailed(ctx, req, errorSignInFailed)
+	}
+
+	return h.continueAuthorization(ctx, req, client, sso)
+}
+
+// continueAuthorization asks for consent unless the client is trusted to skip it, then issues the code.
+func (h *OIDCHandler) continueAuthorization(ctx *fiber.Ctx, req *authEntity.AuthorizationRequest, client *authEntity.OAuthClient, sso *authEntity.SSOSession) error {
+	if !client.SkipConsent || req.Prompt == authEntity.PromptConsent {
+		if req.Prompt == authEntity.PromptNone {
+			return redirectWithOAuthError(ctx, req, &authEntity.OAuthError{Code: authEntity.OAuthErrorConsentRequired})
+		}
+
+		var scopes []string
+		for _, scope := range strings.Fields(req.Scope) {
+			scopes = append(scopes, scopeDescriptions[scope])
+		}
+
+		return h.renderAuthorizePage(ctx, fiber.StatusOK, authorizePage{Step: authorizeStepConsent, ClientName: client.Name, Scopes: scopes, Request: req})
+	}
+
+	return h.issueCode(ctx, req, sso)
+}
+
+func (h *OIDCHandler) issueCode(ctx *fiber.Ctx, req *authEntity.AuthorizationRequest, sso *authEntity.SSOSession) error {
+	redirectURL, err := h.OIDCController.Authorize(ctx.Context(), req, sso)
+	if err != nil {
+		return h.authorizationFailed(ctx, req, err)
+	}
+
+	return ctx.Redirect(redirectURL, fiber.StatusFound)
+}
+
+// authorizationFailed sends protocol errors back to the client. Errors about the client or its redirect uri are
+// shown on the page instead, the browser must not be sent to an unverified redirect uri.
+func (h *OIDCHandler) authorizationFailed(ctx *fiber.Ctx, req *authEntity.AuthorizationRequest, err error) error {
+	if errors.Is(err, authController.ErrorInvalidOAuthClient) || errors.Is(err, authController.ErrorInvalidRedirectURI) {
+		return h.renderAuthorizePage(ctx, fiber.StatusBadRequest, authorizePage{Step: authorizeStepError, Error: err.Error()})
+	}
+
+	var oauthErr *authEntity.OAuthError
+	if errors.As(err, &oauthErr) {
+		return redirectWithOAuthError(ctx, req, oauthErr)
+	}
+
+	return h.renderAuthorizePage(ctx, fiber.StatusInternalServerError, authorizePage{Step: authorizeStepError, Error: errorSignInFailed.Error()})
+}
+
+func (h *OIDCHandler) signInFailed(ctx *fiber.Ctx, page authorizePage, err error) error {
+	page.Error = err.Error()
+
+	var lockoutErr *authController.LockoutError
+	switch {
+	case errors.As(err, &lockoutErr):
+		return h.renderAuthorizePage(ctx, fiber.StatusTooManyRequests, page)
+	case errors.Is(err, authController.ErrorInvalidSignIn), errors.Is(err, authController.ErrorInvalidMFACode):
+		return h.renderAuthorizePage(ctx, fiber.StatusUnauthorized, page)
+	case errors.Is(err, userController.ErrorUserSuspended):
+		return h.renderAuthorizePage(ctx, fiber.StatusForbidden, page)
+	default:
+		page.Error = errorSignInFailed.Error()
+		return h.renderAuthorizePage(ctx, fiber.StatusInternalServerError, page)
+	}
+}
+
+// renderAuthorizePage renders the page with a CSRF token that is also kept in a cookie, so the forms can only be
+// submitted from the page itself.
+func (h *OIDCHandler) renderAuthorizePage(ctx *fiber.Ctx, status int, page authorizePage) error {
+	csrfToken := ctx.Cookies(csrfCookie)
+	if csrfToken == "" {
+		var err error
+		csrfToken, err = helpers.GenerateRandomToken(csrfTokenLength)
+		if err != nil {
+			return responses.FailedResponse(ctx, fiber.StatusInternalServerError, "Failed to render sign in page", err)
+		}
+
+		ctx.Cookie(&fiber.Cookie{
+			Name:     csrfCookie,
+			Value:    csrfToken,
+			Path:     oidcCookiePath,
+			Secure:   true,
+			HTTPOnly: true,
+			SameSite: fiber.CookieSameSiteLaxMode,
+		})
+	}
+
+	page.CSRFToken = csrfToken
+
+	var body bytes.Buffer
+	err := helpers.ParseHTMLTemplateAndExecute(authorizePageHtmlTemplate, &body, page)
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusInternalServerError, "Failed to render sign in page", err)
+	}
+
+	ctx.Set(fiber.HeaderCacheControl, "no-store")
+	ctx.Set(fiber.HeaderXFrameOptions, "DENY")
+	ctx.Set(fiber.HeaderContentSecurityPolicy, "frame-ancestors 'none'")
+	ctx.Type("html", "utf-8")
+
+	return ctx.Status(status).Send(body.Bytes())
+}
+
+func (h *OIDCHandler) Token(ctx *fiber.Ctx) error {
+	context := ctx.Context()
+
+	ctx.Set(fiber.HeaderCacheControl, "no-store")
+	ctx.Set(fiber.HeaderPragma, "no-cache")
+
+	clientID, clientSecret, basicAuth := getClientCredentials(ctx)
+	req := authEntity.TokenRequest{
+		GrantType:    ctx.FormValue("grant_type"),
+		Code:         ctx.FormValue("code"),
+		RedirectURI:  ctx.FormValue("redirect_uri"),
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		CodeVerifier: ctx.FormValue("code_verifier"),
+		Scope:        ctx.FormValue("scope"),
+	}
+
+	res, err := h.OIDCController.Exchange(context, &req)
+	if err != nil {
+		return clientRequestFailed(ctx, err, basicAuth)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(res)
+}
+
+// Introspect lets resource servers check a token on every request instead of only verifying its signature.
+func (h *OIDCHandler) Introspect(ctx *fiber.Ctx) error {
+	context := ctx.Context()
+
+	ctx.Set(fiber.HeaderCacheControl, "no-store")
+	ctx.Set(fiber.HeaderPragma, "no-cache")
+
+	clientID, clientSecret, basicAuth := getClientCredentials(ctx)
+	res, err := h.OIDCController.Introspect(context, &authEntity.IntrospectionRequest{
+		Token:         ctx.FormValue("token"),
+		TokenTypeHint: ctx.FormValue("token_type_hint"),
+		ClientID:      clientID,
+		ClientSecret:  clientSecret,
+	})
+	if err != nil {
+		return clientRequestFailed(ctx, err, basicAuth)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(res)
+}
+
+// Revoke answers 200 for every authenticated request, whether or not the token was active, unless the token was
+// issued to another client.
+func (h *OIDCHandler) Revoke(ctx *fiber.Ctx) error {
+	context := ctx.Context()
+
+	clientID, clientSecret, basicAuth := getClientCredentials(ctx)
+	err := h.OIDCController.Revoke(context, &authEntity.RevocationRequest{
+		Token:         ctx.FormValue("token"),
+		TokenTypeHint: ctx.FormValue("token_type_hint"),
+		ClientID:      clientID,
+		ClientSecret:  clientSecret,
+	})
+	if err != nil {
+		return clientRequestFailed(ctx, err, basicAuth)
+	}
+
+	return ctx.SendStatus(fiber.StatusOK)
+}
+
+func (h *OIDCHandler) UserInfo(ctx *fiber.Ctx) error {
+	context := ctx.Context()
+
+	var token string
+	authHeader := ctx.Get(fiber.HeaderAuthorization)
+	if strings.HasPrefix(authHeader, "Bearer ") {
+		token = authHeader[len("Bearer "):]
+	}
+
+	res, err := h.OIDCController.GetUserInfo(context, token)
+	var oauthErr *authEntity.OAuthError
+	if errors.As(err, &oauthErr) {
+		ctx.Set(fiber.HeaderWWWAuthenticate, `Bearer error="`+oauthErr.Code+`"`)
+		return ctx.Status(fiber.StatusUnauthorized).JSON(oauthErr)
+	}
+
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(authEntity.OAuthError{Code: "server_error"})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(res)
+}
+
+func (h *OIDCHandler) CreateClient(ctx *fiber.Ctx) error {
+	context := ctx.Context()
+
+	req := authEntity.CreateOAuthClientRequest{}
+	err := ctx.BodyParser(&req)
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusBadRequest, "Failed to create client", err)
+	}
+
+	res, err := h.OIDCController.CreateClient(context, &req)
+	if errors.Is(err, authController.ErrorInvalidClientName) || errors.Is(err, authController.ErrorInvalidClientScope) ||
+		errors.Is(err, authController.ErrorMissingRedirectURI) || errors.Is(err, authController.ErrorInvalidRedirectURI) ||
+		errors.Is(err, authController.ErrorInvalidGrantTypes) || errors.Is(err, authController.ErrorInvalidServiceScope) ||
+		errors.Is(err, authController.ErrorPublicServiceClient) {
+		return responses.FailedResponse(ctx, fiber.StatusBadRequest, "Failed to create client", err)
+	}
+
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusInternalServerError, "Failed to create client", err)
+	}
+
+	return responses.SuccessResponse(ctx, fiber.StatusCreated, "Success", res, nil)
+}
+
+// redirectWithOAuthError sends the browser back to the client with the error, the redirect uri was verified before.
+func redirectWithOAuthError(ctx *fiber.Ctx, req *authEntity.AuthorizationRequest, err *authEntity.OAuthError) error {
+	return ctx.Redirect(authController.BuildAuthorizationRedirect(req.RedirectURI, url.Values{
+		"error":             {err.Code},
+		"error_description": {err.Description},
+		"state":             {req.State},
+	}), fiber.StatusFound)
+}
+
+// clientRequestFailed answers a request of a client authenticated with its credentials, failed authentication
+// is a 401 that asks for basic authentication again when the client used it.
+func clientRequestFailed(ctx *fiber.Ctx, err error, basicAuth bool) error {
+	var oauthErr *authEntity.OAuthError
+	if !errors.As(err, &oauthErr) {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(authEntity.OAuthError{Code: "server_error"})
+	}
+
+	status := fiber.StatusBadRequest
+	if oauthErr.Code == authEntity.OAuthErrorInvalidClient {
+		status = fiber.StatusUnauthorized
+		if basicAuth {
+			ctx.Set(fiber.HeaderWWWAuthenticate, `Basic realm="apollo"`)
+		}
+	}
+
+	return ctx.Status(status).JSON(oauthErr)
+}
+
+func isCSRFTokenValid(ctx *fiber.Ctx) bool {
+	cookieToken := ctx.Cookies(csrfCookie)
+	return cookieToken != "" && subtle.ConstantTimeCompare([]byte(cookieToken), []byte(ctx.FormValue("csrf_token"))) == 1
+}
+
+// getClientCredentials reads the client credentials from basic authentication, or from the form when the client
+// sent them there.
+func getClientCredentials(ctx *fiber.Ctx) (clientID string, clientSecret string, basicAuth bool) {
+	if clientID, clientSecret, ok := getBasicAuth(ctx); ok {
+		return clientID, clientSecret, true
+	}
+
+	return ctx.FormValue("client_id"), ctx.FormValue("client_secret"), false
+}
+
+// getBasicAuth reads client credentials sent with HTTP basic authentication, both parts are form url encoded.
+func getBasicAuth(ctx *fiber.Ctx) (clientID string, clientSecret string, ok bool) {
+	authHeader := ctx.Get(fiber.HeaderAuthorization)
+	if !strings.HasPrefix(authHeader, "Basic ") {
+		return "", "", false
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(authHeader[len("Basic "):])
+	if err != nil {
+		return "", "", false
+	}
+
+	rawID, rawSecret, ok := strings.Cut(string(decoded), ":")
+	if !ok {
+		return "", "", false
+	}
+
+	clientID, err = url.QueryUnescape(rawID)
+	if err != nil {
+		return "", "", false
+	}
+
+	clientSecret, err = url.QueryUnescape(rawSecret)
+	if err != nil {
+		return "", "", false
+	}
+
+	return clientID, clientSecret, true
+}
+
+// RegisterProvider registers the OpenID Connect endpoints, they live next to the JWKS outside of /api so the
+// issuer url stays short.
+func (h *OIDCHandler) RegisterProvider(router fiber.Router) {
+	router.Get("/.well-known/openid-configuration", h.OpenIDConfiguration)
+
+	oauth2 := router.Group("/oauth2")
+	oauth2.Get("/authorize", h.Authorize)
+	oauth2.Post("/authorize", h.SubmitAuthorize)
+	oauth2.Post("/token", h.Token)
+	oauth2.Post("/introspect", h.Introspect)
+	oauth2.Post("/revoke", h.Revoke)
+	oauth2.Get("/userinfo", h.UserInfo)
+	oauth2.Post("/userinfo", h.UserInfo)
+}
+
+func (h *OIDCHandler) Register(router fiber.Router) error {
+	v1 := router.Group(core.V1)
+
+	admin := v1.Group(core.AccessInternal).Group("/admin", h.HandleAdminAccess())
+	admin.Post("/oauth-clients", h.CreateClient)
+
+	return nil
+}
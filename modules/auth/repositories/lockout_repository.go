@@ -0,0 +1,98 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"time"
+)
+
+const (
+	signInFailurePrefix = "sign_in_failure"
+	signInLockPrefix    = "sign_in_lock"
+	signInDelayPrefix   = "sign_in_delay"
+)
+
+// incrementScript counts up KEYS[1] and makes the first count expire after ARGV[1] milliseconds, so the counter
+// can not be left without an expiry. A counter found without one gets it as well.
+var incrementScript = redis.NewScript(`
+local count = redis.call('INCR', KEYS[1])
+if count == 1 or redis.call('PTTL', KEYS[1]) < 0 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+
+return count
+`)
+
+type LockoutRepositoryItf interface {
+	IncrementFailureRedis(ctx context.Context, scope string, value string, window time.Duration) (count int64, err error)
+	SetLockRedis(ctx context.Context, scope string, value string, ttl time.Duration) (err error)
+	SetDelayRedis(ctx context.Context, scope string, value string, ttl time.Duration) (err error)
+	GetBlockRedis(ctx context.Context, scope string, value string) (lockTTL time.Duration, delayTTL time.Duration, err error)
+	DeleteLockoutRedis(ctx context.Context, scope string, value string) (err error)
+}
+
+type LockoutRepository struct {
+	Redis *redis.Client
+}
+
+func NewLockoutRepository(repository LockoutRepository) LockoutRepositoryItf {
+	return &LockoutRepository{
+		Redis: repository.Redis,
+	}
+}
+
+// IncrementFailureRedis counts failures in a fixed window that starts with the first failure.
+func (lr *LockoutRepository) IncrementFailureRedis(ctx context.Context, scope string, value string, window time.Duration) (count int64, err error) {
+	key := lr.generateRedisKey(signInFailurePrefix, scope, value)
+
+	count, err = incrementScript.Run(ctx, lr.Redis, []string{key}, window.Milliseconds()).Int64()
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func (lr *LockoutRepository) SetLockRedis(ctx context.Context, scope string, value string, ttl time.Duration) (err error) {
+	return lr.Redis.SetEX(ctx, lr.generateRedisKey(signInLockPrefix, scope, value), 1, ttl).Err()
+}
+
+func (lr *LockoutRepository) SetDelayRedis(ctx context.Context, scope string, value string, ttl time.Duration) (err error) {
+	return lr.Redis.SetEX(ctx, lr.generateRedisKey(signInDelayPrefix, scope, value), 1, ttl).Err()
+}
+
+// GetBlockRedis returns how long the lock and the delay of value still last, zero when there is none.
+func (lr *LockoutRepository) GetBlockRedis(ctx context.Context, scope string, value string) (lockTTL time.Duration, delayTTL time.Duration, err error) {
+	pipe := lr.Redis.Pipeline()
+	lockCmd := pipe.PTTL(ctx, lr.generateRedisKey(signInLockPrefix, scope, value))
+	delayCmd := pipe.PTTL(ctx, lr.generateRedisKey(signInDelayPrefix, scope, value))
+
+	_, err = pipe.Exec(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// PTTL answers with a negative duration when the key does not exist
+	return maxDuration(lockCmd.Val(), 0), maxDuration(delayCmd.Val(), 0), nil
+}
+
+func (lr *LockoutRepository) DeleteLockoutRedis(ctx context.Context, scope string, value string) (err error) {
+	return lr.Redis.Del(ctx,
+		lr.generateRedisKey(signInFailurePrefix, scope, value),
+		lr.generateRedisKey(signInLockPrefix, scope, value),
+		lr.generateRedisKey(signInDelayPrefix, scope, value),
+	).Err()
+}
+
+func (lr *LockoutRepository) generateRedisKey(prefix string, scope string, value string) string {
+	return fmt.Sprintf("%s:%s:%s", prefix, scope, value)
+}
+
+func maxDuration(a time.Duration, b time.Duration) time.Duration {
+	if a > b {
+		return a
+	}
+
+	return b
+}
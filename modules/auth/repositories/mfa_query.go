@@ -0,0 +1,92 @@
+package repositories
+
+const (
+	UpsertUserMFADBQuery = `
+		INSERT INTO user_mfa 
+		    (
+				user_id,
+				secret,
+				is_enabled,
+				last_used_step,
+				created_at,
+				updated_at
+			) VALUES (
+						$1, -- user_id
+						$2, -- secret
+						FALSE,
+						0,
+						$3, -- created_at
+						$3  -- updated_at
+					)
+		ON CONFLICT (user_id) DO UPDATE 
+		SET 
+		    secret = EXCLUDED.secret,
+		    is_enabled = FALSE,
+		    last_used_step = 0,
+		    enabled_at = 0,
+		    updated_at = EXCLUDED.updated_at;
+	`
+
+	GetUserMFAByUserIDDBQuery = `
+		SELECT 
+			user_id,
+			secret,
+			is_enabled,
+			last_used_step,
+			enabled_at,
+			created_at,
+			updated_at
+		FROM user_mfa
+		WHERE 
+		    user_id = $1;
+	`
+
+	EnableUserMFADBQuery = `
+		UPDATE user_mfa 
+		SET 
+		    is_enabled = TRUE,
+		    last_used_step = $1,
+		    enabled_at = $2,
+		    updated_at = $2
+		WHERE 
+		    user_id = $3;
+	`
+
+	UpdateMFALastUsedStepDBQuery = `
+		UPDATE user_mfa 
+		SET 
+		    last_used_step = $1,
+		    updated_at = $2
+		WHERE 
+		    user_id = $3 AND last_used_step < $1;
+	`
+
+	DeleteUserMFADBQuery = `
+		DELETE FROM user_mfa WHERE user_id = $1;
+	`
+
+	InsertRecoveryCodeDBQuery = `
+		INSERT INTO user_recovery_codes 
+		    (
+				user_id,
+				code_hash,
+				created_at
+			) VALUES (
+						$1, -- user_id
+						$2, -- code_hash
+						$3  -- created_at
+					);
+	`
+
+	UseRecoveryCodeDBQuery = `
+		UPDATE user_recovery_codes 
+		SET 
+		    used_at = $1
+		WHERE 
+		    user_id = $2 AND code_hash = $3 AND used_at = 0;
+	`
+
+	DeleteRecoveryCodesByUserIDDBQuery = `
+		DELETE FROM user_recovery_codes WHERE user_id = $1;
+	`
+)
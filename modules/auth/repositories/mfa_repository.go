@@ -0,0 +1,242 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"github.com/winartodev/apollo/core/helpers"
+	authEntity "github.com/winartodev/apollo/modules/auth/entities"
+	"time"
+)
+
+const (
+	mfaChallengePrefix         = "mfa_pending"
+	mfaChallengeAttemptsPrefix = "mfa_pending_attempts"
+)
+
+type MFARepositoryItf interface {
+	UpsertUserMFADB(ctx context.Context, userID int64, secret string) (err error)
+	GetUserMFAByUserIDDB(ctx context.Context, userID int64) (res *authEntity.UserMFA, err error)
+	EnableUserMFADB(ctx context.Context, userID int64, step int64, recoveryCodeHashes []string) (err error)
+	UpdateLastUsedStepDB(ctx context.Context, userID int64, step int64) (updated bool, err error)
+	UseRecoveryCodeDB(ctx context.Context, userID int64, codeHash string) (used bool, err error)
+	DeleteUserMFADB(ctx context.Context, userID int64) (err error)
+	SetMFAChallengeRedis(ctx context.Context, tokenHash string, data authEntity.MFAChallengeData, ttl *time.Duration) (err error)
+	GetMFAChallengeRedis(ctx context.Context, tokenHash string) (res *authEntity.MFAChallengeData, err error)
+	DeleteMFAChallengeRedis(ctx context.Context, tokenHash string) (deleted bool, err error)
+	IncrementMFAChallengeAttemptsRedis(ctx context.Context, tokenHash string, ttl time.Duration) (attempts int64, err error)
+}
+
+type MFARepository struct {
+	DB    *sql.DB
+	Redis *redis.Client
+}
+
+func NewMFARepository(repository MFARepository) MFARepositoryItf {
+	return &MFARepository{
+		DB:    repository.DB,
+		Redis: repository.Redis,
+	}
+}
+
+func (mr *MFARepository) UpsertUserMFADB(ctx context.Context, userID int64, secret string) (err error) {
+	_, err = mr.DB.ExecContext(ctx, UpsertUserMFADBQuery, userID, secret, time.Now().Unix())
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (mr *MFARepository) GetUserMFAByUserIDDB(ctx context.Context, userID int64) (res *authEntity.UserMFA, err error) {
+	var enabledAtUnix int64
+	var createdAtUnix int64
+	var updatedAtUnix int64
+
+	res = &authEntity.UserMFA{}
+	err = mr.DB.QueryRowContext(ctx, GetUserMFAByUserIDDBQuery, userID).
+		Scan(
+			&res.UserID,
+			&res.Secret,
+			&res.IsEnabled,
+			&res.LastUsedStep,
+			&enabledAtUnix,
+			&createdAtUnix,
+			&updatedAtUnix,
+		)
+	if err != nil {
+		return nil, err
+	}
+
+	res.EnabledAt = helpers.FormatUnixTime(enabledAtUnix)
+	res.CreatedAt = helpers.FormatUnixTime(createdAtUnix)
+	res.UpdatedAt = helpers.FormatUnixTime(updatedAtUnix)
+
+	return res, nil
+}
+
+func (mr *MFARepository) EnableUserMFADB(ctx context.Context, userID int64, step int64, recoveryCodeHashes []string) (err error) {
+	now := time.Now().Unix()
+
+	tx, err := mr.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, EnableUserMFADBQuery, step, now, userID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, DeleteRecoveryCodesByUserIDDBQuery, userID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	stmt, err := tx.PrepareContext(ctx, InsertRecoveryCodeDBQuery)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	for _, codeHash := range recoveryCodeHashes {
+		_, err = stmt.ExecContext(ctx, userID, codeHash, now)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// UpdateLastUsedStepDB only moves the step forward, so a code that was already accepted can not be replayed.
+func (mr *MFARepository) UpdateLastUsedStepDB(ctx context.Context, userID int64, step int64) (updated bool, err error) {
+	result, err := mr.DB.ExecContext(ctx, UpdateMFALastUsedStepDBQuery, step, time.Now().Unix(), userID)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+func (mr *MFARepository) UseRecoveryCodeDB(ctx context.Context, userID int64, codeHash string) (used bool, err error) {
+	result, err := mr.DB.ExecContext(ctx, UseRecoveryCodeDBQuery, time.Now().Unix(), userID, codeHash)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+func (mr *MFARepository) DeleteUserMFADB(ctx context.Context, userID int64) (err error) {
+	tx, err := mr.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, DeleteRecoveryCodesByUserIDDBQuery, userID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, DeleteUserMFADBQuery, userID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (mr *MFARepository) SetMFAChallengeRedis(ctx context.Context, tokenHash string, data authEntity.MFAChallengeData, ttl *time.Duration) (err error) {
+	dataByte, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	key := fmt.Sprintf("%s:%s", mfaChallengePrefix, tokenHash)
+	err = mr.Redis.SetEX(ctx, key, dataByte, *ttl).Err()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (mr *MFARepository) GetMFAChallengeRedis(ctx context.Context, tokenHash string) (res *authEntity.MFAChallengeData, err error) {
+	key := fmt.Sprintf("%s:%s", mfaChallengePrefix, tokenHash)
+
+	dataStr, err := mr.Redis.Get(ctx, key).Result()
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	if err == redis.Nil {
+		return nil, nil
+	}
+
+	var data authEntity.MFAChallengeData
+	err = json.Unmarshal([]byte(dataStr), &data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &data, nil
+}
+
+// DeleteMFAChallengeRedis removes the challenge together with its attempt counter.
+func (mr *MFARepository) DeleteMFAChallengeRedis(ctx context.Context, tokenHash string) (deleted bool, err error) {
+	key := fmt.Sprintf("%s:%s", mfaChallengePrefix, tokenHash)
+	attemptsKey := fmt.Sprintf("%s:%s", mfaChallengeAttemptsPrefix, tokenHash)
+
+	var count *redis.IntCmd
+	_, err = mr.Redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		count = pipe.Del(ctx, key)
+		pipe.Del(ctx, attemptsKey)
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return count.Val() > 0, nil
+}
+
+// IncrementMFAChallengeAttemptsRedis counts an invalid code for the challenge. The counter is kept apart from the
+// challenge and incremented atomically, so concurrent attempts are all counted.
+func (mr *MFARepository) IncrementMFAChallengeAttemptsRedis(ctx context.Context, tokenHash string, ttl time.Duration) (attempts int64, err error) {
+	key := fmt.Sprintf("%s:%s", mfaChallengeAttemptsPrefix, tokenHash)
+
+	attempts, err = incrementScript.Run(ctx, mr.Redis, []string{key}, ttl.Milliseconds()).Int64()
+	if err != nil {
+		return 0, err
+	}
+
+	return attempts, nil
+}
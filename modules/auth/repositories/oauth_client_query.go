@@ -0,0 +1,45 @@
+package repositories
+
+const (
+	InsertOAuthClientDBQuery = `
+		INSERT INTO oauth_clients
+		    (
+				client_id,
+				client_secret_hash,
+				name,
+				redirect_uris,
+				scopes,
+				skip_consent,
+				grant_types,
+				created_at,
+				updated_at
+			) VALUES (
+						$1, -- client_id
+						NULLIF($2, ''), -- client_secret_hash
+						$3, -- name
+						$4, -- redirect_uris
+						$5, -- scopes
+						$6, -- skip_consent
+						$7, -- grant_types
+						$8, -- created_at
+						$8  -- updated_at
+					)
+			  RETURNING id;
+	`
+
+	GetOAuthClientDBQuery = `
+		SELECT
+			id,
+			client_id,
+			COALESCE(client_secret_hash, ''),
+			name,
+			redirect_uris,
+			scopes,
+			skip_consent,
+			grant_types,
+			created_at,
+			updated_at
+		FROM oauth_clients
+		WHERE client_id = $1
+	`
+)
@@ -0,0 +1,165 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"github.com/lib/pq"
+	"github.com/winartodev/apollo/core/helpers"
+	authEntity "github.com/winartodev/apollo/modules/auth/entities"
+	"strings"
+	"time"
+)
+
+const (
+	authorizationCodePrefix = "oauth_code"
+	ssoSessionPrefix        = "sso_session"
+)
+
+type OAuthClientRepositoryItf interface {
+	CreateClientDB(ctx context.Context, data *authEntity.OAuthClient) (id int64, err error)
+	GetClientDB(ctx context.Context, clientID string) (res *authEntity.OAuthClient, err error)
+	SetAuthorizationCodeRedis(ctx context.Context, codeHash string, data authEntity.AuthorizationCode, ttl time.Duration) (err error)
+	GetAuthorizationCodeRedis(ctx context.Context, codeHash string) (res *authEntity.AuthorizationCode, err error)
+	DeleteAuthorizationCodeRedis(ctx context.Context, codeHash string) (deleted bool, err error)
+	SetSSOSessionRedis(ctx context.Context, tokenHash string, data authEntity.SSOSession, ttl time.Duration) (err error)
+	GetSSOSessionRedis(ctx context.Context, tokenHash string) (res *authEntity.SSOSession, err error)
+	DeleteSSOSessionRedis(ctx context.Context, tokenHash string) (err error)
+}
+
+type OAuthClientRepository struct {
+	DB    *sql.DB
+	Redis *redis.Client
+}
+
+func NewOAuthClientRepository(repository OAuthClientRepository) OAuthClientRepositoryItf {
+	return &OAuthClientRepository{
+		DB:    repository.DB,
+		Redis: repository.Redis,
+	}
+}
+
+func (cr *OAuthClientRepository) CreateClientDB(ctx context.Context, data *authEntity.OAuthClient) (id int64, err error) {
+	err = cr.DB.QueryRowContext(ctx, InsertOAuthClientDBQuery,
+		data.ClientID,
+		data.ClientSecretHash,
+		data.Name,
+		pq.Array(data.RedirectURIs),
+		strings.Join(data.Scopes, " "),
+		data.SkipConsent,
+		strings.Join(data.GrantTypes, " "),
+		data.CreatedAt.Unix(),
+	).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+func (cr *OAuthClientRepository) GetClientDB(ctx context.Context, clientID string) (res *authEntity.OAuthClient, err error) {
+	var scopes string
+	var grantTypes string
+	var createdAtUnix int64
+	var updatedAtUnix int64
+
+	res = &authEntity.OAuthClient{}
+	err = cr.DB.QueryRowContext(ctx, GetOAuthClientDBQuery, clientID).Scan(
+		&res.ID,
+		&res.ClientID,
+		&res.ClientSecretHash,
+		&res.Name,
+		pq.Array(&res.RedirectURIs),
+		&scopes,
+		&res.SkipConsent,
+		&grantTypes,
+		&createdAtUnix,
+		&updatedAtUnix,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	res.Scopes = strings.Fields(scopes)
+	res.GrantTypes = strings.Fields(grantTypes)
+	res.CreatedAt = helpers.FormatUnixTime(createdAtUnix)
+	res.UpdatedAt = helpers.FormatUnixTime(updatedAtUnix)
+
+	return res, nil
+}
+
+func (cr *OAuthClientRepository) SetAuthorizationCodeRedis(ctx context.Context, codeHash string, data authEntity.AuthorizationCode, ttl time.Duration) (err error) {
+	return cr.setRedis(ctx, authorizationCodePrefix, codeHash, data, ttl)
+}
+
+func (cr *OAuthClientRepository) GetAuthorizationCodeRedis(ctx context.Context, codeHash string) (res *authEntity.AuthorizationCode, err error) {
+	res = &authEntity.AuthorizationCode{}
+	found, err := cr.getRedis(ctx, authorizationCodePrefix, codeHash, res)
+	if err != nil || !found {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// DeleteAuthorizationCodeRedis removes the code and reports whether this call was the one that removed it, so a
+// code can only be redeemed once.
+func (cr *OAuthClientRepository) DeleteAuthorizationCodeRedis(ctx context.Context, codeHash string) (deleted bool, err error) {
+	count, err := cr.Redis.Del(ctx, cr.generateRedisKey(authorizationCodePrefix, codeHash)).Result()
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+func (cr *OAuthClientRepository) SetSSOSessionRedis(ctx context.Context, tokenHash string, data authEntity.SSOSession, ttl time.Duration) (err error) {
+	return cr.setRedis(ctx, ssoSessionPrefix, tokenHash, data, ttl)
+}
+
+func (cr *OAuthClientRepository) GetSSOSessionRedis(ctx context.Context, tokenHash string) (res *authEntity.SSOSession, err error) {
+	res = &authEntity.SSOSession{}
+	found, err := cr.getRedis(ctx, ssoSessionPrefix, tokenHash, res)
+	if err != nil || !found {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (cr *OAuthClientRepository) DeleteSSOSessionRedis(ctx context.Context, tokenHash string) (err error) {
+	return cr.Redis.Del(ctx, cr.generateRedisKey(ssoSessionPrefix, tokenHash)).Err()
+}
+
+func (cr *OAuthClientRepository) setRedis(ctx context.Context, prefix string, key string, data interface{}, ttl time.Duration) (err error) {
+	dataByte, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	return cr.Redis.SetEX(ctx, cr.generateRedisKey(prefix, key), dataByte, ttl).Err()
+}
+
+func (cr *OAuthClientRepository) getRedis(ctx context.Context, prefix string, key string, out interface{}) (found bool, err error) {
+	dataStr, err := cr.Redis.Get(ctx, cr.generateRedisKey(prefix, key)).Result()
+	if err == redis.Nil {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	err = json.Unmarshal([]byte(dataStr), out)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (cr *OAuthClientRepository) generateRedisKey(prefix string, value string) string {
+	return fmt.Sprintf("%s:%s", prefix, value)
+}
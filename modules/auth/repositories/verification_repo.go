@@ -7,15 +7,40 @@ import (
 	"github.com/go-redis/redis/v8"
 	"github.com/winartodev/apollo/core/helpers"
 	authEntity "github.com/winartodev/apollo/modules/auth/entities"
+	"strconv"
 	"time"
 )
 
 const (
 	emailOTPPrefix = "otp_email"
 	phoneOTPPrefix = "otp_phone"
-	resendAttempt  = "resend_attempt"
+	// sign in codes live in their own namespace so they can not complete a sign up, nor the other way round
+	phoneSignInOTPPrefix = "otp_phone_sign_in"
+	resendAttempt        = "resend_attempt"
+
+	passwordResetPrefix     = "password_reset"
+	passwordResetUserPrefix = "password_reset_user"
+
+	magicLinkPrefix  = "magic_link"
+	loginAlertPrefix = "login_alert"
 )
 
+// consumeOTPScript removes an otp when the code of ARGV[1] matches it, so concurrent requests can not redeem the same
+// code twice. It returns whether the code matched together with the stored otp, nothing when there is none.
+var consumeOTPScript = redis.NewScript(`
+local data = redis.call('GET', KEYS[1])
+if not data then
+	return false
+end
+
+if cjson.decode(data)['otp'] == ARGV[1] then
+	redis.call('DEL', KEYS[1])
+	return {1, data}
+end
+
+return {0, data}
+`)
+
 type VerificationRepositoryItf interface {
 	SetEmailOTPRedis(ctx context.Context, email string, data authEntity.OTPData, ttl *time.Duration) (err error)
 	SetPhoneOTPRedis(ctx context.Context, phoneNumber string, data authEntity.OTPData, ttl *time.Duration) (err error)
@@ -24,6 +49,20 @@ type VerificationRepositoryItf interface {
 	SetResendAttemptRedis(ctx context.Context, value string, ttl *time.Duration) (count int64, err error)
 	DeletePhoneOTPRedis(ctx context.Context, phoneNumber string) (err error)
 	DeleteEmailOTPRedis(ctx context.Context, email string) (err error)
+	SetPhoneSignInOTPRedis(ctx context.Context, phoneNumber string, data authEntity.OTPData, ttl *time.Duration) (err error)
+	GetPhoneSignInOTPRedis(ctx context.Context, phoneNumber string) (res *authEntity.OTPData, err error)
+	DeletePhoneSignInOTPRedis(ctx context.Context, phoneNumber string) (err error)
+	ConsumePhoneSignInOTPRedis(ctx context.Context, phoneNumber string, code string) (res *authEntity.OTPData, matched bool, err error)
+	SetPasswordResetRedis(ctx context.Context, tokenHash string, data authEntity.PasswordResetData, ttl *time.Duration) (err error)
+	GetPasswordResetRedis(ctx context.Context, tokenHash string) (res *authEntity.PasswordResetData, err error)
+	GetPasswordResetByUserIDRedis(ctx context.Context, userID int64) (tokenHash *string, err error)
+	DeletePasswordResetRedis(ctx context.Context, tokenHash string, userID int64) (deleted bool, err error)
+	SetMagicLinkRedis(ctx context.Context, tokenHash string, data authEntity.MagicLinkData, ttl *time.Duration) (err error)
+	GetMagicLinkRedis(ctx context.Context, tokenHash string) (res *authEntity.MagicLinkData, err error)
+	DeleteMagicLinkRedis(ctx context.Context, tokenHash string) (deleted bool, err error)
+	SetLoginAlertRedis(ctx context.Context, tokenHash string, data authEntity.LoginAlertData, ttl *time.Duration) (err error)
+	GetLoginAlertRedis(ctx context.Context, tokenHash string) (res *authEntity.LoginAlertData, err error)
+	DeleteLoginAlertRedis(ctx context.Context, tokenHash string) (deleted bool, err error)
 }
 
 type VerificationRepository struct {
@@ -108,6 +147,195 @@ func (vr *VerificationRepository) DeleteEmailOTPRedis(ctx context.Context, email
 	return vr.deleteRedisKey(ctx, key)
 }
 
+func (vr *VerificationRepository) SetPhoneSignInOTPRedis(ctx context.Context, phoneNumber string, data authEntity.OTPData, ttl *time.Duration) (err error) {
+	phoneNumberStr := helpers.NormalizePhoneNumber(phoneNumber)
+	key := vr.GenerateRedisKey(phoneSignInOTPPrefix, phoneNumberStr)
+	return vr.setOTPRedis(ctx, key, data, ttl)
+}
+
+func (vr *VerificationRepository) GetPhoneSignInOTPRedis(ctx context.Context, phoneNumber string) (res *authEntity.OTPData, err error) {
+	phoneNumberStr := helpers.NormalizePhoneNumber(phoneNumber)
+	key := vr.GenerateRedisKey(phoneSignInOTPPrefix, phoneNumberStr)
+
+	var data authEntity.OTPData
+	err = vr.getRedisKey(ctx, key, &data)
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	if err == redis.Nil {
+		return nil, nil
+	}
+
+	return &data, nil
+}
+
+func (vr *VerificationRepository) DeletePhoneSignInOTPRedis(ctx context.Context, phoneNumber string) (err error) {
+	phoneNumberStr := helpers.NormalizePhoneNumber(phoneNumber)
+	key := vr.GenerateRedisKey(phoneSignInOTPPrefix, phoneNumberStr)
+	return vr.deleteRedisKey(ctx, key)
+}
+
+// ConsumePhoneSignInOTPRedis removes the sign in code of the phone number when code matches it, in one step.
+func (vr *VerificationRepository) ConsumePhoneSignInOTPRedis(ctx context.Context, phoneNumber string, code string) (res *authEntity.OTPData, matched bool, err error) {
+	phoneNumberStr := helpers.NormalizePhoneNumber(phoneNumber)
+	key := vr.GenerateRedisKey(phoneSignInOTPPrefix, phoneNumberStr)
+
+	return vr.consumeOTPRedis(ctx, key, code)
+}
+
+func (vr *VerificationRepository) SetPasswordResetRedis(ctx context.Context, tokenHash string, data authEntity.PasswordResetData, ttl *time.Duration) (err error) {
+	dataByte, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	key := vr.GenerateRedisKey(passwordResetPrefix, tokenHash)
+	userKey := vr.GenerateRedisKey(passwordResetUserPrefix, strconv.FormatInt(data.UserID, 10))
+
+	pipe := vr.Redis.TxPipeline()
+	pipe.SetEX(ctx, key, dataByte, *ttl)
+	pipe.SetEX(ctx, userKey, tokenHash, *ttl)
+	_, err = pipe.Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (vr *VerificationRepository) GetPasswordResetRedis(ctx context.Context, tokenHash string) (res *authEntity.PasswordResetData, err error) {
+	key := vr.GenerateRedisKey(passwordResetPrefix, tokenHash)
+
+	var data authEntity.PasswordResetData
+	err = vr.getRedisKey(ctx, key, &data)
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	if err == redis.Nil {
+		return nil, nil
+	}
+
+	return &data, nil
+}
+
+func (vr *VerificationRepository) GetPasswordResetByUserIDRedis(ctx context.Context, userID int64) (tokenHash *string, err error) {
+	key := vr.GenerateRedisKey(passwordResetUserPrefix, strconv.FormatInt(userID, 10))
+
+	res, err := vr.Redis.Get(ctx, key).Result()
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	if err == redis.Nil {
+		return nil, nil
+	}
+
+	return &res, nil
+}
+
+// DeletePasswordResetRedis removes the reset token and reports whether this call was the one that removed it,
+// so a token can only be consumed once even when two requests race.
+func (vr *VerificationRepository) DeletePasswordResetRedis(ctx context.Context, tokenHash string, userID int64) (deleted bool, err error) {
+	key := vr.GenerateRedisKey(passwordResetPrefix, tokenHash)
+	count, err := vr.Redis.Del(ctx, key).Result()
+	if err != nil {
+		return false, err
+	}
+
+	userKey := vr.GenerateRedisKey(passwordResetUserPrefix, strconv.FormatInt(userID, 10))
+	current, err := vr.Redis.Get(ctx, userKey).Result()
+	if err != nil && err != redis.Nil {
+		return false, err
+	}
+
+	if current == tokenHash {
+		err = vr.deleteRedisKey(ctx, userKey)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return count > 0, nil
+}
+
+func (vr *VerificationRepository) SetMagicLinkRedis(ctx context.Context, tokenHash string, data authEntity.MagicLinkData, ttl *time.Duration) (err error) {
+	dataByte, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	key := vr.GenerateRedisKey(magicLinkPrefix, tokenHash)
+
+	return vr.Redis.SetEX(ctx, key, dataByte, *ttl).Err()
+}
+
+func (vr *VerificationRepository) GetMagicLinkRedis(ctx context.Context, tokenHash string) (res *authEntity.MagicLinkData, err error) {
+	key := vr.GenerateRedisKey(magicLinkPrefix, tokenHash)
+
+	var data authEntity.MagicLinkData
+	err = vr.getRedisKey(ctx, key, &data)
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	if err == redis.Nil {
+		return nil, nil
+	}
+
+	return &data, nil
+}
+
+// DeleteMagicLinkRedis removes the magic link and reports whether this call was the one that removed it.
+func (vr *VerificationRepository) DeleteMagicLinkRedis(ctx context.Context, tokenHash string) (deleted bool, err error) {
+	key := vr.GenerateRedisKey(magicLinkPrefix, tokenHash)
+	count, err := vr.Redis.Del(ctx, key).Result()
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+func (vr *VerificationRepository) SetLoginAlertRedis(ctx context.Context, tokenHash string, data authEntity.LoginAlertData, ttl *time.Duration) (err error) {
+	dataByte, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	key := vr.GenerateRedisKey(loginAlertPrefix, tokenHash)
+
+	return vr.Redis.SetEX(ctx, key, dataByte, *ttl).Err()
+}
+
+func (vr *VerificationRepository) GetLoginAlertRedis(ctx context.Context, tokenHash string) (res *authEntity.LoginAlertData, err error) {
+	key := vr.GenerateRedisKey(loginAlertPrefix, tokenHash)
+
+	var data authEntity.LoginAlertData
+	err = vr.getRedisKey(ctx, key, &data)
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	if err == redis.Nil {
+		return nil, nil
+	}
+
+	return &data, nil
+}
+
+// DeleteLoginAlertRedis removes the login alert token and reports whether this call was the one that removed it.
+func (vr *VerificationRepository) DeleteLoginAlertRedis(ctx context.Context, tokenHash string) (deleted bool, err error) {
+	key := vr.GenerateRedisKey(loginAlertPrefix, tokenHash)
+	count, err := vr.Redis.Del(ctx, key).Result()
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
 func (vr *VerificationRepository) GenerateRedisKey(prefix string, value string) (key string) {
 	return fmt.Sprintf("%s:%s", prefix, value)
 }
@@ -121,13 +349,13 @@ func (vr *VerificationRepository) deleteRedisKey(ctx context.Context, key string
 	return nil
 }
 
-func (vr *VerificationRepository) getRedisKey(ctx context.Context, key string, data *authEntity.OTPData) (err error) {
+func (vr *VerificationRepository) getRedisKey(ctx context.Context, key string, data interface{}) (err error) {
 	dataStr, err := vr.Redis.Get(ctx, key).Result()
 	if err != nil {
 		return err
 	}
 
-	err = json.Unmarshal([]byte(dataStr), &data)
+	err = json.Unmarshal([]byte(dataStr), data)
 	if err != nil {
 		return err
 	}
@@ -135,6 +363,32 @@ func (vr *VerificationRepository) getRedisKey(ctx context.Context, key string, d
 	return nil
 }
 
+func (vr *VerificationRepository) consumeOTPRedis(ctx context.Context, key string, code string) (res *authEntity.OTPData, matched bool, err error) {
+	values, err := consumeOTPScript.Run(ctx, vr.Redis, []string{key}, code).Slice()
+	if err == redis.Nil {
+		return nil, false, nil
+	}
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	if len(values) != 2 {
+		return nil, false, fmt.Errorf("unexpected consume otp result %v", values)
+	}
+
+	flag, _ := values[0].(int64)
+	dataStr, _ := values[1].(string)
+
+	var data authEntity.OTPData
+	err = json.Unmarshal([]byte(dataStr), &data)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return &data, flag == 1, nil
+}
+
 func (vr *VerificationRepository) setOTPRedis(ctx context.Context, key string, data authEntity.OTPData, ttl *time.Duration) (err error) {
 	dataByte, err := json.Marshal(data)
 	if err != nil {
@@ -0,0 +1,108 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"github.com/winartodev/apollo/core/configs"
+	"github.com/winartodev/apollo/core/helpers"
+	forwardAuthEntity "github.com/winartodev/apollo/modules/forwardauth/entities"
+	forwardAuthRepo "github.com/winartodev/apollo/modules/forwardauth/repositories"
+	userController "github.com/winartodev/apollo/modules/user/controllers"
+	"slices"
+	"time"
+)
+
+var (
+	ErrorMissingRole = errors.New("user does not have the role required for this resource")
+)
+
+type ForwardAuthControllerItf interface {
+	GetCookieName() string
+	GetRule(host string, path string) (rule configs.ForwardAuthRule)
+	GetCachedIdentity(ctx context.Context, token string) (res *forwardAuthEntity.Identity, err error)
+	ResolveIdentity(ctx context.Context, token string, claims *helpers.JWTClaims) (res *forwardAuthEntity.Identity, err error)
+	Authorize(rule configs.ForwardAuthRule, identity *forwardAuthEntity.Identity) (err error)
+}
+
+type ForwardAuthController struct {
+	ForwardAuth           *configs.ForwardAuth
+	ForwardAuthRepository forwardAuthRepo.ForwardAuthRepositoryItf
+	UserController        userController.UserControllerItf
+}
+
+func NewForwardAuthController(controller ForwardAuthController) ForwardAuthControllerItf {
+	return &ForwardAuthController{
+		ForwardAuth:           controller.ForwardAuth,
+		ForwardAuthRepository: controller.ForwardAuthRepository,
+		UserController:        controller.UserController,
+	}
+}
+
+func (fc *ForwardAuthController) GetCookieName() string {
+	return fc.ForwardAuth.GetCookieName()
+}
+
+// GetRule returns the rule of the proxied request, without a matching rule a signed in user is enough.
+func (fc *ForwardAuthController) GetRule(host string, path string) (rule configs.ForwardAuthRule) {
+	rule, _ = fc.ForwardAuth.Match(host, path)
+	return rule
+}
+
+// GetCachedIdentity returns the identity a token was resolved to moments ago, or nil when it is not cached.
+func (fc *ForwardAuthController) GetCachedIdentity(ctx context.Context, token string) (res *forwardAuthEntity.Identity, err error) {
+	if fc.getCacheTTL() <= 0 {
+		return nil, nil
+	}
+
+	return fc.ForwardAuthRepository.GetIdentityRedis(ctx, helpers.HashToken(token))
+}
+
+// ResolveIdentity loads the user of a verified token and caches the result. The cache never outlives the token, a
+// sign out or suspension is seen once the cached entry expires.
+func (fc *ForwardAuthController) ResolveIdentity(ctx context.Context, token string, claims *helpers.JWTClaims) (res *forwardAuthEntity.Identity, err error) {
+	user, err := fc.UserController.GetUserByID(ctx, claims.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.IsSuspended {
+		return nil, userController.ErrorUserSuspended
+	}
+
+	res = &forwardAuthEntity.Identity{
+		UserID:   user.ID,
+		Email:    user.Email,
+		Username: user.Username,
+		Roles:    claims.Roles,
+	}
+
+	ttl := fc.getCacheTTL()
+	if remaining := time.Until(time.Unix(claims.ExpiresAt, 0)); remaining < ttl {
+		ttl = remaining
+	}
+
+	if ttl > 0 {
+		err = fc.ForwardAuthRepository.SetIdentityRedis(ctx, helpers.HashToken(token), *res, ttl)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return res, nil
+}
+
+func (fc *ForwardAuthController) Authorize(rule configs.ForwardAuthRule, identity *forwardAuthEntity.Identity) (err error) {
+	if rule.Role != "" && !slices.Contains(identity.Roles, rule.Role) {
+		return ErrorMissingRole
+	}
+
+	return nil
+}
+
+func (fc *ForwardAuthController) getCacheTTL() time.Duration {
+	if fc.ForwardAuth == nil {
+		return 0
+	}
+
+	return time.Duration(fc.ForwardAuth.CacheTTL) * time.Second
+}
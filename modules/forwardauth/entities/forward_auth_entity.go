@@ -0,0 +1,10 @@
+package entities
+
+// Identity is the user a proxied request belongs to, it is handed to the app behind the proxy in X-User-* headers.
+// It is cached under the hash of the token for a few seconds.
+type Identity struct {
+	UserID   int64    `json:"user_id"`
+	Email    string   `json:"email"`
+	Username string   `json:"username"`
+	Roles    []string `json:"roles"`
+}
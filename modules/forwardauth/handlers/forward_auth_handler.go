@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/winartodev/apollo/core"
+	"github.com/winartodev/apollo/core/middlewares"
+	"github.com/winartodev/apollo/core/responses"
+	forwardAuthController "github.com/winartodev/apollo/modules/forwardauth/controllers"
+	userController "github.com/winartodev/apollo/modules/user/controllers"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+)
+
+const (
+	headerForwardedHost = "X-Forwarded-Host"
+	headerForwardedURI  = "X-Forwarded-Uri"
+	headerOriginalURI   = "X-Original-URI"
+
+	headerUserID    = "X-User-Id"
+	headerUserEmail = "X-User-Email"
+	headerUserName  = "X-User-Name"
+	headerUserRoles = "X-User-Roles"
+)
+
+var (
+	errorMissingToken = errors.New("authentication token is missing, send a bearer token or the session cookie")
+)
+
+type ForwardAuthHandler struct {
+	middlewares.Middleware
+	ForwardAuthController forwardAuthController.ForwardAuthControllerItf
+}
+
+func NewForwardAuthHandler(handler ForwardAuthHandler) ForwardAuthHandler {
+	return ForwardAuthHandler{
+		Middleware:            handler.Middleware,
+		ForwardAuthController: handler.ForwardAuthController,
+	}
+}
+
+// ForwardAuth answers the subrequest of a reverse proxy. The proxy passes the original host and uri in the
+// X-Forwarded-Host and X-Forwarded-Uri headers, or X-Original-URI with nginx, and lets the request through on 200.
+func (h *ForwardAuthHandler) ForwardAuth(ctx *fiber.Ctx) error {
+	context := ctx.Context()
+
+	host, requestPath := getForwardedRequest(ctx)
+	rule := h.ForwardAuthController.GetRule(host, requestPath)
+	if rule.Public {
+		return ctx.SendStatus(fiber.StatusOK)
+	}
+
+	token := h.getToken(ctx)
+	if token == "" {
+		return responses.FailedResponse(ctx, fiber.StatusUnauthorized, "Unauthorized", errorMissingToken)
+	}
+
+	identity, err := h.ForwardAuthController.GetCachedIdentity(context, token)
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusInternalServerError, "Failed Forward Auth", err)
+	}
+
+	if identity == nil {
+		claims, err := h.VerifyAccessToken(ctx, token)
+		if err != nil {
+			return responses.FailedResponse(ctx, fiber.StatusUnauthorized, "Unauthorized", err)
+		}
+
+		identity, err = h.ForwardAuthController.ResolveIdentity(context, token, claims)
+		if errors.Is(err, userController.ErrorUserNotFound) {
+			return responses.FailedResponse(ctx, fiber.StatusUnauthorized, "Unauthorized", err)
+		}
+
+		if errors.Is(err, userController.ErrorUserSuspended) {
+			return responses.FailedResponse(ctx, fiber.StatusForbidden, "Access Denied", err)
+		}
+
+		if err != nil {
+			return responses.FailedResponse(ctx, fiber.StatusInternalServerError, "Failed Forward Auth", err)
+		}
+	}
+
+	err = h.ForwardAuthController.Authorize(rule, identity)
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusForbidden, "Access Denied", err)
+	}
+
+	ctx.Set(headerUserID, strconv.FormatInt(identity.UserID, 10))
+	ctx.Set(headerUserEmail, identity.Email)
+	ctx.Set(headerUserName, identity.Username)
+	ctx.Set(headerUserRoles, strings.Join(identity.Roles, ","))
+
+	return ctx.SendStatus(fiber.StatusOK)
+}
+
+// getToken reads the bearer token, browsers that can not send one use the session cookie instead.
+func (h *ForwardAuthHandler) getToken(ctx *fiber.Ctx) string {
+	authHeader := ctx.Get(fiber.HeaderAuthorization)
+	if strings.HasPrefix(authHeader, "Bearer ") {
+		return authHeader[len("Bearer "):]
+	}
+
+	return ctx.Cookies(h.ForwardAuthController.GetCookieName())
+}
+
+// getForwardedRequest returns the host and the cleaned path of the original request, so "/public/../admin" is
+// matched as "/admin".
+func getForwardedRequest(ctx *fiber.Ctx) (host string, requestPath string) {
+	host = ctx.Get(headerForwardedHost)
+	if host == "" {
+		host = ctx.Hostname()
+	}
+
+	uri := ctx.Get(headerForwardedURI)
+	if uri == "" {
+		uri = ctx.Get(headerOriginalURI)
+	}
+
+	parsed, err := url.ParseRequestURI(uri)
+	if err != nil {
+		return host, "/"
+	}
+
+	return host, path.Clean("/" + parsed.Path)
+}
+
+func (h *ForwardAuthHandler) Register(router fiber.Router) error {
+	v1 := router.Group(core.V1)
+	v1.Get("/forward-auth", h.ForwardAuth)
+
+	return nil
+}
@@ -0,0 +1,61 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	forwardAuthEntity "github.com/winartodev/apollo/modules/forwardauth/entities"
+	"time"
+)
+
+const (
+	forwardAuthIdentityPrefix = "forward_auth"
+)
+
+type ForwardAuthRepositoryItf interface {
+	SetIdentityRedis(ctx context.Context, tokenHash string, data forwardAuthEntity.Identity, ttl time.Duration) (err error)
+	GetIdentityRedis(ctx context.Context, tokenHash string) (res *forwardAuthEntity.Identity, err error)
+}
+
+type ForwardAuthRepository struct {
+	Redis *redis.Client
+}
+
+func NewForwardAuthRepository(repository ForwardAuthRepository) ForwardAuthRepositoryItf {
+	return &ForwardAuthRepository{
+		Redis: repository.Redis,
+	}
+}
+
+func (fr *ForwardAuthRepository) SetIdentityRedis(ctx context.Context, tokenHash string, data forwardAuthEntity.Identity, ttl time.Duration) (err error) {
+	dataByte, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	return fr.Redis.SetEX(ctx, fr.generateRedisKey(tokenHash), dataByte, ttl).Err()
+}
+
+func (fr *ForwardAuthRepository) GetIdentityRedis(ctx context.Context, tokenHash string) (res *forwardAuthEntity.Identity, err error) {
+	dataStr, err := fr.Redis.Get(ctx, fr.generateRedisKey(tokenHash)).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	res = &forwardAuthEntity.Identity{}
+	err = json.Unmarshal([]byte(dataStr), res)
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (fr *ForwardAuthRepository) generateRedisKey(value string) string {
+	return fmt.Sprintf("%s:%s", forwardAuthIdentityPrefix, value)
+}
@@ -0,0 +1,319 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/winartodev/apollo/core/configs"
+	"github.com/winartodev/apollo/core/helpers"
+	authController "github.com/winartodev/apollo/modules/auth/controllers"
+	authEntity "github.com/winartodev/apollo/modules/auth/entities"
+	oauthEntity "github.com/winartodev/apollo/modules/oauth/entities"
+	"github.com/winartodev/apollo/modules/oauth/providers"
+	oauthRepo "github.com/winartodev/apollo/modules/oauth/repositories"
+	sessionController "github.com/winartodev/apollo/modules/session/controllers"
+	sessionEntity "github.com/winartodev/apollo/modules/session/entities"
+	userController "github.com/winartodev/apollo/modules/user/controllers"
+	userEntity "github.com/winartodev/apollo/modules/user/entities"
+	"strings"
+	"time"
+)
+
+const (
+	oauthStateExpiration = 10 * time.Minute
+	oauthStateLength     = 32
+
+	generatedPasswordLength = 32
+	usernameSuffixLength    = 4
+	maxUsernamePrefixLength = 40
+	maxNameLength           = 50
+)
+
+var (
+	ErrorUnknownProvider         = errors.New("unknown identity provider")
+	ErrorInvalidOAuthState       = errors.New("sign in request is invalid or expired, please try again")
+	ErrorOAuthExchangeFailed     = errors.New("failed to sign in with the identity provider")
+	ErrorOAuthEmailMissing       = errors.New("identity provider did not share an email address")
+	ErrorOAuthEmailNotVerified   = errors.New("email address is not verified by the identity provider")
+	ErrorOAuthAccountNotVerified = errors.New("an account with this email address exists but its email address is not verified, verify it before signing in with the identity provider")
+	errorOAuthRedirectURLNotSet  = errors.New("oauth redirect url is not configured")
+)
+
+type OAuthControllerItf interface {
+	Authorize(ctx context.Context, providerName string) (res *oauthEntity.Authorization, err error)
+	Callback(ctx context.Context, providerName string, state string, browserState string, code string, client sessionEntity.ClientInfo) (res *authEntity.AuthResponse, challenge *authEntity.MFAChallenge, err error)
+	DeleteIdentitiesCreatedSince(ctx context.Context, userID int64, since time.Time) (deleted int64, err error)
+}
+
+type OAuthController struct {
+	OAuth             *configs.OAuth
+	Providers         map[string]providers.ProviderItf
+	OAuthRepository   oauthRepo.OAuthRepositoryItf
+	AuthController    authController.AuthControllerItf
+	SessionController sessionController.SessionControllerItf
+	UserController    userController.UserControllerItf
+}
+
+func NewOAuthController(controller OAuthController) OAuthControllerItf {
+	return &OAuthController{
+		OAuth:             controller.OAuth,
+		Providers:         controller.Providers,
+		OAuthRepository:   controller.OAuthRepository,
+		AuthController:    controller.AuthController,
+		SessionController: controller.SessionController,
+		UserController:    controller.UserController,
+	}
+}
+
+// Authorize starts the authorization code flow. The state, nonce and PKCE verifier are kept in Redis, the state is
+// also returned so the caller can bind it to the browser.
+func (oc *OAuthController) Authorize(ctx context.Context, providerName string) (res *oauthEntity.Authorization, err error) {
+	provider, ok := oc.Providers[providerName]
+	if !ok {
+		return nil, ErrorUnknownProvider
+	}
+
+	redirectURL, err := oc.buildRedirectURL(providerName)
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := helpers.GenerateRandomToken(oauthStateLength)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce, err := helpers.GenerateRandomToken(oauthStateLength)
+	if err != nil {
+		return nil, err
+	}
+
+	codeVerifier, err := helpers.GenerateCodeVerifier()
+	if err != nil {
+		return nil, err
+	}
+
+	authCodeURL, err := provider.AuthCodeURL(ctx, redirectURL, state, nonce, codeVerifier)
+	if err != nil {
+		return nil, err
+	}
+
+	err = oc.OAuthRepository.SetStateRedis(ctx, helpers.HashToken(state), oauthEntity.OAuthState{
+		Provider:     providerName,
+		Nonce:        nonce,
+		CodeVerifier: codeVerifier,
+		Expire:       time.Now().Add(oauthStateExpiration).Unix(),
+	}, oauthStateExpiration)
+	if err != nil {
+		return nil, err
+	}
+
+	return &oauthEntity.Authorization{
+		URL:       authCodeURL,
+		State:     state,
+		ExpiresIn: int64(oauthStateExpiration.Seconds()),
+	}, nil
+}
+
+// Callback completes the flow started by Authorize. The state must match the one bound to the browser and can
+// only be used once, the signed-in user is resolved from the identity and goes through the regular sign in.
+func (oc *OAuthController) Callback(ctx context.Context, providerName string, state string, browserState string, code string, client sessionEntity.ClientInfo) (res *authEntity.AuthResponse, challenge *authEntity.MFAChallenge, err error) {
+	provider, ok := oc.Providers[providerName]
+	if !ok {
+		return nil, nil, ErrorUnknownProvider
+	}
+
+	if state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(browserState)) != 1 {
+		return nil, nil, ErrorInvalidOAuthState
+	}
+
+	data, err := oc.consumeState(ctx, state)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if data.Provider != providerName {
+		return nil, nil, ErrorInvalidOAuthState
+	}
+
+	redirectURL, err := oc.buildRedirectURL(providerName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	external, err := provider.Exchange(ctx, redirectURL, code, data.CodeVerifier, data.Nonce)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrorOAuthExchangeFailed, err)
+	}
+
+	user, err := oc.resolveUser(ctx, external, client)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return oc.AuthController.SignInUser(ctx, user, client)
+}
+
+func (oc *OAuthController) consumeState(ctx context.Context, state string) (data *oauthEntity.OAuthState, err error) {
+	stateHash := helpers.HashToken(state)
+
+	data, err = oc.OAuthRepository.GetStateRedis(ctx, stateHash)
+	if err != nil {
+		return nil, err
+	}
+
+	if data == nil {
+		return nil, ErrorInvalidOAuthState
+	}
+
+	deleted, err := oc.OAuthRepository.DeleteStateRedis(ctx, stateHash)
+	if err != nil {
+		return nil, err
+	}
+
+	if !deleted || time.Now().After(time.Unix(data.Expire, 0)) {
+		return nil, ErrorInvalidOAuthState
+	}
+
+	return data, nil
+}
+
+// resolveUser returns the user linked to the identity. An unknown identity is linked to the user with the same
+// email, or to a new account, but only when the provider verified the email, otherwise anyone could register the
+// email of someone else at a provider and take over their account. An existing account must have verified the
+// email as well, otherwise whoever registered it without owning the email would keep access to it.
+func (oc *OAuthController) resolveUser(ctx context.Context, external *oauthEntity.ExternalIdentity, client sessionEntity.ClientInfo) (res *userEntity.User, err error) {
+	identity, err := oc.OAuthRepository.GetIdentityDB(ctx, external.Provider, external.Subject)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	if identity != nil {
+		if identity.Email != external.Email || identity.EmailVerified != external.EmailVerified {
+			err = oc.OAuthRepository.UpdateIdentityEmailDB(ctx, identity.ID, external.Email, external.EmailVerified)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return oc.UserController.GetUserByID(ctx, identity.UserID)
+	}
+
+	if external.Email == "" {
+		return nil, ErrorOAuthEmailMissing
+	}
+
+	if !external.EmailVerified {
+		return nil, ErrorOAuthEmailNotVerified
+	}
+
+	// providers report emails in lower case, the one the user registered with may not be
+	external.Email = strings.ToLower(strings.TrimSpace(external.Email))
+	res, err = oc.UserController.GetUserByEmail(ctx, external.Email)
+	if errors.Is(err, userController.ErrorUserNotFound) {
+		res, err = oc.createUser(ctx, external)
+	} else if err == nil && !res.IsEmailVerified {
+		return nil, ErrorOAuthAccountNotVerified
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = oc.linkIdentity(ctx, res, external, client)
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (oc *OAuthController) linkIdentity(ctx context.Context, user *userEntity.User, external *oauthEntity.ExternalIdentity, client sessionEntity.ClientInfo) (err error) {
+	now := time.Now()
+	_, err = oc.OAuthRepository.CreateIdentityDB(ctx, &oauthEntity.Identity{
+		UserID:        user.ID,
+		Provider:      external.Provider,
+		Subject:       external.Subject,
+		Email:         external.Email,
+		EmailVerified: external.EmailVerified,
+		CreatedAt:     &now,
+		UpdatedAt:     &now,
+	})
+	if err != nil {
+		return err
+	}
+
+	return oc.SessionController.RecordSecurityEvent(ctx, user.ID, "", sessionEntity.SecurityEventIdentityLinked, client)
+}
+
+// DeleteIdentitiesCreatedSince unlinks the identities the user linked at or after since.
+func (oc *OAuthController) DeleteIdentitiesCreatedSince(ctx context.Context, userID int64, since time.Time) (deleted int64, err error) {
+	return oc.OAuthRepository.DeleteIdentitiesCreatedSinceDB(ctx, userID, since)
+}
+
+// createUser registers an account for the identity. It gets a random password nobody knows, the user can still
+// choose one through the forgot password flow.
+func (oc *OAuthController) createUser(ctx context.Context, external *oauthEntity.ExternalIdentity) (res *userEntity.User, err error) {
+	password, err := helpers.GenerateRandomToken(generatedPasswordLength)
+	if err != nil {
+		return nil, err
+	}
+
+	username, err := buildUsername(external.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	return oc.UserController.CreateUser(ctx, userEntity.User{
+		Email:           external.Email,
+		Username:        username,
+		FirstName:       truncate(external.GivenName, maxNameLength),
+		LastName:        truncate(external.FamilyName, maxNameLength),
+		Password:        &password,
+		IsEmailVerified: true,
+	})
+}
+
+func (oc *OAuthController) buildRedirectURL(providerName string) (string, error) {
+	if oc.OAuth == nil || oc.OAuth.RedirectURL == "" {
+		return "", errorOAuthRedirectURLNotSet
+	}
+
+	return fmt.Sprintf("%s/%s/callback", strings.TrimSuffix(oc.OAuth.RedirectURL, "/"), providerName), nil
+}
+
+// buildUsername derives a username from the local part of the email, a random suffix keeps it unique.
+func buildUsername(email string) (string, error) {
+	localPart, _, _ := strings.Cut(email, "@")
+	prefix := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '.' || r == '_' || r == '-' {
+			return r
+		}
+		return -1
+	}, strings.ToLower(localPart))
+
+	if prefix == "" {
+		prefix = "user"
+	}
+
+	suffix := make([]byte, usernameSuffixLength)
+	_, err := rand.Read(suffix)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s-%s", truncate(prefix, maxUsernamePrefixLength), hex.EncodeToString(suffix)), nil
+}
+
+func truncate(value string, length int) string {
+	runes := []rune(value)
+	if len(runes) > length {
+		return string(runes[:length])
+	}
+
+	return value
+}
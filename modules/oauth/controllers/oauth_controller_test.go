@@ -0,0 +1,194 @@
+package controllers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/winartodev/apollo/core/configs"
+	authController "github.com/winartodev/apollo/modules/auth/controllers"
+	authEntity "github.com/winartodev/apollo/modules/auth/entities"
+	oauthEntity "github.com/winartodev/apollo/modules/oauth/entities"
+	"github.com/winartodev/apollo/modules/oauth/providers"
+	oauthRepo "github.com/winartodev/apollo/modules/oauth/repositories"
+	sessionController "github.com/winartodev/apollo/modules/session/controllers"
+	sessionEntity "github.com/winartodev/apollo/modules/session/entities"
+	userController "github.com/winartodev/apollo/modules/user/controllers"
+	userEntity "github.com/winartodev/apollo/modules/user/entities"
+	"testing"
+	"time"
+)
+
+type fakeProvider struct {
+	providers.ProviderItf
+	external *oauthEntity.ExternalIdentity
+}
+
+func (f *fakeProvider) Exchange(ctx context.Context, redirectURL string, code string, codeVerifier string, nonce string) (res *oauthEntity.ExternalIdentity, err error) {
+	return f.external, nil
+}
+
+type fakeOAuthRepository struct {
+	oauthRepo.OAuthRepositoryItf
+	identity   *oauthEntity.Identity
+	identities []*oauthEntity.Identity
+}
+
+func (f *fakeOAuthRepository) GetStateRedis(ctx context.Context, stateHash string) (res *oauthEntity.OAuthState, err error) {
+	return &oauthEntity.OAuthState{Provider: "google", Expire: time.Now().Add(time.Minute).Unix()}, nil
+}
+
+func (f *fakeOAuthRepository) DeleteStateRedis(ctx context.Context, stateHash string) (deleted bool, err error) {
+	return true, nil
+}
+
+func (f *fakeOAuthRepository) GetIdentityDB(ctx context.Context, provider string, subject string) (res *oauthEntity.Identity, err error) {
+	if f.identity == nil {
+		return nil, sql.ErrNoRows
+	}
+
+	return f.identity, nil
+}
+
+func (f *fakeOAuthRepository) CreateIdentityDB(ctx context.Context, data *oauthEntity.Identity) (id int64, err error) {
+	f.identities = append(f.identities, data)
+	return int64(len(f.identities)), nil
+}
+
+type fakeUserController struct {
+	userController.UserControllerItf
+	users   map[string]*userEntity.User
+	created []userEntity.User
+}
+
+func (f *fakeUserController) GetUserByEmail(ctx context.Context, email string) (res *userEntity.User, err error) {
+	user, ok := f.users[email]
+	if !ok {
+		return nil, userController.ErrorUserNotFound
+	}
+
+	return user, nil
+}
+
+func (f *fakeUserController) GetUserByID(ctx context.Context, id int64) (res *userEntity.User, err error) {
+	for _, user := range f.users {
+		if user.ID == id {
+			return user, nil
+		}
+	}
+
+	return nil, userController.ErrorUserNotFound
+}
+
+func (f *fakeUserController) CreateUser(ctx context.Context, data userEntity.User) (res *userEntity.User, err error) {
+	data.ID = 100
+	f.created = append(f.created, data)
+	return &data, nil
+}
+
+type fakeSessionController struct {
+	sessionController.SessionControllerItf
+}
+
+func (f *fakeSessionController) RecordSecurityEvent(ctx context.Context, userID int64, sessionID string, eventType string, client sessionEntity.ClientInfo) (err error) {
+	return nil
+}
+
+type fakeAuthController struct {
+	authController.AuthControllerItf
+	user *userEntity.User
+}
+
+func (f *fakeAuthController) SignInUser(ctx context.Context, user *userEntity.User, client sessionEntity.ClientInfo) (res *authEntity.AuthResponse, challenge *authEntity.MFAChallenge, err error) {
+	f.user = user
+	return &authEntity.AuthResponse{AccessToken: "access"}, nil, nil
+}
+
+func TestOAuthController_Callback(t *testing.T) {
+	external := &oauthEntity.ExternalIdentity{
+		Provider:      "google",
+		Subject:       "subject",
+		Email:         "apollo@gmail.com",
+		EmailVerified: true,
+	}
+
+	tests := []struct {
+		name        string
+		users       map[string]*userEntity.User
+		identity    *oauthEntity.Identity
+		wantUserID  int64
+		wantCreated bool
+		wantLinked  bool
+		wantErr     error
+	}{
+		{
+			name:        "success_creates_user",
+			users:       map[string]*userEntity.User{},
+			wantUserID:  100,
+			wantCreated: true,
+			wantLinked:  true,
+		},
+		{
+			name: "success_links_verified_account",
+			users: map[string]*userEntity.User{
+				"apollo@gmail.com": {ID: 1, Email: "Apollo@Gmail.com", IsEmailVerified: true},
+			},
+			wantUserID: 1,
+			wantLinked: true,
+		},
+		{
+			name: "success_existing_identity",
+			users: map[string]*userEntity.User{
+				"apollo@gmail.com": {ID: 1, Email: "apollo@gmail.com"},
+			},
+			identity:   &oauthEntity.Identity{ID: 1, UserID: 1, Provider: "google", Subject: "subject", Email: "apollo@gmail.com", EmailVerified: true},
+			wantUserID: 1,
+		},
+		{
+			name: "failed_unverified_account_not_linked",
+			users: map[string]*userEntity.User{
+				"apollo@gmail.com": {ID: 1, Email: "apollo@gmail.com"},
+			},
+			wantErr: ErrorOAuthAccountNotVerified,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identity := *external
+			repository := &fakeOAuthRepository{identity: tt.identity}
+			users := &fakeUserController{users: tt.users}
+			auth := &fakeAuthController{}
+			controller := NewOAuthController(OAuthController{
+				OAuth:             &configs.OAuth{RedirectURL: "http://localhost/oauth"},
+				Providers:         map[string]providers.ProviderItf{"google": &fakeProvider{external: &identity}},
+				OAuthRepository:   repository,
+				AuthController:    auth,
+				SessionController: &fakeSessionController{},
+				UserController:    users,
+			})
+
+			_, _, err := controller.Callback(context.Background(), "google", "state", "state", "code", sessionEntity.ClientInfo{})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Callback() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if (len(users.created) == 1) != tt.wantCreated {
+				t.Errorf("Callback() created = %v, want %v", len(users.created) == 1, tt.wantCreated)
+			}
+
+			if (len(repository.identities) == 1) != tt.wantLinked {
+				t.Errorf("Callback() linked = %v, want %v", len(repository.identities) == 1, tt.wantLinked)
+			}
+
+			if tt.wantErr != nil {
+				if auth.user != nil {
+					t.Errorf("Callback() signed in user %d", auth.user.ID)
+				}
+				return
+			}
+
+			if auth.user == nil || auth.user.ID != tt.wantUserID {
+				t.Errorf("Callback() signed in %v, want user %d", auth.user, tt.wantUserID)
+			}
+		})
+	}
+}
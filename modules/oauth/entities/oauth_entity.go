@@ -0,0 +1,39 @@
+package entities
+
+import "time"
+
+// Identity links the subject of an external provider to a user, a user can have one identity per provider.
+type Identity struct {
+	ID            int64      `json:"id"`
+	UserID        int64      `json:"user_id"`
+	Provider      string     `json:"provider"`
+	Subject       string     `json:"subject"`
+	Email         string     `json:"email"`
+	EmailVerified bool       `json:"email_verified"`
+	CreatedAt     *time.Time `json:"created_at,omitempty"`
+	UpdatedAt     *time.Time `json:"updated_at,omitempty"`
+}
+
+// ExternalIdentity is the user as described by the provider after a successful code exchange.
+type ExternalIdentity struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	GivenName     string
+	FamilyName    string
+}
+
+// OAuthState is kept in Redis between the redirect to the provider and the callback.
+type OAuthState struct {
+	Provider     string `json:"provider"`
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"code_verifier"`
+	Expire       int64  `json:"expire"`
+}
+
+type Authorization struct {
+	URL       string
+	State     string
+	ExpiresIn int64
+}
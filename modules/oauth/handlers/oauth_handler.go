@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"github.com/winartodev/apollo/core"
+	"github.com/winartodev/apollo/core/middlewares"
+	"github.com/winartodev/apollo/core/responses"
+	oauthController "github.com/winartodev/apollo/modules/oauth/controllers"
+	sessionEntity "github.com/winartodev/apollo/modules/session/entities"
+	userController "github.com/winartodev/apollo/modules/user/controllers"
+	"time"
+)
+
+const (
+	oauthStateCookie     = "apollo_oauth_state"
+	oauthStateCookiePath = core.API + core.V1 + "/auth/oauth"
+)
+
+type OAuthHandler struct {
+	middlewares.Middleware
+	OAuthController oauthController.OAuthControllerItf
+}
+
+func NewOAuthHandler(handler OAuthHandler) OAuthHandler {
+	return OAuthHandler{
+		Middleware:      handler.Middleware,
+		OAuthController: handler.OAuthController,
+	}
+}
+
+// Authorize redirects the browser to the provider. The state is kept in a cookie as well, so a callback that
+// was not started by this browser is rejected.
+func (h *OAuthHandler) Authorize(ctx *fiber.Ctx) error {
+	context := ctx.Context()
+
+	res, err := h.OAuthController.Authorize(context, ctx.Params("provider"))
+	if errors.Is(err, oauthController.ErrorUnknownProvider) {
+		return responses.FailedResponse(ctx, fiber.StatusNotFound, "Failed to sign in with provider", err)
+	}
+
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusInternalServerError, "Failed to sign in with provider", err)
+	}
+
+	// providers such as Apple post the callback from their own site, so the cookie has to be sent cross site
+	ctx.Cookie(&fiber.Cookie{
+		Name:     oauthStateCookie,
+		Value:    res.State,
+		Path:     oauthStateCookiePath,
+		MaxAge:   int(res.ExpiresIn),
+		Secure:   true,
+		HTTPOnly: true,
+		SameSite: fiber.CookieSameSiteNoneMode,
+	})
+
+	return ctx.Redirect(res.URL, fiber.StatusFound)
+}
+
+func (h *OAuthHandler) Callback(ctx *fiber.Ctx) error {
+	context := ctx.Context()
+
+	if providerErr := getCallbackValue(ctx, "error"); providerErr != "" {
+		err := fmt.Errorf("%w: %s", oauthController.ErrorOAuthExchangeFailed, providerErr)
+		return responses.FailedResponse(ctx, fiber.StatusUnauthorized, "Failed to sign in with provider", err)
+	}
+
+	res, challenge, err := h.OAuthController.Callback(context,
+		ctx.Params("provider"),
+		getCallbackValue(ctx, "state"),
+		ctx.Cookies(oauthStateCookie),
+		getCallbackValue(ctx, "code"),
+		sessionEntity.NewClientInfo(ctx),
+	)
+
+	ctx.Cookie(&fiber.Cookie{
+		Name:     oauthStateCookie,
+		Path:     oauthStateCookiePath,
+		Expires:  time.Unix(0, 0),
+		Secure:   true,
+		HTTPOnly: true,
+		SameSite: fiber.CookieSameSiteNoneMode,
+	})
+
+	if errors.Is(err, oauthController.ErrorUnknownProvider) {
+		return responses.FailedResponse(ctx, fiber.StatusNotFound, "Failed to sign in with provider", err)
+	}
+
+	if errors.Is(err, oauthController.ErrorInvalidOAuthState) || errors.Is(err, oauthController.ErrorOAuthEmailMissing) {
+		return responses.FailedResponse(ctx, fiber.StatusBadRequest, "Failed to sign in with provider", err)
+	}
+
+	if errors.Is(err, oauthController.ErrorOAuthExchangeFailed) {
+		return responses.FailedResponse(ctx, fiber.StatusUnauthorized, "Failed to sign in with provider", err)
+	}
+
+	if errors.Is(err, oauthController.ErrorOAuthEmailNotVerified) || errors.Is(err, oauthController.ErrorOAuthAccountNotVerified) ||
+		errors.Is(err, userController.ErrorUserSuspended) {
+		return responses.FailedResponse(ctx, fiber.StatusForbidden, "Failed to sign in with provider", err)
+	}
+
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusInternalServerError, "Failed to sign in with provider", err)
+	}
+
+	if challenge != nil {
+		return responses.SuccessResponse(ctx, fiber.StatusOK, "Two-factor authentication required", challenge, nil)
+	}
+
+	return responses.SuccessResponse(ctx, fiber.StatusOK, "Success", res, nil)
+}
+
+// getCallbackValue reads a callback parameter from the query, or from the form body when the provider posts it.
+func getCallbackValue(ctx *fiber.Ctx, key string) string {
+	value := ctx.Query(key)
+	if value == "" {
+		value = ctx.FormValue(key)
+	}
+
+	return value
+}
+
+func (h *OAuthHandler) Register(router fiber.Router) error {
+	v1 := router.Group(core.V1)
+
+	oauth := v1.Group("/auth/oauth")
+	oauth.Get("/:provider", h.Authorize)
+	oauth.Get("/:provider/callback", h.Callback)
+	oauth.Post("/:provider/callback", h.Callback)
+
+	return nil
+}
@@ -0,0 +1,115 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"github.com/winartodev/apollo/core/configs"
+	oauthEntity "github.com/winartodev/apollo/modules/oauth/entities"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	gitHubAuthURL  = "https://github.com/login/oauth/authorize"
+	gitHubTokenURL = "https://github.com/login/oauth/access_token"
+	gitHubAPIURL   = "https://api.github.com"
+)
+
+var (
+	defaultGitHubScopes = []string{"read:user", "user:email"}
+
+	errorMissingGitHubUser = errors.New("github user has no id")
+)
+
+type gitHubUser struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+type gitHubEmail struct {
+	Email    string `json:"email"`
+	Primary  bool   `json:"primary"`
+	Verified bool   `json:"verified"`
+}
+
+// GitHubProvider signs users in with GitHub, which only speaks OAuth2, so the user is read from the REST API.
+type GitHubProvider struct {
+	name     string
+	provider configs.OAuthProvider
+	client   *http.Client
+}
+
+func NewGitHubProvider(name string, provider configs.OAuthProvider, client *http.Client) ProviderItf {
+	if len(provider.Scopes) == 0 {
+		provider.Scopes = defaultGitHubScopes
+	}
+
+	if provider.AuthURL == "" {
+		provider.AuthURL = gitHubAuthURL
+	}
+
+	if provider.TokenURL == "" {
+		provider.TokenURL = gitHubTokenURL
+	}
+
+	if provider.APIURL == "" {
+		provider.APIURL = gitHubAPIURL
+	}
+
+	return &GitHubProvider{
+		name:     name,
+		provider: provider,
+		client:   client,
+	}
+}
+
+func (p *GitHubProvider) Name() string {
+	return p.name
+}
+
+func (p *GitHubProvider) AuthCodeURL(ctx context.Context, redirectURL string, state string, nonce string, codeVerifier string) (string, error) {
+	return buildAuthCodeURL(p.provider.AuthURL, p.provider.ClientID, redirectURL, p.provider.Scopes, state, codeVerifier, nil)
+}
+
+// Exchange redeems the code and uses the primary email of the account, GitHub reports whether it is verified.
+func (p *GitHubProvider) Exchange(ctx context.Context, redirectURL string, code string, codeVerifier string, nonce string) (res *oauthEntity.ExternalIdentity, err error) {
+	token, err := exchangeCode(ctx, p.client, p.provider.TokenURL, p.provider, redirectURL, code, codeVerifier)
+	if err != nil {
+		return nil, err
+	}
+
+	apiURL := strings.TrimSuffix(p.provider.APIURL, "/")
+
+	var user gitHubUser
+	err = getJSON(ctx, p.client, apiURL+"/user", token.AccessToken, &user)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.ID == 0 {
+		return nil, errorMissingGitHubUser
+	}
+
+	var emails []gitHubEmail
+	err = getJSON(ctx, p.client, apiURL+"/user/emails", token.AccessToken, &emails)
+	if err != nil {
+		return nil, err
+	}
+
+	res = &oauthEntity.ExternalIdentity{
+		Provider: p.name,
+		Subject:  strconv.FormatInt(user.ID, 10),
+	}
+
+	for _, email := range emails {
+		if email.Primary {
+			res.Email = strings.ToLower(email.Email)
+			res.EmailVerified = email.Verified
+		}
+	}
+
+	res.GivenName, res.FamilyName, _ = strings.Cut(strings.TrimSpace(user.Name), " ")
+
+	return res, nil
+}
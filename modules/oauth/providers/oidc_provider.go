@@ -0,0 +1,345 @@
+package providers
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/winartodev/apollo/core/configs"
+	oauthEntity "github.com/winartodev/apollo/modules/oauth/entities"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	discoveryPath       = "/.well-known/openid-configuration"
+	jwksRefreshInterval = time.Minute
+)
+
+var (
+	defaultOIDCScopes = []string{"openid", "email", "profile"}
+
+	errorIssuerMismatch    = errors.New("discovery document belongs to another issuer")
+	errorMissingIDToken    = errors.New("token response contains no id_token")
+	errorInvalidIDToken    = errors.New("id_token is invalid")
+	errorNonceMismatch     = errors.New("id_token nonce does not match the authorization request")
+	errorSubjectMismatch   = errors.New("userinfo subject does not match the id_token")
+	errorUnknownSigningKey = errors.New("id_token is signed by an unknown key")
+)
+
+type discoveryDocument struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserInfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type userInfo struct {
+	Subject       string      `json:"sub"`
+	Email         string      `json:"email"`
+	EmailVerified interface{} `json:"email_verified"`
+	GivenName     string      `json:"given_name"`
+	FamilyName    string      `json:"family_name"`
+}
+
+// OIDCProvider signs users in with any OpenID Connect provider. The endpoints are discovered from the issuer and
+// the id_token is verified against the provider's published keys.
+type OIDCProvider struct {
+	name     string
+	provider configs.OAuthProvider
+	client   *http.Client
+
+	mutex        sync.Mutex
+	discovery    *discoveryDocument
+	keys         map[string]interface{}
+	keysLoadedAt time.Time
+}
+
+func NewOIDCProvider(name string, provider configs.OAuthProvider, client *http.Client) ProviderItf {
+	if len(provider.Scopes) == 0 {
+		provider.Scopes = defaultOIDCScopes
+	}
+
+	return &OIDCProvider{
+		name:     name,
+		provider: provider,
+		client:   client,
+	}
+}
+
+func (p *OIDCProvider) Name() string {
+	return p.name
+}
+
+func (p *OIDCProvider) AuthCodeURL(ctx context.Context, redirectURL string, state string, nonce string, codeVerifier string) (string, error) {
+	discovery, err := p.getDiscovery(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	extra := url.Values{
+		"nonce": {nonce},
+	}
+
+	if p.provider.ResponseMode != "" {
+		extra.Set("response_mode", p.provider.ResponseMode)
+	}
+
+	return buildAuthCodeURL(discovery.AuthorizationEndpoint, p.provider.ClientID, redirectURL, p.provider.Scopes, state, codeVerifier, extra)
+}
+
+func (p *OIDCProvider) Exchange(ctx context.Context, redirectURL string, code string, codeVerifier string, nonce string) (res *oauthEntity.ExternalIdentity, err error) {
+	discovery, err := p.getDiscovery(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := exchangeCode(ctx, p.client, discovery.TokenEndpoint, p.provider, redirectURL, code, codeVerifier)
+	if err != nil {
+		return nil, err
+	}
+
+	if token.IDToken == "" {
+		return nil, errorMissingIDToken
+	}
+
+	claims, err := p.verifyIDToken(ctx, discovery, token.IDToken, nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	info := userInfo{
+		Subject:       stringClaim(claims, "sub"),
+		Email:         stringClaim(claims, "email"),
+		EmailVerified: claims["email_verified"],
+		GivenName:     stringClaim(claims, "given_name"),
+		FamilyName:    stringClaim(claims, "family_name"),
+	}
+
+	// some providers only put the email in the userinfo response
+	if info.Email == "" && discovery.UserInfoEndpoint != "" {
+		var fetched userInfo
+		err = getJSON(ctx, p.client, discovery.UserInfoEndpoint, token.AccessToken, &fetched)
+		if err != nil {
+			return nil, err
+		}
+
+		if fetched.Subject != info.Subject {
+			return nil, errorSubjectMismatch
+		}
+
+		info = fetched
+	}
+
+	return &oauthEntity.ExternalIdentity{
+		Provider:      p.name,
+		Subject:       info.Subject,
+		Email:         strings.ToLower(info.Email),
+		EmailVerified: isTrue(info.EmailVerified),
+		GivenName:     info.GivenName,
+		FamilyName:    info.FamilyName,
+	}, nil
+}
+
+// verifyIDToken checks the signature, issuer, audience, expiry and nonce of the id_token.
+func (p *OIDCProvider) verifyIDToken(ctx context.Context, discovery *discoveryDocument, idToken string, nonce string) (claims jwt.MapClaims, err error) {
+	claims = jwt.MapClaims{}
+	token, err := jwt.ParseWithClaims(idToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, err := p.getKey(ctx, discovery, kid)
+		if err != nil {
+			return nil, err
+		}
+
+		switch key.(type) {
+		case *rsa.PublicKey:
+			if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
+				return nil, errorInvalidIDToken
+			}
+		case *ecdsa.PublicKey:
+			if _, ok := token.Method.(*jwt.SigningMethodECDSA); !ok {
+				return nil, errorInvalidIDToken
+			}
+		default:
+			return nil, errorInvalidIDToken
+		}
+
+		return key, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errorInvalidIDToken, err)
+	}
+
+	if !token.Valid || !claims.VerifyIssuer(discovery.Issuer, true) || !hasAudience(claims["aud"], p.provider.ClientID) {
+		return nil, errorInvalidIDToken
+	}
+
+	if _, ok := claims["exp"]; !ok {
+		return nil, errorInvalidIDToken
+	}
+
+	if subtle.ConstantTimeCompare([]byte(stringClaim(claims, "nonce")), []byte(nonce)) != 1 {
+		return nil, errorNonceMismatch
+	}
+
+	if stringClaim(claims, "sub") == "" {
+		return nil, errorInvalidIDToken
+	}
+
+	return claims, nil
+}
+
+func (p *OIDCProvider) getDiscovery(ctx context.Context) (*discoveryDocument, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	issuer := strings.TrimSuffix(p.provider.Issuer, "/")
+
+	var discovery discoveryDocument
+	err := getJSON(ctx, p.client, issuer+discoveryPath, "", &discovery)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.TrimSuffix(discovery.Issuer, "/") != issuer {
+		return nil, errorIssuerMismatch
+	}
+
+	p.discovery = &discovery
+
+	return p.discovery, nil
+}
+
+// getKey returns the key identified by kid, the key set is fetched again when the provider rotated its keys.
+func (p *OIDCProvider) getKey(ctx context.Context, discovery *discoveryDocument, kid string) (interface{}, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+
+	if time.Since(p.keysLoadedAt) < jwksRefreshInterval {
+		return nil, errorUnknownSigningKey
+	}
+
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	err := getJSON(ctx, p.client, discovery.JWKSURI, "", &jwks)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]interface{}, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		key, err := jwk.publicKey()
+		if err != nil {
+			continue
+		}
+
+		keys[jwk.Kid] = key
+	}
+
+	p.keys = keys
+	p.keysLoadedAt = time.Now()
+
+	key, ok := p.keys[kid]
+	if !ok {
+		return nil, errorUnknownSigningKey
+	}
+
+	return key, nil
+}
+
+func (k jsonWebKey) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %s", k.Crv)
+		}
+
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %s", k.Kty)
+	}
+}
+
+func hasAudience(aud interface{}, clientID string) bool {
+	switch value := aud.(type) {
+	case string:
+		return value == clientID
+	case []interface{}:
+		for _, item := range value {
+			if item == clientID {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func stringClaim(claims jwt.MapClaims, name string) string {
+	value, _ := claims[name].(string)
+	return value
+}
+
+// isTrue accepts both the boolean and the string form of email_verified, Apple sends the latter.
+func isTrue(value interface{}) bool {
+	switch v := value.(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true"
+	}
+
+	return false
+}
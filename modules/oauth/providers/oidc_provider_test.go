@@ -0,0 +1,202 @@
+package providers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/winartodev/apollo/core/configs"
+	"github.com/winartodev/apollo/core/helpers"
+)
+
+const (
+	fakeClientID     = "apollo"
+	fakeClientSecret = "apollo-secret"
+	fakeCode         = "fake-code"
+	fakeKeyID        = "fake-key"
+	fakeRedirectURL  = "http://localhost:8989/api/v1/auth/oauth/fake/callback"
+)
+
+// fakeOIDCProvider is a minimal OpenID Connect provider that remembers the last authorization request and
+// issues an id_token for it when the code is redeemed with the matching PKCE verifier.
+type fakeOIDCProvider struct {
+	server        *httptest.Server
+	key           *rsa.PrivateKey
+	audience      string
+	codeChallenge string
+	nonce         string
+}
+
+func newFakeOIDCProvider(t *testing.T) *fakeOIDCProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fake := &fakeOIDCProvider{key: key, audience: fakeClientID}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(discoveryPath, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, discoveryDocument{
+			Issuer:                fake.server.URL,
+			AuthorizationEndpoint: fake.server.URL + "/authorize",
+			TokenEndpoint:         fake.server.URL + "/token",
+			JWKSURI:               fake.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"keys": []jsonWebKey{{
+				Kty: "RSA",
+				Kid: fakeKeyID,
+				Alg: "RS256",
+				N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", fake.token)
+
+	fake.server = httptest.NewServer(mux)
+	t.Cleanup(fake.server.Close)
+
+	return fake
+}
+
+// authorize plays the part of the user approving the request at the provider.
+func (f *fakeOIDCProvider) authorize(t *testing.T, authCodeURL string) {
+	parsedURL, err := url.Parse(authCodeURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	query := parsedURL.Query()
+	if query.Get("code_challenge_method") != helpers.PKCEMethodS256 || query.Get("client_id") != fakeClientID {
+		t.Fatalf("AuthCodeURL() = %v, want an S256 challenge for client %v", authCodeURL, fakeClientID)
+	}
+
+	f.codeChallenge = query.Get("code_challenge")
+	f.nonce = query.Get("nonce")
+}
+
+func (f *fakeOIDCProvider) token(w http.ResponseWriter, r *http.Request) {
+	if r.FormValue("code") != fakeCode || r.FormValue("client_secret") != fakeClientSecret ||
+		helpers.CodeChallengeS256(r.FormValue("code_verifier")) != f.codeChallenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":            f.server.URL,
+		"aud":            f.audience,
+		"sub":            "fake-subject",
+		"email":          "Apollo@Example.com",
+		"email_verified": true,
+		"given_name":     "Apollo",
+		"nonce":          f.nonce,
+		"iat":            time.Now().Unix(),
+		"exp":            time.Now().Add(time.Minute).Unix(),
+	})
+	idToken.Header["kid"] = fakeKeyID
+
+	signed, err := idToken.SignedString(f.key)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, nil)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, tokenResponse{AccessToken: "fake-access-token", TokenType: "Bearer", IDToken: signed})
+}
+
+func writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(data)
+}
+
+func TestOIDCProvider_Exchange(t *testing.T) {
+	tests := []struct {
+		name         string
+		audience     string
+		codeVerifier func(verifier string) string
+		nonce        func(nonce string) string
+		wantErr      bool
+	}{
+		{
+			name: "success",
+		},
+		{
+			name:         "failed_code_verifier_mismatch",
+			codeVerifier: func(string) string { return "another-verifier-another-verifier-another" },
+			wantErr:      true,
+		},
+		{
+			name:    "failed_nonce_mismatch",
+			nonce:   func(string) string { return "another-nonce" },
+			wantErr: true,
+		},
+		{
+			name:     "failed_audience_mismatch",
+			audience: "another-client",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeOIDCProvider(t)
+			if tt.audience != "" {
+				fake.audience = tt.audience
+			}
+
+			provider := NewOIDCProvider("fake", configs.OAuthProvider{
+				Type:         configs.OAuthProviderOIDC,
+				Issuer:       fake.server.URL,
+				ClientID:     fakeClientID,
+				ClientSecret: fakeClientSecret,
+			}, fake.server.Client())
+
+			verifier, err := helpers.GenerateCodeVerifier()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			nonce := "fake-nonce"
+			authCodeURL, err := provider.AuthCodeURL(context.Background(), fakeRedirectURL, "fake-state", nonce, verifier)
+			if err != nil {
+				t.Fatalf("AuthCodeURL() error = %v", err)
+			}
+
+			fake.authorize(t, authCodeURL)
+
+			if tt.codeVerifier != nil {
+				verifier = tt.codeVerifier(verifier)
+			}
+
+			if tt.nonce != nil {
+				nonce = tt.nonce(nonce)
+			}
+
+			identity, err := provider.Exchange(context.Background(), fakeRedirectURL, fakeCode, verifier, nonce)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Exchange() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if identity.Subject != "fake-subject" || identity.Email != "apollo@example.com" || !identity.EmailVerified {
+				t.Errorf("Exchange() = %+v, want the verified identity of fake-subject", identity)
+			}
+		})
+	}
+}
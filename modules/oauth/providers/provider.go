@@ -0,0 +1,163 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/winartodev/apollo/core/configs"
+	"github.com/winartodev/apollo/core/helpers"
+	oauthEntity "github.com/winartodev/apollo/modules/oauth/entities"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	requestTimeout = 10 * time.Second
+	maxBodySize    = 1 << 20
+
+	errorUnsupportedProvider = "unsupported oauth provider type %s for %s"
+	errorProviderResponse    = "%s responded with status %d: %s"
+)
+
+var (
+	ErrorMissingAuthorizationCode = errors.New("authorization code is missing")
+)
+
+// ProviderItf is implemented by every external identity provider. AuthCodeURL builds the authorization code
+// request with a PKCE challenge, Exchange redeems the code and returns the verified identity of the user.
+type ProviderItf interface {
+	Name() string
+	AuthCodeURL(ctx context.Context, redirectURL string, state string, nonce string, codeVerifier string) (string, error)
+	Exchange(ctx context.Context, redirectURL string, code string, codeVerifier string, nonce string) (res *oauthEntity.ExternalIdentity, err error)
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	IDToken     string `json:"id_token"`
+	Error       string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+// NewProviders creates a provider for every configured entry, keyed by the name used in the routes.
+func NewProviders(oauth *configs.OAuth, client *http.Client) (res map[string]ProviderItf, err error) {
+	if client == nil {
+		client = &http.Client{Timeout: requestTimeout}
+	}
+
+	res = make(map[string]ProviderItf, len(oauth.Providers))
+	for name, provider := range oauth.Providers {
+		switch provider.Type {
+		case configs.OAuthProviderOIDC:
+			res[name] = NewOIDCProvider(name, provider, client)
+		case configs.OAuthProviderGitHub:
+			res[name] = NewGitHubProvider(name, provider, client)
+		default:
+			return nil, fmt.Errorf(errorUnsupportedProvider, provider.Type, name)
+		}
+	}
+
+	return res, nil
+}
+
+func buildAuthCodeURL(authURL string, clientID string, redirectURL string, scopes []string, state string, codeVerifier string, extra url.Values) (string, error) {
+	parsedURL, err := url.Parse(authURL)
+	if err != nil {
+		return "", err
+	}
+
+	query := parsedURL.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", clientID)
+	query.Set("redirect_uri", redirectURL)
+	query.Set("scope", strings.Join(scopes, " "))
+	query.Set("state", state)
+	query.Set("code_challenge", helpers.CodeChallengeS256(codeVerifier))
+	query.Set("code_challenge_method", helpers.PKCEMethodS256)
+	for key, values := range extra {
+		for _, value := range values {
+			query.Add(key, value)
+		}
+	}
+
+	parsedURL.RawQuery = query.Encode()
+
+	return parsedURL.String(), nil
+}
+
+// exchangeCode redeems an authorization code at tokenURL, sending the PKCE verifier along with the client credentials.
+func exchangeCode(ctx context.Context, client *http.Client, tokenURL string, provider configs.OAuthProvider, redirectURL string, code string, codeVerifier string) (res *tokenResponse, err error) {
+	if code == "" {
+		return nil, ErrorMissingAuthorizationCode
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", redirectURL)
+	form.Set("client_id", provider.ClientID)
+	form.Set("client_secret", provider.ClientSecret)
+	form.Set("code_verifier", codeVerifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	res = &tokenResponse{}
+	err = doJSON(client, req, res)
+	if err != nil {
+		return nil, err
+	}
+
+	// GitHub answers failed exchanges with 200 and an error field
+	if res.Error != "" {
+		return nil, fmt.Errorf("token exchange failed: %s %s", res.Error, res.Description)
+	}
+
+	if res.AccessToken == "" {
+		return nil, errors.New("token exchange returned no access token")
+	}
+
+	return res, nil
+}
+
+func getJSON(ctx context.Context, client *http.Client, endpoint string, accessToken string, data interface{}) (err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+
+	return doJSON(client, req, data)
+}
+
+func doJSON(client *http.Client, req *http.Request, data interface{}) (err error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf(errorProviderResponse, req.URL.Host, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	return json.Unmarshal(body, data)
+}
@@ -0,0 +1,55 @@
+package repositories
+
+const (
+	InsertIdentityDBQuery = `
+		INSERT INTO identities 
+		    (
+				user_id,
+				provider,
+				subject,
+				email,
+				email_verified,
+				created_at,
+				updated_at
+			) VALUES (
+						$1, -- user_id
+						$2, -- provider
+						$3, -- subject
+						$4, -- email
+						$5, -- email_verified
+						$6, -- created_at
+						$6  -- updated_at
+					)
+			  RETURNING id;
+	`
+
+	GetIdentityQueryDB = `
+		SELECT 
+			id,
+			user_id,
+			provider,
+			subject,
+			COALESCE(email, ''),
+			email_verified,
+			created_at,
+			updated_at
+		FROM identities
+	`
+
+	UpdateIdentityEmailDBQuery = `
+		UPDATE identities 
+		SET 
+		    email = $1,
+		    email_verified = $2,
+		    updated_at = $3
+		WHERE 
+		    id = $4;
+	`
+
+	DeleteIdentitiesCreatedSinceDBQuery = `
+		DELETE FROM identities 
+		WHERE 
+		    user_id = $1 
+		  AND created_at >= $2;
+	`
+)
@@ -0,0 +1,142 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"github.com/winartodev/apollo/core/helpers"
+	oauthEntity "github.com/winartodev/apollo/modules/oauth/entities"
+	"time"
+)
+
+const (
+	oauthStatePrefix = "oauth_state"
+)
+
+type OAuthRepositoryItf interface {
+	CreateIdentityDB(ctx context.Context, data *oauthEntity.Identity) (id int64, err error)
+	GetIdentityDB(ctx context.Context, provider string, subject string) (res *oauthEntity.Identity, err error)
+	UpdateIdentityEmailDB(ctx context.Context, id int64, email string, emailVerified bool) (err error)
+	DeleteIdentitiesCreatedSinceDB(ctx context.Context, userID int64, since time.Time) (deleted int64, err error)
+	SetStateRedis(ctx context.Context, stateHash string, data oauthEntity.OAuthState, ttl time.Duration) (err error)
+	GetStateRedis(ctx context.Context, stateHash string) (res *oauthEntity.OAuthState, err error)
+	DeleteStateRedis(ctx context.Context, stateHash string) (deleted bool, err error)
+}
+
+type OAuthRepository struct {
+	DB    *sql.DB
+	Redis *redis.Client
+}
+
+func NewOAuthRepository(repository OAuthRepository) OAuthRepositoryItf {
+	return &OAuthRepository{
+		DB:    repository.DB,
+		Redis: repository.Redis,
+	}
+}
+
+func (or *OAuthRepository) CreateIdentityDB(ctx context.Context, data *oauthEntity.Identity) (id int64, err error) {
+	err = or.DB.QueryRowContext(ctx, InsertIdentityDBQuery,
+		data.UserID,
+		data.Provider,
+		data.Subject,
+		data.Email,
+		data.EmailVerified,
+		data.CreatedAt.Unix(),
+	).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+func (or *OAuthRepository) GetIdentityDB(ctx context.Context, provider string, subject string) (res *oauthEntity.Identity, err error) {
+	query := fmt.Sprintf("%s WHERE provider = $1 AND subject = $2", GetIdentityQueryDB)
+
+	var createdAtUnix int64
+	var updatedAtUnix int64
+
+	res = &oauthEntity.Identity{}
+	err = or.DB.QueryRowContext(ctx, query, provider, subject).Scan(
+		&res.ID,
+		&res.UserID,
+		&res.Provider,
+		&res.Subject,
+		&res.Email,
+		&res.EmailVerified,
+		&createdAtUnix,
+		&updatedAtUnix,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	res.CreatedAt = helpers.FormatUnixTime(createdAtUnix)
+	res.UpdatedAt = helpers.FormatUnixTime(updatedAtUnix)
+
+	return res, nil
+}
+
+func (or *OAuthRepository) UpdateIdentityEmailDB(ctx context.Context, id int64, email string, emailVerified bool) (err error) {
+	_, err = or.DB.ExecContext(ctx, UpdateIdentityEmailDBQuery, email, emailVerified, time.Now().Unix(), id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (or *OAuthRepository) DeleteIdentitiesCreatedSinceDB(ctx context.Context, userID int64, since time.Time) (deleted int64, err error) {
+	result, err := or.DB.ExecContext(ctx, DeleteIdentitiesCreatedSinceDBQuery, userID, since.Unix())
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+func (or *OAuthRepository) SetStateRedis(ctx context.Context, stateHash string, data oauthEntity.OAuthState, ttl time.Duration) (err error) {
+	dataByte, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	return or.Redis.SetEX(ctx, or.generateRedisKey(oauthStatePrefix, stateHash), dataByte, ttl).Err()
+}
+
+func (or *OAuthRepository) GetStateRedis(ctx context.Context, stateHash string) (res *oauthEntity.OAuthState, err error) {
+	dataStr, err := or.Redis.Get(ctx, or.generateRedisKey(oauthStatePrefix, stateHash)).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	res = &oauthEntity.OAuthState{}
+	err = json.Unmarshal([]byte(dataStr), res)
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// DeleteStateRedis removes the state and reports whether this call was the one that removed it, so a callback
+// can only be completed once.
+func (or *OAuthRepository) DeleteStateRedis(ctx context.Context, stateHash string) (deleted bool, err error) {
+	count, err := or.Redis.Del(ctx, or.generateRedisKey(oauthStatePrefix, stateHash)).Result()
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+func (or *OAuthRepository) generateRedisKey(prefix string, value string) string {
+	return fmt.Sprintf("%s:%s", prefix, value)
+}
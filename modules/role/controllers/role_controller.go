@@ -0,0 +1,115 @@
+package controllers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	roleEntity "github.com/winartodev/apollo/modules/role/entities"
+	roleRepo "github.com/winartodev/apollo/modules/role/repositories"
+	sessionController "github.com/winartodev/apollo/modules/session/controllers"
+	userController "github.com/winartodev/apollo/modules/user/controllers"
+	"slices"
+)
+
+var (
+	ErrorRoleNotFound    = errors.New("role not found")
+	ErrorRoleNotAssigned = errors.New("role is not assigned to the user")
+)
+
+type RoleControllerItf interface {
+	GetRoles(ctx context.Context) (res []roleEntity.Role, err error)
+	GetUserRoles(ctx context.Context, userID int64) (res []string, err error)
+	HasPermissions(ctx context.Context, roles []string, permissions []string) (ok bool, err error)
+	AssignRole(ctx context.Context, userID int64, role string) (err error)
+	RemoveRole(ctx context.Context, userID int64, role string) (err error)
+}
+
+type RoleController struct {
+	RoleRepository    roleRepo.RoleRepositoryItf
+	SessionController sessionController.SessionControllerItf
+	UserController    userController.UserControllerItf
+}
+
+func NewRoleController(controller RoleController) RoleControllerItf {
+	return &RoleController{
+		RoleRepository:    controller.RoleRepository,
+		SessionController: controller.SessionController,
+		UserController:    controller.UserController,
+	}
+}
+
+func (rc *RoleController) GetRoles(ctx context.Context) (res []roleEntity.Role, err error) {
+	return rc.RoleRepository.GetRolesDB(ctx)
+}
+
+func (rc *RoleController) GetUserRoles(ctx context.Context, userID int64) (res []string, err error) {
+	return rc.RoleRepository.GetRoleNamesByUserIDDB(ctx, userID)
+}
+
+// HasPermissions tells whether the roles together grant every one of permissions.
+func (rc *RoleController) HasPermissions(ctx context.Context, roles []string, permissions []string) (ok bool, err error) {
+	if len(roles) == 0 {
+		return len(permissions) == 0, nil
+	}
+
+	granted, err := rc.RoleRepository.GetPermissionsByRoleNamesDB(ctx, roles)
+	if err != nil {
+		return false, err
+	}
+
+	for _, permission := range permissions {
+		if !slices.Contains(granted, permission) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// AssignRole gives the user a role, it is part of the tokens issued from the next sign in or refresh on.
+func (rc *RoleController) AssignRole(ctx context.Context, userID int64, role string) (err error) {
+	_, err = rc.UserController.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	roleID, err := rc.getRoleID(ctx, role)
+	if err != nil {
+		return err
+	}
+
+	return rc.RoleRepository.CreateUserRoleDB(ctx, userID, roleID)
+}
+
+// RemoveRole takes a role away from the user. The access tokens of the user still carry the role, so they are
+// revoked and the user picks up its remaining roles on the next refresh.
+func (rc *RoleController) RemoveRole(ctx context.Context, userID int64, role string) (err error) {
+	roleID, err := rc.getRoleID(ctx, role)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := rc.RoleRepository.DeleteUserRoleDB(ctx, userID, roleID)
+	if err != nil {
+		return err
+	}
+
+	if !deleted {
+		return ErrorRoleNotAssigned
+	}
+
+	return rc.SessionController.RevokeUserTokens(ctx, userID)
+}
+
+func (rc *RoleController) getRoleID(ctx context.Context, role string) (id int64, err error) {
+	id, err = rc.RoleRepository.GetRoleIDByNameDB(ctx, role)
+	if err == sql.ErrNoRows {
+		return 0, ErrorRoleNotFound
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
@@ -0,0 +1,237 @@
+package controllers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	roleRepo "github.com/winartodev/apollo/modules/role/repositories"
+	sessionController "github.com/winartodev/apollo/modules/session/controllers"
+	userController "github.com/winartodev/apollo/modules/user/controllers"
+	userEntity "github.com/winartodev/apollo/modules/user/entities"
+	"slices"
+	"testing"
+)
+
+// fakeRoleRepository knows the admin and viewer roles and keeps the roles assigned to each user.
+type fakeRoleRepository struct {
+	roleRepo.RoleRepositoryItf
+	userRoles map[int64][]int64
+}
+
+var fakeRoleIDs = map[string]int64{"admin": 1, "viewer": 2}
+
+func (f *fakeRoleRepository) GetRoleIDByNameDB(ctx context.Context, name string) (id int64, err error) {
+	id, ok := fakeRoleIDs[name]
+	if !ok {
+		return 0, sql.ErrNoRows
+	}
+
+	return id, nil
+}
+
+func (f *fakeRoleRepository) GetPermissionsByRoleNamesDB(ctx context.Context, roles []string) (res []string, err error) {
+	for _, role := range roles {
+		switch role {
+		case "admin":
+			res = append(res, "users:read", "users:write")
+		case "viewer":
+			res = append(res, "users:read")
+		}
+	}
+
+	return res, nil
+}
+
+func (f *fakeRoleRepository) CreateUserRoleDB(ctx context.Context, userID int64, roleID int64) (err error) {
+	if !slices.Contains(f.userRoles[userID], roleID) {
+		f.userRoles[userID] = append(f.userRoles[userID], roleID)
+	}
+
+	return nil
+}
+
+func (f *fakeRoleRepository) DeleteUserRoleDB(ctx context.Context, userID int64, roleID int64) (deleted bool, err error) {
+	index := slices.Index(f.userRoles[userID], roleID)
+	if index < 0 {
+		return false, nil
+	}
+
+	f.userRoles[userID] = slices.Delete(f.userRoles[userID], index, index+1)
+	return true, nil
+}
+
+// fakeUserController only knows the user with id 1.
+type fakeUserController struct {
+	userController.UserControllerItf
+}
+
+func (f *fakeUserController) GetUserByID(ctx context.Context, id int64) (res *userEntity.User, err error) {
+	if id != 1 {
+		return nil, userController.ErrorUserNotFound
+	}
+
+	return &userEntity.User{ID: id, Username: "apollo", Email: "apollo@gmail.com"}, nil
+}
+
+type fakeSessionController struct {
+	sessionController.SessionControllerItf
+	revokedUsers []int64
+}
+
+func (f *fakeSessionController) RevokeUserTokens(ctx context.Context, userID int64) (err error) {
+	f.revokedUsers = append(f.revokedUsers, userID)
+	return nil
+}
+
+func TestRoleController_HasPermissions(t *testing.T) {
+	tests := []struct {
+		name        string
+		roles       []string
+		permissions []string
+		want        bool
+	}{
+		{
+			name:        "success_granted_by_role",
+			roles:       []string{"viewer"},
+			permissions: []string{"users:read"},
+			want:        true,
+		},
+		{
+			name:        "success_granted_by_roles_together",
+			roles:       []string{"viewer", "admin"},
+			permissions: []string{"users:read", "users:write"},
+			want:        true,
+		},
+		{
+			name:        "failed_permission_not_granted",
+			roles:       []string{"viewer"},
+			permissions: []string{"users:read", "users:write"},
+		},
+		{
+			name:        "failed_without_roles",
+			permissions: []string{"users:read"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			controller := NewRoleController(RoleController{RoleRepository: &fakeRoleRepository{}})
+
+			ok, err := controller.HasPermissions(context.Background(), tt.roles, tt.permissions)
+			if err != nil {
+				t.Fatalf("HasPermissions() error = %v", err)
+			}
+
+			if ok != tt.want {
+				t.Errorf("HasPermissions() = %v, want %v", ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoleController_AssignRole(t *testing.T) {
+	tests := []struct {
+		name      string
+		userID    int64
+		role      string
+		wantErr   error
+		wantRoles []int64
+	}{
+		{
+			name:      "success_assign_role",
+			userID:    1,
+			role:      "admin",
+			wantRoles: []int64{2, 1},
+		},
+		{
+			name:      "success_assign_role_already_assigned",
+			userID:    1,
+			role:      "viewer",
+			wantRoles: []int64{2},
+		},
+		{
+			name:      "failed_user_not_found",
+			userID:    2,
+			role:      "admin",
+			wantErr:   userController.ErrorUserNotFound,
+			wantRoles: []int64{2},
+		},
+		{
+			name:      "failed_role_not_found",
+			userID:    1,
+			role:      "owner",
+			wantErr:   ErrorRoleNotFound,
+			wantRoles: []int64{2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repository := &fakeRoleRepository{userRoles: map[int64][]int64{1: {2}}}
+			controller := NewRoleController(RoleController{
+				RoleRepository:    repository,
+				SessionController: &fakeSessionController{},
+				UserController:    &fakeUserController{},
+			})
+
+			err := controller.AssignRole(context.Background(), tt.userID, tt.role)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("AssignRole() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !slices.Equal(repository.userRoles[1], tt.wantRoles) {
+				t.Errorf("AssignRole() roles = %v, want %v", repository.userRoles[1], tt.wantRoles)
+			}
+		})
+	}
+}
+
+func TestRoleController_RemoveRole(t *testing.T) {
+	tests := []struct {
+		name        string
+		role        string
+		wantErr     error
+		wantRoles   []int64
+		wantRevoked bool
+	}{
+		{
+			name:        "success_remove_role_revokes_tokens",
+			role:        "admin",
+			wantRevoked: true,
+		},
+		{
+			name:      "failed_role_not_assigned",
+			role:      "viewer",
+			wantErr:   ErrorRoleNotAssigned,
+			wantRoles: []int64{1},
+		},
+		{
+			name:      "failed_role_not_found",
+			role:      "owner",
+			wantErr:   ErrorRoleNotFound,
+			wantRoles: []int64{1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repository := &fakeRoleRepository{userRoles: map[int64][]int64{1: {1}}}
+			session := &fakeSessionController{}
+			controller := NewRoleController(RoleController{
+				RoleRepository:    repository,
+				SessionController: session,
+				UserController:    &fakeUserController{},
+			})
+
+			err := controller.RemoveRole(context.Background(), 1, tt.role)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("RemoveRole() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !slices.Equal(repository.userRoles[1], tt.wantRoles) {
+				t.Errorf("RemoveRole() roles = %v, want %v", repository.userRoles[1], tt.wantRoles)
+			}
+
+			if revoked := slices.Contains(session.revokedUsers, 1); revoked != tt.wantRevoked {
+				t.Errorf("RemoveRole() revoked tokens = %v, want %v", revoked, tt.wantRevoked)
+			}
+		})
+	}
+}
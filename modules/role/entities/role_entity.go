@@ -0,0 +1,17 @@
+package entities
+
+import "time"
+
+// Role groups permissions, a user holds the permissions of all of its roles.
+type Role struct {
+	ID          int64      `json:"id"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Permissions []string   `json:"permissions"`
+	CreatedAt   *time.Time `json:"created_at,omitempty"`
+	UpdatedAt   *time.Time `json:"updated_at,omitempty"`
+}
+
+type AssignRoleRequest struct {
+	Role string `json:"role"`
+}
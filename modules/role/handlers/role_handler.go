@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/winartodev/apollo/core"
+	"github.com/winartodev/apollo/core/middlewares"
+	"github.com/winartodev/apollo/core/responses"
+	roleController "github.com/winartodev/apollo/modules/role/controllers"
+	roleEntity "github.com/winartodev/apollo/modules/role/entities"
+	userController "github.com/winartodev/apollo/modules/user/controllers"
+	"strconv"
+)
+
+type RoleHandler struct {
+	middlewares.Middleware
+	RoleController roleController.RoleControllerItf
+}
+
+func NewRoleHandler(handler RoleHandler) RoleHandler {
+	return RoleHandler{
+		Middleware:     handler.Middleware,
+		RoleController: handler.RoleController,
+	}
+}
+
+func (h *RoleHandler) GetRoles(ctx *fiber.Ctx) error {
+	context := ctx.Context()
+
+	res, err := h.RoleController.GetRoles(context)
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusInternalServerError, "Failed Get Roles", err)
+	}
+
+	return responses.SuccessResponse(ctx, fiber.StatusOK, "Success Get Roles", res, nil)
+}
+
+func (h *RoleHandler) GetUserRoles(ctx *fiber.Ctx) error {
+	context := ctx.Context()
+
+	id, err := strconv.ParseInt(ctx.Params("id"), 10, 64)
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusBadRequest, "Failed Get User Roles", err)
+	}
+
+	res, err := h.RoleController.GetUserRoles(context, id)
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusInternalServerError, "Failed Get User Roles", err)
+	}
+
+	return responses.SuccessResponse(ctx, fiber.StatusOK, "Success Get User Roles", res, nil)
+}
+
+func (h *RoleHandler) AssignRole(ctx *fiber.Ctx) error {
+	context := ctx.Context()
+
+	id, err := strconv.ParseInt(ctx.Params("id"), 10, 64)
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusBadRequest, "Failed Assign Role", err)
+	}
+
+	req := roleEntity.AssignRoleRequest{}
+	err = ctx.BodyParser(&req)
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusBadRequest, "Failed Assign Role", err)
+	}
+
+	err = h.RoleController.AssignRole(context, id, req.Role)
+	if errors.Is(err, roleController.ErrorRoleNotFound) || errors.Is(err, userController.ErrorUserNotFound) {
+		return responses.FailedResponse(ctx, fiber.StatusNotFound, "Failed Assign Role", err)
+	}
+
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusInternalServerError, "Failed Assign Role", err)
+	}
+
+	return responses.SuccessResponse(ctx, fiber.StatusOK, "Success", "role assigned successfully", nil)
+}
+
+func (h *RoleHandler) RemoveRole(ctx *fiber.Ctx) error {
+	context := ctx.Context()
+
+	id, err := strconv.ParseInt(ctx.Params("id"), 10, 64)
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusBadRequest, "Failed Remove Role", err)
+	}
+
+	err = h.RoleController.RemoveRole(context, id, ctx.Params("role"))
+	if errors.Is(err, roleController.ErrorRoleNotFound) || errors.Is(err, roleController.ErrorRoleNotAssigned) {
+		return responses.FailedResponse(ctx, fiber.StatusNotFound, "Failed Remove Role", err)
+	}
+
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusInternalServerError, "Failed Remove Role", err)
+	}
+
+	return responses.SuccessResponse(ctx, fiber.StatusOK, "Success", "role removed successfully", nil)
+}
+
+func (h *RoleHandler) Register(router fiber.Router) error {
+	v1 := router.Group(core.V1)
+
+	admin := v1.Group(core.AccessInternal).Group("/admin", h.HandleAdminAccess())
+	admin.Get("/roles", h.GetRoles)
+	admin.Get("/users/:id/roles", h.GetUserRoles)
+	admin.Post("/users/:id/roles", h.AssignRole)
+	admin.Delete("/users/:id/roles/:role", h.RemoveRole)
+
+	return nil
+}
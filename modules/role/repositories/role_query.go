@@ -0,0 +1,57 @@
+package repositories
+
+const (
+	GetRolesDBQuery = `
+		SELECT
+			r.id,
+			r.name,
+			COALESCE(r.description, ''),
+			COALESCE(ARRAY_AGG(p.name ORDER BY p.name) FILTER (WHERE p.name IS NOT NULL), '{}'),
+			r.created_at,
+			r.updated_at
+		FROM roles r
+		LEFT JOIN role_permissions rp ON rp.role_id = r.id
+		LEFT JOIN permissions p ON p.id = rp.permission_id
+		GROUP BY r.id
+		ORDER BY r.name
+	`
+
+	GetRoleIDByNameDBQuery = `
+		SELECT id FROM roles WHERE name = $1
+	`
+
+	GetRoleNamesByUserIDDBQuery = `
+		SELECT r.name
+		FROM user_roles ur
+		JOIN roles r ON r.id = ur.role_id
+		WHERE ur.user_id = $1
+		ORDER BY r.name
+	`
+
+	GetPermissionsByRoleNamesDBQuery = `
+		SELECT DISTINCT p.name
+		FROM roles r
+		JOIN role_permissions rp ON rp.role_id = r.id
+		JOIN permissions p ON p.id = rp.permission_id
+		WHERE r.name = ANY($1)
+		ORDER BY p.name
+	`
+
+	InsertUserRoleDBQuery = `
+		INSERT INTO user_roles
+		    (
+				user_id,
+				role_id,
+				created_at
+			) VALUES (
+						$1, -- user_id
+						$2, -- role_id
+						$3  -- created_at
+					)
+			  ON CONFLICT (user_id, role_id) DO NOTHING;
+	`
+
+	DeleteUserRoleDBQuery = `
+		DELETE FROM user_roles WHERE user_id = $1 AND role_id = $2
+	`
+)
@@ -0,0 +1,128 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"github.com/lib/pq"
+	"github.com/winartodev/apollo/core/helpers"
+	roleEntity "github.com/winartodev/apollo/modules/role/entities"
+	"time"
+)
+
+type RoleRepositoryItf interface {
+	GetRolesDB(ctx context.Context) (res []roleEntity.Role, err error)
+	GetRoleIDByNameDB(ctx context.Context, name string) (id int64, err error)
+	GetRoleNamesByUserIDDB(ctx context.Context, userID int64) (res []string, err error)
+	GetPermissionsByRoleNamesDB(ctx context.Context, roles []string) (res []string, err error)
+	CreateUserRoleDB(ctx context.Context, userID int64, roleID int64) (err error)
+	DeleteUserRoleDB(ctx context.Context, userID int64, roleID int64) (deleted bool, err error)
+}
+
+type RoleRepository struct {
+	DB *sql.DB
+}
+
+func NewRoleRepository(repository RoleRepository) RoleRepositoryItf {
+	return &RoleRepository{
+		DB: repository.DB,
+	}
+}
+
+func (rr *RoleRepository) GetRolesDB(ctx context.Context) (res []roleEntity.Role, err error) {
+	rows, err := rr.DB.QueryContext(ctx, GetRolesDBQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res = []roleEntity.Role{}
+	for rows.Next() {
+		var createdAtUnix int64
+		var updatedAtUnix int64
+
+		role := roleEntity.Role{}
+		err = rows.Scan(
+			&role.ID,
+			&role.Name,
+			&role.Description,
+			pq.Array(&role.Permissions),
+			&createdAtUnix,
+			&updatedAtUnix,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		role.CreatedAt = helpers.FormatUnixTime(createdAtUnix)
+		role.UpdatedAt = helpers.FormatUnixTime(updatedAtUnix)
+
+		res = append(res, role)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (rr *RoleRepository) GetRoleIDByNameDB(ctx context.Context, name string) (id int64, err error) {
+	err = rr.DB.QueryRowContext(ctx, GetRoleIDByNameDBQuery, name).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+func (rr *RoleRepository) GetRoleNamesByUserIDDB(ctx context.Context, userID int64) (res []string, err error) {
+	return rr.queryNames(ctx, GetRoleNamesByUserIDDBQuery, userID)
+}
+
+func (rr *RoleRepository) GetPermissionsByRoleNamesDB(ctx context.Context, roles []string) (res []string, err error) {
+	return rr.queryNames(ctx, GetPermissionsByRoleNamesDBQuery, pq.Array(roles))
+}
+
+func (rr *RoleRepository) CreateUserRoleDB(ctx context.Context, userID int64, roleID int64) (err error) {
+	_, err = rr.DB.ExecContext(ctx, InsertUserRoleDBQuery, userID, roleID, time.Now().Unix())
+	return err
+}
+
+func (rr *RoleRepository) DeleteUserRoleDB(ctx context.Context, userID int64, roleID int64) (deleted bool, err error) {
+	result, err := rr.DB.ExecContext(ctx, DeleteUserRoleDBQuery, userID, roleID)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+func (rr *RoleRepository) queryNames(ctx context.Context, query string, args ...interface{}) (res []string, err error) {
+	rows, err := rr.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res = []string{}
+	for rows.Next() {
+		var name string
+		err = rows.Scan(&name)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, name)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
@@ -0,0 +1,299 @@
+package controllers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/winartodev/apollo/core/helpers"
+	auditController "github.com/winartodev/apollo/modules/audit/controllers"
+	auditEntity "github.com/winartodev/apollo/modules/audit/entities"
+	sessionEntity "github.com/winartodev/apollo/modules/session/entities"
+	sessionRepo "github.com/winartodev/apollo/modules/session/repositories"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrorSessionNotFound     = errors.New("session not found")
+	ErrorInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	ErrorRefreshTokenReused  = errors.New("refresh token was already used, the session has been revoked")
+)
+
+type SessionControllerItf interface {
+	CreateSession(ctx context.Context, userID int64, sessionID string, refreshToken string, client sessionEntity.ClientInfo) (res *sessionEntity.Session, err error)
+	GetSession(ctx context.Context, sessionID string) (res *sessionEntity.Session, err error)
+	GetRefreshTokenSession(ctx context.Context, refreshToken string) (res *sessionEntity.Session, err error)
+	GetActiveSessions(ctx context.Context, userID int64, currentSessionID string) (res []sessionEntity.Session, err error)
+	RotateRefreshToken(ctx context.Context, userID int64, sessionID string, currentToken string, newToken string, client sessionEntity.ClientInfo) (err error)
+	MarkAuthenticated(ctx context.Context, userID int64, sessionID string) (err error)
+	RevokeSession(ctx context.Context, userID int64, sessionID string) (err error)
+	RevokeAllSessions(ctx context.Context, userID int64, exceptSessionID string) (err error)
+	RevokeAccessToken(ctx context.Context, claims *helpers.JWTClaims) (err error)
+	RevokeUserTokens(ctx context.Context, userID int64) (err error)
+	IsAccessTokenRevoked(ctx context.Context, claims *helpers.JWTClaims) (revoked bool, err error)
+	RecordSecurityEvent(ctx context.Context, userID int64, sessionID string, eventType string, client sessionEntity.ClientInfo) (err error)
+}
+
+type SessionController struct {
+	SessionRepository sessionRepo.SessionRepositoryItf
+	AuditController   auditController.AuditControllerItf
+}
+
+func NewSessionController(controller SessionController) SessionControllerItf {
+	return &SessionController{
+		SessionRepository: controller.SessionRepository,
+		AuditController:   controller.AuditController,
+	}
+}
+
+func (sc *SessionController) CreateSession(ctx context.Context, userID int64, sessionID string, refreshToken string, client sessionEntity.ClientInfo) (res *sessionEntity.Session, err error) {
+	now := time.Now()
+	expiresAt := now.Add(helpers.RefreshTokenExpiration)
+
+	res = &sessionEntity.Session{
+		ID:              sessionID,
+		UserID:          userID,
+		UserAgent:       client.UserAgent,
+		IPAddress:       client.IPAddress,
+		LastUsedAt:      &now,
+		ExpiresAt:       &expiresAt,
+		AuthenticatedAt: &now,
+		CreatedAt:       &now,
+		UpdatedAt:       &now,
+	}
+
+	err = sc.SessionRepository.CreateSessionDB(ctx, res, helpers.HashToken(refreshToken))
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (sc *SessionController) GetSession(ctx context.Context, sessionID string) (res *sessionEntity.Session, err error) {
+	res, err = sc.SessionRepository.GetSessionByIDDB(ctx, sessionID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	if res == nil {
+		return nil, ErrorSessionNotFound
+	}
+
+	return res, nil
+}
+
+// RotateRefreshToken exchanges currentToken for newToken within the session's token family. Replaying a token
+// that was already exchanged is treated as theft: the whole family is revoked and a security event is recorded.
+func (sc *SessionController) RotateRefreshToken(ctx context.Context, userID int64, sessionID string, currentToken string, newToken string, client sessionEntity.ClientInfo) (err error) {
+	current, err := sc.SessionRepository.GetRefreshTokenByHashDB(ctx, helpers.HashToken(currentToken))
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	if current == nil || current.SessionID != sessionID {
+		return ErrorInvalidRefreshToken
+	}
+
+	session, err := sc.GetSession(ctx, sessionID)
+	if errors.Is(err, ErrorSessionNotFound) {
+		return ErrorInvalidRefreshToken
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if session.UserID != userID {
+		return ErrorInvalidRefreshToken
+	}
+
+	if current.UsedAt != nil {
+		return sc.revokeTokenFamily(ctx, session, client)
+	}
+
+	if session.IsRevoked || current.ExpiresAt == nil || time.Now().After(*current.ExpiresAt) {
+		return ErrorInvalidRefreshToken
+	}
+
+	expiresAt := time.Now().Add(helpers.RefreshTokenExpiration)
+
+	result, err := sc.SessionRepository.RotateRefreshTokenDB(ctx, current, helpers.HashToken(newToken), client, expiresAt)
+	if err != nil {
+		return err
+	}
+
+	switch result {
+	case sessionEntity.RefreshTokenAlreadyUsed:
+		// another request exchanged the same token first
+		return sc.revokeTokenFamily(ctx, session, client)
+	case sessionEntity.RefreshTokenSessionRevoked:
+		// the session was signed out in the meantime, which is no sign of theft
+		return ErrorInvalidRefreshToken
+	}
+
+	return nil
+}
+
+// GetRefreshTokenSession returns the session of a refresh token that can still be exchanged. Tokens that were
+// already exchanged, expired or belong to a revoked session are invalid.
+func (sc *SessionController) GetRefreshTokenSession(ctx context.Context, refreshToken string) (res *sessionEntity.Session, err error) {
+	current, err := sc.SessionRepository.GetRefreshTokenByHashDB(ctx, helpers.HashToken(refreshToken))
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	if current == nil || current.UsedAt != nil || current.ExpiresAt == nil || time.Now().After(*current.ExpiresAt) {
+		return nil, ErrorInvalidRefreshToken
+	}
+
+	res, err = sc.GetSession(ctx, current.SessionID)
+	if errors.Is(err, ErrorSessionNotFound) {
+		return nil, ErrorInvalidRefreshToken
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if res.IsRevoked {
+		return nil, ErrorInvalidRefreshToken
+	}
+
+	return res, nil
+}
+
+// GetActiveSessions lists the devices the user is signed in on, most recently used first.
+func (sc *SessionController) GetActiveSessions(ctx context.Context, userID int64, currentSessionID string) (res []sessionEntity.Session, err error) {
+	res, err = sc.SessionRepository.GetActiveSessionsByUserIDDB(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range res {
+		res[i].IsCurrent = res[i].ID == currentSessionID
+	}
+
+	return res, nil
+}
+
+// MarkAuthenticated records that the user just proved who they are again in the session, which opens the
+// protected routes for a while.
+func (sc *SessionController) MarkAuthenticated(ctx context.Context, userID int64, sessionID string) (err error) {
+	updated, err := sc.SessionRepository.UpdateSessionAuthenticatedAtDB(ctx, userID, sessionID, time.Now())
+	if err != nil {
+		return err
+	}
+
+	if !updated {
+		return ErrorSessionNotFound
+	}
+
+	return nil
+}
+
+// RevokeSession makes both the refresh token and the access tokens of the session unusable right away.
+func (sc *SessionController) RevokeSession(ctx context.Context, userID int64, sessionID string) (err error) {
+	revoked, err := sc.SessionRepository.RevokeSessionByIDDB(ctx, userID, sessionID)
+	if err != nil {
+		return err
+	}
+
+	if !revoked {
+		return ErrorSessionNotFound
+	}
+
+	return sc.denySessions(ctx, []string{sessionID})
+}
+
+// RevokeAllSessions signs the user out of every device except exceptSessionID, an empty exceptSessionID signs
+// the user out everywhere.
+func (sc *SessionController) RevokeAllSessions(ctx context.Context, userID int64, exceptSessionID string) (err error) {
+	ids, err := sc.SessionRepository.RevokeSessionsByUserIDDB(ctx, userID, exceptSessionID)
+	if err != nil {
+		return err
+	}
+
+	return sc.denySessions(ctx, ids)
+}
+
+// RevokeAccessToken puts a single access token on the denylist for the rest of its lifetime.
+func (sc *SessionController) RevokeAccessToken(ctx context.Context, claims *helpers.JWTClaims) (err error) {
+	if claims == nil || claims.Id == "" {
+		return nil
+	}
+
+	ttl := time.Until(time.Unix(claims.ExpiresAt, 0))
+	if ttl <= 0 {
+		return nil
+	}
+
+	return sc.SessionRepository.SetRevokedTokenRedis(ctx, claims.Id, ttl)
+}
+
+// RevokeUserTokens rejects every access token issued to the user until now. Refresh tokens are revoked through
+// their sessions, so the cutoff only has to outlive the access tokens.
+func (sc *SessionController) RevokeUserTokens(ctx context.Context, userID int64) (err error) {
+	return sc.SessionRepository.SetTokensRevokedBeforeRedis(ctx, userID, time.Now(), helpers.AccessTokenExpiration)
+}
+
+func (sc *SessionController) IsAccessTokenRevoked(ctx context.Context, claims *helpers.JWTClaims) (revoked bool, err error) {
+	revocation, err := sc.SessionRepository.GetTokenRevocationRedis(ctx, claims.ID, claims.SessionID, claims.Id)
+	if err != nil {
+		return false, err
+	}
+
+	if revocation.SessionRevoked || revocation.TokenRevoked {
+		return true, nil
+	}
+
+	// compared to the millisecond, so a token issued right after the cutoff, e.g. by a refresh following a password
+	// change, stays valid
+	return revocation.RevokedBefore > 0 && claims.IssuedAtMilli() < revocation.RevokedBefore, nil
+}
+
+// denySessions keeps revoked sessions on the denylist for as long as the access tokens issued to them are valid.
+func (sc *SessionController) denySessions(ctx context.Context, ids []string) (err error) {
+	return sc.SessionRepository.SetRevokedSessionsRedis(ctx, ids, helpers.AccessTokenExpiration)
+}
+
+func (sc *SessionController) revokeTokenFamily(ctx context.Context, session *sessionEntity.Session, client sessionEntity.ClientInfo) (err error) {
+	log.Warnf("refresh token reuse detected for user %d session %s", session.UserID, session.ID)
+
+	_, err = sc.SessionRepository.RevokeSessionByIDDB(ctx, session.UserID, session.ID)
+	if err != nil {
+		return err
+	}
+
+	err = sc.denySessions(ctx, []string{session.ID})
+	if err != nil {
+		return err
+	}
+
+	err = sc.RecordSecurityEvent(ctx, session.UserID, session.ID, sessionEntity.SecurityEventRefreshTokenReuse, client)
+	if err != nil {
+		return err
+	}
+
+	return ErrorRefreshTokenReused
+}
+
+// RecordSecurityEvent records the event in the audit trail as a security action about the user.
+func (sc *SessionController) RecordSecurityEvent(ctx context.Context, userID int64, sessionID string, eventType string, client sessionEntity.ClientInfo) (err error) {
+	event := &auditEntity.Event{
+		Action:     auditEntity.ActionSecurityPrefix + eventType,
+		TargetType: auditEntity.TargetUser,
+		TargetID:   strconv.FormatInt(userID, 10),
+		UserID:     userID,
+		IPAddress:  client.IPAddress,
+		UserAgent:  client.UserAgent,
+	}
+
+	if sessionID != "" {
+		event.Metadata = map[string]interface{}{"session_id": sessionID}
+	}
+
+	sc.AuditController.Record(ctx, event, nil)
+	return nil
+}
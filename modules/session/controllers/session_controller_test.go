@@ -0,0 +1,165 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/google/uuid"
+	"github.com/winartodev/apollo/core/helpers"
+	auditController "github.com/winartodev/apollo/modules/audit/controllers"
+	auditEntity "github.com/winartodev/apollo/modules/audit/entities"
+	sessionEntity "github.com/winartodev/apollo/modules/session/entities"
+	sessionRepo "github.com/winartodev/apollo/modules/session/repositories"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type fakeSessionRepository struct {
+	sessionRepo.SessionRepositoryItf
+	rotation      int
+	revoked       []string
+	revokedBefore int64
+}
+
+func (f *fakeSessionRepository) GetRefreshTokenByHashDB(ctx context.Context, tokenHash string) (res *sessionEntity.RefreshToken, err error) {
+	expiresAt := time.Now().Add(time.Hour)
+	return &sessionEntity.RefreshToken{ID: 1, SessionID: "session-1", ExpiresAt: &expiresAt}, nil
+}
+
+func (f *fakeSessionRepository) GetSessionByIDDB(ctx context.Context, id string) (res *sessionEntity.Session, err error) {
+	return &sessionEntity.Session{ID: id, UserID: 1}, nil
+}
+
+func (f *fakeSessionRepository) RotateRefreshTokenDB(ctx context.Context, current *sessionEntity.RefreshToken, newHash string, client sessionEntity.ClientInfo, expiresAt time.Time) (result int, err error) {
+	return f.rotation, nil
+}
+
+func (f *fakeSessionRepository) RevokeSessionByIDDB(ctx context.Context, userID int64, id string) (revoked bool, err error) {
+	f.revoked = append(f.revoked, id)
+	return true, nil
+}
+
+func (f *fakeSessionRepository) SetRevokedSessionsRedis(ctx context.Context, ids []string, ttl time.Duration) (err error) {
+	return nil
+}
+
+func (f *fakeSessionRepository) SetTokensRevokedBeforeRedis(ctx context.Context, userID int64, before time.Time, ttl time.Duration) (err error) {
+	f.revokedBefore = before.UnixMilli()
+	return nil
+}
+
+func (f *fakeSessionRepository) GetTokenRevocationRedis(ctx context.Context, userID int64, sessionID string, tokenID string) (res *sessionEntity.TokenRevocation, err error) {
+	return &sessionEntity.TokenRevocation{RevokedBefore: f.revokedBefore}, nil
+}
+
+type fakeAuditController struct {
+	auditController.AuditControllerItf
+	actions []string
+}
+
+func (f *fakeAuditController) Record(ctx context.Context, event *auditEntity.Event, err error) {
+	f.actions = append(f.actions, event.Action)
+}
+
+func TestSessionController_RotateRefreshToken(t *testing.T) {
+	tests := []struct {
+		name              string
+		rotation          int
+		wantErr           error
+		wantFamilyRevoked bool
+		wantActions       []string
+	}{
+		{
+			name:     "success",
+			rotation: sessionEntity.RefreshTokenRotated,
+		},
+		{
+			name:              "failed_token_already_used",
+			rotation:          sessionEntity.RefreshTokenAlreadyUsed,
+			wantErr:           ErrorRefreshTokenReused,
+			wantFamilyRevoked: true,
+			wantActions:       []string{"security.refresh_token_reuse"},
+		},
+		{
+			name:     "failed_session_revoked_concurrently",
+			rotation: sessionEntity.RefreshTokenSessionRevoked,
+			wantErr:  ErrorInvalidRefreshToken,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repository := &fakeSessionRepository{rotation: tt.rotation}
+			audit := &fakeAuditController{}
+			controller := NewSessionController(SessionController{
+				SessionRepository: repository,
+				AuditController:   audit,
+			})
+
+			err := controller.RotateRefreshToken(context.Background(), 1, "session-1", "current", "new", sessionEntity.ClientInfo{})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("RotateRefreshToken() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if (len(repository.revoked) == 1) != tt.wantFamilyRevoked {
+				t.Errorf("RotateRefreshToken() revoked family = %v, want %v", len(repository.revoked) == 1, tt.wantFamilyRevoked)
+			}
+
+			if !reflect.DeepEqual(audit.actions, tt.wantActions) {
+				t.Errorf("RotateRefreshToken() audit actions = %v, want %v", audit.actions, tt.wantActions)
+			}
+		})
+	}
+}
+
+func TestSessionController_IsAccessTokenRevoked(t *testing.T) {
+	repository := &fakeSessionRepository{}
+	controller := NewSessionController(SessionController{
+		SessionRepository: repository,
+	})
+
+	issuedBefore := uuid.Must(uuid.NewV7())
+	time.Sleep(2 * time.Millisecond)
+
+	err := controller.RevokeUserTokens(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("RevokeUserTokens() error = %v", err)
+	}
+
+	time.Sleep(2 * time.Millisecond)
+	issuedAfter := uuid.Must(uuid.NewV7())
+	cutoff := time.UnixMilli(repository.revokedBefore)
+
+	tests := []struct {
+		name        string
+		claims      *helpers.JWTClaims
+		wantRevoked bool
+	}{
+		{
+			name:        "success_issued_before_cutoff_is_revoked",
+			claims:      &helpers.JWTClaims{ID: 1, StandardClaims: jwt.StandardClaims{Id: issuedBefore.String(), IssuedAt: cutoff.Unix()}},
+			wantRevoked: true,
+		},
+		{
+			name:   "success_issued_in_the_same_second_after_cutoff_is_valid",
+			claims: &helpers.JWTClaims{ID: 1, StandardClaims: jwt.StandardClaims{Id: issuedAfter.String(), IssuedAt: cutoff.Unix()}},
+		},
+		{
+			name:        "success_token_without_time_in_jti_falls_back_to_iat",
+			claims:      &helpers.JWTClaims{ID: 1, StandardClaims: jwt.StandardClaims{Id: uuid.NewString(), IssuedAt: cutoff.Unix() - 1}},
+			wantRevoked: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			revoked, err := controller.IsAccessTokenRevoked(context.Background(), tt.claims)
+			if err != nil {
+				t.Fatalf("IsAccessTokenRevoked() error = %v", err)
+			}
+
+			if revoked != tt.wantRevoked {
+				t.Errorf("IsAccessTokenRevoked() = %v, want %v", revoked, tt.wantRevoked)
+			}
+		})
+	}
+}
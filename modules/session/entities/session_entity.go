@@ -0,0 +1,76 @@
+package entities
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"time"
+)
+
+const (
+	SecurityEventRefreshTokenReuse = "refresh_token_reuse"
+	SecurityEventAccountLocked     = "account_locked"
+	SecurityEventAccountUnlocked   = "account_unlocked"
+	SecurityEventIdentityLinked    = "identity_linked"
+	SecurityEventStepUp            = "step_up"
+	SecurityEventLoginDenied       = "login_denied"
+
+	maxUserAgentLength = 255
+)
+
+// outcomes of exchanging a refresh token
+const (
+	RefreshTokenRotated = iota + 1
+	RefreshTokenAlreadyUsed
+	RefreshTokenSessionRevoked
+)
+
+// Session is one signed-in device. The refresh tokens issued to the session form its token family.
+type Session struct {
+	ID              string     `json:"id"`
+	UserID          int64      `json:"user_id"`
+	UserAgent       string     `json:"user_agent"`
+	IPAddress       string     `json:"ip_address"`
+	IsRevoked       bool       `json:"is_revoked"`
+	RevokedAt       *time.Time `json:"revoked_at,omitempty"`
+	LastUsedAt      *time.Time `json:"last_used_at,omitempty"`
+	ExpiresAt       *time.Time `json:"expires_at,omitempty"`
+	AuthenticatedAt *time.Time `json:"authenticated_at,omitempty"`
+	CreatedAt       *time.Time `json:"created_at,omitempty"`
+	UpdatedAt       *time.Time `json:"updated_at,omitempty"`
+	IsCurrent       bool       `json:"is_current"`
+}
+
+// RefreshToken is one token of a session's family, only its hash is stored. A token is used once it has been
+// exchanged for a new one.
+type RefreshToken struct {
+	ID        int64      `json:"id"`
+	SessionID string     `json:"session_id"`
+	TokenHash string     `json:"-"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+}
+
+// TokenRevocation tells whether an access token was revoked directly, through its session, or because it was
+// issued before RevokedBefore, in unix milliseconds.
+type TokenRevocation struct {
+	SessionRevoked bool
+	TokenRevoked   bool
+	RevokedBefore  int64
+}
+
+type ClientInfo struct {
+	IPAddress string
+	UserAgent string
+}
+
+func NewClientInfo(ctx *fiber.Ctx) ClientInfo {
+	userAgent := ctx.Get(fiber.HeaderUserAgent)
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+
+	return ClientInfo{
+		IPAddress: ctx.IP(),
+		UserAgent: userAgent,
+	}
+}
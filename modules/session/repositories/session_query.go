@@ -0,0 +1,130 @@
+package repositories
+
+const (
+	InsertSessionDBQuery = `
+		INSERT INTO sessions 
+		    (
+				id,
+				user_id,
+				user_agent,
+				ip_address,
+				last_used_at,
+				expires_at,
+				authenticated_at,
+				created_at,
+				updated_at
+			) VALUES (
+						$1, -- id
+						$2, -- user_id
+						$3, -- user_agent
+						$4, -- ip_address
+						$5, -- last_used_at
+						$6, -- expires_at
+						$5, -- authenticated_at
+						$5, -- created_at
+						$5  -- updated_at
+					);
+	`
+
+	GetSessionQueryDB = `
+		SELECT 
+			id,
+			user_id,
+			COALESCE(user_agent, ''),
+			COALESCE(ip_address, ''),
+			is_revoked,
+			revoked_at,
+			last_used_at,
+			expires_at,
+			authenticated_at,
+			created_at,
+			updated_at
+		FROM sessions
+	`
+
+	UpdateSessionAuthenticatedAtDBQuery = `
+		UPDATE sessions 
+		SET 
+		    authenticated_at = $1,
+		    updated_at = $1
+		WHERE 
+		    id = $2 
+		  AND user_id = $3 
+		  AND is_revoked = FALSE;
+	`
+
+	UpdateSessionLastUsedDBQuery = `
+		UPDATE sessions 
+		SET 
+		    user_agent = $1,
+		    ip_address = $2,
+		    last_used_at = $3,
+		    expires_at = $4,
+		    updated_at = $3
+		WHERE 
+		    id = $5 
+		  AND is_revoked = FALSE;
+	`
+
+	RevokeSessionByIDDBQuery = `
+		UPDATE sessions 
+		SET 
+		    is_revoked = TRUE,
+		    revoked_at = $1,
+		    updated_at = $1
+		WHERE 
+		    id = $2 
+		  AND user_id = $3 
+		  AND is_revoked = FALSE;
+	`
+
+	RevokeSessionsByUserIDDBQuery = `
+		UPDATE sessions 
+		SET 
+		    is_revoked = TRUE,
+		    revoked_at = $1,
+		    updated_at = $1
+		WHERE 
+		    user_id = $2 
+		  AND id <> $3 
+		  AND is_revoked = FALSE
+		RETURNING id;
+	`
+
+	InsertRefreshTokenDBQuery = `
+		INSERT INTO refresh_tokens 
+		    (
+				session_id,
+				token_hash,
+				expires_at,
+				created_at
+			) VALUES (
+						$1, -- session_id
+						$2, -- token_hash
+						$3, -- expires_at
+						$4  -- created_at
+					);
+	`
+
+	GetRefreshTokenByHashDBQuery = `
+		SELECT 
+			id,
+			session_id,
+			token_hash,
+			used_at,
+			expires_at,
+			created_at
+		FROM refresh_tokens
+		WHERE 
+		    token_hash = $1;
+	`
+
+	MarkRefreshTokenUsedDBQuery = `
+		UPDATE refresh_tokens 
+		SET 
+		    used_at = $1
+		WHERE 
+		    id = $2 
+		  AND used_at = 0;
+	`
+)
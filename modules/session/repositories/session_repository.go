@@ -0,0 +1,381 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"github.com/winartodev/apollo/core/helpers"
+	sessionEntity "github.com/winartodev/apollo/modules/session/entities"
+	"strconv"
+	"time"
+)
+
+const (
+	revokedSessionPrefix      = "revoked_session"
+	revokedTokenPrefix        = "revoked_token"
+	tokensRevokedBeforePrefix = "tokens_revoked_before_ms"
+)
+
+type SessionRepositoryItf interface {
+	CreateSessionDB(ctx context.Context, data *sessionEntity.Session, refreshTokenHash string) (err error)
+	GetSessionByIDDB(ctx context.Context, id string) (res *sessionEntity.Session, err error)
+	GetActiveSessionsByUserIDDB(ctx context.Context, userID int64) (res []sessionEntity.Session, err error)
+	GetRefreshTokenByHashDB(ctx context.Context, tokenHash string) (res *sessionEntity.RefreshToken, err error)
+	RotateRefreshTokenDB(ctx context.Context, current *sessionEntity.RefreshToken, newHash string, client sessionEntity.ClientInfo, expiresAt time.Time) (result int, err error)
+	UpdateSessionAuthenticatedAtDB(ctx context.Context, userID int64, id string, authenticatedAt time.Time) (updated bool, err error)
+	RevokeSessionByIDDB(ctx context.Context, userID int64, id string) (revoked bool, err error)
+	RevokeSessionsByUserIDDB(ctx context.Context, userID int64, exceptID string) (ids []string, err error)
+	SetRevokedSessionsRedis(ctx context.Context, ids []string, ttl time.Duration) (err error)
+	SetRevokedTokenRedis(ctx context.Context, tokenID string, ttl time.Duration) (err error)
+	SetTokensRevokedBeforeRedis(ctx context.Context, userID int64, before time.Time, ttl time.Duration) (err error)
+	GetTokenRevocationRedis(ctx context.Context, userID int64, sessionID string, tokenID string) (res *sessionEntity.TokenRevocation, err error)
+}
+
+type SessionRepository struct {
+	DB    *sql.DB
+	Redis *redis.Client
+}
+
+func NewSessionRepository(repository SessionRepository) SessionRepositoryItf {
+	return &SessionRepository{
+		DB:    repository.DB,
+		Redis: repository.Redis,
+	}
+}
+
+// CreateSessionDB stores the session together with the first refresh token of its family.
+func (sr *SessionRepository) CreateSessionDB(ctx context.Context, data *sessionEntity.Session, refreshTokenHash string) (err error) {
+	createdAtUnix := data.CreatedAt.Unix()
+	expiresAtUnix := data.ExpiresAt.Unix()
+
+	tx, err := sr.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, InsertSessionDBQuery,
+		data.ID,
+		data.UserID,
+		data.UserAgent,
+		data.IPAddress,
+		createdAtUnix,
+		expiresAtUnix,
+	)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, InsertRefreshTokenDBQuery, data.ID, refreshTokenHash, expiresAtUnix, createdAtUnix)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (sr *SessionRepository) GetSessionByIDDB(ctx context.Context, id string) (res *sessionEntity.Session, err error) {
+	query := fmt.Sprintf("%s WHERE id = $1", GetSessionQueryDB)
+
+	var revokedAtUnix int64
+	var lastUsedAtUnix int64
+	var expiresAtUnix int64
+	var authenticatedAtUnix int64
+	var createdAtUnix int64
+	var updatedAtUnix int64
+
+	res = &sessionEntity.Session{}
+	err = sr.DB.QueryRowContext(ctx, query, id).
+		Scan(
+			&res.ID,
+			&res.UserID,
+			&res.UserAgent,
+			&res.IPAddress,
+			&res.IsRevoked,
+			&revokedAtUnix,
+			&lastUsedAtUnix,
+			&expiresAtUnix,
+			&authenticatedAtUnix,
+			&createdAtUnix,
+			&updatedAtUnix,
+		)
+	if err != nil {
+		return nil, err
+	}
+
+	res.RevokedAt = helpers.FormatUnixTime(revokedAtUnix)
+	res.LastUsedAt = helpers.FormatUnixTime(lastUsedAtUnix)
+	res.ExpiresAt = helpers.FormatUnixTime(expiresAtUnix)
+	res.AuthenticatedAt = helpers.FormatUnixTime(authenticatedAtUnix)
+	res.CreatedAt = helpers.FormatUnixTime(createdAtUnix)
+	res.UpdatedAt = helpers.FormatUnixTime(updatedAtUnix)
+
+	return res, nil
+}
+
+func (sr *SessionRepository) GetActiveSessionsByUserIDDB(ctx context.Context, userID int64) (res []sessionEntity.Session, err error) {
+	query := fmt.Sprintf("%s WHERE user_id = $1 AND is_revoked = FALSE AND expires_at > $2 ORDER BY last_used_at DESC", GetSessionQueryDB)
+
+	rows, err := sr.DB.QueryContext(ctx, query, userID, time.Now().Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res = []sessionEntity.Session{}
+	for rows.Next() {
+		var revokedAtUnix int64
+		var lastUsedAtUnix int64
+		var expiresAtUnix int64
+		var authenticatedAtUnix int64
+		var createdAtUnix int64
+		var updatedAtUnix int64
+
+		session := sessionEntity.Session{}
+		err = rows.Scan(
+			&session.ID,
+			&session.UserID,
+			&session.UserAgent,
+			&session.IPAddress,
+			&session.IsRevoked,
+			&revokedAtUnix,
+			&lastUsedAtUnix,
+			&expiresAtUnix,
+			&authenticatedAtUnix,
+			&createdAtUnix,
+			&updatedAtUnix,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		session.RevokedAt = helpers.FormatUnixTime(revokedAtUnix)
+		session.LastUsedAt = helpers.FormatUnixTime(lastUsedAtUnix)
+		session.ExpiresAt = helpers.FormatUnixTime(expiresAtUnix)
+		session.AuthenticatedAt = helpers.FormatUnixTime(authenticatedAtUnix)
+		session.CreatedAt = helpers.FormatUnixTime(createdAtUnix)
+		session.UpdatedAt = helpers.FormatUnixTime(updatedAtUnix)
+
+		res = append(res, session)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (sr *SessionRepository) GetRefreshTokenByHashDB(ctx context.Context, tokenHash string) (res *sessionEntity.RefreshToken, err error) {
+	var usedAtUnix int64
+	var expiresAtUnix int64
+	var createdAtUnix int64
+
+	res = &sessionEntity.RefreshToken{}
+	err = sr.DB.QueryRowContext(ctx, GetRefreshTokenByHashDBQuery, tokenHash).
+		Scan(
+			&res.ID,
+			&res.SessionID,
+			&res.TokenHash,
+			&usedAtUnix,
+			&expiresAtUnix,
+			&createdAtUnix,
+		)
+	if err != nil {
+		return nil, err
+	}
+
+	res.UsedAt = helpers.FormatUnixTime(usedAtUnix)
+	res.ExpiresAt = helpers.FormatUnixTime(expiresAtUnix)
+	res.CreatedAt = helpers.FormatUnixTime(createdAtUnix)
+
+	return res, nil
+}
+
+// RotateRefreshTokenDB marks current as used and adds newHash to the same family. Nothing is changed when current
+// was already used or the session was revoked in the meantime, so a token can only be exchanged once. The result
+// tells these apart from a rotation.
+func (sr *SessionRepository) RotateRefreshTokenDB(ctx context.Context, current *sessionEntity.RefreshToken, newHash string, client sessionEntity.ClientInfo, expiresAt time.Time) (result int, err error) {
+	now := time.Now().Unix()
+
+	tx, err := sr.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	execResult, err := tx.ExecContext(ctx, MarkRefreshTokenUsedDBQuery, now, current.ID)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	affected, err := execResult.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	if affected == 0 {
+		tx.Rollback()
+		return sessionEntity.RefreshTokenAlreadyUsed, nil
+	}
+
+	execResult, err = tx.ExecContext(ctx, UpdateSessionLastUsedDBQuery,
+		client.UserAgent,
+		client.IPAddress,
+		now,
+		expiresAt.Unix(),
+		current.SessionID,
+	)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	affected, err = execResult.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	if affected == 0 {
+		tx.Rollback()
+		return sessionEntity.RefreshTokenSessionRevoked, nil
+	}
+
+	_, err = tx.ExecContext(ctx, InsertRefreshTokenDBQuery, current.SessionID, newHash, expiresAt.Unix(), now)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+
+	return sessionEntity.RefreshTokenRotated, nil
+}
+
+func (sr *SessionRepository) UpdateSessionAuthenticatedAtDB(ctx context.Context, userID int64, id string, authenticatedAt time.Time) (updated bool, err error) {
+	result, err := sr.DB.ExecContext(ctx, UpdateSessionAuthenticatedAtDBQuery, authenticatedAt.Unix(), id, userID)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+func (sr *SessionRepository) RevokeSessionByIDDB(ctx context.Context, userID int64, id string) (revoked bool, err error) {
+	result, err := sr.DB.ExecContext(ctx, RevokeSessionByIDDBQuery, time.Now().Unix(), id, userID)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+// RevokeSessionsByUserIDDB revokes every session of the user except exceptID, an empty exceptID revokes all of them.
+// It returns the ids of the sessions that were revoked.
+func (sr *SessionRepository) RevokeSessionsByUserIDDB(ctx context.Context, userID int64, exceptID string) (ids []string, err error) {
+	rows, err := sr.DB.QueryContext(ctx, RevokeSessionsByUserIDDBQuery, time.Now().Unix(), userID, exceptID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id string
+		err = rows.Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, id)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+func (sr *SessionRepository) SetRevokedSessionsRedis(ctx context.Context, ids []string, ttl time.Duration) (err error) {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	pipe := sr.Redis.TxPipeline()
+	for _, id := range ids {
+		pipe.SetEX(ctx, fmt.Sprintf("%s:%s", revokedSessionPrefix, id), 1, ttl)
+	}
+
+	_, err = pipe.Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (sr *SessionRepository) SetRevokedTokenRedis(ctx context.Context, tokenID string, ttl time.Duration) (err error) {
+	err = sr.Redis.SetEX(ctx, fmt.Sprintf("%s:%s", revokedTokenPrefix, tokenID), 1, ttl).Err()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (sr *SessionRepository) SetTokensRevokedBeforeRedis(ctx context.Context, userID int64, before time.Time, ttl time.Duration) (err error) {
+	err = sr.Redis.SetEX(ctx, fmt.Sprintf("%s:%d", tokensRevokedBeforePrefix, userID), before.UnixMilli(), ttl).Err()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetTokenRevocationRedis reads the session denylist, the token denylist and the cutoff of the user in one round trip.
+func (sr *SessionRepository) GetTokenRevocationRedis(ctx context.Context, userID int64, sessionID string, tokenID string) (res *sessionEntity.TokenRevocation, err error) {
+	values, err := sr.Redis.MGet(ctx,
+		fmt.Sprintf("%s:%s", revokedSessionPrefix, sessionID),
+		fmt.Sprintf("%s:%s", revokedTokenPrefix, tokenID),
+		fmt.Sprintf("%s:%d", tokensRevokedBeforePrefix, userID),
+	).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	res = &sessionEntity.TokenRevocation{
+		SessionRevoked: sessionID != "" && values[0] != nil,
+		TokenRevoked:   tokenID != "" && values[1] != nil,
+	}
+
+	if before, ok := values[2].(string); ok {
+		res.RevokedBefore, err = strconv.ParseInt(before, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return res, nil
+}
@@ -5,38 +5,58 @@ import (
 	"database/sql"
 	"errors"
 	"github.com/google/uuid"
-	"github.com/winartodev/apollo/core"
 	"github.com/winartodev/apollo/core/helpers"
+	auditController "github.com/winartodev/apollo/modules/audit/controllers"
+	auditEntity "github.com/winartodev/apollo/modules/audit/entities"
+	sessionController "github.com/winartodev/apollo/modules/session/controllers"
 	userEntity "github.com/winartodev/apollo/modules/user/entities"
 	userRepo "github.com/winartodev/apollo/modules/user/repositories"
+	"strconv"
 	"time"
 )
 
+const (
+	maxUserAgentLength = 255
+)
+
 var (
-	ErrorUserNotFound = errors.New("user not found")
+	ErrorUserNotFound  = errors.New("user not found")
+	ErrorUserSuspended = errors.New("user account is suspended")
 )
 
 type UserControllerItf interface {
 	CreateUser(ctx context.Context, data userEntity.User) (res *userEntity.User, err error)
-	UpdateRefreshToken(ctx context.Context, force bool, id int64, refreshToken *string) (err error)
+	UpdatePassword(ctx context.Context, id int64, password string) (err error)
+	UpdateSuspension(ctx context.Context, id int64, suspended bool) (err error)
 	GetUserByID(ctx context.Context, id int64) (res *userEntity.User, err error)
 	GetUserByEmail(ctx context.Context, email string) (res *userEntity.User, err error)
+	GetUserByPhoneNumber(ctx context.Context, phoneNumber string) (res *userEntity.User, err error)
 	GetPasswordByEmail(ctx context.Context, email string) (res *string, err error)
-	GetRefreshTokenByID(ctx context.Context, id int64) (res *string, err error)
+	GetPasswordByID(ctx context.Context, id int64) (res *string, err error)
 	ValidateUserIsExists(ctx context.Context, data *userEntity.User) (err error)
+	RecordLogin(ctx context.Context, data *userEntity.LoginHistory) (err error)
+	GetLoginHistory(ctx context.Context, id int64, paginate *helpers.Paginate) (res []userEntity.LoginHistory, total int64, err error)
+	RecognizeLogin(ctx context.Context, data *userEntity.LoginHistory) (res *userEntity.LoginRecognition, err error)
 }
 
 type UserController struct {
-	UserRepository userRepo.UserRepositoryItf
+	UserRepository    userRepo.UserRepositoryItf
+	SessionController sessionController.SessionControllerItf
+	AuditController   auditController.AuditControllerItf
 }
 
 func NewUserController(controller UserController) UserControllerItf {
 	return &UserController{
-		UserRepository: controller.UserRepository,
+		UserRepository:    controller.UserRepository,
+		SessionController: controller.SessionController,
+		AuditController:   controller.AuditController,
 	}
 }
 
 func (uc *UserController) CreateUser(ctx context.Context, data userEntity.User) (res *userEntity.User, err error) {
+	event := &auditEntity.Event{Action: auditEntity.ActionUserCreate, TargetType: auditEntity.TargetEmail, TargetID: data.Email}
+	defer func() { uc.AuditController.Record(ctx, event, err) }()
+
 	now := time.Now()
 	data.CreatedAt = &now
 	data.UpdatedAt = &now
@@ -60,6 +80,7 @@ func (uc *UserController) CreateUser(ctx context.Context, data userEntity.User)
 	}
 
 	data.ID = id
+	event.UserID = id
 
 	return &data, err
 }
@@ -90,6 +111,19 @@ func (uc *UserController) GetUserByEmail(ctx context.Context, email string) (res
 	return res, nil
 }
 
+func (uc *UserController) GetUserByPhoneNumber(ctx context.Context, phoneNumber string) (res *userEntity.User, err error) {
+	res, err = uc.UserRepository.GetUserByPhoneNumberDB(ctx, phoneNumber)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	if res == nil {
+		return nil, ErrorUserNotFound
+	}
+
+	return res, nil
+}
+
 func (uc *UserController) GetPasswordByEmail(ctx context.Context, email string) (res *string, err error) {
 	res, err = uc.UserRepository.GetUserPasswordByEmailDB(ctx, email)
 	if err != nil && err != sql.ErrNoRows {
@@ -103,8 +137,8 @@ func (uc *UserController) GetPasswordByEmail(ctx context.Context, email string)
 	return res, nil
 }
 
-func (uc *UserController) GetRefreshTokenByID(ctx context.Context, id int64) (res *string, err error) {
-	res, err = uc.UserRepository.GetRefreshTokenByIDDB(ctx, id)
+func (uc *UserController) GetPasswordByID(ctx context.Context, id int64) (res *string, err error) {
+	res, err = uc.UserRepository.GetUserPasswordByIDDB(ctx, id)
 	if err != nil && err != sql.ErrNoRows {
 		return nil, err
 	}
@@ -116,24 +150,75 @@ func (uc *UserController) GetRefreshTokenByID(ctx context.Context, id int64) (re
 	return res, nil
 }
 
-func (uc *UserController) UpdateRefreshToken(ctx context.Context, force bool, id int64, refreshToken *string) (err error) {
-	if !force {
-		exists, err := uc.UserRepository.IsRefreshTokenExistByIDDB(ctx, id)
-		if err != nil {
-			return err
-		}
+func (uc *UserController) UpdatePassword(ctx context.Context, id int64, password string) (err error) {
+	passwordHash, err := helpers.HashPassword(password)
+	if err != nil {
+		return err
+	}
 
-		if exists {
-			return core.ErrRefreshTokenExists
-		}
+	return uc.UserRepository.UpdatePasswordByIDDB(ctx, id, &passwordHash)
+}
+
+// UpdateSuspension suspends or reinstates a user. Suspending also signs the user out of every device and
+// revokes the access tokens that were already issued.
+func (uc *UserController) UpdateSuspension(ctx context.Context, id int64, suspended bool) (err error) {
+	event := &auditEntity.Event{Action: auditEntity.ActionUserReinstate, TargetType: auditEntity.TargetUser, TargetID: strconv.FormatInt(id, 10), UserID: id}
+	if suspended {
+		event.Action = auditEntity.ActionUserSuspend
 	}
 
-	err = uc.UserRepository.UpdateRefreshTokenByIDDB(ctx, id, refreshToken)
+	defer func() { uc.AuditController.Record(ctx, event, err) }()
+
+	updated, err := uc.UserRepository.UpdateSuspensionByIDDB(ctx, id, suspended)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	if !updated {
+		return ErrorUserNotFound
+	}
+
+	if !suspended {
+		return nil
+	}
+
+	err = uc.SessionController.RevokeAllSessions(ctx, id, "")
+	if err != nil {
+		return err
+	}
+
+	return uc.SessionController.RevokeUserTokens(ctx, id)
+}
+
+// RecordLogin adds a sign in attempt to the login history of the user, a successful one updates last_login.
+func (uc *UserController) RecordLogin(ctx context.Context, data *userEntity.LoginHistory) (err error) {
+	now := time.Now()
+	data.CreatedAt = &now
+
+	if len(data.UserAgent) > maxUserAgentLength {
+		data.UserAgent = data.UserAgent[:maxUserAgentLength]
+	}
+
+	data.ID, err = uc.UserRepository.CreateLoginHistoryDB(ctx, data, data.Outcome == userEntity.LoginOutcomeSuccess)
+	return err
+}
+
+// GetLoginHistory reads a page of the login history of the user, newest first unless paginate asks otherwise.
+func (uc *UserController) GetLoginHistory(ctx context.Context, id int64, paginate *helpers.Paginate) (res []userEntity.LoginHistory, total int64, err error) {
+	if paginate.SortBy == nil || *paginate.SortBy == "" {
+		sort := "desc"
+		paginate.SortBy = &sort
+	}
+
+	paginate.Validate()
+
+	return uc.UserRepository.GetLoginHistoryDB(ctx, id, paginate)
+}
+
+// RecognizeLogin tells whether the device and network of a sign in attempt were used by previous successful sign
+// ins of the user, it has to be asked before the attempt is recorded.
+func (uc *UserController) RecognizeLogin(ctx context.Context, data *userEntity.LoginHistory) (res *userEntity.LoginRecognition, err error) {
+	return uc.UserRepository.GetLoginRecognitionDB(ctx, data.UserID, data.DeviceID, data.IPRange)
 }
 
 func (uc *UserController) ValidateUserIsExists(ctx context.Context, data *userEntity.User) (err error) {
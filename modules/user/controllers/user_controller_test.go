@@ -0,0 +1,182 @@
+package controllers
+
+import (
+	"context"
+	"github.com/winartodev/apollo/core/helpers"
+	"github.com/winartodev/apollo/core/responses"
+	userEntity "github.com/winartodev/apollo/modules/user/entities"
+	userRepo "github.com/winartodev/apollo/modules/user/repositories"
+	"testing"
+	"time"
+)
+
+// fakeUserRepository keeps the login history of a single user in insertion order, ids start at 1.
+type fakeUserRepository struct {
+	userRepo.UserRepositoryItf
+	logins    []userEntity.LoginHistory
+	lastLogin *time.Time
+}
+
+func (f *fakeUserRepository) CreateLoginHistoryDB(ctx context.Context, data *userEntity.LoginHistory, updateLastLogin bool) (id int64, err error) {
+	data.ID = int64(len(f.logins) + 1)
+	f.logins = append(f.logins, *data)
+
+	if updateLastLogin {
+		f.lastLogin = data.CreatedAt
+	}
+
+	return data.ID, nil
+}
+
+func (f *fakeUserRepository) GetLoginHistoryDB(ctx context.Context, userID int64, paginate *helpers.Paginate) (res []userEntity.LoginHistory, total int64, err error) {
+	ordered := make([]userEntity.LoginHistory, 0, len(f.logins))
+	for i := range f.logins {
+		if *paginate.SortBy == "desc" {
+			ordered = append(ordered, f.logins[len(f.logins)-1-i])
+		} else {
+			ordered = append(ordered, f.logins[i])
+		}
+	}
+
+	offset := paginate.CalculateOffset(paginate.Offset, paginate.Limit)
+	end := min(offset+*paginate.Limit, int64(len(ordered)))
+	if offset >= end {
+		return []userEntity.LoginHistory{}, int64(len(ordered)), nil
+	}
+
+	return ordered[offset:end], int64(len(ordered)), nil
+}
+
+func TestUserController_RecordLogin(t *testing.T) {
+	tests := []struct {
+		name          string
+		outcome       string
+		wantLastLogin bool
+	}{
+		{
+			name:          "success_updates_last_login",
+			outcome:       userEntity.LoginOutcomeSuccess,
+			wantLastLogin: true,
+		},
+		{
+			name:    "success_failure_keeps_last_login",
+			outcome: userEntity.LoginOutcomeFailure,
+		},
+		{
+			name:    "success_mfa_required_keeps_last_login",
+			outcome: userEntity.LoginOutcomeMFARequired,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repository := &fakeUserRepository{}
+			controller := NewUserController(UserController{UserRepository: repository})
+
+			err := controller.RecordLogin(context.Background(), &userEntity.LoginHistory{UserID: 1, Outcome: tt.outcome})
+			if err != nil {
+				t.Fatalf("RecordLogin() error = %v", err)
+			}
+
+			if len(repository.logins) != 1 {
+				t.Fatalf("RecordLogin() recorded %d logins, want 1", len(repository.logins))
+			}
+
+			if (repository.lastLogin != nil) != tt.wantLastLogin {
+				t.Errorf("RecordLogin() updated last login = %v, want %v", repository.lastLogin != nil, tt.wantLastLogin)
+			}
+		})
+	}
+}
+
+func TestUserController_GetLoginHistory(t *testing.T) {
+	const link = "/api/v1/users/me/login-history"
+
+	type want struct {
+		firstID  int64
+		count    int
+		first    string
+		last     string
+		next     string
+		previous string
+	}
+
+	tests := []struct {
+		name     string
+		link     string
+		paginate *helpers.Paginate
+		want     want
+	}{
+		{
+			name:     "success_newest_first_by_default",
+			link:     link,
+			paginate: &helpers.Paginate{},
+			want: want{
+				firstID: 25,
+				count:   10,
+				first:   link + "?page=1&limit=10",
+				last:    link + "?page=3&limit=10",
+				next:    link + "?page=2&limit=10",
+			},
+		},
+		{
+			name:     "success_middle_page_ascending",
+			link:     link + "?sort=asc",
+			paginate: &helpers.Paginate{Offset: int64Pointer(2), Limit: int64Pointer(10), SortBy: stringPointer("asc")},
+			want: want{
+				firstID:  11,
+				count:    10,
+				first:    link + "?sort=asc&page=1&limit=10",
+				last:     link + "?sort=asc&page=3&limit=10",
+				next:     link + "?sort=asc&page=3&limit=10",
+				previous: link + "?sort=asc&page=1&limit=10",
+			},
+		},
+		{
+			name:     "success_last_page",
+			link:     link,
+			paginate: &helpers.Paginate{Offset: int64Pointer(3), Limit: int64Pointer(10)},
+			want: want{
+				firstID:  5,
+				count:    5,
+				first:    link + "?page=1&limit=10",
+				last:     link + "?page=3&limit=10",
+				previous: link + "?page=2&limit=10",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repository := &fakeUserRepository{}
+			controller := NewUserController(UserController{UserRepository: repository})
+
+			for i := 0; i < 25; i++ {
+				err := controller.RecordLogin(context.Background(), &userEntity.LoginHistory{UserID: 1, Outcome: userEntity.LoginOutcomeSuccess})
+				if err != nil {
+					t.Fatalf("RecordLogin() error = %v", err)
+				}
+			}
+
+			res, total, err := controller.GetLoginHistory(context.Background(), 1, tt.paginate)
+			if err != nil {
+				t.Fatalf("GetLoginHistory() error = %v", err)
+			}
+
+			if total != 25 || len(res) != tt.want.count || res[0].ID != tt.want.firstID {
+				t.Fatalf("GetLoginHistory() = %d logins starting at %d of %d, want %d starting at %d of 25", len(res), res[0].ID, total, tt.want.count, tt.want.firstID)
+			}
+
+			links := responses.BuildPaginate(total, tt.link, tt.paginate).Links
+			if links.First != tt.want.first || links.Last != tt.want.last || links.Next != tt.want.next || links.Previous != tt.want.previous {
+				t.Errorf("BuildPaginate() links = %+v, want first %q last %q next %q previous %q", links, tt.want.first, tt.want.last, tt.want.next, tt.want.previous)
+			}
+		})
+	}
+}
+
+func int64Pointer(value int64) *int64 {
+	return &value
+}
+
+func stringPointer(value string) *string {
+	return &value
+}
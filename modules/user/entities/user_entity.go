@@ -2,6 +2,12 @@ package entities
 
 import "time"
 
+const (
+	LoginOutcomeSuccess     = "success"
+	LoginOutcomeFailure     = "failure"
+	LoginOutcomeMFARequired = "mfa_required"
+)
+
 type User struct {
 	ID              int64      `json:"id"`
 	UUID            string     `json:"uuid"`
@@ -12,14 +18,39 @@ type User struct {
 	LastName        string     `json:"last_name"`
 	ProfilePicture  string     `json:"profile_picture"`
 	Password        *string    `json:"password,omitempty"`
-	RefreshToken    *string    `json:"refresh_token,omitempty"`
 	IsEmailVerified bool       `json:"is_email_verified"`
 	IsPhoneVerified bool       `json:"is_phone_verified"`
+	IsSuspended     bool       `json:"is_suspended"`
+	Roles           []string   `json:"roles,omitempty"`
 	LastLogin       *time.Time `json:"last_login,omitempty"`
 	CreatedAt       *time.Time `json:"created_at,omitempty"`
 	UpdatedAt       *time.Time `json:"updated_at,omitempty"`
 }
 
+// LoginHistory is one sign in attempt of a user. A sign in waiting for the second factor is recorded as
+// mfa_required, its completion as a separate mfa attempt. DeviceID and IPRange identify the device and network
+// the attempt came from so new ones can be recognized.
+type LoginHistory struct {
+	ID        int64      `json:"id"`
+	UserID    int64      `json:"user_id"`
+	Method    string     `json:"method"`
+	Outcome   string     `json:"outcome"`
+	IPAddress string     `json:"ip_address"`
+	UserAgent string     `json:"user_agent"`
+	DeviceID  string     `json:"device_id,omitempty"`
+	IPRange   string     `json:"-"`
+	Location  string     `json:"location,omitempty"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+}
+
+// LoginRecognition tells whether the device and network of a sign in were used by a previous successful sign in
+// of the user. HasHistory is false until the user signed in successfully from an identified device.
+type LoginRecognition struct {
+	HasHistory   bool
+	KnownDevice  bool
+	KnownIPRange bool
+}
+
 type UserUniqueField struct {
 	Email       string
 	PhoneNumber string
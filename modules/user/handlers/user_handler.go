@@ -2,12 +2,16 @@ package handlers
 
 import (
 	"errors"
+	"fmt"
 	"github.com/gofiber/fiber/v2"
 	"github.com/winartodev/apollo/core"
 	"github.com/winartodev/apollo/core/helpers"
 	"github.com/winartodev/apollo/core/middlewares"
 	"github.com/winartodev/apollo/core/responses"
+	sessionController "github.com/winartodev/apollo/modules/session/controllers"
 	userControler "github.com/winartodev/apollo/modules/user/controllers"
+	"net/url"
+	"strconv"
 )
 
 var (
@@ -16,13 +20,15 @@ var (
 
 type UserHandler struct {
 	middlewares.Middleware
-	UserController userControler.UserControllerItf
+	UserController    userControler.UserControllerItf
+	SessionController sessionController.SessionControllerItf
 }
 
 func NewUserHandler(handler UserHandler) UserHandler {
 	return UserHandler{
-		Middleware:     handler.Middleware,
-		UserController: handler.UserController,
+		Middleware:        handler.Middleware,
+		UserController:    handler.UserController,
+		SessionController: handler.SessionController,
 	}
 }
 
@@ -41,11 +47,147 @@ func (h *UserHandler) GetCurrentUser(ctx *fiber.Ctx) error {
 	return responses.SuccessResponse(ctx, fiber.StatusOK, "Success Get Current User", res, nil)
 }
 
+// GetUser returns any user by id to users and service clients holding the users:read permission.
+func (h *UserHandler) GetUser(ctx *fiber.Ctx) error {
+	context := ctx.Context()
+
+	id, err := strconv.ParseInt(ctx.Params("id"), 10, 64)
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusBadRequest, "Failed Get User", err)
+	}
+
+	res, err := h.UserController.GetUserByID(context, id)
+	if errors.Is(err, userControler.ErrorUserNotFound) {
+		return responses.FailedResponse(ctx, fiber.StatusNotFound, "Failed Get User", err)
+	}
+
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusInternalServerError, "Failed Get User", err)
+	}
+
+	return responses.SuccessResponse(ctx, fiber.StatusOK, "Success Get User", res, nil)
+}
+
+func (h *UserHandler) GetSessions(ctx *fiber.Ctx) error {
+	context := ctx.Context()
+	id, err := helpers.GetUserIDFromContext(ctx)
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusBadRequest, "Failed Get Sessions", userNotLoggedIn)
+	}
+
+	sessionID, _ := helpers.GetSessionIDFromContext(ctx)
+
+	res, err := h.SessionController.GetActiveSessions(context, id, sessionID)
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusInternalServerError, "Failed Get Sessions", err)
+	}
+
+	return responses.SuccessResponse(ctx, fiber.StatusOK, "Success Get Sessions", res, nil)
+}
+
+// GetLoginHistory pages through the sign in attempts of the current user, newest first unless sort=asc.
+func (h *UserHandler) GetLoginHistory(ctx *fiber.Ctx) error {
+	context := ctx.Context()
+	id, err := helpers.GetUserIDFromContext(ctx)
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusBadRequest, "Failed Get Login History", userNotLoggedIn)
+	}
+
+	paginate := helpers.NewPaginateFromQuery(ctx)
+
+	res, total, err := h.UserController.GetLoginHistory(context, id, paginate)
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusInternalServerError, "Failed Get Login History", err)
+	}
+
+	link := ctx.Path()
+	if sort := ctx.Query("sort"); sort != "" {
+		link = fmt.Sprintf("%s?%s", link, url.Values{"sort": {sort}}.Encode())
+	}
+
+	return responses.SuccessResponse(ctx, fiber.StatusOK, "Success Get Login History", res, responses.BuildPaginate(total, link, paginate))
+}
+
+func (h *UserHandler) RevokeSession(ctx *fiber.Ctx) error {
+	context := ctx.Context()
+	id, err := helpers.GetUserIDFromContext(ctx)
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusBadRequest, "Failed Revoke Session", userNotLoggedIn)
+	}
+
+	err = h.SessionController.RevokeSession(context, id, ctx.Params("id"))
+	if errors.Is(err, sessionController.ErrorSessionNotFound) {
+		return responses.FailedResponse(ctx, fiber.StatusNotFound, "Failed Revoke Session", err)
+	}
+
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusInternalServerError, "Failed Revoke Session", err)
+	}
+
+	return responses.SuccessResponse(ctx, fiber.StatusOK, "Success", "session revoked successfully", nil)
+}
+
+// RevokeOtherSessions signs the user out of every device except the one making the request.
+func (h *UserHandler) RevokeOtherSessions(ctx *fiber.Ctx) error {
+	context := ctx.Context()
+	id, err := helpers.GetUserIDFromContext(ctx)
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusBadRequest, "Failed Revoke Sessions", userNotLoggedIn)
+	}
+
+	sessionID, _ := helpers.GetSessionIDFromContext(ctx)
+
+	err = h.SessionController.RevokeAllSessions(context, id, sessionID)
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusInternalServerError, "Failed Revoke Sessions", err)
+	}
+
+	return responses.SuccessResponse(ctx, fiber.StatusOK, "Success", "other sessions revoked successfully", nil)
+}
+
+func (h *UserHandler) SuspendUser(ctx *fiber.Ctx) error {
+	return h.updateSuspension(ctx, true)
+}
+
+func (h *UserHandler) UnsuspendUser(ctx *fiber.Ctx) error {
+	return h.updateSuspension(ctx, false)
+}
+
+func (h *UserHandler) updateSuspension(ctx *fiber.Ctx, suspended bool) error {
+	context := ctx.Context()
+
+	id, err := strconv.ParseInt(ctx.Params("id"), 10, 64)
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusBadRequest, "Failed Update Suspension", err)
+	}
+
+	err = h.UserController.UpdateSuspension(context, id, suspended)
+	if errors.Is(err, userControler.ErrorUserNotFound) {
+		return responses.FailedResponse(ctx, fiber.StatusNotFound, "Failed Update Suspension", err)
+	}
+
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusInternalServerError, "Failed Update Suspension", err)
+	}
+
+	return responses.SuccessResponse(ctx, fiber.StatusOK, "Success", "user suspension updated successfully", nil)
+}
+
 func (h *UserHandler) Register(router fiber.Router) error {
 	v1 := router.Group(core.V1)
 	internal := v1.Group(core.AccessInternal)
-	user := internal.Group("/users", h.HandleInternalAccess())
-	user.Get("/me", h.GetCurrentUser)
+	me := internal.Group("/users/me", h.HandleInternalAccess())
+	me.Get("", h.GetCurrentUser)
+	me.Get("/sessions", h.GetSessions)
+	me.Delete("/sessions", h.RevokeOtherSessions)
+	me.Delete("/sessions/:id", h.RevokeSession)
+	me.Get("/login-history", h.GetLoginHistory)
+
+	internal.Get("/users/:id", h.RequirePermission(core.PermissionUsersRead), h.GetUser)
+
+	admin := internal.Group("/admin", h.HandleAdminAccess())
+	admin.Post("/users/:id/suspend", h.SuspendUser)
+	admin.Post("/users/:id/unsuspend", h.UnsuspendUser)
 
 	return nil
 }
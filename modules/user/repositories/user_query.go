@@ -12,7 +12,6 @@ const (
 				 last_name,
 				 profile_picture,
 				 password,
-				 refresh_token,
 				 is_email_verified,
 				 is_phone_verified,
 				 created_at,
@@ -20,17 +19,16 @@ const (
 			) VALUES (
 						$1,  -- uuid
 						$2,  -- email
-						$3,  -- phone_number
+						NULLIF($3, ''),  -- phone_number
 						$4,  -- username
 						$5,  -- first_name
 						$6,  -- last_name
 						$7,  -- profile_picture
 						$8,  -- password
-						$9,  -- refresh_token
-						$10, -- is_email_verified
-						$11, -- is_phone_verified
-						$12, -- created_at
-						$13  -- updated_at 
+						$9,  -- is_email_verified
+						$10, -- is_phone_verified
+						$11, -- created_at
+						$12  -- updated_at 
 					) 
 			  RETURNING id;
 	`
@@ -40,14 +38,14 @@ const (
 		    password 
 		FROM users 
 		WHERE 
-		    email = $1;
+		    LOWER(email) = LOWER($1);
 	`
 
-	GetRefreshTokenByIDDBQuery = `
-		Select 
-			refresh_token
-		FROM users
-		where 
+	GetUserPasswordByIDDBQuery = `
+		SELECT 
+		    password 
+		FROM users 
+		WHERE 
 		    id = $1;
 	`
 
@@ -56,35 +54,104 @@ const (
 			id,
 			uuid, 
 			email, 
-			phone_number,
+			COALESCE(phone_number, ''),
 			username,
 			first_name,
 			last_name,
 			profile_picture,
 			is_email_verified,
 			is_phone_verified,
+			is_suspended,
 			last_login,
 			created_at,
 			updated_at
 		FROM users
 	`
 
-	UpdateRefreshTokenByIDDBQuery = `
+	UpdatePasswordByIDDBQuery = `
+		UPDATE users 
+		SET 
+		    password = $1,
+		    updated_at = $2
+		WHERE 
+		    id = $3;
+	`
+
+	UpdateSuspensionByIDDBQuery = `
+		UPDATE users 
+		SET 
+		    is_suspended = $1,
+		    updated_at = $2
+		WHERE 
+		    id = $3;
+	`
+
+	InsertLoginHistoryDBQuery = `
+		INSERT INTO login_history
+		    (
+				user_id,
+				method,
+				outcome,
+				ip_address,
+				user_agent,
+				device_id,
+				ip_range,
+				location,
+				created_at
+			) VALUES (
+						$1, -- user_id
+						$2, -- method
+						$3, -- outcome
+						NULLIF($4, ''), -- ip_address
+						NULLIF($5, ''), -- user_agent
+						NULLIF($6, ''), -- device_id
+						NULLIF($7, ''), -- ip_range
+						NULLIF($8, ''), -- location
+						$9  -- created_at
+					)
+			  RETURNING id;
+	`
+
+	UpdateLastLoginByIDDBQuery = `
 		UPDATE users 
 		SET 
-		    refresh_token = $1 
+		    last_login = $1
 		WHERE 
 		    id = $2;
 	`
 
-	IsRefreshTokenIsExistsDBQuery = `
-		SELECT EXISTS ( SELECT 1 FROM users WHERE id = $1 AND (refresh_token <> '' or refresh_token IS NOT NULL)) AS refresh_token_exists
+	GetLoginHistoryDBQuery = `
+		SELECT
+			id,
+			user_id,
+			method,
+			outcome,
+			COALESCE(ip_address, ''),
+			COALESCE(user_agent, ''),
+			COALESCE(device_id, ''),
+			COALESCE(ip_range, ''),
+			COALESCE(location, ''),
+			created_at
+		FROM login_history
+		WHERE 
+		    user_id = $1
+	`
+
+	GetLoginRecognitionDBQuery = `
+		SELECT
+			EXISTS (SELECT 1 FROM login_history WHERE user_id = $1 AND outcome = 'success' AND device_id IS NOT NULL) AS has_history,
+			EXISTS (SELECT 1 FROM login_history WHERE user_id = $1 AND outcome = 'success' AND device_id = $2) AS known_device,
+			EXISTS (SELECT 1 FROM login_history WHERE user_id = $1 AND outcome = 'success' AND ip_range = $3) AS known_ip_range
+	`
+
+	CountLoginHistoryDBQuery = `
+		SELECT COUNT(*) FROM login_history WHERE user_id = $1
 	`
 
 	IsUserExistDBQuery = `
 		SELECT
 			EXISTS (SELECT 1 FROM users WHERE username = $1) AS username_is_exists,
-			EXISTS (SELECT 1 FROM users WHERE email = $2) as email_is_exists,
+			EXISTS (SELECT 1 FROM users WHERE LOWER(email) = LOWER($2)) as email_is_exists,
 			EXISTS (SELECT 1 FROM users WHERE phone_number = $3) as phone_number_is_exists
 	`
 )
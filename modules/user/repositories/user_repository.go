@@ -6,18 +6,23 @@ import (
 	"fmt"
 	"github.com/winartodev/apollo/core/helpers"
 	"github.com/winartodev/apollo/modules/user/entities"
+	"strings"
+	"time"
 )
 
 type UserRepositoryItf interface {
 	CreateUserDB(ctx context.Context, user *entities.User) (id int64, err error)
-	UpdateRefreshTokenByIDDB(ctx context.Context, id int64, refreshToken *string) (err error)
 	UpdatePasswordByIDDB(ctx context.Context, id int64, password *string) error
+	UpdateSuspensionByIDDB(ctx context.Context, id int64, suspended bool) (updated bool, err error)
 	GetUserByIDDB(ctx context.Context, id int64) (res *entities.User, err error)
 	GetUserByEmailDB(ctx context.Context, email string) (res *entities.User, err error)
-	GetRefreshTokenByIDDB(ctx context.Context, id int64) (res *string, err error)
+	GetUserByPhoneNumberDB(ctx context.Context, phoneNumber string) (res *entities.User, err error)
 	GetUserPasswordByEmailDB(ctx context.Context, email string) (res *string, err error)
-	IsRefreshTokenExistByIDDB(ctx context.Context, id int64) (exists bool, err error)
+	GetUserPasswordByIDDB(ctx context.Context, id int64) (res *string, err error)
 	IsUserExistsDB(ctx context.Context, data *entities.UserUniqueField) (res *entities.UserUniqueFieldExists, err error)
+	CreateLoginHistoryDB(ctx context.Context, data *entities.LoginHistory, updateLastLogin bool) (id int64, err error)
+	GetLoginHistoryDB(ctx context.Context, userID int64, paginate *helpers.Paginate) (res []entities.LoginHistory, total int64, err error)
+	GetLoginRecognitionDB(ctx context.Context, userID int64, deviceID string, ipRange string) (res *entities.LoginRecognition, err error)
 }
 
 type UserRepository struct {
@@ -54,7 +59,6 @@ func (ur *UserRepository) CreateUserDB(ctx context.Context, user *entities.User)
 		user.LastName,
 		user.ProfilePicture,
 		user.Password,
-		user.RefreshToken,
 		user.IsEmailVerified,
 		user.IsPhoneVerified,
 		createdAtUnix,
@@ -93,6 +97,7 @@ func (ur *UserRepository) GetUserByIDDB(ctx context.Context, id int64) (res *ent
 			&res.ProfilePicture,
 			&res.IsEmailVerified,
 			&res.IsPhoneVerified,
+			&res.IsSuspended,
 			&lastLoginUnix,
 			&createdAtUnix,
 			&updatedAtUnix,
@@ -110,7 +115,7 @@ func (ur *UserRepository) GetUserByIDDB(ctx context.Context, id int64) (res *ent
 }
 
 func (ur *UserRepository) GetUserByEmailDB(ctx context.Context, email string) (res *entities.User, err error) {
-	query := fmt.Sprintf("%s WHERE email = $1", GetUserQueryDB)
+	query := fmt.Sprintf("%s WHERE LOWER(email) = LOWER($1)", GetUserQueryDB)
 
 	var lastLoginUnix int64
 	var createdAtUnix int64
@@ -131,6 +136,7 @@ func (ur *UserRepository) GetUserByEmailDB(ctx context.Context, email string) (r
 		&res.ProfilePicture,
 		&res.IsEmailVerified,
 		&res.IsPhoneVerified,
+		&res.IsSuspended,
 		&lastLoginUnix,
 		&createdAtUnix,
 		&updatedAtUnix,
@@ -146,19 +152,57 @@ func (ur *UserRepository) GetUserByEmailDB(ctx context.Context, email string) (r
 	return res, nil
 }
 
-func (ur *UserRepository) UpdateRefreshTokenByIDDB(ctx context.Context, id int64, refreshToken *string) (err error) {
+func (ur *UserRepository) GetUserByPhoneNumberDB(ctx context.Context, phoneNumber string) (res *entities.User, err error) {
+	query := fmt.Sprintf("%s WHERE phone_number = $1", GetUserQueryDB)
+
+	var lastLoginUnix int64
+	var createdAtUnix int64
+	var updatedAtUnix int64
+
+	res = &entities.User{}
+	err = ur.DB.QueryRowContext(ctx,
+		query,
+		phoneNumber,
+	).Scan(
+		&res.ID,
+		&res.UUID,
+		&res.Email,
+		&res.PhoneNumber,
+		&res.Username,
+		&res.FirstName,
+		&res.LastName,
+		&res.ProfilePicture,
+		&res.IsEmailVerified,
+		&res.IsPhoneVerified,
+		&res.IsSuspended,
+		&lastLoginUnix,
+		&createdAtUnix,
+		&updatedAtUnix,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	res.LastLogin = helpers.FormatUnixTime(lastLoginUnix)
+	res.CreatedAt = helpers.FormatUnixTime(createdAtUnix)
+	res.UpdatedAt = helpers.FormatUnixTime(updatedAtUnix)
+
+	return res, nil
+}
+
+func (ur *UserRepository) UpdatePasswordByIDDB(ctx context.Context, id int64, password *string) error {
 	tx, err := ur.DB.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 
-	stmt, err := tx.PrepareContext(ctx, UpdateRefreshTokenByIDDBQuery)
+	stmt, err := tx.PrepareContext(ctx, UpdatePasswordByIDDBQuery)
 	if err != nil {
 		tx.Rollback()
 		return err
 	}
 
-	_, err = stmt.ExecContext(ctx, refreshToken, id)
+	_, err = stmt.ExecContext(ctx, password, time.Now().Unix(), id)
 	if err != nil {
 		tx.Rollback()
 		return err
@@ -172,19 +216,18 @@ func (ur *UserRepository) UpdateRefreshTokenByIDDB(ctx context.Context, id int64
 	return nil
 }
 
-func (ur *UserRepository) UpdatePasswordByIDDB(ctx context.Context, id int64, password *string) error {
-	return nil
-}
+func (ur *UserRepository) UpdateSuspensionByIDDB(ctx context.Context, id int64, suspended bool) (updated bool, err error) {
+	result, err := ur.DB.ExecContext(ctx, UpdateSuspensionByIDDBQuery, suspended, time.Now().Unix(), id)
+	if err != nil {
+		return false, err
+	}
 
-func (ur *UserRepository) GetRefreshTokenByIDDB(ctx context.Context, id int64) (res *string, err error) {
-	err = ur.DB.QueryRowContext(ctx, GetRefreshTokenByIDDBQuery,
-		id,
-	).Scan(&res)
+	affected, err := result.RowsAffected()
 	if err != nil {
-		return nil, err
+		return false, err
 	}
 
-	return res, err
+	return affected > 0, nil
 }
 
 func (ur *UserRepository) GetUserPasswordByEmailDB(ctx context.Context, email string) (res *string, err error) {
@@ -199,15 +242,16 @@ func (ur *UserRepository) GetUserPasswordByEmailDB(ctx context.Context, email st
 	return res, err
 }
 
-func (ur *UserRepository) IsRefreshTokenExistByIDDB(ctx context.Context, id int64) (exists bool, err error) {
-	err = ur.DB.QueryRowContext(ctx, IsRefreshTokenIsExistsDBQuery,
+func (ur *UserRepository) GetUserPasswordByIDDB(ctx context.Context, id int64) (res *string, err error) {
+	err = ur.DB.QueryRowContext(ctx, GetUserPasswordByIDDBQuery,
 		id,
-	).Scan(&exists)
+	).Scan(&res)
+
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 
-	return exists, nil
+	return res, err
 }
 
 func (ur *UserRepository) IsUserExistsDB(ctx context.Context, data *entities.UserUniqueField) (res *entities.UserUniqueFieldExists, err error) {
@@ -228,3 +272,113 @@ func (ur *UserRepository) IsUserExistsDB(ctx context.Context, data *entities.Use
 
 	return res, err
 }
+
+// CreateLoginHistoryDB stores a sign in attempt, with updateLastLogin it also becomes the last login of the user
+// in the same transaction.
+func (ur *UserRepository) CreateLoginHistoryDB(ctx context.Context, data *entities.LoginHistory, updateLastLogin bool) (id int64, err error) {
+	createdAtUnix := data.CreatedAt.Unix()
+
+	tx, err := ur.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.QueryRowContext(ctx, InsertLoginHistoryDBQuery,
+		data.UserID,
+		data.Method,
+		data.Outcome,
+		data.IPAddress,
+		data.UserAgent,
+		data.DeviceID,
+		data.IPRange,
+		data.Location,
+		createdAtUnix,
+	).Scan(&id)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	if updateLastLogin {
+		_, err = tx.ExecContext(ctx, UpdateLastLoginByIDDBQuery, createdAtUnix, data.UserID)
+		if err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// GetLoginHistoryDB reads one page of the sign in attempts of the user ordered by id in the sort order of
+// paginate, together with the number of attempts. paginate must be validated.
+func (ur *UserRepository) GetLoginHistoryDB(ctx context.Context, userID int64, paginate *helpers.Paginate) (res []entities.LoginHistory, total int64, err error) {
+	err = ur.DB.QueryRowContext(ctx, CountLoginHistoryDBQuery, userID).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	sort := "ASC"
+	if strings.EqualFold(*paginate.SortBy, "desc") {
+		sort = "DESC"
+	}
+
+	query := fmt.Sprintf("%s ORDER BY id %s LIMIT $2 OFFSET $3", GetLoginHistoryDBQuery, sort)
+
+	rows, err := ur.DB.QueryContext(ctx, query, userID, *paginate.Limit, paginate.CalculateOffset(paginate.Offset, paginate.Limit))
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	res = []entities.LoginHistory{}
+	for rows.Next() {
+		var createdAtUnix int64
+
+		login := entities.LoginHistory{}
+		err = rows.Scan(
+			&login.ID,
+			&login.UserID,
+			&login.Method,
+			&login.Outcome,
+			&login.IPAddress,
+			&login.UserAgent,
+			&login.DeviceID,
+			&login.IPRange,
+			&login.Location,
+			&createdAtUnix,
+		)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		login.CreatedAt = helpers.FormatUnixTime(createdAtUnix)
+		res = append(res, login)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return res, total, nil
+}
+
+// GetLoginRecognitionDB tells whether previous successful sign ins of the user came from deviceID and ipRange.
+func (ur *UserRepository) GetLoginRecognitionDB(ctx context.Context, userID int64, deviceID string, ipRange string) (res *entities.LoginRecognition, err error) {
+	res = &entities.LoginRecognition{}
+	err = ur.DB.QueryRowContext(ctx, GetLoginRecognitionDBQuery, userID, deviceID, ipRange).Scan(
+		&res.HasHistory,
+		&res.KnownDevice,
+		&res.KnownIPRange,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
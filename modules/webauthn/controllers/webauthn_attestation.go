@@ -0,0 +1,301 @@
+package controllers
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/binary"
+	"fmt"
+	"github.com/winartodev/apollo/core/helpers"
+	"math/big"
+	"slices"
+)
+
+const (
+	AttestationFormatNone   = "none"
+	AttestationFormatPacked = "packed"
+
+	coseAlgES256 int64 = -7
+	coseAlgEdDSA int64 = -8
+	coseAlgRS256 int64 = -257
+
+	coseKeyTypeOKP int64 = 1
+	coseKeyTypeEC2 int64 = 2
+	coseKeyTypeRSA int64 = 3
+
+	coseCurveP256    int64 = 1
+	coseCurveEd25519 int64 = 6
+
+	flagUserPresent            byte = 0x01
+	flagUserVerified           byte = 0x04
+	flagAttestedCredentialData byte = 0x40
+	flagExtensionData          byte = 0x80
+
+	minRSAKeyBits = 2048
+)
+
+var (
+	// supportedAlgorithms are offered to authenticators in the order of preference
+	supportedAlgorithms = []int64{coseAlgEdDSA, coseAlgES256, coseAlgRS256}
+
+	// oidFIDOGenCeAAGUID is the certificate extension holding the aaguid of the authenticator model
+	oidFIDOGenCeAAGUID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 45724, 1, 1, 4}
+)
+
+type authenticatorData struct {
+	RPIDHash     []byte
+	Flags        byte
+	SignCount    uint32
+	AAGUID       []byte
+	CredentialID []byte
+	PublicKey    []byte
+}
+
+type coseKey struct {
+	Algorithm int64
+	PublicKey crypto.PublicKey
+}
+
+// parseAuthenticatorData splits the authenticator data into its fields, the credential public key is kept COSE
+// encoded the way it is stored.
+func parseAuthenticatorData(data []byte) (res *authenticatorData, err error) {
+	if len(data) < 37 {
+		return nil, invalidResponse("authenticator data is too short")
+	}
+
+	res = &authenticatorData{
+		RPIDHash:  data[:32],
+		Flags:     data[32],
+		SignCount: binary.BigEndian.Uint32(data[33:37]),
+	}
+
+	rest := data[37:]
+	if res.Flags&flagAttestedCredentialData != 0 {
+		if len(rest) < 18 {
+			return nil, invalidResponse("attested credential data is too short")
+		}
+
+		res.AAGUID = rest[:16]
+		length := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if length == 0 || len(rest) < length {
+			return nil, invalidResponse("credential id is invalid")
+		}
+
+		res.CredentialID = rest[:length]
+		rest = rest[length:]
+
+		_, afterKey, err := helpers.DecodeCBOR(rest)
+		if err != nil {
+			return nil, invalidResponse("credential public key is invalid")
+		}
+
+		res.PublicKey = rest[:len(rest)-len(afterKey)]
+		rest = afterKey
+	}
+
+	if res.Flags&flagExtensionData != 0 {
+		_, rest, err = helpers.DecodeCBOR(rest)
+		if err != nil {
+			return nil, invalidResponse("extension data is invalid")
+		}
+	}
+
+	if len(rest) != 0 {
+		return nil, invalidResponse("authenticator data has trailing bytes")
+	}
+
+	return res, nil
+}
+
+// verify checks that the authenticator data is scoped to the relying party and that the user was present and
+// verified by the authenticator.
+func (a *authenticatorData) verify(rpID string) error {
+	rpIDHash := sha256.Sum256([]byte(rpID))
+	if !bytes.Equal(a.RPIDHash, rpIDHash[:]) {
+		return invalidResponse("relying party id does not match")
+	}
+
+	if a.Flags&flagUserPresent == 0 {
+		return invalidResponse("user was not present")
+	}
+
+	if a.Flags&flagUserVerified == 0 {
+		return invalidResponse("user was not verified")
+	}
+
+	return nil
+}
+
+// parseCOSEKey reads an ES256 P-256, EdDSA Ed25519 or RS256 public key.
+func parseCOSEKey(data []byte) (res *coseKey, err error) {
+	decoded, rest, err := helpers.DecodeCBOR(data)
+	if err != nil || len(rest) != 0 {
+		return nil, invalidResponse("credential public key is invalid")
+	}
+
+	key, ok := decoded.(map[interface{}]interface{})
+	if !ok {
+		return nil, invalidResponse("credential public key is invalid")
+	}
+
+	keyType, _ := key[int64(1)].(int64)
+	algorithm, _ := key[int64(3)].(int64)
+
+	switch {
+	case keyType == coseKeyTypeEC2 && algorithm == coseAlgES256:
+		curve, _ := key[int64(-1)].(int64)
+		x, _ := key[int64(-2)].([]byte)
+		y, _ := key[int64(-3)].([]byte)
+		if curve != coseCurveP256 || len(x) != 32 || len(y) != 32 {
+			return nil, invalidResponse("credential public key is not a P-256 key")
+		}
+
+		// ecdh rejects points that are not on the curve
+		_, err = ecdh.P256().NewPublicKey(slices.Concat([]byte{4}, x, y))
+		if err != nil {
+			return nil, invalidResponse("credential public key is not a P-256 key")
+		}
+
+		return &coseKey{
+			Algorithm: algorithm,
+			PublicKey: &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)},
+		}, nil
+	case keyType == coseKeyTypeOKP && algorithm == coseAlgEdDSA:
+		curve, _ := key[int64(-1)].(int64)
+		x, _ := key[int64(-2)].([]byte)
+		if curve != coseCurveEd25519 || len(x) != ed25519.PublicKeySize {
+			return nil, invalidResponse("credential public key is not an Ed25519 key")
+		}
+
+		return &coseKey{Algorithm: algorithm, PublicKey: ed25519.PublicKey(x)}, nil
+	case keyType == coseKeyTypeRSA && algorithm == coseAlgRS256:
+		n, _ := key[int64(-1)].([]byte)
+		e, _ := key[int64(-2)].([]byte)
+		modulus := new(big.Int).SetBytes(n)
+		exponent := new(big.Int).SetBytes(e)
+		if modulus.BitLen() < minRSAKeyBits || !exponent.IsInt64() || exponent.Int64() < 3 || exponent.Int64() > 1<<31-1 {
+			return nil, invalidResponse("credential public key is not a supported RSA key")
+		}
+
+		return &coseKey{Algorithm: algorithm, PublicKey: &rsa.PublicKey{N: modulus, E: int(exponent.Int64())}}, nil
+	}
+
+	return nil, invalidResponse("credential public key algorithm is not supported")
+}
+
+// verifySignature checks signature over message with a key of the given COSE algorithm.
+func verifySignature(algorithm int64, publicKey crypto.PublicKey, message []byte, signature []byte) error {
+	digest := sha256.Sum256(message)
+
+	switch algorithm {
+	case coseAlgES256:
+		key, ok := publicKey.(*ecdsa.PublicKey)
+		if ok && ecdsa.VerifyASN1(key, digest[:], signature) {
+			return nil
+		}
+	case coseAlgEdDSA:
+		key, ok := publicKey.(ed25519.PublicKey)
+		if ok && ed25519.Verify(key, message, signature) {
+			return nil
+		}
+	case coseAlgRS256:
+		key, ok := publicKey.(*rsa.PublicKey)
+		if ok && rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) == nil {
+			return nil
+		}
+	}
+
+	return invalidResponse("signature is invalid")
+}
+
+// verifyAttestation checks the attestation statement of a new credential. Packed statements are verified with
+// the attestation certificate or, for self attestation, the credential key. The certificate is not chained to a
+// trust anchor, Apollo does not restrict which authenticator models may register.
+func verifyAttestation(format string, statement map[interface{}]interface{}, rawAuthData []byte, clientDataHash []byte, authData *authenticatorData, key *coseKey) error {
+	switch format {
+	case AttestationFormatNone:
+		if len(statement) != 0 {
+			return invalidResponse("none attestation must not carry a statement")
+		}
+
+		return nil
+	case AttestationFormatPacked:
+		return verifyPackedAttestation(statement, slices.Concat(rawAuthData, clientDataHash), authData, key)
+	}
+
+	return ErrorUnsupportedAttestation
+}
+
+func verifyPackedAttestation(statement map[interface{}]interface{}, message []byte, authData *authenticatorData, key *coseKey) error {
+	algorithm, _ := statement["alg"].(int64)
+	signature, _ := statement["sig"].([]byte)
+	if len(signature) == 0 {
+		return invalidResponse("packed attestation has no signature")
+	}
+
+	chain, hasChain := statement["x5c"].([]interface{})
+	if !hasChain {
+		if algorithm != key.Algorithm {
+			return invalidResponse("self attestation algorithm does not match the credential key")
+		}
+
+		return verifySignature(algorithm, key.PublicKey, message, signature)
+	}
+
+	if len(chain) == 0 {
+		return invalidResponse("packed attestation certificate is missing")
+	}
+
+	raw, _ := chain[0].([]byte)
+	certificate, err := x509.ParseCertificate(raw)
+	if err != nil {
+		return invalidResponse("packed attestation certificate is invalid")
+	}
+
+	err = verifySignature(algorithm, certificate.PublicKey, message, signature)
+	if err != nil {
+		return err
+	}
+
+	return verifyAttestationCertificate(certificate, authData.AAGUID)
+}
+
+// verifyAttestationCertificate checks the requirements of the WebAuthn specification for packed attestation
+// certificates.
+func verifyAttestationCertificate(certificate *x509.Certificate, aaguid []byte) error {
+	subject := certificate.Subject
+	if certificate.Version != 3 || len(subject.Country) == 0 || len(subject.Organization) == 0 || subject.CommonName == "" ||
+		!slices.Contains(subject.OrganizationalUnit, "Authenticator Attestation") {
+		return invalidResponse("packed attestation certificate subject is invalid")
+	}
+
+	if !certificate.BasicConstraintsValid || certificate.IsCA {
+		return invalidResponse("packed attestation certificate must not be a ca")
+	}
+
+	for _, extension := range certificate.Extensions {
+		if !extension.Id.Equal(oidFIDOGenCeAAGUID) {
+			continue
+		}
+
+		var value []byte
+		_, err := asn1.Unmarshal(extension.Value, &value)
+		if err != nil || extension.Critical || !bytes.Equal(value, aaguid) {
+			return invalidResponse("packed attestation certificate aaguid does not match")
+		}
+	}
+
+	return nil
+}
+
+func invalidResponse(reason string) error {
+	return fmt.Errorf("%w: %s", ErrorInvalidPasskeyResponse, reason)
+}
@@ -0,0 +1,501 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/winartodev/apollo/core/configs"
+	"github.com/winartodev/apollo/core/helpers"
+	authController "github.com/winartodev/apollo/modules/auth/controllers"
+	authEntity "github.com/winartodev/apollo/modules/auth/entities"
+	sessionEntity "github.com/winartodev/apollo/modules/session/entities"
+	userController "github.com/winartodev/apollo/modules/user/controllers"
+	webAuthnEntity "github.com/winartodev/apollo/modules/webauthn/entities"
+	webAuthnRepo "github.com/winartodev/apollo/modules/webauthn/repositories"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	challengeLength       = 32
+	defaultTimeout        = 300 // in seconds
+	defaultRPName         = "Apollo"
+	defaultCredentialName = "Passkey"
+	maxCredentialNameLen  = 100
+
+	attestationConveyance = "none"
+	residentKeyRequired   = "required"
+	userVerification      = "required"
+)
+
+var (
+	ErrorWebAuthnDisabled          = errors.New("passkeys are not configured")
+	ErrorInvalidPasskeyChallenge   = errors.New("passkey challenge is invalid or expired")
+	ErrorInvalidPasskeyResponse    = errors.New("passkey response is invalid")
+	ErrorUnsupportedAttestation    = errors.New("passkey attestation format is not supported")
+	ErrorPasskeyAlreadyRegistered  = errors.New("passkey is already registered")
+	ErrorPasskeyNotFound           = errors.New("passkey not found")
+	ErrorPasskeySignCountRegressed = errors.New("passkey sign counter did not increase, the authenticator may have been cloned")
+)
+
+type WebAuthnControllerItf interface {
+	BeginRegistration(ctx context.Context, userID int64) (res *webAuthnEntity.CreationOptions, err error)
+	FinishRegistration(ctx context.Context, userID int64, data *webAuthnEntity.RegistrationRequest) (res *webAuthnEntity.Credential, err error)
+	GetCredentials(ctx context.Context, userID int64) (res []webAuthnEntity.Credential, err error)
+	DeleteCredential(ctx context.Context, userID int64, id int64) (err error)
+	DeleteCredentialsCreatedSince(ctx context.Context, userID int64, since time.Time) (deleted int64, err error)
+	BeginSignIn(ctx context.Context, data *webAuthnEntity.BeginSignInRequest) (res *webAuthnEntity.RequestOptions, err error)
+	FinishSignIn(ctx context.Context, data *webAuthnEntity.AssertionRequest, client sessionEntity.ClientInfo) (res *authEntity.AuthResponse, err error)
+}
+
+type WebAuthnController struct {
+	WebAuthn           *configs.WebAuthn
+	WebAuthnRepository webAuthnRepo.WebAuthnRepositoryItf
+	UserController     userController.UserControllerItf
+	AuthController     authController.AuthControllerItf
+}
+
+func NewWebAuthnController(controller WebAuthnController) WebAuthnControllerItf {
+	return &WebAuthnController{
+		WebAuthn:           controller.WebAuthn,
+		WebAuthnRepository: controller.WebAuthnRepository,
+		UserController:     controller.UserController,
+		AuthController:     controller.AuthController,
+	}
+}
+
+// BeginRegistration returns the options to create a passkey for the signed in user. Passkeys the user already has
+// are excluded, so an authenticator is not registered twice.
+func (wc *WebAuthnController) BeginRegistration(ctx context.Context, userID int64) (res *webAuthnEntity.CreationOptions, err error) {
+	if !wc.isEnabled() {
+		return nil, ErrorWebAuthnDisabled
+	}
+
+	user, err := wc.UserController.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	credentials, err := wc.WebAuthnRepository.GetCredentialsByUserIDDB(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	challenge, err := wc.createCeremony(ctx, webAuthnEntity.Ceremony{Type: webAuthnEntity.CeremonyRegistration, UserID: userID})
+	if err != nil {
+		return nil, err
+	}
+
+	parameters := make([]webAuthnEntity.CredentialParameter, 0, len(supportedAlgorithms))
+	for _, algorithm := range supportedAlgorithms {
+		parameters = append(parameters, webAuthnEntity.CredentialParameter{Type: webAuthnEntity.PublicKeyCredentialType, Alg: algorithm})
+	}
+
+	rpName := wc.WebAuthn.RPName
+	if rpName == "" {
+		rpName = defaultRPName
+	}
+
+	return &webAuthnEntity.CreationOptions{
+		Challenge: challenge,
+		RP: webAuthnEntity.RelyingParty{
+			ID:   wc.WebAuthn.RPID,
+			Name: rpName,
+		},
+		User: webAuthnEntity.UserEntity{
+			ID:          base64.RawURLEncoding.EncodeToString(userHandle(user.ID)),
+			Name:        user.Email,
+			DisplayName: user.Username,
+		},
+		PubKeyCredParams:   parameters,
+		Timeout:            wc.getTimeout().Milliseconds(),
+		Attestation:        attestationConveyance,
+		ExcludeCredentials: toDescriptors(credentials),
+		AuthenticatorSelection: webAuthnEntity.AuthenticatorSelection{
+			ResidentKey:      residentKeyRequired,
+			UserVerification: userVerification,
+		},
+	}, nil
+}
+
+// FinishRegistration verifies the attestation of a new passkey and stores its public key and sign counter.
+func (wc *WebAuthnController) FinishRegistration(ctx context.Context, userID int64, data *webAuthnEntity.RegistrationRequest) (res *webAuthnEntity.Credential, err error) {
+	if !wc.isEnabled() {
+		return nil, ErrorWebAuthnDisabled
+	}
+
+	clientDataJSON, clientData, err := wc.parseClientData(data.Type, data.Response.ClientDataJSON, webAuthnEntity.ClientDataTypeCreate)
+	if err != nil {
+		return nil, err
+	}
+
+	ceremony, err := wc.consumeCeremony(ctx, clientData.Challenge, webAuthnEntity.CeremonyRegistration)
+	if err != nil {
+		return nil, err
+	}
+
+	if ceremony.UserID != userID {
+		return nil, ErrorInvalidPasskeyChallenge
+	}
+
+	attestationObject, err := decodeBase64URL(data.Response.AttestationObject)
+	if err != nil {
+		return nil, invalidResponse("attestation object is not base64url encoded")
+	}
+
+	decoded, rest, err := helpers.DecodeCBOR(attestationObject)
+	if err != nil || len(rest) != 0 {
+		return nil, invalidResponse("attestation object is invalid")
+	}
+
+	attestation, _ := decoded.(map[interface{}]interface{})
+	format, _ := attestation["fmt"].(string)
+	statement, _ := attestation["attStmt"].(map[interface{}]interface{})
+	rawAuthData, _ := attestation["authData"].([]byte)
+	if format == "" || statement == nil || rawAuthData == nil {
+		return nil, invalidResponse("attestation object is incomplete")
+	}
+
+	authData, err := parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, err
+	}
+
+	err = authData.verify(wc.WebAuthn.RPID)
+	if err != nil {
+		return nil, err
+	}
+
+	if authData.CredentialID == nil {
+		return nil, invalidResponse("attested credential data is missing")
+	}
+
+	rawID, err := decodeBase64URL(data.RawID)
+	if err != nil || !bytes.Equal(rawID, authData.CredentialID) {
+		return nil, invalidResponse("credential id does not match the authenticator data")
+	}
+
+	key, err := parseCOSEKey(authData.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	err = verifyAttestation(format, statement, rawAuthData, clientDataHash[:], authData, key)
+	if err != nil {
+		return nil, err
+	}
+
+	credentialID := base64.RawURLEncoding.EncodeToString(authData.CredentialID)
+	_, err = wc.WebAuthnRepository.GetCredentialByCredentialIDDB(ctx, credentialID)
+	if err == nil {
+		return nil, ErrorPasskeyAlreadyRegistered
+	}
+
+	if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	var aaguid string
+	if id, err := uuid.FromBytes(authData.AAGUID); err == nil && id != uuid.Nil {
+		aaguid = id.String()
+	}
+
+	name := strings.TrimSpace(data.Name)
+	if name == "" {
+		name = defaultCredentialName
+	}
+
+	if len(name) > maxCredentialNameLen {
+		name = name[:maxCredentialNameLen]
+	}
+
+	now := time.Now()
+	res = &webAuthnEntity.Credential{
+		UserID:            userID,
+		CredentialID:      credentialID,
+		PublicKey:         authData.PublicKey,
+		Algorithm:         key.Algorithm,
+		SignCount:         authData.SignCount,
+		AAGUID:            aaguid,
+		AttestationFormat: format,
+		Name:              name,
+		CreatedAt:         &now,
+		UpdatedAt:         &now,
+	}
+
+	res.ID, err = wc.WebAuthnRepository.CreateCredentialDB(ctx, res)
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (wc *WebAuthnController) GetCredentials(ctx context.Context, userID int64) (res []webAuthnEntity.Credential, err error) {
+	return wc.WebAuthnRepository.GetCredentialsByUserIDDB(ctx, userID)
+}
+
+func (wc *WebAuthnController) DeleteCredential(ctx context.Context, userID int64, id int64) (err error) {
+	deleted, err := wc.WebAuthnRepository.DeleteCredentialDB(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+
+	if !deleted {
+		return ErrorPasskeyNotFound
+	}
+
+	return nil
+}
+
+// DeleteCredentialsCreatedSince removes the passkeys the user registered at or after since.
+func (wc *WebAuthnController) DeleteCredentialsCreatedSince(ctx context.Context, userID int64, since time.Time) (deleted int64, err error) {
+	return wc.WebAuthnRepository.DeleteCredentialsCreatedSinceDB(ctx, userID, since)
+}
+
+// BeginSignIn returns the options to sign in with a passkey. Without an email the browser offers every passkey it
+// has for the site, with an email only the passkeys of that user are allowed.
+func (wc *WebAuthnController) BeginSignIn(ctx context.Context, data *webAuthnEntity.BeginSignInRequest) (res *webAuthnEntity.RequestOptions, err error) {
+	if !wc.isEnabled() {
+		return nil, ErrorWebAuthnDisabled
+	}
+
+	ceremony := webAuthnEntity.Ceremony{Type: webAuthnEntity.CeremonySignIn}
+	allowCredentials := []webAuthnEntity.CredentialDescriptor{}
+
+	email := strings.TrimSpace(data.Email)
+	if email != "" {
+		user, err := wc.UserController.GetUserByEmail(ctx, email)
+		if err != nil && !errors.Is(err, userController.ErrorUserNotFound) {
+			return nil, err
+		}
+
+		if user != nil {
+			credentials, err := wc.WebAuthnRepository.GetCredentialsByUserIDDB(ctx, user.ID)
+			if err != nil {
+				return nil, err
+			}
+
+			ceremony.UserID = user.ID
+			allowCredentials = toDescriptors(credentials)
+		}
+	}
+
+	challenge, err := wc.createCeremony(ctx, ceremony)
+	if err != nil {
+		return nil, err
+	}
+
+	return &webAuthnEntity.RequestOptions{
+		Challenge:        challenge,
+		RPID:             wc.WebAuthn.RPID,
+		Timeout:          wc.getTimeout().Milliseconds(),
+		AllowCredentials: allowCredentials,
+		UserVerification: userVerification,
+	}, nil
+}
+
+// FinishSignIn verifies the assertion of a passkey and signs its user in. The passkey proves possession and, with
+// user verification, a second factor, so no two-factor challenge follows.
+func (wc *WebAuthnController) FinishSignIn(ctx context.Context, data *webAuthnEntity.AssertionRequest, client sessionEntity.ClientInfo) (res *authEntity.AuthResponse, err error) {
+	if !wc.isEnabled() {
+		return nil, ErrorWebAuthnDisabled
+	}
+
+	clientDataJSON, clientData, err := wc.parseClientData(data.Type, data.Response.ClientDataJSON, webAuthnEntity.ClientDataTypeGet)
+	if err != nil {
+		return nil, err
+	}
+
+	ceremony, err := wc.consumeCeremony(ctx, clientData.Challenge, webAuthnEntity.CeremonySignIn)
+	if err != nil {
+		return nil, err
+	}
+
+	rawID, err := decodeBase64URL(data.RawID)
+	if err != nil || len(rawID) == 0 {
+		return nil, invalidResponse("credential id is not base64url encoded")
+	}
+
+	credential, err := wc.WebAuthnRepository.GetCredentialByCredentialIDDB(ctx, base64.RawURLEncoding.EncodeToString(rawID))
+	if err == sql.ErrNoRows {
+		return nil, ErrorPasskeyNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if ceremony.UserID != 0 && ceremony.UserID != credential.UserID {
+		return nil, ErrorPasskeyNotFound
+	}
+
+	if data.Response.UserHandle != "" {
+		handle, err := decodeBase64URL(data.Response.UserHandle)
+		if err != nil || !bytes.Equal(handle, userHandle(credential.UserID)) {
+			return nil, invalidResponse("user handle does not match the passkey")
+		}
+	}
+
+	rawAuthData, err := decodeBase64URL(data.Response.AuthenticatorData)
+	if err != nil {
+		return nil, invalidResponse("authenticator data is not base64url encoded")
+	}
+
+	authData, err := parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, err
+	}
+
+	err = authData.verify(wc.WebAuthn.RPID)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := decodeBase64URL(data.Response.Signature)
+	if err != nil {
+		return nil, invalidResponse("signature is not base64url encoded")
+	}
+
+	key, err := parseCOSEKey(credential.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	err = verifySignature(key.Algorithm, key.PublicKey, slices.Concat(rawAuthData, clientDataHash[:]), signature)
+	if err != nil {
+		return nil, err
+	}
+
+	// authenticators without a counter always report zero, any other counter has to grow with every assertion
+	if (authData.SignCount != 0 || credential.SignCount != 0) && authData.SignCount <= credential.SignCount {
+		return nil, ErrorPasskeySignCountRegressed
+	}
+
+	updated, err := wc.WebAuthnRepository.UpdateCredentialSignCountDB(ctx, credential.ID, credential.SignCount, authData.SignCount, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	if !updated {
+		return nil, ErrorPasskeySignCountRegressed
+	}
+
+	user, err := wc.UserController.GetUserByID(ctx, credential.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	return wc.AuthController.SignInVerifiedUser(ctx, user, client)
+}
+
+// createCeremony stores a new challenge until it is answered or the ceremony times out.
+func (wc *WebAuthnController) createCeremony(ctx context.Context, ceremony webAuthnEntity.Ceremony) (challenge string, err error) {
+	challenge, err = helpers.GenerateRandomToken(challengeLength)
+	if err != nil {
+		return "", err
+	}
+
+	err = wc.WebAuthnRepository.SetCeremonyRedis(ctx, challenge, ceremony, wc.getTimeout())
+	if err != nil {
+		return "", err
+	}
+
+	return challenge, nil
+}
+
+// consumeCeremony returns the ceremony of challenge and removes it, so every challenge is answered only once.
+func (wc *WebAuthnController) consumeCeremony(ctx context.Context, challenge string, ceremonyType string) (res *webAuthnEntity.Ceremony, err error) {
+	res, err = wc.WebAuthnRepository.GetCeremonyRedis(ctx, challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	if res == nil || res.Type != ceremonyType {
+		return nil, ErrorInvalidPasskeyChallenge
+	}
+
+	deleted, err := wc.WebAuthnRepository.DeleteCeremonyRedis(ctx, challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	if !deleted {
+		return nil, ErrorInvalidPasskeyChallenge
+	}
+
+	return res, nil
+}
+
+// parseClientData decodes the client data the browser collected and checks its type and origin.
+func (wc *WebAuthnController) parseClientData(credentialType string, encoded string, clientDataType string) (raw []byte, res *webAuthnEntity.CollectedClientData, err error) {
+	if credentialType != webAuthnEntity.PublicKeyCredentialType {
+		return nil, nil, invalidResponse("credential type is not public-key")
+	}
+
+	raw, err = decodeBase64URL(encoded)
+	if err != nil {
+		return nil, nil, invalidResponse("client data is not base64url encoded")
+	}
+
+	res = &webAuthnEntity.CollectedClientData{}
+	err = json.Unmarshal(raw, res)
+	if err != nil {
+		return nil, nil, invalidResponse("client data is not valid json")
+	}
+
+	if res.Type != clientDataType {
+		return nil, nil, invalidResponse("client data type does not match the ceremony")
+	}
+
+	if res.CrossOrigin || !slices.Contains(wc.WebAuthn.Origins, res.Origin) {
+		return nil, nil, invalidResponse("origin is not allowed")
+	}
+
+	return raw, res, nil
+}
+
+func (wc *WebAuthnController) isEnabled() bool {
+	return wc.WebAuthn != nil && wc.WebAuthn.RPID != "" && len(wc.WebAuthn.Origins) > 0
+}
+
+func (wc *WebAuthnController) getTimeout() time.Duration {
+	if wc.WebAuthn.Timeout <= 0 {
+		return defaultTimeout * time.Second
+	}
+
+	return time.Duration(wc.WebAuthn.Timeout) * time.Second
+}
+
+func toDescriptors(credentials []webAuthnEntity.Credential) []webAuthnEntity.CredentialDescriptor {
+	descriptors := make([]webAuthnEntity.CredentialDescriptor, 0, len(credentials))
+	for _, credential := range credentials {
+		descriptors = append(descriptors, webAuthnEntity.CredentialDescriptor{
+			Type: webAuthnEntity.PublicKeyCredentialType,
+			ID:   credential.CredentialID,
+		})
+	}
+
+	return descriptors
+}
+
+// userHandle is the id passkeys store for their user, it does not reveal anything but the user id.
+func userHandle(userID int64) []byte {
+	handle := make([]byte, 8)
+	binary.BigEndian.PutUint64(handle, uint64(userID))
+	return handle
+}
+
+// decodeBase64URL accepts base64url with or without padding, browsers and libraries differ.
+func decodeBase64URL(value string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+}
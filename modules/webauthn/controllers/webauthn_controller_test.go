@@ -0,0 +1,617 @@
+package controllers
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"database/sql"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/winartodev/apollo/core/configs"
+	authController "github.com/winartodev/apollo/modules/auth/controllers"
+	authEntity "github.com/winartodev/apollo/modules/auth/entities"
+	sessionEntity "github.com/winartodev/apollo/modules/session/entities"
+	userController "github.com/winartodev/apollo/modules/user/controllers"
+	userEntity "github.com/winartodev/apollo/modules/user/entities"
+	webAuthnEntity "github.com/winartodev/apollo/modules/webauthn/entities"
+)
+
+const (
+	testRPID   = "apollo.example.com"
+	testOrigin = "https://apollo.example.com"
+)
+
+var testAAGUID = []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10}
+
+type fakeRepository struct {
+	credentials map[string]*webAuthnEntity.Credential
+	ceremonies  map[string]webAuthnEntity.Ceremony
+}
+
+func newFakeRepository() *fakeRepository {
+	return &fakeRepository{
+		credentials: map[string]*webAuthnEntity.Credential{},
+		ceremonies:  map[string]webAuthnEntity.Ceremony{},
+	}
+}
+
+func (f *fakeRepository) CreateCredentialDB(ctx context.Context, data *webAuthnEntity.Credential) (id int64, err error) {
+	stored := *data
+	stored.ID = int64(len(f.credentials) + 1)
+	f.credentials[data.CredentialID] = &stored
+	return stored.ID, nil
+}
+
+func (f *fakeRepository) GetCredentialsByUserIDDB(ctx context.Context, userID int64) (res []webAuthnEntity.Credential, err error) {
+	for _, credential := range f.credentials {
+		if credential.UserID == userID {
+			res = append(res, *credential)
+		}
+	}
+
+	return res, nil
+}
+
+func (f *fakeRepository) GetCredentialByCredentialIDDB(ctx context.Context, credentialID string) (res *webAuthnEntity.Credential, err error) {
+	credential, ok := f.credentials[credentialID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+
+	copied := *credential
+	return &copied, nil
+}
+
+func (f *fakeRepository) UpdateCredentialSignCountDB(ctx context.Context, id int64, previous uint32, signCount uint32, usedAt time.Time) (updated bool, err error) {
+	for _, credential := range f.credentials {
+		if credential.ID == id && credential.SignCount == previous {
+			credential.SignCount = signCount
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (f *fakeRepository) DeleteCredentialDB(ctx context.Context, userID int64, id int64) (deleted bool, err error) {
+	return false, nil
+}
+
+func (f *fakeRepository) DeleteCredentialsCreatedSinceDB(ctx context.Context, userID int64, since time.Time) (deleted int64, err error) {
+	return 0, nil
+}
+
+func (f *fakeRepository) SetCeremonyRedis(ctx context.Context, challenge string, data webAuthnEntity.Ceremony, ttl time.Duration) (err error) {
+	f.ceremonies[challenge] = data
+	return nil
+}
+
+func (f *fakeRepository) GetCeremonyRedis(ctx context.Context, challenge string) (res *webAuthnEntity.Ceremony, err error) {
+	ceremony, ok := f.ceremonies[challenge]
+	if !ok {
+		return nil, nil
+	}
+
+	return &ceremony, nil
+}
+
+func (f *fakeRepository) DeleteCeremonyRedis(ctx context.Context, challenge string) (deleted bool, err error) {
+	_, ok := f.ceremonies[challenge]
+	delete(f.ceremonies, challenge)
+	return ok, nil
+}
+
+type fakeUserController struct {
+	userController.UserControllerItf
+	users []*userEntity.User
+}
+
+func (f *fakeUserController) GetUserByID(ctx context.Context, id int64) (res *userEntity.User, err error) {
+	for _, user := range f.users {
+		if user.ID == id {
+			return user, nil
+		}
+	}
+
+	return nil, userController.ErrorUserNotFound
+}
+
+func (f *fakeUserController) GetUserByEmail(ctx context.Context, email string) (res *userEntity.User, err error) {
+	for _, user := range f.users {
+		if user.Email == email {
+			return user, nil
+		}
+	}
+
+	return nil, userController.ErrorUserNotFound
+}
+
+type fakeAuthController struct {
+	authController.AuthControllerItf
+}
+
+func (f *fakeAuthController) SignInVerifiedUser(ctx context.Context, user *userEntity.User, client sessionEntity.ClientInfo) (res *authEntity.AuthResponse, err error) {
+	return &authEntity.AuthResponse{AccessToken: user.Email}, nil
+}
+
+// softwareAuthenticator plays the part of a security key or platform authenticator, including the browser that
+// collects the client data.
+type softwareAuthenticator struct {
+	rpID         string
+	origin       string
+	flags        byte
+	credentialID []byte
+	algorithm    int64
+	signer       crypto.Signer
+	signCount    uint32
+}
+
+func newSoftwareAuthenticator(t *testing.T, algorithm int64) *softwareAuthenticator {
+	var signer crypto.Signer
+	var err error
+	if algorithm == coseAlgEdDSA {
+		_, signer, err = ed25519.GenerateKey(rand.Reader)
+	} else {
+		signer, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	}
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	credentialID := make([]byte, 32)
+	_, _ = rand.Read(credentialID)
+
+	return &softwareAuthenticator{
+		rpID:         testRPID,
+		origin:       testOrigin,
+		flags:        flagUserPresent | flagUserVerified,
+		credentialID: credentialID,
+		algorithm:    algorithm,
+		signer:       signer,
+	}
+}
+
+func (a *softwareAuthenticator) coseKey() []byte {
+	switch key := a.signer.Public().(type) {
+	case ed25519.PublicKey:
+		return encodeCBOR(map[int64]interface{}{1: coseKeyTypeOKP, 3: coseAlgEdDSA, -1: coseCurveEd25519, -2: []byte(key)})
+	case *ecdsa.PublicKey:
+		return encodeCBOR(map[int64]interface{}{1: coseKeyTypeEC2, 3: coseAlgES256, -1: coseCurveP256, -2: key.X.FillBytes(make([]byte, 32)), -3: key.Y.FillBytes(make([]byte, 32))})
+	}
+
+	return nil
+}
+
+func (a *softwareAuthenticator) authenticatorData(attested bool) []byte {
+	rpIDHash := sha256.Sum256([]byte(a.rpID))
+	data := append(rpIDHash[:], a.flags)
+	data = binary.BigEndian.AppendUint32(data, a.signCount)
+	if attested {
+		data[32] |= flagAttestedCredentialData
+		data = append(data, testAAGUID...)
+		data = binary.BigEndian.AppendUint16(data, uint16(len(a.credentialID)))
+		data = append(data, a.credentialID...)
+		data = append(data, a.coseKey()...)
+	}
+
+	return data
+}
+
+func (a *softwareAuthenticator) clientData(clientDataType string, challenge string) []byte {
+	clientData, _ := json.Marshal(webAuthnEntity.CollectedClientData{Type: clientDataType, Challenge: challenge, Origin: a.origin})
+	return clientData
+}
+
+// create answers navigator.credentials.create with the given attestation format, "packed" uses self attestation
+// unless an attestation key and certificate are given.
+func (a *softwareAuthenticator) create(t *testing.T, challenge string, format string, attestationKey crypto.Signer, certificate []byte) *webAuthnEntity.RegistrationRequest {
+	clientData := a.clientData(webAuthnEntity.ClientDataTypeCreate, challenge)
+	authData := a.authenticatorData(true)
+	clientDataHash := sha256.Sum256(clientData)
+
+	statement := map[string]interface{}{}
+	if format == AttestationFormatPacked {
+		if attestationKey == nil {
+			statement["alg"] = a.algorithm
+			statement["sig"] = sign(t, a.signer, slices.Concat(authData, clientDataHash[:]))
+		} else {
+			statement["alg"] = coseAlgES256
+			statement["sig"] = sign(t, attestationKey, slices.Concat(authData, clientDataHash[:]))
+			statement["x5c"] = []interface{}{certificate}
+		}
+	}
+
+	attestationObject := encodeCBOR(map[string]interface{}{"fmt": format, "attStmt": statement, "authData": authData})
+
+	return &webAuthnEntity.RegistrationRequest{
+		ID:    base64.RawURLEncoding.EncodeToString(a.credentialID),
+		RawID: base64.RawURLEncoding.EncodeToString(a.credentialID),
+		Type:  webAuthnEntity.PublicKeyCredentialType,
+		Name:  "Software Key",
+		Response: webAuthnEntity.AttestationResponse{
+			ClientDataJSON:    base64.RawURLEncoding.EncodeToString(clientData),
+			AttestationObject: base64.RawURLEncoding.EncodeToString(attestationObject),
+		},
+	}
+}
+
+// get answers navigator.credentials.get and counts the assertion.
+func (a *softwareAuthenticator) get(t *testing.T, challenge string, userID int64) *webAuthnEntity.AssertionRequest {
+	a.signCount++
+
+	clientData := a.clientData(webAuthnEntity.ClientDataTypeGet, challenge)
+	authData := a.authenticatorData(false)
+	clientDataHash := sha256.Sum256(clientData)
+
+	return &webAuthnEntity.AssertionRequest{
+		ID:    base64.RawURLEncoding.EncodeToString(a.credentialID),
+		RawID: base64.RawURLEncoding.EncodeToString(a.credentialID),
+		Type:  webAuthnEntity.PublicKeyCredentialType,
+		Response: webAuthnEntity.AssertionResponse{
+			ClientDataJSON:    base64.RawURLEncoding.EncodeToString(clientData),
+			AuthenticatorData: base64.RawURLEncoding.EncodeToString(authData),
+			Signature:         base64.RawURLEncoding.EncodeToString(sign(t, a.signer, slices.Concat(authData, clientDataHash[:]))),
+			UserHandle:        base64.RawURLEncoding.EncodeToString(userHandle(userID)),
+		},
+	}
+}
+
+func decode(value string) []byte {
+	decoded, _ := base64.RawURLEncoding.DecodeString(value)
+	return decoded
+}
+
+func sign(t *testing.T, signer crypto.Signer, message []byte) []byte {
+	var signature []byte
+	var err error
+	if _, ok := signer.(ed25519.PrivateKey); ok {
+		signature, err = signer.Sign(rand.Reader, message, crypto.Hash(0))
+	} else {
+		digest := sha256.Sum256(message)
+		signature, err = signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	}
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signature
+}
+
+// newAttestationCertificate issues a packed attestation certificate for the test aaguid.
+func newAttestationCertificate(t *testing.T) (crypto.Signer, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	aaguid, err := asn1.Marshal(testAAGUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject: pkix.Name{
+			Country:            []string{"ID"},
+			Organization:       []string{"Apollo Test"},
+			OrganizationalUnit: []string{"Authenticator Attestation"},
+			CommonName:         "Apollo Software Authenticator",
+		},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		BasicConstraintsValid: true,
+		ExtraExtensions:       []pkix.Extension{{Id: oidFIDOGenCeAAGUID, Value: aaguid}},
+	}
+
+	certificate, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key, certificate
+}
+
+// encodeCBOR encodes the values the software authenticator needs with definite lengths.
+func encodeCBOR(value interface{}) []byte {
+	header := func(major byte, argument uint64) []byte {
+		switch {
+		case argument < 24:
+			return []byte{major<<5 | byte(argument)}
+		case argument <= 0xff:
+			return []byte{major<<5 | 24, byte(argument)}
+		case argument <= 0xffff:
+			return binary.BigEndian.AppendUint16([]byte{major<<5 | 25}, uint16(argument))
+		default:
+			return binary.BigEndian.AppendUint32([]byte{major<<5 | 26}, uint32(argument))
+		}
+	}
+
+	switch v := value.(type) {
+	case int64:
+		if v < 0 {
+			return header(1, uint64(-1-v))
+		}
+
+		return header(0, uint64(v))
+	case []byte:
+		return append(header(2, uint64(len(v))), v...)
+	case string:
+		return append(header(3, uint64(len(v))), v...)
+	case []interface{}:
+		data := header(4, uint64(len(v)))
+		for _, item := range v {
+			data = append(data, encodeCBOR(item)...)
+		}
+
+		return data
+	case map[int64]interface{}:
+		data := header(5, uint64(len(v)))
+		for key, item := range v {
+			data = append(data, encodeCBOR(key)...)
+			data = append(data, encodeCBOR(item)...)
+		}
+
+		return data
+	case map[string]interface{}:
+		data := header(5, uint64(len(v)))
+		for key, item := range v {
+			data = append(data, encodeCBOR(key)...)
+			data = append(data, encodeCBOR(item)...)
+		}
+
+		return data
+	}
+
+	panic("encodeCBOR: unsupported value")
+}
+
+func newTestController(repository *fakeRepository) *WebAuthnController {
+	return &WebAuthnController{
+		WebAuthn:           &configs.WebAuthn{RPID: testRPID, Origins: []string{testOrigin}},
+		WebAuthnRepository: repository,
+		UserController: &fakeUserController{users: []*userEntity.User{
+			{ID: 1, Email: "apollo@gmail.com", Username: "apollo"},
+			{ID: 2, Email: "artemis@gmail.com", Username: "artemis"},
+		}},
+		AuthController: &fakeAuthController{},
+	}
+}
+
+func TestWebAuthnController_FinishRegistration(t *testing.T) {
+	attestationKey, certificate := newAttestationCertificate(t)
+
+	tests := []struct {
+		name       string
+		algorithm  int64
+		format     string
+		attested   bool
+		prepare    func(a *softwareAuthenticator)
+		tamper     func(req *webAuthnEntity.RegistrationRequest)
+		wantFormat string
+		wantErr    error
+	}{
+		{
+			name:       "success_none_es256",
+			algorithm:  coseAlgES256,
+			format:     AttestationFormatNone,
+			wantFormat: AttestationFormatNone,
+		},
+		{
+			name:       "success_packed_self_attestation_eddsa",
+			algorithm:  coseAlgEdDSA,
+			format:     AttestationFormatPacked,
+			wantFormat: AttestationFormatPacked,
+		},
+		{
+			name:       "success_packed_certificate_es256",
+			algorithm:  coseAlgES256,
+			format:     AttestationFormatPacked,
+			attested:   true,
+			wantFormat: AttestationFormatPacked,
+		},
+		{
+			name:      "failed_unsupported_format",
+			algorithm: coseAlgES256,
+			format:    "fido-u2f",
+			wantErr:   ErrorUnsupportedAttestation,
+		},
+		{
+			name:      "failed_wrong_origin",
+			algorithm: coseAlgES256,
+			format:    AttestationFormatNone,
+			prepare:   func(a *softwareAuthenticator) { a.origin = "https://apollo.example.com.evil.test" },
+			wantErr:   ErrorInvalidPasskeyResponse,
+		},
+		{
+			name:      "failed_wrong_rp_id",
+			algorithm: coseAlgES256,
+			format:    AttestationFormatNone,
+			prepare:   func(a *softwareAuthenticator) { a.rpID = "evil.test" },
+			wantErr:   ErrorInvalidPasskeyResponse,
+		},
+		{
+			name:      "failed_user_not_verified",
+			algorithm: coseAlgES256,
+			format:    AttestationFormatNone,
+			prepare:   func(a *softwareAuthenticator) { a.flags = flagUserPresent },
+			wantErr:   ErrorInvalidPasskeyResponse,
+		},
+		{
+			name:      "failed_tampered_self_attestation",
+			algorithm: coseAlgEdDSA,
+			format:    AttestationFormatPacked,
+			tamper: func(req *webAuthnEntity.RegistrationRequest) {
+				req.Name = "Tampered"
+				req.Response.ClientDataJSON = base64.RawURLEncoding.EncodeToString(append(decode(req.Response.ClientDataJSON), ' '))
+			},
+			wantErr: ErrorInvalidPasskeyResponse,
+		},
+		{
+			name:      "failed_unknown_challenge",
+			algorithm: coseAlgES256,
+			format:    AttestationFormatNone,
+			tamper: func(req *webAuthnEntity.RegistrationRequest) {
+				req.Response.ClientDataJSON = base64.RawURLEncoding.EncodeToString([]byte(`{"type":"webauthn.create","challenge":"unknown","origin":"` + testOrigin + `"}`))
+			},
+			wantErr: ErrorInvalidPasskeyChallenge,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			repository := newFakeRepository()
+			controller := newTestController(repository)
+
+			options, err := controller.BeginRegistration(ctx, 1)
+			if err != nil {
+				t.Fatalf("BeginRegistration() error = %v", err)
+			}
+
+			authenticator := newSoftwareAuthenticator(t, tt.algorithm)
+			if tt.prepare != nil {
+				tt.prepare(authenticator)
+			}
+
+			var req *webAuthnEntity.RegistrationRequest
+			if tt.attested {
+				req = authenticator.create(t, options.Challenge, tt.format, attestationKey, certificate)
+			} else {
+				req = authenticator.create(t, options.Challenge, tt.format, nil, nil)
+			}
+
+			if tt.tamper != nil {
+				tt.tamper(req)
+			}
+
+			got, err := controller.FinishRegistration(ctx, 1, req)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("FinishRegistration() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr != nil {
+				return
+			}
+
+			if got.AttestationFormat != tt.wantFormat || got.Algorithm != tt.algorithm || got.AAGUID != "01020304-0506-0708-090a-0b0c0d0e0f10" {
+				t.Errorf("FinishRegistration() = %+v", got)
+			}
+
+			_, err = controller.FinishRegistration(ctx, 1, req)
+			if !errors.Is(err, ErrorInvalidPasskeyChallenge) {
+				t.Errorf("FinishRegistration() replayed error = %v, want %v", err, ErrorInvalidPasskeyChallenge)
+			}
+		})
+	}
+}
+
+func TestWebAuthnController_FinishSignIn(t *testing.T) {
+	tests := []struct {
+		name    string
+		email   string
+		tamper  func(a *softwareAuthenticator, req *webAuthnEntity.AssertionRequest)
+		want    string
+		wantErr error
+	}{
+		{
+			name: "success_discoverable_passkey",
+			want: "apollo@gmail.com",
+		},
+		{
+			name:  "success_passkey_of_email",
+			email: "apollo@gmail.com",
+			want:  "apollo@gmail.com",
+		},
+		{
+			name:    "failed_passkey_of_other_user",
+			email:   "artemis@gmail.com",
+			wantErr: ErrorPasskeyNotFound,
+		},
+		{
+			name: "failed_invalid_signature",
+			tamper: func(a *softwareAuthenticator, req *webAuthnEntity.AssertionRequest) {
+				req.Response.Signature = base64.RawURLEncoding.EncodeToString([]byte("invalid"))
+			},
+			wantErr: ErrorInvalidPasskeyResponse,
+		},
+		{
+			name: "failed_sign_count_not_increased",
+			tamper: func(a *softwareAuthenticator, req *webAuthnEntity.AssertionRequest) {
+				a.signCount = 0
+			},
+			wantErr: ErrorPasskeySignCountRegressed,
+		},
+		{
+			name: "failed_user_handle_mismatch",
+			tamper: func(a *softwareAuthenticator, req *webAuthnEntity.AssertionRequest) {
+				req.Response.UserHandle = base64.RawURLEncoding.EncodeToString(userHandle(2))
+			},
+			wantErr: ErrorInvalidPasskeyResponse,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			repository := newFakeRepository()
+			controller := newTestController(repository)
+			authenticator := newSoftwareAuthenticator(t, coseAlgES256)
+			authenticator.signCount = 5
+
+			creation, err := controller.BeginRegistration(ctx, 1)
+			if err != nil {
+				t.Fatalf("BeginRegistration() error = %v", err)
+			}
+
+			_, err = controller.FinishRegistration(ctx, 1, authenticator.create(t, creation.Challenge, AttestationFormatNone, nil, nil))
+			if err != nil {
+				t.Fatalf("FinishRegistration() error = %v", err)
+			}
+
+			options, err := controller.BeginSignIn(ctx, &webAuthnEntity.BeginSignInRequest{Email: tt.email})
+			if err != nil {
+				t.Fatalf("BeginSignIn() error = %v", err)
+			}
+
+			req := authenticator.get(t, options.Challenge, 1)
+			if tt.tamper != nil {
+				tt.tamper(authenticator, req)
+				if authenticator.signCount == 0 {
+					req = authenticator.get(t, options.Challenge, 1)
+				}
+			}
+
+			got, err := controller.FinishSignIn(ctx, req, sessionEntity.ClientInfo{})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("FinishSignIn() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr != nil {
+				return
+			}
+
+			if got.AccessToken != tt.want {
+				t.Errorf("FinishSignIn() signed in %v, want %v", got.AccessToken, tt.want)
+			}
+
+			_, err = controller.FinishSignIn(ctx, req, sessionEntity.ClientInfo{})
+			if !errors.Is(err, ErrorInvalidPasskeyChallenge) {
+				t.Errorf("FinishSignIn() replayed error = %v, want %v", err, ErrorInvalidPasskeyChallenge)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package entities
+
+import "time"
+
+const (
+	CeremonyRegistration = "registration"
+	CeremonySignIn       = "sign_in"
+
+	ClientDataTypeCreate = "webauthn.create"
+	ClientDataTypeGet    = "webauthn.get"
+
+	PublicKeyCredentialType = "public-key"
+)
+
+// Credential is a passkey of a user. CredentialID is the base64url encoded id chosen by the authenticator.
+type Credential struct {
+	ID                int64      `json:"id"`
+	UserID            int64      `json:"-"`
+	CredentialID      string     `json:"credential_id"`
+	PublicKey         []byte     `json:"-"`
+	Algorithm         int64      `json:"algorithm"`
+	SignCount         uint32     `json:"sign_count"`
+	AAGUID            string     `json:"aaguid,omitempty"`
+	AttestationFormat string     `json:"attestation_format"`
+	Name              string     `json:"name"`
+	LastUsedAt        *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt         *time.Time `json:"created_at,omitempty"`
+	UpdatedAt         *time.Time `json:"updated_at,omitempty"`
+}
+
+// Ceremony is kept in Redis under its challenge between the options and the response of the browser. UserID is
+// zero for a sign in that does not know the user yet.
+type Ceremony struct {
+	Type   string `json:"type"`
+	UserID int64  `json:"user_id"`
+}
+
+type RelyingParty struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type UserEntity struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+type CredentialParameter struct {
+	Type string `json:"type"`
+	Alg  int64  `json:"alg"`
+}
+
+type CredentialDescriptor struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+type AuthenticatorSelection struct {
+	ResidentKey      string `json:"residentKey"`
+	UserVerification string `json:"userVerification"`
+}
+
+// CreationOptions are passed to navigator.credentials.create, binary values are base64url encoded.
+type CreationOptions struct {
+	Challenge              string                 `json:"challenge"`
+	RP                     RelyingParty           `json:"rp"`
+	User                   UserEntity             `json:"user"`
+	PubKeyCredParams       []CredentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                  `json:"timeout"`
+	Attestation            string                 `json:"attestation"`
+	ExcludeCredentials     []CredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection AuthenticatorSelection `json:"authenticatorSelection"`
+}
+
+// RequestOptions are passed to navigator.credentials.get, binary values are base64url encoded.
+type RequestOptions struct {
+	Challenge        string                 `json:"challenge"`
+	RPID             string                 `json:"rpId"`
+	Timeout          int64                  `json:"timeout"`
+	AllowCredentials []CredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                 `json:"userVerification"`
+}
+
+type BeginSignInRequest struct {
+	Email string `json:"email"`
+}
+
+type AttestationResponse struct {
+	ClientDataJSON    string `json:"clientDataJSON"`
+	AttestationObject string `json:"attestationObject"`
+}
+
+// RegistrationRequest is the credential returned by navigator.credentials.create with its binary values base64url
+// encoded, Name labels the passkey in the list of the user.
+type RegistrationRequest struct {
+	ID       string              `json:"id"`
+	RawID    string              `json:"rawId"`
+	Type     string              `json:"type"`
+	Name     string              `json:"name"`
+	Response AttestationResponse `json:"response"`
+}
+
+type AssertionResponse struct {
+	ClientDataJSON    string `json:"clientDataJSON"`
+	AuthenticatorData string `json:"authenticatorData"`
+	Signature         string `json:"signature"`
+	UserHandle        string `json:"userHandle"`
+}
+
+// AssertionRequest is the credential returned by navigator.credentials.get with its binary values base64url encoded.
+type AssertionRequest struct {
+	ID       string            `json:"id"`
+	RawID    string            `json:"rawId"`
+	Type     string            `json:"type"`
+	Response AssertionResponse `json:"response"`
+}
+
+// CollectedClientData is the clientDataJSON the browser signs along with the authenticator data.
+type CollectedClientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/winartodev/apollo/core"
+	"github.com/winartodev/apollo/core/helpers"
+	"github.com/winartodev/apollo/core/middlewares"
+	"github.com/winartodev/apollo/core/responses"
+	sessionEntity "github.com/winartodev/apollo/modules/session/entities"
+	userController "github.com/winartodev/apollo/modules/user/controllers"
+	webAuthnController "github.com/winartodev/apollo/modules/webauthn/controllers"
+	webAuthnEntity "github.com/winartodev/apollo/modules/webauthn/entities"
+	"strconv"
+)
+
+type WebAuthnHandler struct {
+	middlewares.Middleware
+	WebAuthnController webAuthnController.WebAuthnControllerItf
+}
+
+func NewWebAuthnHandler(handler WebAuthnHandler) WebAuthnHandler {
+	return WebAuthnHandler{
+		Middleware:         handler.Middleware,
+		WebAuthnController: handler.WebAuthnController,
+	}
+}
+
+func (h *WebAuthnHandler) BeginRegistration(ctx *fiber.Ctx) error {
+	context := ctx.Context()
+
+	id, err := helpers.GetUserIDFromContext(ctx)
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusUnauthorized, "Failed to register passkey", err)
+	}
+
+	res, err := h.WebAuthnController.BeginRegistration(context, id)
+	if err != nil {
+		return passkeyFailedResponse(ctx, "Failed to register passkey", err)
+	}
+
+	return responses.SuccessResponse(ctx, fiber.StatusOK, "Success", res, nil)
+}
+
+func (h *WebAuthnHandler) FinishRegistration(ctx *fiber.Ctx) error {
+	context := ctx.Context()
+
+	id, err := helpers.GetUserIDFromContext(ctx)
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusUnauthorized, "Failed to register passkey", err)
+	}
+
+	req := webAuthnEntity.RegistrationRequest{}
+	err = ctx.BodyParser(&req)
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusBadRequest, "Failed to register passkey", err)
+	}
+
+	res, err := h.WebAuthnController.FinishRegistration(context, id, &req)
+	if errors.Is(err, webAuthnController.ErrorPasskeyAlreadyRegistered) {
+		return responses.FailedResponse(ctx, fiber.StatusConflict, "Failed to register passkey", err)
+	}
+
+	if err != nil {
+		return passkeyFailedResponse(ctx, "Failed to register passkey", err)
+	}
+
+	return responses.SuccessResponse(ctx, fiber.StatusCreated, "Success", res, nil)
+}
+
+func (h *WebAuthnHandler) GetPasskeys(ctx *fiber.Ctx) error {
+	context := ctx.Context()
+
+	id, err := helpers.GetUserIDFromContext(ctx)
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusUnauthorized, "Failed Get Passkeys", err)
+	}
+
+	res, err := h.WebAuthnController.GetCredentials(context, id)
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusInternalServerError, "Failed Get Passkeys", err)
+	}
+
+	return responses.SuccessResponse(ctx, fiber.StatusOK, "Success Get Passkeys", res, nil)
+}
+
+func (h *WebAuthnHandler) DeletePasskey(ctx *fiber.Ctx) error {
+	context := ctx.Context()
+
+	userID, err := helpers.GetUserIDFromContext(ctx)
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusUnauthorized, "Failed to delete passkey", err)
+	}
+
+	id, err := strconv.ParseInt(ctx.Params("id"), 10, 64)
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusBadRequest, "Failed to delete passkey", err)
+	}
+
+	err = h.WebAuthnController.DeleteCredential(context, userID, id)
+	if errors.Is(err, webAuthnController.ErrorPasskeyNotFound) {
+		return responses.FailedResponse(ctx, fiber.StatusNotFound, "Failed to delete passkey", err)
+	}
+
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusInternalServerError, "Failed to delete passkey", err)
+	}
+
+	return responses.SuccessResponse(ctx, fiber.StatusOK, "Success", "passkey deleted successfully", nil)
+}
+
+func (h *WebAuthnHandler) BeginSignIn(ctx *fiber.Ctx) error {
+	context := ctx.Context()
+
+	req := webAuthnEntity.BeginSignInRequest{}
+	if len(ctx.Body()) > 0 {
+		err := ctx.BodyParser(&req)
+		if err != nil {
+			return responses.FailedResponse(ctx, fiber.StatusBadRequest, "Failed to sign in with passkey", err)
+		}
+	}
+
+	res, err := h.WebAuthnController.BeginSignIn(context, &req)
+	if err != nil {
+		return passkeyFailedResponse(ctx, "Failed to sign in with passkey", err)
+	}
+
+	return responses.SuccessResponse(ctx, fiber.StatusOK, "Success", res, nil)
+}
+
+func (h *WebAuthnHandler) FinishSignIn(ctx *fiber.Ctx) error {
+	context := ctx.Context()
+
+	req := webAuthnEntity.AssertionRequest{}
+	err := ctx.BodyParser(&req)
+	if err != nil {
+		return responses.FailedResponse(ctx, fiber.StatusBadRequest, "Failed to sign in with passkey", err)
+	}
+
+	res, err := h.WebAuthnController.FinishSignIn(context, &req, sessionEntity.NewClientInfo(ctx))
+	if errors.Is(err, webAuthnController.ErrorPasskeyNotFound) || errors.Is(err, webAuthnController.ErrorPasskeySignCountRegressed) {
+		return responses.FailedResponse(ctx, fiber.StatusUnauthorized, "Failed to sign in with passkey", err)
+	}
+
+	if errors.Is(err, userController.ErrorUserSuspended) {
+		return responses.FailedResponse(ctx, fiber.StatusForbidden, "Failed to sign in with passkey", err)
+	}
+
+	if err != nil {
+		return passkeyFailedResponse(ctx, "Failed to sign in with passkey", err)
+	}
+
+	return responses.SuccessResponse(ctx, fiber.StatusOK, "Success", res, nil)
+}
+
+// passkeyFailedResponse answers the errors every passkey ceremony can run into.
+func passkeyFailedResponse(ctx *fiber.Ctx, message string, err error) error {
+	if errors.Is(err, webAuthnController.ErrorWebAuthnDisabled) {
+		return responses.FailedResponse(ctx, fiber.StatusNotFound, message, err)
+	}
+
+	if errors.Is(err, webAuthnController.ErrorInvalidPasskeyChallenge) || errors.Is(err, webAuthnController.ErrorInvalidPasskeyResponse) ||
+		errors.Is(err, webAuthnController.ErrorUnsupportedAttestation) {
+		return responses.FailedResponse(ctx, fiber.StatusBadRequest, message, err)
+	}
+
+	if errors.Is(err, userController.ErrorUserNotFound) {
+		return responses.FailedResponse(ctx, fiber.StatusUnauthorized, message, err)
+	}
+
+	return responses.FailedResponse(ctx, fiber.StatusInternalServerError, message, err)
+}
+
+func (h *WebAuthnHandler) Register(router fiber.Router) error {
+	v1 := router.Group(core.V1)
+
+	auth := v1.Group("/auth/passkeys")
+	auth.Post("/sign-in/options", h.BeginSignIn)
+	auth.Post("/sign-in", h.FinishSignIn)
+
+	// adding a passkey adds a way to sign in, so it needs a recent authentication like a password change
+	passkeys := v1.Group(core.AccessProtected+"/users/passkeys", h.HandleProtectedAccess())
+	passkeys.Get("", h.GetPasskeys)
+	passkeys.Post("/register/options", h.BeginRegistration)
+	passkeys.Post("/register", h.FinishRegistration)
+	passkeys.Delete("/:id", h.DeletePasskey)
+
+	return nil
+}
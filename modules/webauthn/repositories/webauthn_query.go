@@ -0,0 +1,73 @@
+package repositories
+
+const (
+	InsertCredentialDBQuery = `
+		INSERT INTO webauthn_credentials
+		    (
+				user_id,
+				credential_id,
+				public_key,
+				algorithm,
+				sign_count,
+				aaguid,
+				attestation_format,
+				name,
+				created_at,
+				updated_at
+			) VALUES (
+						$1, -- user_id
+						$2, -- credential_id
+						$3, -- public_key
+						$4, -- algorithm
+						$5, -- sign_count
+						NULLIF($6, ''), -- aaguid
+						$7, -- attestation_format
+						$8, -- name
+						$9, -- created_at
+						$9  -- updated_at
+					)
+			  RETURNING id;
+	`
+
+	GetCredentialQueryDB = `
+		SELECT
+			id,
+			user_id,
+			credential_id,
+			public_key,
+			algorithm,
+			sign_count,
+			COALESCE(aaguid, ''),
+			attestation_format,
+			COALESCE(name, ''),
+			last_used_at,
+			created_at,
+			updated_at
+		FROM webauthn_credentials
+	`
+
+	UpdateCredentialSignCountDBQuery = `
+		UPDATE webauthn_credentials 
+		SET 
+		    sign_count = $1,
+		    last_used_at = $2,
+		    updated_at = $2
+		WHERE 
+		    id = $3 
+		  AND sign_count = $4;
+	`
+
+	DeleteCredentialDBQuery = `
+		DELETE FROM webauthn_credentials 
+		WHERE 
+		    id = $1 
+		  AND user_id = $2;
+	`
+
+	DeleteCredentialsCreatedSinceDBQuery = `
+		DELETE FROM webauthn_credentials 
+		WHERE 
+		    user_id = $1 
+		  AND created_at >= $2;
+	`
+)
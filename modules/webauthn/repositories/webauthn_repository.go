@@ -0,0 +1,210 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"github.com/winartodev/apollo/core/helpers"
+	webAuthnEntity "github.com/winartodev/apollo/modules/webauthn/entities"
+	"time"
+)
+
+const (
+	webAuthnChallengePrefix = "webauthn_challenge"
+)
+
+type WebAuthnRepositoryItf interface {
+	CreateCredentialDB(ctx context.Context, data *webAuthnEntity.Credential) (id int64, err error)
+	GetCredentialsByUserIDDB(ctx context.Context, userID int64) (res []webAuthnEntity.Credential, err error)
+	GetCredentialByCredentialIDDB(ctx context.Context, credentialID string) (res *webAuthnEntity.Credential, err error)
+	UpdateCredentialSignCountDB(ctx context.Context, id int64, previous uint32, signCount uint32, usedAt time.Time) (updated bool, err error)
+	DeleteCredentialDB(ctx context.Context, userID int64, id int64) (deleted bool, err error)
+	DeleteCredentialsCreatedSinceDB(ctx context.Context, userID int64, since time.Time) (deleted int64, err error)
+	SetCeremonyRedis(ctx context.Context, challenge string, data webAuthnEntity.Ceremony, ttl time.Duration) (err error)
+	GetCeremonyRedis(ctx context.Context, challenge string) (res *webAuthnEntity.Ceremony, err error)
+	DeleteCeremonyRedis(ctx context.Context, challenge string) (deleted bool, err error)
+}
+
+type WebAuthnRepository struct {
+	DB    *sql.DB
+	Redis *redis.Client
+}
+
+func NewWebAuthnRepository(repository WebAuthnRepository) WebAuthnRepositoryItf {
+	return &WebAuthnRepository{
+		DB:    repository.DB,
+		Redis: repository.Redis,
+	}
+}
+
+func (wr *WebAuthnRepository) CreateCredentialDB(ctx context.Context, data *webAuthnEntity.Credential) (id int64, err error) {
+	err = wr.DB.QueryRowContext(ctx, InsertCredentialDBQuery,
+		data.UserID,
+		data.CredentialID,
+		data.PublicKey,
+		data.Algorithm,
+		int64(data.SignCount),
+		data.AAGUID,
+		data.AttestationFormat,
+		data.Name,
+		data.CreatedAt.Unix(),
+	).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+func (wr *WebAuthnRepository) GetCredentialsByUserIDDB(ctx context.Context, userID int64) (res []webAuthnEntity.Credential, err error) {
+	query := fmt.Sprintf("%s WHERE user_id = $1 ORDER BY id", GetCredentialQueryDB)
+
+	rows, err := wr.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res = []webAuthnEntity.Credential{}
+	for rows.Next() {
+		credential, err := scanCredential(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, *credential)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (wr *WebAuthnRepository) GetCredentialByCredentialIDDB(ctx context.Context, credentialID string) (res *webAuthnEntity.Credential, err error) {
+	query := fmt.Sprintf("%s WHERE credential_id = $1", GetCredentialQueryDB)
+
+	return scanCredential(wr.DB.QueryRowContext(ctx, query, credentialID))
+}
+
+// UpdateCredentialSignCountDB stores the counter of a successful assertion, it only updates the credential while
+// its counter is still previous so two concurrent assertions with the same counter can not both succeed.
+func (wr *WebAuthnRepository) UpdateCredentialSignCountDB(ctx context.Context, id int64, previous uint32, signCount uint32, usedAt time.Time) (updated bool, err error) {
+	result, err := wr.DB.ExecContext(ctx, UpdateCredentialSignCountDBQuery, int64(signCount), usedAt.Unix(), id, int64(previous))
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+func (wr *WebAuthnRepository) DeleteCredentialDB(ctx context.Context, userID int64, id int64) (deleted bool, err error) {
+	result, err := wr.DB.ExecContext(ctx, DeleteCredentialDBQuery, id, userID)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+func (wr *WebAuthnRepository) DeleteCredentialsCreatedSinceDB(ctx context.Context, userID int64, since time.Time) (deleted int64, err error) {
+	result, err := wr.DB.ExecContext(ctx, DeleteCredentialsCreatedSinceDBQuery, userID, since.Unix())
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+func (wr *WebAuthnRepository) SetCeremonyRedis(ctx context.Context, challenge string, data webAuthnEntity.Ceremony, ttl time.Duration) (err error) {
+	dataByte, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	return wr.Redis.SetEX(ctx, wr.generateRedisKey(challenge), dataByte, ttl).Err()
+}
+
+func (wr *WebAuthnRepository) GetCeremonyRedis(ctx context.Context, challenge string) (res *webAuthnEntity.Ceremony, err error) {
+	dataStr, err := wr.Redis.Get(ctx, wr.generateRedisKey(challenge)).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	res = &webAuthnEntity.Ceremony{}
+	err = json.Unmarshal([]byte(dataStr), res)
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// DeleteCeremonyRedis removes the ceremony and reports whether this call was the one that removed it, so a
+// challenge can only be answered once.
+func (wr *WebAuthnRepository) DeleteCeremonyRedis(ctx context.Context, challenge string) (deleted bool, err error) {
+	count, err := wr.Redis.Del(ctx, wr.generateRedisKey(challenge)).Result()
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+func (wr *WebAuthnRepository) generateRedisKey(challenge string) string {
+	return fmt.Sprintf("%s:%s", webAuthnChallengePrefix, helpers.HashToken(challenge))
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanCredential(row rowScanner) (res *webAuthnEntity.Credential, err error) {
+	var signCount int64
+	var lastUsedAtUnix int64
+	var createdAtUnix int64
+	var updatedAtUnix int64
+
+	res = &webAuthnEntity.Credential{}
+	err = row.Scan(
+		&res.ID,
+		&res.UserID,
+		&res.CredentialID,
+		&res.PublicKey,
+		&res.Algorithm,
+		&signCount,
+		&res.AAGUID,
+		&res.AttestationFormat,
+		&res.Name,
+		&lastUsedAtUnix,
+		&createdAtUnix,
+		&updatedAtUnix,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	res.SignCount = uint32(signCount)
+	res.LastUsedAt = helpers.FormatUnixTime(lastUsedAtUnix)
+	res.CreatedAt = helpers.FormatUnixTime(createdAtUnix)
+	res.UpdatedAt = helpers.FormatUnixTime(updatedAtUnix)
+
+	return res, nil
+}